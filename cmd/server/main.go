@@ -4,12 +4,19 @@ import (
 	"MusicService/internal/config"
 	"MusicService/internal/controller"
 	"MusicService/internal/middleware"
+	"MusicService/internal/model"
 	"MusicService/internal/repository"
 	"MusicService/internal/service"
 	"MusicService/internal/storage"
 	"MusicService/pkg/jwt"
+	"MusicService/pkg/mailer"
+	"MusicService/pkg/oidc"
+	"MusicService/pkg/ratelimit"
 	"MusicService/pkg/response"
+	"MusicService/pkg/secretbox"
 	"log"
+	"strings"
+	"time"
 
 	_ "MusicService/docs" // Импорт сгенерированной документации
 	"github.com/gin-gonic/gin"
@@ -35,7 +42,7 @@ import (
 // @securityDefinitions.apikey BearerAuth
 // @in header
 // @name Authorization
-// @description Type "Bearer" followed by a space and JWT token
+// @description Type "Bearer" followed by a space and JWT token or API key
 func main() {
 	cfg, err := config.LoadConfig()
 	if err != nil {
@@ -57,23 +64,89 @@ func main() {
 		log.Fatalf("Failed to create bucket: %v", err)
 	}
 
-	jwtService := jwt.NewJWTService(cfg.JWT.SecretKey)
+	jwtService := jwt.NewJWTService(cfg.JWT.SecretKey, cfg.JWT.AccessTokenTTL)
+
+	encryptionKey := cfg.Subsonic.EncryptionKey
+	if encryptionKey == "" {
+		encryptionKey = cfg.JWT.SecretKey
+	}
+	secretBox, err := secretbox.New(encryptionKey)
+	if err != nil {
+		log.Fatalf("Failed to initialize encryption: %v", err)
+	}
+
 	userRepo := repository.NewUserRepository(db)
 	trackRepo := repository.NewTrackRepository(db)
+	artistRepo := repository.NewArtistRepository(db)
+	albumRepo := repository.NewAlbumRepository(db)
 	playlistRepo := repository.NewPlaylistRepository(db)
 	statsRepo := repository.NewStatsRepository(db)
+	starRepo := repository.NewStarRepository(db)
+	refreshTokenRepo := repository.NewRefreshTokenRepository(db)
+	sessionRepo := repository.NewSessionRepository(db)
+	userTokenRepo := repository.NewUserTokenRepository(db)
+	recoveryCodeRepo := repository.NewRecoveryCodeRepository(db)
+	externalIdentityRepo := repository.NewExternalIdentityRepository(db)
+	apiKeyRepo := repository.NewAPIKeyRepository(db)
+	dataExportRepo := repository.NewDataExportRepository(db)
+	searchRepo := repository.NewSearchRepository(db)
+	accountRepo := repository.NewAccountRepository(db)
+
+	if err := service.BackfillLibrary(trackRepo, artistRepo, albumRepo); err != nil {
+		log.Fatalf("Failed to link tracks to artists and albums: %v", err)
+	}
 
-	authService := service.NewAuthService(userRepo, jwtService)
-	userService := service.NewUserService(userRepo)
-	trackService := service.NewTrackService(trackRepo, minioClient, cfg.MinIO.BucketName)
+	if err := service.EnsureAdmin(userRepo, cfg.Admin.Username); err != nil {
+		log.Fatalf("Failed to assign admin role: %v", err)
+	}
+
+	mailSender := newMailer(cfg)
+	verificationService := service.NewVerificationService(userRepo, userTokenRepo, mailSender, cfg.Server.PublicURL)
+	// Для нескольких экземпляров сервиса ratelimit.Store можно реализовать поверх Redis
+	limits := newRateLimits(cfg, ratelimit.NewMemoryStore())
+
+	twoFactorService := service.NewTwoFactorService(userRepo, recoveryCodeRepo, secretBox, cfg.Auth.TOTPIssuer)
+	authService := service.NewAuthService(userRepo, refreshTokenRepo, sessionRepo, userTokenRepo, verificationService,
+		twoFactorService, limits.lockout, jwtService, cfg.JWT.RefreshTokenTTL)
+	passwordService := service.NewPasswordService(userRepo, userTokenRepo, sessionRepo, mailSender, cfg.Server.PublicURL)
+	userService := service.NewUserService(userRepo, sessionRepo, verificationService, secretBox)
+	transcoder, err := service.NewFFmpegTranscoder(cfg.Transcoding.FFmpegPath)
+	if err != nil {
+		log.Printf("Transcoding disabled: %v", err)
+	}
+
+	trackService := service.NewTrackService(trackRepo, minioClient, transcoder, cfg.MinIO.BucketName)
+	artistService := service.NewArtistService(artistRepo, minioClient, cfg.MinIO.BucketName)
+	albumService := service.NewAlbumService(albumRepo, minioClient, cfg.MinIO.BucketName)
 	playlistService := service.NewPlaylistService(playlistRepo, trackRepo)
 	statsService := service.NewStatsService(statsRepo)
+	searchService := service.NewSearchService(searchRepo, cfg.Search.SuggestCacheSize, cfg.Search.SuggestCacheTTL, cfg.Search.SuggestTimeout)
+	subsonicService := service.NewSubsonicService(userRepo, trackRepo, artistRepo, albumRepo, starRepo, secretBox, limits.lockout)
+	adminService := service.NewAdminService(userRepo, statsRepo, minioClient, cfg.MinIO.BucketName)
+	apiKeyService := service.NewAPIKeyService(apiKeyRepo)
+	dataExportService := service.NewDataExportService(dataExportRepo, userRepo, trackRepo, playlistRepo, statsRepo,
+		minioClient, cfg.MinIO.BucketName, mailSender, cfg.DataExport.LinkTTL, cfg.DataExport.Retention)
+	accountService := service.NewAccountService(accountRepo, userRepo, trackRepo, twoFactorService, minioClient,
+		cfg.MinIO.BucketName, mailSender)
+
+	if err := dataExportService.FailUnfinished(); err != nil {
+		log.Printf("Failed to mark interrupted data exports as failed: %v", err)
+	}
+	go removeExpiredExports(dataExportService, cfg.DataExport.CleanupInterval)
 
-	authController := controller.NewAuthController(authService)
-	userController := controller.NewUserController(userService)
+	authController := controller.NewAuthController(authService, passwordService)
+	userController := controller.NewUserController(userService, verificationService)
+	twoFactorController := controller.NewTwoFactorController(twoFactorService)
 	trackController := controller.NewTrackController(trackService, statsService)
+	artistController := controller.NewArtistController(artistService)
+	albumController := controller.NewAlbumController(albumService)
 	playlistController := controller.NewPlaylistController(playlistService)
 	statsController := controller.NewStatsController(statsService)
+	searchController := controller.NewSearchController(searchService)
+	subsonicController := controller.NewSubsonicController(subsonicService, trackService, playlistService, statsService)
+	adminController := controller.NewAdminController(adminService, trackService, playlistService)
+	apiKeyController := controller.NewAPIKeyController(apiKeyService)
+	accountController := controller.NewAccountController(accountService, dataExportService)
 
 	router := gin.Default()
 	router.Use(response.CORSMiddleware())
@@ -81,33 +154,99 @@ func main() {
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
 	auth := router.Group("/auth")
+	auth.Use(limits.auth)
 	{
-		auth.POST("/register", authController.Register)
-		auth.POST("/login", authController.Login)
+		auth.POST("/register", limits.register, authController.Register)
+		auth.POST("/login", limits.loginPerIP, limits.loginPerAccount, authController.Login)
+		auth.POST("/login/2fa", authController.LoginTwoFactor)
+		auth.POST("/refresh", authController.Refresh)
+		auth.POST("/logout", authController.Logout)
+		auth.POST("/forgot-password", authController.ForgotPassword)
+		auth.POST("/reset-password", authController.ResetPassword)
+		auth.POST("/verify-email", userController.VerifyEmail)
+	}
+
+	if cfg.OIDC.Enabled {
+		oidcService := service.NewOIDCService(oidc.Config{
+			Issuer:       cfg.OIDC.Issuer,
+			ClientID:     cfg.OIDC.ClientID,
+			ClientSecret: cfg.OIDC.ClientSecret,
+			RedirectURL:  cfg.OIDC.RedirectURL,
+			Scopes:       cfg.OIDC.Scopes,
+		}, cfg.OIDC.ProviderName, userRepo, externalIdentityRepo, authService, secretBox)
+		oidcController := controller.NewOIDCController(oidcService, strings.HasPrefix(cfg.OIDC.RedirectURL, "https://"))
+
+		auth.GET("/oidc/login", oidcController.Login)
+		auth.GET("/oidc/callback", oidcController.Callback)
 	}
 
 	api := router.Group("/api")
-	api.Use(middleware.AuthMiddleware(jwtService))
+	api.Use(middleware.AuthMiddleware(jwtService, userRepo, sessionRepo, apiKeyRepo, "/api/tracks/:id/hls/"))
 	{
+		// Области API-ключей; для входа через сессию проверки ничего не ограничивают
+		read := middleware.RequireScope(model.APIKeyScopeRead)
+		upload := middleware.RequireScope(model.APIKeyScopeUpload)
+
 		user := api.Group("/user")
+		user.GET("/profile", read, userController.GetProfile)
+		// Управление учетной записью недоступно по API-ключу
+		account := user.Group("", middleware.RequireSession())
 		{
-			user.GET("/profile", userController.GetProfile)
-			user.PUT("/profile", userController.UpdateProfile)
+			account.PUT("/profile", userController.UpdateProfile)
+			account.PUT("/subsonic-password", userController.SetSubsonicPassword)
+			account.POST("/change-password", userController.ChangePassword)
+			account.POST("/verify-email/resend", userController.ResendVerification)
+			account.POST("/2fa/setup", twoFactorController.Setup)
+			account.POST("/2fa/enable", twoFactorController.Enable)
+			account.POST("/2fa/disable", twoFactorController.Disable)
+			account.POST("/2fa/recovery-codes", twoFactorController.RegenerateRecoveryCodes)
+			account.POST("/logout-all", authController.LogoutEverywhere)
+			account.GET("/sessions", authController.GetSessions)
+			account.DELETE("/sessions/:id", authController.RevokeSession)
+			account.GET("/api-keys", apiKeyController.GetAPIKeys)
+			account.POST("/api-keys", apiKeyController.CreateAPIKey)
+			account.DELETE("/api-keys/:id", apiKeyController.RevokeAPIKey)
+			account.POST("/exports", accountController.RequestDataExport)
+			account.GET("/exports", accountController.GetDataExports)
+			account.GET("/exports/:id", accountController.GetDataExport)
+			account.DELETE("/account", accountController.DeleteAccount)
 		}
 
 		track := api.Group("/tracks")
 		{
-			track.POST("", trackController.UploadTrack)
-			track.GET("", trackController.GetAllTracks)
-			track.GET("/user/:userId", trackController.GetUserTracks)
-			track.GET("/:id", trackController.GetTrackByID)
-			track.GET("/stream/:id", trackController.StreamTrack)
-			track.DELETE("/:id", trackController.DeleteTrack)
-			track.GET("/search", trackController.SearchTracks)
-			track.GET("/:id/image", trackController.GetTrackImage)
+			track.POST("", uploadGuards(cfg, limits.upload, upload, trackController.UploadTrack)...)
+			track.GET("", read, trackController.GetAllTracks)
+			track.GET("/user/:userId", read, trackController.GetUserTracks)
+			track.GET("/:id", read, trackController.GetTrackByID)
+			track.GET("/stream/:id", read, limits.stream, trackController.StreamTrack)
+			track.HEAD("/stream/:id", read, limits.stream, trackController.StreamTrack)
+			track.DELETE("/:id", upload, trackController.DeleteTrack)
+			track.GET("/search", read, trackController.SearchTracks)
+			track.GET("/:id/image", read, trackController.GetTrackImage)
+			track.GET("/:id/hls/master.m3u8", read, limits.stream, trackController.GetHLSMasterPlaylist)
+			track.GET("/:id/hls/:variant/:file", read, limits.stream, trackController.GetHLSVariant)
+		}
+
+		api.GET("/search/suggest", read, searchController.Suggest)
+
+		artist := api.Group("/artists")
+		artist.Use(read)
+		{
+			artist.GET("", artistController.GetAllArtists)
+			artist.GET("/:id", artistController.GetArtistByID)
+			artist.GET("/:id/image", artistController.GetArtistImage)
+		}
+
+		album := api.Group("/albums")
+		album.Use(read)
+		{
+			album.GET("", albumController.GetAllAlbums)
+			album.GET("/:id", albumController.GetAlbumByID)
+			album.GET("/:id/image", albumController.GetAlbumImage)
 		}
 
 		playlist := api.Group("/playlists")
+		playlist.Use(middleware.RequireScope(model.APIKeyScopePlaylists))
 		{
 			playlist.POST("", playlistController.CreatePlaylist)
 			playlist.GET("", playlistController.GetUserPlaylists)
@@ -116,15 +255,58 @@ func main() {
 			playlist.DELETE("/:id", playlistController.DeletePlaylist)
 			playlist.POST("/:id/tracks", playlistController.AddTrackToPlaylist)
 			playlist.DELETE("/:id/tracks/:trackId", playlistController.RemoveTrackFromPlaylist)
+			playlist.PUT("/:id/entries", playlistController.ReorderPlaylist)
+			playlist.PATCH("/:id/entries/:entryId", playlistController.MovePlaylistEntry)
+			playlist.DELETE("/:id/entries/:entryId", playlistController.RemovePlaylistEntry)
 		}
 
 		statsGroup := api.Group("/stats")
+		statsGroup.Use(middleware.RequireScope(model.APIKeyScopeStats))
 		{
 			statsGroup.GET("/track-plays", statsController.GetTrackPlaysCount)
 			statsGroup.GET("/artist-plays", statsController.GetArtistPlaysCount)
 			statsGroup.GET("/recent-tracks", statsController.GetRecentTracks)
 			statsGroup.GET("/recent-artists", statsController.GetRecentArtists)
 		}
+
+		admin := api.Group("/admin")
+		admin.Use(middleware.RequireSession(), middleware.RequireRole(model.RoleAdmin))
+		{
+			admin.GET("/users", adminController.ListUsers)
+			admin.PUT("/users/:id/role", adminController.SetUserRole)
+			admin.POST("/users/:id/disable", adminController.DisableUser)
+			admin.POST("/users/:id/enable", adminController.EnableUser)
+			admin.DELETE("/tracks/:id", adminController.DeleteTrack)
+			admin.DELETE("/playlists/:id", adminController.DeletePlaylist)
+			admin.GET("/stats", adminController.GetSystemStats)
+		}
+	}
+
+	// Subsonic API для сторонних клиентов; getOpenSubsonicExtensions доступен без аутентификации
+	rest := router.Group("/rest", limits.subsonic)
+	controller.SubsonicRoute(rest, "getOpenSubsonicExtensions", subsonicController.GetOpenSubsonicExtensions)
+	rest.Use(subsonicController.Authenticate)
+	{
+		controller.SubsonicRoute(rest, "ping", subsonicController.Ping)
+		controller.SubsonicRoute(rest, "getLicense", subsonicController.GetLicense)
+		controller.SubsonicRoute(rest, "getMusicFolders", subsonicController.GetMusicFolders)
+		controller.SubsonicRoute(rest, "getArtists", subsonicController.GetArtists)
+		controller.SubsonicRoute(rest, "getArtist", subsonicController.GetArtist)
+		controller.SubsonicRoute(rest, "getAlbum", subsonicController.GetAlbum)
+		controller.SubsonicRoute(rest, "getSong", subsonicController.GetSong)
+		controller.SubsonicRoute(rest, "stream", subsonicController.Stream)
+		controller.SubsonicRoute(rest, "download", subsonicController.Download)
+		controller.SubsonicRoute(rest, "getCoverArt", subsonicController.GetCoverArt)
+		controller.SubsonicRoute(rest, "search3", subsonicController.Search3)
+		controller.SubsonicRoute(rest, "getPlaylists", subsonicController.GetPlaylists)
+		controller.SubsonicRoute(rest, "getPlaylist", subsonicController.GetPlaylist)
+		controller.SubsonicRoute(rest, "createPlaylist", subsonicController.CreatePlaylist)
+		controller.SubsonicRoute(rest, "updatePlaylist", subsonicController.UpdatePlaylist)
+		controller.SubsonicRoute(rest, "deletePlaylist", subsonicController.DeletePlaylist)
+		controller.SubsonicRoute(rest, "scrobble", subsonicController.Scrobble)
+		controller.SubsonicRoute(rest, "star", subsonicController.Star)
+		controller.SubsonicRoute(rest, "unstar", subsonicController.Unstar)
+		controller.SubsonicRoute(rest, "getStarred2", subsonicController.GetStarred2)
 	}
 
 	log.Printf("Server is running on port %s", cfg.Server.Port)
@@ -132,3 +314,85 @@ func main() {
 		log.Fatalf("Failed to start server: %v", err)
 	}
 }
+
+func newMailer(cfg *config.Config) mailer.Mailer {
+	if cfg.Mail.Driver == "smtp" {
+		return mailer.NewSMTPMailer(cfg.Mail.SMTPHost, cfg.Mail.SMTPPort, cfg.Mail.SMTPUsername, cfg.Mail.SMTPPassword, cfg.Mail.From)
+	}
+	return mailer.NewLogMailer(cfg.Mail.From, cfg.Mail.Dir)
+}
+
+// uploadGuards добавляет к обработчику загрузки ограничение частоты, проверку области API-ключа
+// и, если требуется, роли и подтвержденного email
+func uploadGuards(cfg *config.Config, limit, scope, handler gin.HandlerFunc) []gin.HandlerFunc {
+	guards := []gin.HandlerFunc{limit, scope}
+	if cfg.Auth.RestrictUploadsToUploaders {
+		guards = append(guards, middleware.RequireRole(model.RoleUploader, model.RoleAdmin))
+	}
+	if cfg.Auth.RequireVerifiedEmailForUpload {
+		guards = append(guards, middleware.RequireVerifiedEmail())
+	}
+	return append(guards, handler)
+}
+
+// removeExpiredExports удаляет архивы выгрузок с истекшим сроком хранения при запуске и затем
+// каждые interval; при interval <= 0 - только при запуске
+func removeExpiredExports(exports service.DataExportService, interval time.Duration) {
+	removeExpired := func() {
+		if err := exports.RemoveExpired(); err != nil {
+			log.Printf("Failed to remove expired data exports: %v", err)
+		}
+	}
+
+	removeExpired()
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		removeExpired()
+	}
+}
+
+// rateLimits - ограничители частоты запросов из конфигурации
+type rateLimits struct {
+	auth            gin.HandlerFunc
+	loginPerIP      gin.HandlerFunc
+	loginPerAccount gin.HandlerFunc
+	register        gin.HandlerFunc
+	upload          gin.HandlerFunc
+	stream          gin.HandlerFunc
+	subsonic        gin.HandlerFunc
+	lockout         *ratelimit.Lockout
+}
+
+func newRateLimits(cfg *config.Config, store ratelimit.Store) rateLimits {
+	rl := cfg.RateLimit
+	limit := func(name string, rule config.RateLimitRule, key middleware.RateLimitKey) gin.HandlerFunc {
+		if !rl.Enabled {
+			rule = config.RateLimitRule{}
+		}
+		return middleware.RateLimit(store, name, ratelimit.Per(rule.Requests, rule.Per), key)
+	}
+
+	limits := rateLimits{
+		auth:            limit("auth", rl.AuthPerIP, middleware.ByIP),
+		loginPerIP:      limit("login", rl.LoginPerIP, middleware.ByIP),
+		loginPerAccount: limit("login", rl.LoginPerAccount, middleware.ByEmail),
+		register:        limit("register", rl.RegisterPerIP, middleware.ByIP),
+		upload:          limit("upload", rl.UploadPerUser, middleware.ByUser),
+		stream:          limit("stream", rl.StreamPerUser, middleware.ByUser),
+		subsonic:        limit("subsonic", rl.SubsonicPerIP, middleware.ByIP),
+	}
+	if rl.Enabled {
+		limits.lockout = ratelimit.NewLockout(store, ratelimit.LockoutPolicy{
+			Threshold:    rl.Lockout.Threshold,
+			BaseDuration: rl.Lockout.BaseDuration,
+			MaxDuration:  rl.Lockout.MaxDuration,
+			Window:       rl.Lockout.Window,
+		})
+	}
+	return limits
+}
@@ -7,11 +7,15 @@ import (
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"log"
+	"strings"
+	"time"
 )
 
 type Config struct {
 	Server struct {
 		Port string `mapstructure:"PORT"`
+		// PublicURL - адрес сервиса для ссылок в письмах
+		PublicURL string `mapstructure:"PUBLIC_URL"`
 	} `mapstructure:"SERVER"`
 	Database struct {
 		Host     string `mapstructure:"HOST"`
@@ -28,8 +32,83 @@ type Config struct {
 		UseSSL     bool   `mapstructure:"USE_SSL"`
 	} `mapstructure:"MINIO"`
 	JWT struct {
-		SecretKey string `mapstructure:"SECRET_KEY"`
+		SecretKey       string        `mapstructure:"SECRET_KEY"`
+		AccessTokenTTL  time.Duration `mapstructure:"ACCESS_TOKEN_TTL"`
+		RefreshTokenTTL time.Duration `mapstructure:"REFRESH_TOKEN_TTL"`
 	} `mapstructure:"JWT"`
+	Mail struct {
+		Driver       string `mapstructure:"DRIVER"` // smtp или log
+		From         string `mapstructure:"FROM"`
+		Dir          string `mapstructure:"DIR"` // каталог для писем драйвера log; если пуст, письма выводятся в журнал
+		SMTPHost     string `mapstructure:"SMTP_HOST"`
+		SMTPPort     int    `mapstructure:"SMTP_PORT"`
+		SMTPUsername string `mapstructure:"SMTP_USERNAME"`
+		SMTPPassword string `mapstructure:"SMTP_PASSWORD"`
+	} `mapstructure:"MAIL"`
+	Auth struct {
+		// RequireVerifiedEmailForUpload запрещает загрузку треков до подтверждения email
+		RequireVerifiedEmailForUpload bool `mapstructure:"REQUIRE_VERIFIED_EMAIL_FOR_UPLOAD"`
+		// RestrictUploadsToUploaders разрешает загрузку треков только ролям uploader и admin
+		RestrictUploadsToUploaders bool `mapstructure:"RESTRICT_UPLOADS_TO_UPLOADERS"`
+		// TOTPIssuer - название сервиса, которое приложение-аутентификатор показывает рядом с кодом
+		TOTPIssuer string `mapstructure:"TOTP_ISSUER"`
+	} `mapstructure:"AUTH"`
+	// RateLimit - ограничение частоты запросов и блокировка входа после неудачных попыток
+	RateLimit struct {
+		Enabled         bool          `mapstructure:"ENABLED"`
+		AuthPerIP       RateLimitRule `mapstructure:"AUTH_PER_IP"` // все запросы /auth с одного адреса
+		LoginPerIP      RateLimitRule `mapstructure:"LOGIN_PER_IP"`
+		LoginPerAccount RateLimitRule `mapstructure:"LOGIN_PER_ACCOUNT"`
+		RegisterPerIP   RateLimitRule `mapstructure:"REGISTER_PER_IP"`
+		UploadPerUser   RateLimitRule `mapstructure:"UPLOAD_PER_USER"`
+		StreamPerUser   RateLimitRule `mapstructure:"STREAM_PER_USER"`
+		SubsonicPerIP   RateLimitRule `mapstructure:"SUBSONIC_PER_IP"` // все запросы /rest: каждый из них проверяет пароль
+		Lockout         struct {
+			Threshold    int           `mapstructure:"THRESHOLD"` // неудачных попыток до блокировки; 0 отключает
+			BaseDuration time.Duration `mapstructure:"BASE_DURATION"`
+			MaxDuration  time.Duration `mapstructure:"MAX_DURATION"`
+			Window       time.Duration `mapstructure:"WINDOW"` // через сколько без неудач счетчик сбрасывается
+		} `mapstructure:"LOCKOUT"`
+	} `mapstructure:"RATE_LIMIT"`
+	// OIDC - вход через провайдера OpenID Connect (Keycloak, Authentik и т.п.)
+	OIDC struct {
+		Enabled bool `mapstructure:"ENABLED"`
+		// ProviderName - имя провайдера, под которым сохраняются привязанные учетные записи
+		ProviderName string `mapstructure:"PROVIDER_NAME"`
+		// Issuer - адрес издателя; метаданные загружаются с Issuer/.well-known/openid-configuration
+		Issuer       string   `mapstructure:"ISSUER"`
+		ClientID     string   `mapstructure:"CLIENT_ID"`
+		ClientSecret string   `mapstructure:"CLIENT_SECRET"` // пуст для публичного клиента
+		RedirectURL  string   `mapstructure:"REDIRECT_URL"`  // должен указывать на /auth/oidc/callback
+		Scopes       []string `mapstructure:"SCOPES"`
+	} `mapstructure:"OIDC"`
+	Admin struct {
+		// Username - пользователь, которому при запуске назначается роль администратора
+		Username string `mapstructure:"USERNAME"`
+	} `mapstructure:"ADMIN"`
+	DataExport struct {
+		LinkTTL         time.Duration `mapstructure:"LINK_TTL"`         // срок действия подписанной ссылки на архив
+		Retention       time.Duration `mapstructure:"RETENTION"`        // сколько хранится готовый архив
+		CleanupInterval time.Duration `mapstructure:"CLEANUP_INTERVAL"` // как часто удаляются архивы с истекшим сроком хранения
+	} `mapstructure:"DATA_EXPORT"`
+	Search struct {
+		SuggestCacheSize int           `mapstructure:"SUGGEST_CACHE_SIZE"` // запросов в кэше подсказок
+		SuggestCacheTTL  time.Duration `mapstructure:"SUGGEST_CACHE_TTL"`  // через сколько новые треки появляются в подсказках
+		SuggestTimeout   time.Duration `mapstructure:"SUGGEST_TIMEOUT"`    // время на запросы подсказок к базе
+	} `mapstructure:"SEARCH"`
+	Subsonic struct {
+		// EncryptionKey - ключ шифрования паролей Subsonic; по умолчанию используется JWT.SECRET_KEY
+		EncryptionKey string `mapstructure:"ENCRYPTION_KEY"`
+	} `mapstructure:"SUBSONIC"`
+	Transcoding struct {
+		FFmpegPath string `mapstructure:"FFMPEG_PATH"`
+	} `mapstructure:"TRANSCODING"`
+}
+
+// RateLimitRule - не более Requests запросов за период Per; Requests = 0 отключает ограничение
+type RateLimitRule struct {
+	Requests int           `mapstructure:"REQUESTS"`
+	Per      time.Duration `mapstructure:"PER"`
 }
 
 func LoadConfig() (*Config, error) {
@@ -42,6 +121,29 @@ func LoadConfig() (*Config, error) {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
 
+	viper.SetDefault("JWT.ACCESS_TOKEN_TTL", "15m")
+	viper.SetDefault("JWT.REFRESH_TOKEN_TTL", "720h")
+	viper.SetDefault("AUTH.TOTP_ISSUER", "MusicService")
+	viper.SetDefault("OIDC.PROVIDER_NAME", "oidc")
+	viper.SetDefault("DATA_EXPORT.LINK_TTL", "24h")
+	viper.SetDefault("DATA_EXPORT.RETENTION", "168h")
+	viper.SetDefault("DATA_EXPORT.CLEANUP_INTERVAL", "1h")
+	viper.SetDefault("SEARCH.SUGGEST_CACHE_SIZE", 10000)
+	viper.SetDefault("SEARCH.SUGGEST_CACHE_TTL", "1m")
+	viper.SetDefault("SEARCH.SUGGEST_TIMEOUT", "150ms")
+	viper.SetDefault("RATE_LIMIT.ENABLED", true)
+	setRateLimitDefault("AUTH_PER_IP", 60, time.Minute)
+	setRateLimitDefault("LOGIN_PER_IP", 20, time.Minute)
+	setRateLimitDefault("LOGIN_PER_ACCOUNT", 10, time.Minute)
+	setRateLimitDefault("REGISTER_PER_IP", 5, time.Hour)
+	setRateLimitDefault("UPLOAD_PER_USER", 30, time.Hour)
+	setRateLimitDefault("STREAM_PER_USER", 600, time.Minute)
+	setRateLimitDefault("SUBSONIC_PER_IP", 300, time.Minute)
+	viper.SetDefault("RATE_LIMIT.LOCKOUT.THRESHOLD", 5)
+	viper.SetDefault("RATE_LIMIT.LOCKOUT.BASE_DURATION", "1m")
+	viper.SetDefault("RATE_LIMIT.LOCKOUT.MAX_DURATION", "1h")
+	viper.SetDefault("RATE_LIMIT.LOCKOUT.WINDOW", "1h")
+
 	var cfg Config
 	if err := viper.Unmarshal(&cfg); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
@@ -50,6 +152,11 @@ func LoadConfig() (*Config, error) {
 	return &cfg, nil
 }
 
+func setRateLimitDefault(name string, requests int, per time.Duration) {
+	viper.SetDefault("RATE_LIMIT."+name+".REQUESTS", requests)
+	viper.SetDefault("RATE_LIMIT."+name+".PER", per)
+}
+
 func InitDB(cfg *Config) (*gorm.DB, error) {
 	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable TimeZone=UTC",
 		cfg.Database.Host,
@@ -64,16 +171,149 @@ func InitDB(cfg *Config) (*gorm.DB, error) {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 
+	hadRoles := db.Migrator().HasColumn(&model.User{}, "Role")
+	hadEmailVerification := db.Migrator().HasColumn(&model.User{}, "EmailVerified")
+
 	err = db.AutoMigrate(
 		&model.User{},
+		&model.Artist{},
+		&model.Album{},
 		&model.Track{},
 		&model.Playlist{},
+		&model.PlaylistEntry{},
 		&model.ListeningHistory{},
+		&model.Star{},
+		&model.Session{},
+		&model.RefreshToken{},
+		&model.UserToken{},
+		&model.RecoveryCode{},
+		&model.ExternalIdentity{},
+		&model.APIKey{},
+		&model.DataExport{},
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to auto migrate models: %w", err)
 	}
 
+	if !hadRoles {
+		if err := migrateUploaderRoles(db); err != nil {
+			return nil, fmt.Errorf("failed to migrate user roles: %w", err)
+		}
+	}
+
+	// Адреса пользователей, зарегистрированных до появления подтверждения, считаются подтвержденными
+	if !hadEmailVerification {
+		if err := db.Model(&model.User{}).Where("1 = 1").Update("email_verified", true).Error; err != nil {
+			return nil, fmt.Errorf("failed to migrate email verification: %w", err)
+		}
+	}
+
+	if err := migratePlaylistTracks(db); err != nil {
+		return nil, fmt.Errorf("failed to migrate playlist tracks: %w", err)
+	}
+
+	if err := migrateTrackSearch(db); err != nil {
+		return nil, fmt.Errorf("failed to migrate track search: %w", err)
+	}
+
+	if err := migrateTrackTrigrams(db); err != nil {
+		return nil, fmt.Errorf("failed to migrate track trigram index: %w", err)
+	}
+
+	if err := migrateSuggestIndexes(db); err != nil {
+		return nil, fmt.Errorf("failed to migrate search suggestion indexes: %w", err)
+	}
+
 	log.Println("Database connection established")
 	return db, nil
 }
+
+// migrateUploaderRoles выдает роль uploader пользователям, загружавшим треки до появления ролей,
+// чтобы они сохранили возможность загрузки
+func migrateUploaderRoles(db *gorm.DB) error {
+	return db.Model(&model.User{}).
+		Where("id IN (?)", db.Model(&model.Track{}).Select("uploaded_by")).
+		Update("role", model.RoleUploader).Error
+}
+
+// migratePlaylistTracks переносит треки из прежней таблицы связи playlist_tracks в playlist_entries.
+// Порядок в старой таблице не хранился, поэтому позиции назначаются по идентификатору трека
+func migratePlaylistTracks(db *gorm.DB) error {
+	if !db.Migrator().HasTable("playlist_tracks") {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec(`
+			INSERT INTO playlist_entries (playlist_id, position, track_id, added_by, added_at)
+			SELECT pt.playlist_id,
+				row_number() OVER (PARTITION BY pt.playlist_id ORDER BY pt.track_id) - 1,
+				pt.track_id, p.user_id, now()
+			FROM playlist_tracks pt
+			JOIN playlists p ON p.id = pt.playlist_id`).Error
+		if err != nil {
+			return err
+		}
+
+		log.Println("Migrated playlist_tracks to playlist_entries")
+		return tx.Migrator().DropTable("playlist_tracks")
+	})
+}
+
+// migrateTrackSearch добавляет в tracks вычисляемую колонку search_vector и GIN-индекс по ней.
+// Название весит A, исполнитель B, альбом C, жанр D; каждое поле разбирается всеми SearchDictionaries
+func migrateTrackSearch(db *gorm.DB) error {
+	if !db.Migrator().HasColumn("tracks", "search_vector") {
+		fields := []struct{ column, weight string }{
+			{"title", "A"},
+			{"artist", "B"},
+			{"album", "C"},
+			{"genre", "D"},
+		}
+
+		var weighted []string
+		for _, field := range fields {
+			var vectors []string
+			for _, dictionary := range model.SearchDictionaries {
+				vectors = append(vectors, fmt.Sprintf("to_tsvector('%s', coalesce(%s, ''))", dictionary, field.column))
+			}
+			weighted = append(weighted, fmt.Sprintf("setweight(%s, '%s')", strings.Join(vectors, " || "), field.weight))
+		}
+
+		err := db.Exec("ALTER TABLE tracks ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (" +
+			strings.Join(weighted, " || ") + ") STORED").Error
+		if err != nil {
+			return err
+		}
+		log.Println("Added full-text search column to tracks")
+	}
+
+	return db.Exec("CREATE INDEX IF NOT EXISTS idx_tracks_search_vector ON tracks USING GIN (search_vector)").Error
+}
+
+// migrateTrackTrigrams подключает pg_trgm и строит триграммный индекс по названию и исполнителю
+// для нечеткого поиска. Выражение индекса должно совпадать с тем, по которому ищет репозиторий
+func migrateTrackTrigrams(db *gorm.DB) error {
+	if err := db.Exec("CREATE EXTENSION IF NOT EXISTS pg_trgm").Error; err != nil {
+		return err
+	}
+	return db.Exec("CREATE INDEX IF NOT EXISTS idx_tracks_trigram ON tracks USING GIN ((lower(title) || ' ' || lower(artist)) gin_trgm_ops)").Error
+}
+
+// migrateSuggestIndexes строит индексы для поиска по префиксу в подсказках. text_pattern_ops нужен,
+// чтобы LIKE 'prefix%' использовал индекс независимо от правил сортировки базы
+func migrateSuggestIndexes(db *gorm.DB) error {
+	indexes := []string{
+		"CREATE INDEX IF NOT EXISTS idx_tracks_title_prefix ON tracks (lower(title) text_pattern_ops)",
+		"CREATE INDEX IF NOT EXISTS idx_tracks_genre_prefix ON tracks (lower(genre) text_pattern_ops)",
+		"CREATE INDEX IF NOT EXISTS idx_artists_name_prefix ON artists (normalized_name text_pattern_ops)",
+		"CREATE INDEX IF NOT EXISTS idx_albums_title_prefix ON albums (normalized_title text_pattern_ops)",
+		"CREATE INDEX IF NOT EXISTS idx_playlists_name_prefix ON playlists (user_id, lower(name) text_pattern_ops)",
+	}
+	for _, index := range indexes {
+		if err := db.Exec(index).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package controller
+
+import (
+	"MusicService/internal/model"
+	"MusicService/internal/service"
+	"MusicService/pkg/response"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AccountController struct {
+	accountService    service.AccountService
+	dataExportService service.DataExportService
+}
+
+func NewAccountController(accountService service.AccountService, dataExportService service.DataExportService) *AccountController {
+	return &AccountController{accountService: accountService, dataExportService: dataExportService}
+}
+
+// RequestDataExport godoc
+// @Summary Запросить выгрузку данных
+// @Description Запускает сборку ZIP-архива с профилем, метаданными загруженных треков (по желанию и аудиофайлами),
+// @Description плейлистами и историей прослушиваний в JSON и CSV. По готовности на email приходит ссылка для скачивания
+// @Tags Account
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body model.CreateDataExportRequest false "Параметры выгрузки"
+// @Success 202 {object} model.DataExportResponse
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /api/user/exports [post]
+func (c *AccountController) RequestDataExport(ctx *gin.Context) {
+	var req model.CreateDataExportRequest
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			response.Error(ctx, http.StatusBadRequest, "Invalid request payload")
+			return
+		}
+	}
+
+	export, err := c.dataExportService.Request(ctx.GetUint("userID"), &req)
+	if err != nil {
+		if errors.Is(err, service.ErrDataExportInProgress) {
+			response.Error(ctx, http.StatusConflict, err.Error())
+			return
+		}
+		response.Error(ctx, http.StatusInternalServerError, "Failed to request data export")
+		return
+	}
+
+	response.Success(ctx, http.StatusAccepted, export)
+}
+
+// GetDataExports godoc
+// @Summary Выгрузки данных
+// @Description Возвращает выгрузки пользователя; для готовых архивов - подписанную ссылку для скачивания
+// @Tags Account
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} model.DataExportResponse
+// @Failure 401 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /api/user/exports [get]
+func (c *AccountController) GetDataExports(ctx *gin.Context) {
+	exports, err := c.dataExportService.List(ctx.GetUint("userID"))
+	if err != nil {
+		response.Error(ctx, http.StatusInternalServerError, "Failed to get data exports")
+		return
+	}
+
+	response.Success(ctx, http.StatusOK, exports)
+}
+
+// GetDataExport godoc
+// @Summary Состояние выгрузки данных
+// @Description Возвращает состояние выгрузки и, если архив готов, подписанную ссылку для скачивания
+// @Tags Account
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "ID выгрузки"
+// @Success 200 {object} model.DataExportResponse
+// @Failure 401 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /api/user/exports/{id} [get]
+func (c *AccountController) GetDataExport(ctx *gin.Context) {
+	export, err := c.dataExportService.Get(ctx.GetUint("userID"), ctx.Param("id"))
+	if err != nil {
+		if errors.Is(err, service.ErrDataExportNotFound) {
+			response.Error(ctx, http.StatusNotFound, err.Error())
+			return
+		}
+		response.Error(ctx, http.StatusInternalServerError, "Failed to get data export")
+		return
+	}
+
+	response.Success(ctx, http.StatusOK, export)
+}
+
+// DeleteAccount godoc
+// @Summary Удалить учетную запись
+// @Description Удаляет учетную запись после проверки пароля и кода 2FA, если она включена.
+// @Description data=delete удаляет загруженные треки вместе с файлами, плейлисты и историю прослушиваний;
+// @Description data=anonymize оставляет их, отвязав от пользователя
+// @Tags Account
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body model.DeleteAccountRequest true "Пароль и способ обработки данных"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /api/user/account [delete]
+func (c *AccountController) DeleteAccount(ctx *gin.Context) {
+	var req model.DeleteAccountRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		response.Error(ctx, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if err := c.accountService.DeleteAccount(ctx.GetUint("userID"), &req); err != nil {
+		switch {
+		case errors.Is(err, service.ErrIncorrectPassword):
+			response.Error(ctx, http.StatusForbidden, err.Error())
+		case errors.Is(err, service.ErrInvalidTwoFactorCode):
+			response.Error(ctx, http.StatusBadRequest, err.Error())
+		case errors.Is(err, service.ErrLastAdmin):
+			response.Error(ctx, http.StatusConflict, err.Error())
+		default:
+			response.Error(ctx, http.StatusInternalServerError, "Failed to delete account")
+		}
+		return
+	}
+
+	response.Success(ctx, http.StatusOK, gin.H{"message": "Account deleted successfully"})
+}
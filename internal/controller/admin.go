@@ -0,0 +1,242 @@
+package controller
+
+import (
+	"MusicService/internal/middleware"
+	"MusicService/internal/model"
+	"MusicService/internal/service"
+	"MusicService/pkg/response"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AdminController struct {
+	adminService    service.AdminService
+	trackService    service.TrackService
+	playlistService service.PlaylistService
+}
+
+func NewAdminController(adminService service.AdminService, trackService service.TrackService,
+	playlistService service.PlaylistService) *AdminController {
+	return &AdminController{
+		adminService:    adminService,
+		trackService:    trackService,
+		playlistService: playlistService,
+	}
+}
+
+// ListUsers godoc
+// @Summary Список пользователей
+// @Description Возвращает всех пользователей или найденных по подстроке имени или email
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param q query string false "Поисковый запрос"
+// @Success 200 {array} model.AdminUserResponse
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /api/admin/users [get]
+func (c *AdminController) ListUsers(ctx *gin.Context) {
+	var params model.AdminUserSearchParams
+	if err := ctx.ShouldBindQuery(&params); err != nil {
+		response.Error(ctx, http.StatusBadRequest, "Invalid search parameters")
+		return
+	}
+
+	users, err := c.adminService.SearchUsers(params)
+	if err != nil {
+		response.Error(ctx, http.StatusInternalServerError, "Failed to get users")
+		return
+	}
+
+	response.Success(ctx, http.StatusOK, users)
+}
+
+// SetUserRole godoc
+// @Summary Изменить роль пользователя
+// @Description Назначает пользователю роль user, uploader или admin
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "ID пользователя"
+// @Param request body model.UpdateUserRoleRequest true "Новая роль"
+// @Success 200 {object} model.AdminUserResponse
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /api/admin/users/{id}/role [put]
+func (c *AdminController) SetUserRole(ctx *gin.Context) {
+	userID, ok := parseUserID(ctx)
+	if !ok {
+		return
+	}
+
+	var req model.UpdateUserRoleRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		response.Error(ctx, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	user, err := c.adminService.SetUserRole(ctx.GetUint("userID"), userID, req.Role)
+	if err != nil {
+		writeAdminError(ctx, err)
+		return
+	}
+
+	response.Success(ctx, http.StatusOK, user)
+}
+
+// DisableUser godoc
+// @Summary Заблокировать пользователя
+// @Description Блокирует учетную запись: пользователь не сможет войти, а выданные токены перестают действовать
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "ID пользователя"
+// @Success 200 {object} model.AdminUserResponse
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /api/admin/users/{id}/disable [post]
+func (c *AdminController) DisableUser(ctx *gin.Context) {
+	c.setUserDisabled(ctx, true)
+}
+
+// EnableUser godoc
+// @Summary Разблокировать пользователя
+// @Description Снимает блокировку учетной записи
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "ID пользователя"
+// @Success 200 {object} model.AdminUserResponse
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /api/admin/users/{id}/enable [post]
+func (c *AdminController) EnableUser(ctx *gin.Context) {
+	c.setUserDisabled(ctx, false)
+}
+
+func (c *AdminController) setUserDisabled(ctx *gin.Context, disabled bool) {
+	userID, ok := parseUserID(ctx)
+	if !ok {
+		return
+	}
+
+	user, err := c.adminService.SetUserDisabled(ctx.GetUint("userID"), userID, disabled)
+	if err != nil {
+		writeAdminError(ctx, err)
+		return
+	}
+
+	response.Success(ctx, http.StatusOK, user)
+}
+
+// DeleteTrack godoc
+// @Summary Удалить любой трек
+// @Description Удаляет трек независимо от того, кто его загрузил
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "ID трека"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /api/admin/tracks/{id} [delete]
+func (c *AdminController) DeleteTrack(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "Invalid track ID")
+		return
+	}
+
+	if err := c.trackService.DeleteTrack(uint(id), middleware.CurrentActor(ctx)); err != nil {
+		writeAdminError(ctx, err)
+		return
+	}
+
+	response.Success(ctx, http.StatusOK, gin.H{"message": "Track deleted successfully"})
+}
+
+// DeletePlaylist godoc
+// @Summary Удалить любой плейлист
+// @Description Удаляет плейлист любого пользователя
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "ID плейлиста"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /api/admin/playlists/{id} [delete]
+func (c *AdminController) DeletePlaylist(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "Invalid playlist ID")
+		return
+	}
+
+	if err := c.playlistService.DeletePlaylist(uint(id), middleware.CurrentActor(ctx)); err != nil {
+		writeAdminError(ctx, err)
+		return
+	}
+
+	response.Success(ctx, http.StatusOK, gin.H{"message": "Playlist deleted successfully"})
+}
+
+// GetSystemStats godoc
+// @Summary Статистика сервиса
+// @Description Возвращает число пользователей, треков, плейлистов и прослушиваний, а также объем хранилища
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} model.SystemStats
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /api/admin/stats [get]
+func (c *AdminController) GetSystemStats(ctx *gin.Context) {
+	stats, err := c.adminService.GetSystemStats()
+	if err != nil {
+		response.Error(ctx, http.StatusInternalServerError, "Failed to get system stats")
+		return
+	}
+
+	response.Success(ctx, http.StatusOK, stats)
+}
+
+func parseUserID(ctx *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "Invalid user ID")
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func writeAdminError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrUserNotFound),
+		errors.Is(err, service.ErrTrackNotFound),
+		errors.Is(err, service.ErrPlaylistNotFound):
+		response.Error(ctx, http.StatusNotFound, err.Error())
+	case errors.Is(err, service.ErrCannotModifySelf):
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+	case errors.Is(err, service.ErrForbidden):
+		response.Error(ctx, http.StatusForbidden, "Access denied")
+	default:
+		response.Error(ctx, http.StatusInternalServerError, "Internal server error")
+	}
+}
@@ -0,0 +1,122 @@
+package controller
+
+import (
+	"MusicService/internal/middleware"
+	"MusicService/internal/model"
+	"MusicService/internal/service"
+	"MusicService/internal/storage/storagetest"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Учетные записи, которыми управляет администратор в тестах
+const (
+	activeUserID   uint = 4
+	disabledUserID uint = 5
+)
+
+type adminFixture struct {
+	users     *fakeUserRepository
+	tracks    *fakeTrackRepository
+	playlists *fakePlaylistRepository
+	router    *gin.Engine
+}
+
+func newAdminFixture(t *testing.T, user testUser) *adminFixture {
+	f := &adminFixture{
+		users: &fakeUserRepository{users: map[uint]*model.User{
+			activeUserID:   {Model: gorm.Model{ID: activeUserID}, Username: "active", Role: model.RoleUser},
+			disabledUserID: {Model: gorm.Model{ID: disabledUserID}, Username: "disabled", Role: model.RoleUser, Disabled: true},
+			adminID:        {Model: gorm.Model{ID: adminID}, Username: "admin", Role: model.RoleAdmin},
+		}},
+	}
+	f.tracks, f.playlists = newPlaylistRepositories()
+	f.tracks.tracks[1] = &model.Track{Model: gorm.Model{ID: 1}, Title: "Song", FilePath: "tracks/1.mp3", UploadedBy: ownerID}
+	store := storagetest.NewMemory(t)
+	store.Put(testBucket, "tracks/1.mp3", []byte("audio"), "audio/mpeg")
+
+	controller := NewAdminController(
+		service.NewAdminService(f.users, nil, store, testBucket),
+		service.NewTrackService(f.tracks, store, nil, testBucket),
+		service.NewPlaylistService(f.playlists, f.tracks),
+	)
+
+	// Те же проверки, что у группы /api/admin в cmd/server
+	f.router = newTestRouter(user)
+	group := f.router.Group("/admin", middleware.RequireSession(), middleware.RequireRole(model.RoleAdmin))
+	group.PUT("/users/:id/role", controller.SetUserRole)
+	group.POST("/users/:id/disable", controller.DisableUser)
+	group.POST("/users/:id/enable", controller.EnableUser)
+	group.DELETE("/tracks/:id", controller.DeleteTrack)
+	group.DELETE("/playlists/:id", controller.DeletePlaylist)
+	return f
+}
+
+func TestAdminPolicy(t *testing.T) {
+	endpoints := []struct {
+		name    string
+		method  string
+		target  string
+		body    any
+		applied func(f *adminFixture) bool
+	}{
+		{
+			name: "set role", method: http.MethodPut, target: "/admin/users/4/role",
+			body:    model.UpdateUserRoleRequest{Role: model.RoleUploader},
+			applied: func(f *adminFixture) bool { return f.users.users[activeUserID].Role == model.RoleUploader },
+		},
+		{
+			name: "disable user", method: http.MethodPost, target: "/admin/users/4/disable",
+			applied: func(f *adminFixture) bool { return f.users.users[activeUserID].Disabled },
+		},
+		{
+			name: "enable user", method: http.MethodPost, target: "/admin/users/5/enable",
+			applied: func(f *adminFixture) bool { return !f.users.users[disabledUserID].Disabled },
+		},
+		{
+			name: "delete track", method: http.MethodDelete, target: "/admin/tracks/1",
+			applied: func(f *adminFixture) bool { _, exists := f.tracks.tracks[1]; return !exists },
+		},
+		{
+			name: "delete playlist", method: http.MethodDelete, target: "/admin/playlists/10",
+			applied: func(f *adminFixture) bool { _, exists := f.playlists.playlists[testPlaylistID]; return !exists },
+		},
+	}
+
+	for _, endpoint := range endpoints {
+		for _, user := range []testUser{owner, other, admin} {
+			allowed := user == admin
+
+			t.Run(endpoint.name+"/"+user.name, func(t *testing.T) {
+				f := newAdminFixture(t, user)
+
+				rec := serve(t, f.router, endpoint.method, endpoint.target, endpoint.body)
+				want := http.StatusOK
+				if !allowed {
+					want = http.StatusForbidden
+				}
+				if rec.Code != want {
+					t.Fatalf("status = %d, want %d: %s", rec.Code, want, rec.Body.String())
+				}
+				if got := endpoint.applied(f); got != allowed {
+					t.Errorf("change applied = %v, want %v", got, allowed)
+				}
+			})
+		}
+	}
+}
+
+func TestAdminCannotModifySelf(t *testing.T) {
+	f := newAdminFixture(t, admin)
+
+	rec := serve(t, f.router, http.MethodPut, "/admin/users/3/role", model.UpdateUserRoleRequest{Role: model.RoleUser})
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	if f.users.users[adminID].Role != model.RoleAdmin {
+		t.Error("admin changed their own role")
+	}
+}
@@ -0,0 +1,116 @@
+package controller
+
+import (
+	"MusicService/internal/service"
+	"MusicService/pkg/response"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AlbumController struct {
+	albumService service.AlbumService
+}
+
+func NewAlbumController(albumService service.AlbumService) *AlbumController {
+	return &AlbumController{albumService: albumService}
+}
+
+// GetAllAlbums возвращает все альбомы
+// @Summary Получить все альбомы
+// @Description Возвращает постраничный список альбомов, по умолчанию в алфавитном порядке
+// @Tags Albums
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "Номер страницы (с 1)"
+// @Param per_page query int false "Записей на странице (по умолчанию 50, не больше 200)"
+// @Param cursor query string false "Курсор next_cursor из предыдущего ответа; заменяет page"
+// @Param sort query string false "Сортировка: title, artist, year, created_at, track_count; \"-\" в начале - по убыванию (по умолчанию title)"
+// @Success 200 {object} response.PaginatedResponse{data=[]model.AlbumResponse}
+// @Failure 400 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /api/albums [get]
+func (c *AlbumController) GetAllAlbums(ctx *gin.Context) {
+	page, ok := bindPage(ctx)
+	if !ok {
+		return
+	}
+
+	albums, err := c.albumService.GetAllAlbums(page)
+	if err != nil {
+		writePageError(ctx, err, "Failed to get albums")
+		return
+	}
+
+	writePage(ctx, albums)
+}
+
+// GetAlbumByID возвращает альбом по ID
+// @Summary Получить альбом по ID
+// @Description Возвращает альбом и его треки в порядке дисков и номеров
+// @Tags Albums
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "ID альбома"
+// @Success 200 {object} model.AlbumDetailResponse
+// @Failure 400 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /api/albums/{id} [get]
+func (c *AlbumController) GetAlbumByID(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "Invalid album ID")
+		return
+	}
+
+	artist, err := c.albumService.GetAlbumByID(uint(id))
+	if err != nil {
+		if errors.Is(err, service.ErrAlbumNotFound) {
+			response.Error(ctx, http.StatusNotFound, "Album not found")
+		} else {
+			response.Error(ctx, http.StatusInternalServerError, "Failed to get album")
+		}
+		return
+	}
+
+	response.Success(ctx, http.StatusOK, artist)
+}
+
+// GetAlbumImage godoc
+// @Summary Получить обложку альбома
+// @Description Возвращает изображение первого трека альбома, у которого оно есть
+// @Tags Albums
+// @Produce image/*
+// @Security BearerAuth
+// @Param id path int true "ID альбома"
+// @Success 200 {file} byte
+// @Failure 400 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /api/albums/{id}/image [get]
+func (c *AlbumController) GetAlbumImage(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "Invalid album ID")
+		return
+	}
+
+	reader, contentType, err := c.albumService.GetAlbumImage(uint(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrAlbumNotFound):
+			response.Error(ctx, http.StatusNotFound, "Album not found")
+		case errors.Is(err, service.ErrCoverNotFound):
+			response.Error(ctx, http.StatusNotFound, "Image not found")
+		default:
+			response.Error(ctx, http.StatusInternalServerError, "Failed to get image")
+		}
+		return
+	}
+	defer reader.Close()
+
+	ctx.DataFromReader(http.StatusOK, -1, contentType, reader, nil)
+}
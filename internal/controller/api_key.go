@@ -0,0 +1,108 @@
+package controller
+
+import (
+	"MusicService/internal/model"
+	"MusicService/internal/service"
+	"MusicService/pkg/response"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type APIKeyController struct {
+	apiKeyService service.APIKeyService
+}
+
+func NewAPIKeyController(apiKeyService service.APIKeyService) *APIKeyController {
+	return &APIKeyController{apiKeyService: apiKeyService}
+}
+
+// CreateAPIKey godoc
+// @Summary Создать API-ключ
+// @Description Создает ключ для скриптов и интеграций с областями read, upload, playlists и stats.
+// @Description Ключ передается в заголовке Authorization: Bearer и показывается только один раз
+// @Tags APIKeys
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body model.CreateAPIKeyRequest true "Название, области и срок действия"
+// @Success 201 {object} model.CreatedAPIKeyResponse
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /api/user/api-keys [post]
+func (c *APIKeyController) CreateAPIKey(ctx *gin.Context) {
+	var req model.CreateAPIKeyRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		response.Error(ctx, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	key, err := c.apiKeyService.Create(ctx.GetUint("userID"), &req)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidAPIKeyExpiry) {
+			response.Error(ctx, http.StatusBadRequest, err.Error())
+			return
+		}
+		response.Error(ctx, http.StatusInternalServerError, "Failed to create API key")
+		return
+	}
+
+	response.Success(ctx, http.StatusCreated, key)
+}
+
+// GetAPIKeys godoc
+// @Summary Список API-ключей
+// @Description Возвращает неотозванные ключи пользователя без самих ключей
+// @Tags APIKeys
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} model.APIKeyResponse
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /api/user/api-keys [get]
+func (c *APIKeyController) GetAPIKeys(ctx *gin.Context) {
+	keys, err := c.apiKeyService.List(ctx.GetUint("userID"))
+	if err != nil {
+		response.Error(ctx, http.StatusInternalServerError, "Failed to get API keys")
+		return
+	}
+
+	response.Success(ctx, http.StatusOK, keys)
+}
+
+// RevokeAPIKey godoc
+// @Summary Отозвать API-ключ
+// @Description Ключ перестает действовать сразу
+// @Tags APIKeys
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "ID ключа"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /api/user/api-keys/{id} [delete]
+func (c *APIKeyController) RevokeAPIKey(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "Invalid API key ID")
+		return
+	}
+
+	if err := c.apiKeyService.Revoke(ctx.GetUint("userID"), uint(id)); err != nil {
+		if errors.Is(err, service.ErrAPIKeyNotFound) {
+			response.Error(ctx, http.StatusNotFound, err.Error())
+			return
+		}
+		response.Error(ctx, http.StatusInternalServerError, "Failed to revoke API key")
+		return
+	}
+
+	response.Success(ctx, http.StatusOK, gin.H{"message": "API key revoked successfully"})
+}
@@ -0,0 +1,116 @@
+package controller
+
+import (
+	"MusicService/internal/service"
+	"MusicService/pkg/response"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ArtistController struct {
+	artistService service.ArtistService
+}
+
+func NewArtistController(artistService service.ArtistService) *ArtistController {
+	return &ArtistController{artistService: artistService}
+}
+
+// GetAllArtists возвращает всех исполнителей
+// @Summary Получить всех исполнителей
+// @Description Возвращает постраничный список исполнителей, по умолчанию в алфавитном порядке
+// @Tags Artists
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "Номер страницы (с 1)"
+// @Param per_page query int false "Записей на странице (по умолчанию 50, не больше 200)"
+// @Param cursor query string false "Курсор next_cursor из предыдущего ответа; заменяет page"
+// @Param sort query string false "Сортировка: name, created_at, album_count; \"-\" в начале - по убыванию (по умолчанию name)"
+// @Success 200 {object} response.PaginatedResponse{data=[]model.ArtistResponse}
+// @Failure 400 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /api/artists [get]
+func (c *ArtistController) GetAllArtists(ctx *gin.Context) {
+	page, ok := bindPage(ctx)
+	if !ok {
+		return
+	}
+
+	artists, err := c.artistService.GetAllArtists(page)
+	if err != nil {
+		writePageError(ctx, err, "Failed to get artists")
+		return
+	}
+
+	writePage(ctx, artists)
+}
+
+// GetArtistByID возвращает исполнителя по ID
+// @Summary Получить исполнителя по ID
+// @Description Возвращает исполнителя и список его альбомов
+// @Tags Artists
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "ID исполнителя"
+// @Success 200 {object} model.ArtistDetailResponse
+// @Failure 400 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /api/artists/{id} [get]
+func (c *ArtistController) GetArtistByID(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "Invalid artist ID")
+		return
+	}
+
+	artist, err := c.artistService.GetArtistByID(uint(id))
+	if err != nil {
+		if errors.Is(err, service.ErrArtistNotFound) {
+			response.Error(ctx, http.StatusNotFound, "Artist not found")
+		} else {
+			response.Error(ctx, http.StatusInternalServerError, "Failed to get artist")
+		}
+		return
+	}
+
+	response.Success(ctx, http.StatusOK, artist)
+}
+
+// GetArtistImage godoc
+// @Summary Получить изображение исполнителя
+// @Description Возвращает обложку одного из альбомов исполнителя
+// @Tags Artists
+// @Produce image/*
+// @Security BearerAuth
+// @Param id path int true "ID исполнителя"
+// @Success 200 {file} byte
+// @Failure 400 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /api/artists/{id}/image [get]
+func (c *ArtistController) GetArtistImage(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "Invalid artist ID")
+		return
+	}
+
+	reader, contentType, err := c.artistService.GetArtistImage(uint(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrArtistNotFound):
+			response.Error(ctx, http.StatusNotFound, "Artist not found")
+		case errors.Is(err, service.ErrCoverNotFound):
+			response.Error(ctx, http.StatusNotFound, "Image not found")
+		default:
+			response.Error(ctx, http.StatusInternalServerError, "Failed to get image")
+		}
+		return
+	}
+	defer reader.Close()
+
+	ctx.DataFromReader(http.StatusOK, -1, contentType, reader, nil)
+}
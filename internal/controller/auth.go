@@ -4,17 +4,19 @@ import (
 	"MusicService/internal/model"
 	"MusicService/internal/service"
 	"MusicService/pkg/response"
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
 
 type AuthController struct {
-	authService service.AuthService
+	authService     service.AuthService
+	passwordService service.PasswordService
 }
 
-func NewAuthController(authService service.AuthService) *AuthController {
-	return &AuthController{authService: authService}
+func NewAuthController(authService service.AuthService, passwordService service.PasswordService) *AuthController {
+	return &AuthController{authService: authService, passwordService: passwordService}
 }
 
 // Register godoc
@@ -50,14 +52,17 @@ func (c *AuthController) Register(ctx *gin.Context) {
 
 // Login godoc
 // @Summary Авторизация пользователя
-// @Description Вход в систему и получение JWT токена
+// @Description Вход в систему. Возвращает короткоживущий JWT токен доступа и одноразовый токен обновления.
+// @Description Если включена двухфакторная аутентификация, вместо токенов возвращается challengeToken для /auth/login/2fa
 // @Tags Auth
 // @Accept json
 // @Produce json
 // @Param request body model.LoginRequest true "Данные авторизации"
-// @Success 200 {object} response.Response
+// @Success 200 {object} model.LoginResponse
 // @Failure 400 {object} response.Response
 // @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 429 {object} response.Response
 // @Router /auth/login [post]
 func (c *AuthController) Login(ctx *gin.Context) {
 	var req model.LoginRequest
@@ -66,11 +71,236 @@ func (c *AuthController) Login(ctx *gin.Context) {
 		return
 	}
 
-	token, err := c.authService.Login(&req)
+	tokens, err := c.authService.Login(&req, clientInfo(ctx))
 	if err != nil {
-		response.Error(ctx, http.StatusUnauthorized, err.Error())
+		writeAuthError(ctx, err)
+		return
+	}
+
+	response.Success(ctx, http.StatusOK, tokens)
+}
+
+// LoginTwoFactor godoc
+// @Summary Второй шаг входа
+// @Description Завершает вход с двухфакторной аутентификацией кодом из приложения или кодом восстановления.
+// @Description После пяти неверных кодов вход нужно начинать заново
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body model.TwoFactorLoginRequest true "Токен первого шага и код"
+// @Success 200 {object} model.TokenResponse
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 429 {object} response.Response
+// @Router /auth/login/2fa [post]
+func (c *AuthController) LoginTwoFactor(ctx *gin.Context) {
+	var req model.TwoFactorLoginRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		response.Error(ctx, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	tokens, err := c.authService.CompleteTwoFactorLogin(&req, clientInfo(ctx))
+	if err != nil {
+		writeAuthError(ctx, err)
+		return
+	}
+
+	response.Success(ctx, http.StatusOK, tokens)
+}
+
+// Refresh godoc
+// @Summary Обновить токены
+// @Description Обменивает токен обновления на новую пару токенов. Предъявленный токен становится недействительным;
+// @Description его повторное использование отзывает все токены, полученные с того же входа
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body model.RefreshTokenRequest true "Токен обновления"
+// @Success 200 {object} model.TokenResponse
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Router /auth/refresh [post]
+func (c *AuthController) Refresh(ctx *gin.Context) {
+	var req model.RefreshTokenRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		response.Error(ctx, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	tokens, err := c.authService.Refresh(req.RefreshToken, clientInfo(ctx))
+	if err != nil {
+		writeAuthError(ctx, err)
 		return
 	}
 
-	response.Success(ctx, http.StatusOK, gin.H{"token": token})
+	response.Success(ctx, http.StatusOK, tokens)
+}
+
+// Logout godoc
+// @Summary Выход
+// @Description Завершает сессию, к которой относится токен обновления
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body model.RefreshTokenRequest true "Токен обновления"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Router /auth/logout [post]
+func (c *AuthController) Logout(ctx *gin.Context) {
+	var req model.RefreshTokenRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		response.Error(ctx, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if err := c.authService.Logout(req.RefreshToken); err != nil {
+		writeAuthError(ctx, err)
+		return
+	}
+
+	response.Success(ctx, http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+// LogoutEverywhere godoc
+// @Summary Выход на всех устройствах
+// @Description Завершает все сессии пользователя, включая текущую
+// @Tags Auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /api/user/logout-all [post]
+func (c *AuthController) LogoutEverywhere(ctx *gin.Context) {
+	if err := c.authService.LogoutEverywhere(ctx.GetUint("userID")); err != nil {
+		response.Error(ctx, http.StatusInternalServerError, "Failed to log out")
+		return
+	}
+
+	response.Success(ctx, http.StatusOK, gin.H{"message": "Logged out on all devices"})
+}
+
+// ForgotPassword godoc
+// @Summary Запросить сброс пароля
+// @Description Отправляет на email ссылку для сброса пароля. Ответ одинаков, зарегистрирован email или нет
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body model.ForgotPasswordRequest true "Email"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /auth/forgot-password [post]
+func (c *AuthController) ForgotPassword(ctx *gin.Context) {
+	var req model.ForgotPasswordRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		response.Error(ctx, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if err := c.passwordService.RequestReset(req.Email); err != nil {
+		response.Error(ctx, http.StatusInternalServerError, "Failed to request password reset")
+		return
+	}
+
+	response.Success(ctx, http.StatusOK, gin.H{"message": "If the email is registered, a reset link has been sent"})
+}
+
+// ResetPassword godoc
+// @Summary Сбросить пароль
+// @Description Задает новый пароль по одноразовому токену из письма. Все сессии пользователя завершаются
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body model.ResetPasswordRequest true "Токен и новый пароль"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /auth/reset-password [post]
+func (c *AuthController) ResetPassword(ctx *gin.Context) {
+	var req model.ResetPasswordRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		response.Error(ctx, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if err := c.passwordService.ResetPassword(&req); err != nil {
+		if errors.Is(err, service.ErrInvalidResetToken) {
+			response.Error(ctx, http.StatusBadRequest, err.Error())
+			return
+		}
+		response.Error(ctx, http.StatusInternalServerError, "Failed to reset password")
+		return
+	}
+
+	response.Success(ctx, http.StatusOK, gin.H{"message": "Password has been reset"})
+}
+
+// GetSessions godoc
+// @Summary Активные сессии
+// @Description Возвращает устройства, на которых выполнен вход
+// @Tags Auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} model.SessionResponse
+// @Failure 401 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /api/user/sessions [get]
+func (c *AuthController) GetSessions(ctx *gin.Context) {
+	sessions, err := c.authService.GetSessions(ctx.GetUint("userID"), ctx.GetString("sessionID"))
+	if err != nil {
+		response.Error(ctx, http.StatusInternalServerError, "Failed to get sessions")
+		return
+	}
+
+	response.Success(ctx, http.StatusOK, sessions)
+}
+
+// RevokeSession godoc
+// @Summary Завершить сессию
+// @Description Выполняет выход на указанном устройстве; его токены перестают действовать сразу
+// @Tags Auth
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "ID сессии"
+// @Success 200 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /api/user/sessions/{id} [delete]
+func (c *AuthController) RevokeSession(ctx *gin.Context) {
+	if err := c.authService.RevokeSession(ctx.GetUint("userID"), ctx.Param("id")); err != nil {
+		if errors.Is(err, service.ErrSessionNotFound) {
+			response.Error(ctx, http.StatusNotFound, err.Error())
+			return
+		}
+		response.Error(ctx, http.StatusInternalServerError, "Failed to revoke session")
+		return
+	}
+
+	response.Success(ctx, http.StatusOK, gin.H{"message": "Session revoked successfully"})
+}
+
+func clientInfo(ctx *gin.Context) model.ClientInfo {
+	return model.ClientInfo{UserAgent: ctx.Request.UserAgent(), IP: ctx.ClientIP()}
+}
+
+func writeAuthError(ctx *gin.Context, err error) {
+	var locked *service.LoginLockedError
+	switch {
+	case errors.As(err, &locked):
+		response.TooManyRequests(ctx, locked.RetryAfter, err.Error())
+	case errors.Is(err, service.ErrAccountDisabled):
+		response.Error(ctx, http.StatusForbidden, err.Error())
+	case errors.Is(err, service.ErrInvalidRefreshToken), errors.Is(err, service.ErrInvalidCredentials),
+		errors.Is(err, service.ErrInvalidChallenge), errors.Is(err, service.ErrInvalidTwoFactorCode),
+		errors.Is(err, service.ErrTwoFactorNotEnabled):
+		response.Error(ctx, http.StatusUnauthorized, err.Error())
+	default:
+		response.Error(ctx, http.StatusInternalServerError, "Authentication failed")
+	}
 }
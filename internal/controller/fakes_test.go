@@ -0,0 +1,256 @@
+package controller
+
+import (
+	"MusicService/internal/model"
+	"MusicService/internal/repository"
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Пользователи в тестах прав: владелец ресурса, другой пользователь и администратор
+const (
+	ownerID uint = 1
+	otherID uint = 2
+	adminID uint = 3
+)
+
+type testUser struct {
+	name string
+	id   uint
+	role string
+}
+
+var (
+	owner = testUser{name: "owner", id: ownerID, role: model.RoleUser}
+	other = testUser{name: "non-owner", id: otherID, role: model.RoleUser}
+	admin = testUser{name: "admin", id: adminID, role: model.RoleAdmin}
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// newTestRouter возвращает роутер, в котором запрос уже аутентифицирован как user
+func newTestRouter(user testUser) *gin.Engine {
+	router := gin.New()
+	router.Use(func(ctx *gin.Context) {
+		ctx.Set("userID", user.id)
+		ctx.Set("role", user.role)
+		ctx.Next()
+	})
+	return router
+}
+
+func serve(t *testing.T, router *gin.Engine, method, target string, body any) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var payload bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&payload).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(method, target, &payload)
+	req.Header.Set("Content-Type", "application/json")
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+// fakeTrackRepository хранит треки в памяти; нереализованные методы паникуют
+type fakeTrackRepository struct {
+	repository.TrackRepository
+	tracks map[uint]*model.Track
+}
+
+func (r *fakeTrackRepository) GetByID(id uint) (*model.Track, error) {
+	track, ok := r.tracks[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	copied := *track
+	return &copied, nil
+}
+
+func (r *fakeTrackRepository) Delete(id uint) error {
+	delete(r.tracks, id)
+	return nil
+}
+
+// fakePlaylistRepository хранит плейлисты в памяти; нереализованные методы паникуют
+type fakePlaylistRepository struct {
+	repository.PlaylistRepository
+	playlists map[uint]*model.Playlist
+	nextEntry uint
+}
+
+func (r *fakePlaylistRepository) Create(playlist *model.Playlist) error {
+	playlist.ID = testPlaylistID + uint(len(r.playlists))
+	copied := *playlist
+	r.playlists[playlist.ID] = &copied
+	return nil
+}
+
+func (r *fakePlaylistRepository) GetByID(id uint) (*model.Playlist, error) {
+	playlist, ok := r.playlists[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	copied := *playlist
+	copied.Entries = slices.Clone(playlist.Entries)
+	return &copied, nil
+}
+
+func (r *fakePlaylistRepository) GetInfo(id uint) (*model.Playlist, error) {
+	playlist, err := r.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	playlist.Entries = nil
+	return playlist, nil
+}
+
+func (r *fakePlaylistRepository) Update(playlist *model.Playlist) error {
+	stored := r.playlists[playlist.ID]
+	stored.Name, stored.Description = playlist.Name, playlist.Description
+	return nil
+}
+
+func (r *fakePlaylistRepository) Delete(id uint) error {
+	delete(r.playlists, id)
+	return nil
+}
+
+func (r *fakePlaylistRepository) AddTrack(playlistID, trackID, addedBy uint, position *int) (*model.PlaylistEntry, error) {
+	playlist := r.playlists[playlistID]
+	r.nextEntry++
+	entry := model.PlaylistEntry{ID: r.nextEntry, PlaylistID: playlistID, TrackID: trackID, AddedBy: addedBy}
+	playlist.Entries = append(playlist.Entries, entry)
+	r.renumber(playlist)
+	return &playlist.Entries[len(playlist.Entries)-1], nil
+}
+
+func (r *fakePlaylistRepository) RemoveTrack(playlistID, trackID uint) error {
+	playlist := r.playlists[playlistID]
+	playlist.Entries = slices.DeleteFunc(playlist.Entries, func(e model.PlaylistEntry) bool { return e.TrackID == trackID })
+	r.renumber(playlist)
+	return nil
+}
+
+func (r *fakePlaylistRepository) RemoveEntry(playlistID, entryID uint) error {
+	playlist := r.playlists[playlistID]
+	i := r.entryIndex(playlist, entryID)
+	if i < 0 {
+		return repository.ErrPlaylistEntryNotFound
+	}
+	playlist.Entries = slices.Delete(playlist.Entries, i, i+1)
+	r.renumber(playlist)
+	return nil
+}
+
+func (r *fakePlaylistRepository) MoveEntry(playlistID, entryID uint, position int) error {
+	playlist := r.playlists[playlistID]
+	i := r.entryIndex(playlist, entryID)
+	if i < 0 {
+		return repository.ErrPlaylistEntryNotFound
+	}
+	entry := playlist.Entries[i]
+	entries := slices.Delete(playlist.Entries, i, i+1)
+	playlist.Entries = slices.Insert(entries, min(position, len(entries)), entry)
+	r.renumber(playlist)
+	return nil
+}
+
+func (r *fakePlaylistRepository) Reorder(playlistID uint, entryIDs []uint) error {
+	playlist := r.playlists[playlistID]
+	if len(entryIDs) != len(playlist.Entries) {
+		return repository.ErrInvalidPlaylistOrder
+	}
+	reordered := make([]model.PlaylistEntry, 0, len(entryIDs))
+	for _, id := range entryIDs {
+		i := r.entryIndex(playlist, id)
+		if i < 0 {
+			return repository.ErrInvalidPlaylistOrder
+		}
+		reordered = append(reordered, playlist.Entries[i])
+	}
+	playlist.Entries = reordered
+	r.renumber(playlist)
+	return nil
+}
+
+func (r *fakePlaylistRepository) entryIndex(playlist *model.Playlist, entryID uint) int {
+	return slices.IndexFunc(playlist.Entries, func(e model.PlaylistEntry) bool { return e.ID == entryID })
+}
+
+func (r *fakePlaylistRepository) renumber(playlist *model.Playlist) {
+	for i := range playlist.Entries {
+		playlist.Entries[i].Position = i
+	}
+}
+
+// fakeStarRepository хранит избранное в памяти
+type fakeStarRepository struct {
+	repository.StarRepository
+	stars []model.Star
+}
+
+func (r *fakeStarRepository) Create(stars []model.Star) error {
+	for _, star := range stars {
+		if !r.has(star.UserID, star.ItemType, star.ItemID) {
+			r.stars = append(r.stars, star)
+		}
+	}
+	return nil
+}
+
+func (r *fakeStarRepository) Delete(userID uint, itemType string, itemIDs []string) error {
+	r.stars = slices.DeleteFunc(r.stars, func(s model.Star) bool {
+		return s.UserID == userID && s.ItemType == itemType && slices.Contains(itemIDs, s.ItemID)
+	})
+	return nil
+}
+
+func (r *fakeStarRepository) GetByUserID(userID uint) ([]model.Star, error) {
+	var stars []model.Star
+	for _, star := range r.stars {
+		if star.UserID == userID {
+			stars = append(stars, star)
+		}
+	}
+	return stars, nil
+}
+
+func (r *fakeStarRepository) has(userID uint, itemType, itemID string) bool {
+	return slices.ContainsFunc(r.stars, func(s model.Star) bool {
+		return s.UserID == userID && s.ItemType == itemType && s.ItemID == itemID
+	})
+}
+
+// fakeUserRepository хранит пользователей в памяти по ID
+type fakeUserRepository struct {
+	repository.UserRepository
+	users map[uint]*model.User
+}
+
+func (r *fakeUserRepository) FindByID(id uint) (*model.User, error) {
+	user, ok := r.users[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	copied := *user
+	return &copied, nil
+}
+
+func (r *fakeUserRepository) Update(user *model.User) error {
+	copied := *user
+	r.users[user.ID] = &copied
+	return nil
+}
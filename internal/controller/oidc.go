@@ -0,0 +1,111 @@
+package controller
+
+import (
+	"MusicService/internal/service"
+	"MusicService/pkg/response"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	oidcFlowCookie     = "oidc_flow"
+	oidcFlowCookiePath = "/auth/oidc"
+)
+
+type OIDCController struct {
+	oidcService  service.OIDCService
+	secureCookie bool
+}
+
+// NewOIDCController создает контроллер входа через OpenID Connect. secureCookie выставляет
+// флаг Secure у cookie с состоянием входа; его нужно включать, если сервис доступен по HTTPS
+func NewOIDCController(oidcService service.OIDCService, secureCookie bool) *OIDCController {
+	return &OIDCController{oidcService: oidcService, secureCookie: secureCookie}
+}
+
+// Login godoc
+// @Summary Вход через внешнего провайдера
+// @Description Перенаправляет на страницу входа провайдера OpenID Connect (authorization code с PKCE)
+// @Tags Auth
+// @Success 302
+// @Failure 502 {object} response.Response
+// @Router /auth/oidc/login [get]
+func (c *OIDCController) Login(ctx *gin.Context) {
+	authURL, flow, err := c.oidcService.Begin(ctx.Request.Context())
+	if err != nil {
+		log.Printf("Failed to start OIDC login: %v", err)
+		response.Error(ctx, http.StatusBadGateway, "Identity provider is unavailable")
+		return
+	}
+
+	c.setFlowCookie(ctx, flow, int(service.OIDCFlowTTL.Seconds()))
+	ctx.Redirect(http.StatusFound, authURL)
+}
+
+// Callback godoc
+// @Summary Завершение входа через внешнего провайдера
+// @Description Принимает код авторизации от провайдера, проверяет ID токен и выдает токены сервиса.
+// @Description При первом входе учетная запись провайдера привязывается к пользователю с тем же подтвержденным email
+// @Description или создается новый пользователь
+// @Tags Auth
+// @Produce json
+// @Param code query string true "Код авторизации"
+// @Param state query string true "Состояние входа"
+// @Success 200 {object} model.TokenResponse
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /auth/oidc/callback [get]
+func (c *OIDCController) Callback(ctx *gin.Context) {
+	flow, _ := ctx.Cookie(oidcFlowCookie)
+	// Состояние входа одноразовое
+	c.setFlowCookie(ctx, "", -1)
+
+	if providerError := ctx.Query("error"); providerError != "" {
+		response.Error(ctx, http.StatusUnauthorized, "Identity provider denied sign-in: "+providerError)
+		return
+	}
+
+	code, state := ctx.Query("code"), ctx.Query("state")
+	if code == "" || state == "" || flow == "" {
+		response.Error(ctx, http.StatusBadRequest, service.ErrInvalidOIDCState.Error())
+		return
+	}
+
+	tokens, err := c.oidcService.Complete(ctx.Request.Context(), flow, state, code, clientInfo(ctx))
+	if err != nil {
+		writeOIDCError(ctx, err)
+		return
+	}
+
+	response.Success(ctx, http.StatusOK, tokens)
+}
+
+func (c *OIDCController) setFlowCookie(ctx *gin.Context, value string, maxAge int) {
+	// Lax: cookie должен приходить при переходе с сайта провайдера обратно на callback
+	ctx.SetSameSite(http.SameSiteLaxMode)
+	ctx.SetCookie(oidcFlowCookie, value, maxAge, oidcFlowCookiePath, "", c.secureCookie, true)
+}
+
+func writeOIDCError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidOIDCState),
+		errors.Is(err, service.ErrOIDCEmailRequired):
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+	case errors.Is(err, service.ErrOIDCLoginFailed):
+		response.Error(ctx, http.StatusUnauthorized, err.Error())
+	case errors.Is(err, service.ErrAccountDisabled),
+		errors.Is(err, service.ErrOIDCEmailNotVerified):
+		response.Error(ctx, http.StatusForbidden, err.Error())
+	case errors.Is(err, service.ErrOIDCAccountConflict):
+		response.Error(ctx, http.StatusConflict, err.Error())
+	default:
+		log.Printf("OIDC login failed: %v", err)
+		response.Error(ctx, http.StatusInternalServerError, "Single sign-on failed")
+	}
+}
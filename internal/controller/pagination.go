@@ -0,0 +1,34 @@
+package controller
+
+import (
+	"MusicService/internal/model"
+	"MusicService/internal/service"
+	"MusicService/pkg/response"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// bindPage читает параметры страницы из query. При ошибке уже ответил 400
+func bindPage(ctx *gin.Context) (model.PageParams, bool) {
+	var page model.PageParams
+	if err := ctx.ShouldBindQuery(&page); err != nil {
+		response.Error(ctx, http.StatusBadRequest, "Invalid pagination parameters")
+		return page, false
+	}
+	return page, true
+}
+
+func writePage[T any](ctx *gin.Context, page *model.Page[T]) {
+	response.PaginatedSuccess(ctx, page.Items, page.Total, page.Page, page.PerPage, page.NextCursor)
+}
+
+// writePageError отвечает 400 на неизвестное поле сортировки или чужой курсор, остальное - 500
+func writePageError(ctx *gin.Context, err error, message string) {
+	if errors.Is(err, service.ErrInvalidSort) || errors.Is(err, service.ErrInvalidCursor) {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+	response.Error(ctx, http.StatusInternalServerError, message)
+}
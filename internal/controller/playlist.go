@@ -1,9 +1,11 @@
 package controller
 
 import (
+	"MusicService/internal/middleware"
 	"MusicService/internal/model"
 	"MusicService/internal/service"
 	"MusicService/pkg/response"
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -53,24 +55,33 @@ func (c *PlaylistController) CreatePlaylist(ctx *gin.Context) {
 
 // GetUserPlaylists godoc
 // @Summary Получить плейлисты пользователя
-// @Description Возвращает все плейлисты текущего пользователя
+// @Description Возвращает постраничный список плейлистов текущего пользователя
 // @Tags Playlists
 // @Produce json
 // @Security BearerAuth
-// @Success 200 {array} model.PlaylistResponse
+// @Param page query int false "Номер страницы (с 1)"
+// @Param per_page query int false "Записей на странице (по умолчанию 50, не больше 200)"
+// @Param cursor query string false "Курсор next_cursor из предыдущего ответа; заменяет page"
+// @Param sort query string false "Сортировка: name, created_at; \"-\" в начале - по убыванию (по умолчанию -created_at)"
+// @Success 200 {object} response.PaginatedResponse{data=[]model.PlaylistResponse}
+// @Failure 400 {object} response.Response
 // @Failure 401 {object} response.Response
 // @Failure 500 {object} response.Response
 // @Router /api/playlists [get]
 func (c *PlaylistController) GetUserPlaylists(ctx *gin.Context) {
 	userID := ctx.GetUint("userID")
+	page, ok := bindPage(ctx)
+	if !ok {
+		return
+	}
 
-	playlists, err := c.playlistService.GetUserPlaylists(userID)
+	playlists, err := c.playlistService.ListUserPlaylists(userID, page)
 	if err != nil {
-		response.Error(ctx, http.StatusInternalServerError, "Failed to get playlists")
+		writePageError(ctx, err, "Failed to get playlists")
 		return
 	}
 
-	response.Success(ctx, http.StatusOK, playlists)
+	writePage(ctx, playlists)
 }
 
 // GetPlaylistByID godoc
@@ -82,7 +93,6 @@ func (c *PlaylistController) GetUserPlaylists(ctx *gin.Context) {
 // @Param id path int true "ID плейлиста"
 // @Success 200 {object} model.PlaylistResponse
 // @Failure 400 {object} response.Response
-// @Failure 403 {object} response.Response
 // @Failure 404 {object} response.Response
 // @Router /api/playlists/{id} [get]
 func (c *PlaylistController) GetPlaylistByID(ctx *gin.Context) {
@@ -92,13 +102,9 @@ func (c *PlaylistController) GetPlaylistByID(ctx *gin.Context) {
 		return
 	}
 
-	playlist, err := c.playlistService.GetPlaylistByID(uint(playlistID))
+	playlist, err := c.playlistService.GetPlaylistByID(uint(playlistID), middleware.CurrentActor(ctx))
 	if err != nil {
-		status := http.StatusInternalServerError
-		if err.Error() == "playlist not found" {
-			status = http.StatusNotFound
-		}
-		response.Error(ctx, status, err.Error())
+		writePlaylistError(ctx, err)
 		return
 	}
 
@@ -133,9 +139,9 @@ func (c *PlaylistController) UpdatePlaylist(ctx *gin.Context) {
 		return
 	}
 
-	updatedPlaylist, err := c.playlistService.UpdatePlaylist(uint(playlistID), &req)
+	updatedPlaylist, err := c.playlistService.UpdatePlaylist(uint(playlistID), &req, middleware.CurrentActor(ctx))
 	if err != nil {
-		response.Error(ctx, http.StatusInternalServerError, "Failed to update playlist")
+		writePlaylistError(ctx, err)
 		return
 	}
 
@@ -162,8 +168,8 @@ func (c *PlaylistController) DeletePlaylist(ctx *gin.Context) {
 		return
 	}
 
-	if err := c.playlistService.DeletePlaylist(uint(playlistID)); err != nil {
-		response.Error(ctx, http.StatusInternalServerError, "Failed to delete playlist")
+	if err := c.playlistService.DeletePlaylist(uint(playlistID), middleware.CurrentActor(ctx)); err != nil {
+		writePlaylistError(ctx, err)
 		return
 	}
 
@@ -172,14 +178,15 @@ func (c *PlaylistController) DeletePlaylist(ctx *gin.Context) {
 
 // AddTrackToPlaylist godoc
 // @Summary Добавить трек в плейлист
-// @Description Добавляет трек в указанный плейлист
+// @Description Вставляет трек в плейлист на указанную позицию (с нуля) или в конец, если позиция не задана.
+// @Description Один трек может входить в плейлист несколько раз
 // @Tags Playlists
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param id path int true "ID плейлиста"
-// @Param request body model.AddTrackToPlaylistRequest true "ID трека"
-// @Success 200 {object} response.Response
+// @Param request body model.AddTrackToPlaylistRequest true "ID трека и позиция"
+// @Success 201 {object} model.PlaylistEntryResponse
 // @Failure 400 {object} response.Response
 // @Failure 403 {object} response.Response
 // @Failure 404 {object} response.Response
@@ -198,21 +205,18 @@ func (c *PlaylistController) AddTrackToPlaylist(ctx *gin.Context) {
 		return
 	}
 
-	if err := c.playlistService.AddTrackToPlaylist(uint(playlistID), &req); err != nil {
-		status := http.StatusInternalServerError
-		if err.Error() == "track not found" {
-			status = http.StatusNotFound
-		}
-		response.Error(ctx, status, err.Error())
+	entry, err := c.playlistService.AddTrackToPlaylist(uint(playlistID), &req, middleware.CurrentActor(ctx))
+	if err != nil {
+		writePlaylistError(ctx, err)
 		return
 	}
 
-	response.Success(ctx, http.StatusOK, gin.H{"message": "Track added to playlist successfully"})
+	response.Success(ctx, http.StatusCreated, entry)
 }
 
 // RemoveTrackFromPlaylist godoc
 // @Summary Удалить трек из плейлиста
-// @Description Удаляет трек из указанного плейлиста
+// @Description Удаляет из плейлиста все вхождения трека
 // @Tags Playlists
 // @Produce json
 // @Security BearerAuth
@@ -237,14 +241,151 @@ func (c *PlaylistController) RemoveTrackFromPlaylist(ctx *gin.Context) {
 		return
 	}
 
-	if err := c.playlistService.RemoveTrackFromPlaylist(uint(playlistID), uint(trackID)); err != nil {
-		status := http.StatusInternalServerError
-		if err.Error() == "track not found in playlist" {
-			status = http.StatusNotFound
-		}
-		response.Error(ctx, status, err.Error())
+	if err := c.playlistService.RemoveTrackFromPlaylist(uint(playlistID), uint(trackID), middleware.CurrentActor(ctx)); err != nil {
+		writePlaylistError(ctx, err)
 		return
 	}
 
 	response.Success(ctx, http.StatusOK, gin.H{"message": "Track removed from playlist successfully"})
 }
+
+// RemovePlaylistEntry godoc
+// @Summary Удалить запись из плейлиста
+// @Description Удаляет одно вхождение трека в плейлист по ID записи
+// @Tags Playlists
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "ID плейлиста"
+// @Param entryId path int true "ID записи"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /api/playlists/{id}/entries/{entryId} [delete]
+func (c *PlaylistController) RemovePlaylistEntry(ctx *gin.Context) {
+	playlistID, entryID, ok := parseEntryParams(ctx)
+	if !ok {
+		return
+	}
+
+	if err := c.playlistService.RemoveEntry(playlistID, entryID, middleware.CurrentActor(ctx)); err != nil {
+		writePlaylistError(ctx, err)
+		return
+	}
+
+	response.Success(ctx, http.StatusOK, gin.H{"message": "Entry removed from playlist successfully"})
+}
+
+// MovePlaylistEntry godoc
+// @Summary Переместить запись плейлиста
+// @Description Перемещает запись на новую позицию (с нуля), сдвигая остальные. Позиция за концом плейлиста означает последнюю
+// @Tags Playlists
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "ID плейлиста"
+// @Param entryId path int true "ID записи"
+// @Param request body model.MovePlaylistEntryRequest true "Новая позиция"
+// @Success 200 {object} model.PlaylistResponse
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /api/playlists/{id}/entries/{entryId} [patch]
+func (c *PlaylistController) MovePlaylistEntry(ctx *gin.Context) {
+	playlistID, entryID, ok := parseEntryParams(ctx)
+	if !ok {
+		return
+	}
+
+	var req model.MovePlaylistEntryRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		response.Error(ctx, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if err := c.playlistService.MoveEntry(playlistID, entryID, *req.Position, middleware.CurrentActor(ctx)); err != nil {
+		writePlaylistError(ctx, err)
+		return
+	}
+
+	c.respondPlaylist(ctx, playlistID)
+}
+
+// ReorderPlaylist godoc
+// @Summary Изменить порядок плейлиста
+// @Description Задает новый порядок плейлиста списком ID всех его записей
+// @Tags Playlists
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "ID плейлиста"
+// @Param request body model.ReorderPlaylistRequest true "ID записей в новом порядке"
+// @Success 200 {object} model.PlaylistResponse
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /api/playlists/{id}/entries [put]
+func (c *PlaylistController) ReorderPlaylist(ctx *gin.Context) {
+	playlistID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "Invalid playlist ID")
+		return
+	}
+
+	var req model.ReorderPlaylistRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		response.Error(ctx, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if err := c.playlistService.ReorderEntries(uint(playlistID), req.EntryIDs, middleware.CurrentActor(ctx)); err != nil {
+		writePlaylistError(ctx, err)
+		return
+	}
+
+	c.respondPlaylist(ctx, uint(playlistID))
+}
+
+func (c *PlaylistController) respondPlaylist(ctx *gin.Context, playlistID uint) {
+	playlist, err := c.playlistService.GetPlaylistByID(playlistID, middleware.CurrentActor(ctx))
+	if err != nil {
+		writePlaylistError(ctx, err)
+		return
+	}
+
+	response.Success(ctx, http.StatusOK, playlist)
+}
+
+func parseEntryParams(ctx *gin.Context) (uint, uint, bool) {
+	playlistID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "Invalid playlist ID")
+		return 0, 0, false
+	}
+
+	entryID, err := strconv.ParseUint(ctx.Param("entryId"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "Invalid entry ID")
+		return 0, 0, false
+	}
+
+	return uint(playlistID), uint(entryID), true
+}
+
+func writePlaylistError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrPlaylistNotFound),
+		errors.Is(err, service.ErrPlaylistEntryNotFound),
+		errors.Is(err, service.ErrTrackNotFound):
+		response.Error(ctx, http.StatusNotFound, err.Error())
+	case errors.Is(err, service.ErrForbidden):
+		response.Error(ctx, http.StatusForbidden, "Access denied")
+	case errors.Is(err, service.ErrInvalidPlaylistOrder):
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+	default:
+		response.Error(ctx, http.StatusInternalServerError, "Failed to process playlist")
+	}
+}
@@ -0,0 +1,152 @@
+package controller
+
+import (
+	"MusicService/internal/model"
+	"MusicService/internal/service"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const testPlaylistID uint = 10
+
+// newPlaylistRepositories возвращает треки и плейлист testPlaylistID владельца с двумя записями
+func newPlaylistRepositories() (*fakeTrackRepository, *fakePlaylistRepository) {
+	tracks := &fakeTrackRepository{tracks: map[uint]*model.Track{
+		100: {Model: gorm.Model{ID: 100}, Title: "First", UploadedBy: ownerID},
+		101: {Model: gorm.Model{ID: 101}, Title: "Second", UploadedBy: ownerID},
+		102: {Model: gorm.Model{ID: 102}, Title: "Third", UploadedBy: otherID},
+	}}
+	playlists := &fakePlaylistRepository{
+		playlists: map[uint]*model.Playlist{
+			testPlaylistID: {
+				Model:  gorm.Model{ID: testPlaylistID},
+				Name:   "Road trip",
+				UserID: ownerID,
+				Entries: []model.PlaylistEntry{
+					{ID: 1, PlaylistID: testPlaylistID, Position: 0, TrackID: 100, AddedBy: ownerID},
+					{ID: 2, PlaylistID: testPlaylistID, Position: 1, TrackID: 101, AddedBy: ownerID},
+				},
+			},
+		},
+		nextEntry: 2,
+	}
+	return tracks, playlists
+}
+
+func newPlaylistFixture(user testUser) (*fakePlaylistRepository, *gin.Engine) {
+	tracks, playlists := newPlaylistRepositories()
+
+	controller := NewPlaylistController(service.NewPlaylistService(playlists, tracks))
+	router := newTestRouter(user)
+	router.GET("/playlists/:id", controller.GetPlaylistByID)
+	router.PUT("/playlists/:id", controller.UpdatePlaylist)
+	router.DELETE("/playlists/:id", controller.DeletePlaylist)
+	router.POST("/playlists/:id/tracks", controller.AddTrackToPlaylist)
+	router.DELETE("/playlists/:id/tracks/:trackId", controller.RemoveTrackFromPlaylist)
+	router.PUT("/playlists/:id/entries", controller.ReorderPlaylist)
+	router.PATCH("/playlists/:id/entries/:entryId", controller.MovePlaylistEntry)
+	router.DELETE("/playlists/:id/entries/:entryId", controller.RemovePlaylistEntry)
+
+	return playlists, router
+}
+
+func entryIDs(playlist *model.Playlist) []uint {
+	ids := make([]uint, 0, len(playlist.Entries))
+	for _, entry := range playlist.Entries {
+		ids = append(ids, entry.ID)
+	}
+	return ids
+}
+
+func TestPlaylistPolicy(t *testing.T) {
+	endpoints := []struct {
+		name     string
+		method   string
+		target   string
+		body     any
+		success  int
+		mutation bool
+		// applied сообщает, изменился ли плейлист после запроса
+		applied func(playlist *model.Playlist, exists bool) bool
+	}{
+		{
+			name: "view", method: http.MethodGet, target: "/playlists/10", success: http.StatusOK,
+		},
+		{
+			name: "update", method: http.MethodPut, target: "/playlists/10",
+			body: model.PlaylistRequest{Name: "Renamed"}, success: http.StatusOK, mutation: true,
+			applied: func(p *model.Playlist, _ bool) bool { return p.Name == "Renamed" },
+		},
+		{
+			name: "delete", method: http.MethodDelete, target: "/playlists/10", success: http.StatusOK, mutation: true,
+			applied: func(_ *model.Playlist, exists bool) bool { return !exists },
+		},
+		{
+			name: "add track", method: http.MethodPost, target: "/playlists/10/tracks",
+			body: model.AddTrackToPlaylistRequest{TrackID: 102}, success: http.StatusCreated, mutation: true,
+			applied: func(p *model.Playlist, _ bool) bool { return len(p.Entries) == 3 },
+		},
+		{
+			name: "remove track", method: http.MethodDelete, target: "/playlists/10/tracks/100", success: http.StatusOK, mutation: true,
+			applied: func(p *model.Playlist, _ bool) bool { return slices.Equal(entryIDs(p), []uint{2}) },
+		},
+		{
+			name: "remove entry", method: http.MethodDelete, target: "/playlists/10/entries/2", success: http.StatusOK, mutation: true,
+			applied: func(p *model.Playlist, _ bool) bool { return slices.Equal(entryIDs(p), []uint{1}) },
+		},
+		{
+			name: "move entry", method: http.MethodPatch, target: "/playlists/10/entries/2",
+			body: map[string]int{"position": 0}, success: http.StatusOK, mutation: true,
+			applied: func(p *model.Playlist, _ bool) bool { return slices.Equal(entryIDs(p), []uint{2, 1}) },
+		},
+		{
+			name: "reorder", method: http.MethodPut, target: "/playlists/10/entries",
+			body: model.ReorderPlaylistRequest{EntryIDs: []uint{2, 1}}, success: http.StatusOK, mutation: true,
+			applied: func(p *model.Playlist, _ bool) bool { return slices.Equal(entryIDs(p), []uint{2, 1}) },
+		},
+	}
+
+	for _, endpoint := range endpoints {
+		for _, user := range []testUser{owner, other, admin} {
+			allowed := !endpoint.mutation || user != other
+
+			t.Run(endpoint.name+"/"+user.name, func(t *testing.T) {
+				playlists, router := newPlaylistFixture(user)
+
+				rec := serve(t, router, endpoint.method, endpoint.target, endpoint.body)
+
+				want := endpoint.success
+				if !allowed {
+					want = http.StatusForbidden
+				}
+				if rec.Code != want {
+					t.Fatalf("status = %d, want %d: %s", rec.Code, want, rec.Body.String())
+				}
+
+				if endpoint.applied == nil {
+					return
+				}
+				playlist, exists := playlists.playlists[testPlaylistID]
+				if playlist == nil {
+					playlist = &model.Playlist{}
+				}
+				if got := endpoint.applied(playlist, exists); got != allowed {
+					t.Errorf("change applied = %v, want %v", got, allowed)
+				}
+			})
+		}
+	}
+}
+
+func TestPlaylistNotFound(t *testing.T) {
+	_, router := newPlaylistFixture(owner)
+
+	rec := serve(t, router, http.MethodDelete, "/playlists/99", nil)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
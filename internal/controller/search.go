@@ -0,0 +1,56 @@
+package controller
+
+import (
+	"MusicService/internal/middleware"
+	"MusicService/internal/model"
+	"MusicService/internal/service"
+	"MusicService/pkg/response"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SearchController struct {
+	searchService service.SearchService
+}
+
+func NewSearchController(searchService service.SearchService) *SearchController {
+	return &SearchController{searchService: searchService}
+}
+
+// Suggest godoc
+// @Summary Подсказки поисковой строки
+// @Description Возвращает короткие подсказки по префиксу запроса: исполнителей, треки, альбомы, плейлисты текущего
+// @Description пользователя и жанры, не больше limit каждого типа. Рассчитан на вызов при каждом нажатии клавиши:
+// @Description подсказки каталога кэшируются, а при превышении времени ответа возвращается то, что успело найтись.
+// @Description Плейлисты предлагаются только при входе через сессию или по API-ключу с областью playlists
+// @Tags Search
+// @Produce json
+// @Security BearerAuth
+// @Param q query string true "Начало запроса"
+// @Param limit query int false "Подсказок каждого типа (1-10, по умолчанию 5)"
+// @Success 200 {object} model.SuggestResponse
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /api/search/suggest [get]
+func (c *SearchController) Suggest(ctx *gin.Context) {
+	var params model.SuggestParams
+	if err := ctx.ShouldBindQuery(&params); err != nil {
+		response.Error(ctx, http.StatusBadRequest, "Invalid search parameters")
+		return
+	}
+
+	var userID uint
+	if middleware.HasScope(ctx, model.APIKeyScopePlaylists) {
+		userID = ctx.GetUint("userID")
+	}
+
+	suggestions, err := c.searchService.Suggest(ctx.Request.Context(), userID, params)
+	if err != nil {
+		response.Error(ctx, http.StatusInternalServerError, "Failed to get suggestions")
+		return
+	}
+
+	response.Success(ctx, http.StatusOK, suggestions)
+}
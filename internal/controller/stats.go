@@ -25,19 +25,29 @@ func NewStatsController(statsService service.StatsService) *StatsController {
 // @Accept  json
 // @Produce  json
 // @Security BearerAuth
-// @Success 200 {object} model.TrackPlayStats
+// @Param page query int false "Номер страницы (с 1)"
+// @Param per_page query int false "Записей на странице (по умолчанию 50, не больше 200)"
+// @Param cursor query string false "Курсор next_cursor из предыдущего ответа; заменяет page"
+// @Param sort query string false "Сортировка: play_count, title, artist; \"-\" в начале - по убыванию (по умолчанию -play_count)"
+// @Success 200 {object} response.PaginatedResponse{data=[]model.TrackPlayStats}
+// @Failure 400 {object} response.Response
 // @Failure 500 {object} response.Response
 // @Router /api/stats/track-plays [get]
 func (c *StatsController) GetTrackPlaysCount(ctx *gin.Context) {
 	userID := ctx.MustGet("userID").(uint)
 
-	stats, err := c.statsService.GetTrackPlaysStats(userID)
+	page, ok := bindPage(ctx)
+	if !ok {
+		return
+	}
+
+	stats, err := c.statsService.GetTrackPlaysStats(userID, page)
 	if err != nil {
-		response.Error(ctx, http.StatusInternalServerError, "Failed to get stats")
+		writePageError(ctx, err, "Failed to get stats")
 		return
 	}
 
-	response.Success(ctx, http.StatusOK, stats)
+	writePage(ctx, stats)
 }
 
 // GetArtistPlaysCount godoc
@@ -47,19 +57,29 @@ func (c *StatsController) GetTrackPlaysCount(ctx *gin.Context) {
 // @Accept  json
 // @Produce  json
 // @Security BearerAuth
-// @Success 200 {object} model.ArtistPlayStats
+// @Param page query int false "Номер страницы (с 1)"
+// @Param per_page query int false "Записей на странице (по умолчанию 50, не больше 200)"
+// @Param cursor query string false "Курсор next_cursor из предыдущего ответа; заменяет page"
+// @Param sort query string false "Сортировка: play_count, artist; \"-\" в начале - по убыванию (по умолчанию -play_count)"
+// @Success 200 {object} response.PaginatedResponse{data=[]model.ArtistPlayStats}
+// @Failure 400 {object} response.Response
 // @Failure 500 {object} response.Response
 // @Router /api/stats/artist-plays [get]
 func (c *StatsController) GetArtistPlaysCount(ctx *gin.Context) {
 	userID := ctx.MustGet("userID").(uint)
 
-	stats, err := c.statsService.GetArtistPlaysStats(userID)
+	page, ok := bindPage(ctx)
+	if !ok {
+		return
+	}
+
+	stats, err := c.statsService.GetArtistPlaysStats(userID, page)
 	if err != nil {
-		response.Error(ctx, http.StatusInternalServerError, "Failed to get stats")
+		writePageError(ctx, err, "Failed to get stats")
 		return
 	}
 
-	response.Success(ctx, http.StatusOK, stats)
+	writePage(ctx, stats)
 }
 
 // GetRecentTracks godoc
@@ -0,0 +1,176 @@
+package controller
+
+import (
+	"MusicService/internal/service"
+	"MusicService/pkg/httprange"
+	"MusicService/pkg/response"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// resolveRanges разбирает заголовки Range и If-Range запроса.
+// Возвращает nil, если ресурс нужно отдать целиком, и false, если ответ уже отправлен (416).
+func resolveRanges(ctx *gin.Context, stream *service.TrackStream) ([]httprange.Range, bool) {
+	header := ctx.GetHeader("Range")
+	if header == "" || !stream.Seekable() {
+		return nil, true
+	}
+
+	if !httprange.IfRangeMatches(ctx.GetHeader("If-Range"), stream.ETag, stream.LastModified) {
+		return nil, true
+	}
+
+	ranges, err := httprange.Parse(header, stream.Size)
+	switch err {
+	case nil:
+	case httprange.ErrRangeNotSatisfiable:
+		ctx.Header("Content-Range", fmt.Sprintf("bytes */%d", stream.Size))
+		response.Error(ctx, http.StatusRequestedRangeNotSatisfiable, "Requested range not satisfiable")
+		return nil, false
+	default:
+		// Некорректный заголовок Range игнорируется (RFC 7233, раздел 3.1)
+		return nil, true
+	}
+
+	// Защита от запросов с множеством перекрывающихся диапазонов: отдаем файл целиком
+	if httprange.SumLength(ranges) > stream.Size {
+		return nil, true
+	}
+
+	return ranges, true
+}
+
+// serveStream отдает файл целиком (200), одним диапазоном или multipart/byteranges (206)
+func serveStream(ctx *gin.Context, stream *service.TrackStream, ranges []httprange.Range) {
+	if !stream.Seekable() {
+		serveLiveStream(ctx, stream)
+		return
+	}
+
+	header := ctx.Writer.Header()
+	header.Set("Accept-Ranges", "bytes")
+	if stream.ETag != "" {
+		header.Set("ETag", stream.ETag)
+	}
+	if !stream.LastModified.IsZero() {
+		header.Set("Last-Modified", stream.LastModified.UTC().Format(http.TimeFormat))
+	}
+
+	headOnly := ctx.Request.Method == http.MethodHead
+
+	switch len(ranges) {
+	case 0:
+		header.Set("Content-Type", stream.ContentType)
+		header.Set("Content-Length", strconv.FormatInt(stream.Size, 10))
+		ctx.Status(http.StatusOK)
+		if headOnly {
+			return
+		}
+		copyRange(ctx, stream, httprange.Range{Start: 0, Length: stream.Size}, ctx.Writer)
+
+	case 1:
+		r := ranges[0]
+		header.Set("Content-Type", stream.ContentType)
+		header.Set("Content-Range", r.ContentRange(stream.Size))
+		header.Set("Content-Length", strconv.FormatInt(r.Length, 10))
+		ctx.Status(http.StatusPartialContent)
+		if headOnly {
+			return
+		}
+		copyRange(ctx, stream, r, ctx.Writer)
+
+	default:
+		mw := multipart.NewWriter(ctx.Writer)
+		header.Set("Content-Type", "multipart/byteranges; boundary="+mw.Boundary())
+		ctx.Status(http.StatusPartialContent)
+		if headOnly {
+			return
+		}
+
+		for _, r := range ranges {
+			part, err := mw.CreatePart(textproto.MIMEHeader{
+				"Content-Type":  {stream.ContentType},
+				"Content-Range": {r.ContentRange(stream.Size)},
+			})
+			if err != nil {
+				return
+			}
+			if !copyRange(ctx, stream, r, part) {
+				return
+			}
+		}
+		_ = mw.Close()
+	}
+}
+
+// serveLiveStream отдает поток неизвестной длины (перекодирование на лету) без поддержки диапазонов
+func serveLiveStream(ctx *gin.Context, stream *service.TrackStream) {
+	header := ctx.Writer.Header()
+	header.Set("Accept-Ranges", "none")
+	header.Set("Content-Type", stream.ContentType)
+	ctx.Status(http.StatusOK)
+	if ctx.Request.Method == http.MethodHead {
+		return
+	}
+
+	reader, err := stream.Open(0, -1)
+	if err != nil {
+		log.Printf("Failed to open live stream: %v", err)
+		response.Error(ctx, http.StatusInternalServerError, "Failed to stream track")
+		return
+	}
+	defer func() {
+		if err := reader.Close(); err != nil {
+			log.Printf("Live stream finished with error: %v", err)
+		}
+	}()
+
+	if _, err := io.Copy(ctx.Writer, reader); err != nil && ctx.Request.Context().Err() == nil {
+		log.Printf("Failed to write live stream: %v", err)
+	}
+}
+
+func copyRange(ctx *gin.Context, stream *service.TrackStream, r httprange.Range, w io.Writer) bool {
+	if r.Length == 0 {
+		return true
+	}
+
+	reader, err := stream.Open(r.Start, r.Length)
+	if err != nil {
+		log.Printf("Failed to open range %d-%d: %v", r.Start, r.End(), err)
+		return false
+	}
+	defer reader.Close()
+
+	if _, err := io.CopyN(w, reader, r.Length); err != nil {
+		if ctx.Request.Context().Err() == nil {
+			log.Printf("Failed to stream range %d-%d: %v", r.Start, r.End(), err)
+		}
+		return false
+	}
+
+	return true
+}
+
+// probeRangeLength - размер пробного запроса (Safari запрашивает bytes=0-1 перед воспроизведением)
+const probeRangeLength = 2
+
+// isPlaybackStart определяет, является ли запрос началом воспроизведения.
+// Плееры с поддержкой перемотки запрашивают файл частями, поэтому прослушивание
+// засчитывается только для полного запроса или диапазона, начинающегося с нулевого байта.
+func isPlaybackStart(ctx *gin.Context, ranges []httprange.Range) bool {
+	if ctx.Request.Method == http.MethodHead {
+		return false
+	}
+	if len(ranges) == 0 {
+		return true
+	}
+	return ranges[0].Start == 0 && ranges[0].Length > probeRangeLength
+}
@@ -0,0 +1,255 @@
+package controller
+
+import (
+	"MusicService/internal/model"
+	"MusicService/internal/service"
+	"MusicService/internal/storage/storagetest"
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// fakeStatsService считает записанные прослушивания; нереализованные методы паникуют
+type fakeStatsService struct {
+	service.StatsService
+	plays int
+}
+
+func (s *fakeStatsService) RecordTrackPlay(userID, trackID uint) error {
+	s.plays++
+	return nil
+}
+
+type streamFixture struct {
+	data         []byte
+	etag         string
+	lastModified time.Time
+	stats        *fakeStatsService
+	controller   *TrackController
+}
+
+func newStreamFixture(t *testing.T) *streamFixture {
+	t.Helper()
+
+	data := make([]byte, 1000)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+
+	store := storagetest.NewMemory(t)
+	store.Put(testBucket, "tracks/1.mp3", data, "audio/mpeg")
+	info, err := store.StatObject(testBucket, "tracks/1.mp3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := md5.Sum(data)
+
+	tracks := &fakeTrackRepository{tracks: map[uint]*model.Track{
+		1: {Model: gorm.Model{ID: 1}, Title: "Song", FilePath: "tracks/1.mp3", ContentType: "audio/mpeg", UploadedBy: ownerID},
+	}}
+	stats := &fakeStatsService{}
+
+	return &streamFixture{
+		data:         data,
+		etag:         `"` + hex.EncodeToString(sum[:]) + `"`,
+		lastModified: info.LastModified,
+		stats:        stats,
+		controller:   NewTrackController(service.NewTrackService(tracks, store, nil, testBucket), stats),
+	}
+}
+
+func (f *streamFixture) serve(method string, headers map[string]string) *httptest.ResponseRecorder {
+	router := newTestRouter(owner)
+	router.GET("/tracks/stream/:id", f.controller.StreamTrack)
+	router.HEAD("/tracks/stream/:id", f.controller.StreamTrack)
+
+	req := httptest.NewRequest(method, "/tracks/stream/1", nil)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestStreamTrackRanges(t *testing.T) {
+	fixture := newStreamFixture(t)
+	data := fixture.data
+	modified := fixture.lastModified.UTC().Format(http.TimeFormat)
+	stale := fixture.lastModified.Add(-time.Hour).UTC().Format(http.TimeFormat)
+
+	tests := []struct {
+		name         string
+		method       string
+		headers      map[string]string
+		status       int
+		contentRange string
+		body         []byte
+		played       bool
+	}{
+		{name: "full", status: http.StatusOK, body: data, played: true},
+		{name: "head", method: http.MethodHead, status: http.StatusOK, body: []byte{}},
+		{
+			name:         "probe",
+			headers:      map[string]string{"Range": "bytes=0-1"},
+			status:       http.StatusPartialContent,
+			contentRange: "bytes 0-1/1000",
+			body:         data[:2],
+		},
+		{
+			name:         "open-ended from start",
+			headers:      map[string]string{"Range": "bytes=0-"},
+			status:       http.StatusPartialContent,
+			contentRange: "bytes 0-999/1000",
+			body:         data,
+			played:       true,
+		},
+		{
+			name:         "open-ended seek",
+			headers:      map[string]string{"Range": "bytes=600-"},
+			status:       http.StatusPartialContent,
+			contentRange: "bytes 600-999/1000",
+			body:         data[600:],
+		},
+		{
+			name:         "suffix",
+			headers:      map[string]string{"Range": "bytes=-100"},
+			status:       http.StatusPartialContent,
+			contentRange: "bytes 900-999/1000",
+			body:         data[900:],
+		},
+		{
+			name:    "overlapping ranges served whole",
+			headers: map[string]string{"Range": "bytes=0-599,500-999"},
+			status:  http.StatusOK,
+			body:    data,
+			played:  true,
+		},
+		{
+			name:         "unsatisfiable",
+			headers:      map[string]string{"Range": "bytes=1000-"},
+			status:       http.StatusRequestedRangeNotSatisfiable,
+			contentRange: "bytes */1000",
+		},
+		{name: "invalid range ignored", headers: map[string]string{"Range": "bytes=500-100"}, status: http.StatusOK, body: data, played: true},
+		{
+			name:         "if-range etag match",
+			headers:      map[string]string{"Range": "bytes=600-", "If-Range": fixture.etag},
+			status:       http.StatusPartialContent,
+			contentRange: "bytes 600-999/1000",
+			body:         data[600:],
+		},
+		{
+			name:    "if-range etag changed",
+			headers: map[string]string{"Range": "bytes=600-", "If-Range": `"stale"`},
+			status:  http.StatusOK,
+			body:    data,
+			played:  true,
+		},
+		{
+			name:         "if-range date match",
+			headers:      map[string]string{"Range": "bytes=600-", "If-Range": modified},
+			status:       http.StatusPartialContent,
+			contentRange: "bytes 600-999/1000",
+			body:         data[600:],
+		},
+		{
+			name:    "if-range date changed",
+			headers: map[string]string{"Range": "bytes=600-", "If-Range": stale},
+			status:  http.StatusOK,
+			body:    data,
+			played:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			fixture.stats.plays = 0
+
+			rec := fixture.serve(method, tt.headers)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body.String())
+			}
+			if got := rec.Header().Get("Content-Range"); got != tt.contentRange {
+				t.Errorf("Content-Range = %q, want %q", got, tt.contentRange)
+			}
+			if tt.body != nil && !bytes.Equal(rec.Body.Bytes(), tt.body) {
+				t.Errorf("body length = %d, want %d", rec.Body.Len(), len(tt.body))
+			}
+			if tt.body != nil && method == http.MethodGet {
+				if got := rec.Header().Get("Content-Length"); got != strconv.Itoa(len(tt.body)) {
+					t.Errorf("Content-Length = %q, want %d", got, len(tt.body))
+				}
+			}
+			if played := fixture.stats.plays > 0; played != tt.played {
+				t.Errorf("play recorded = %v, want %v", played, tt.played)
+			}
+		})
+	}
+}
+
+func TestStreamTrackMultipartRanges(t *testing.T) {
+	fixture := newStreamFixture(t)
+
+	rec := fixture.serve(http.MethodGet, map[string]string{"Range": "bytes=0-9, 500-549, -20"})
+	if rec.Code != http.StatusPartialContent {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusPartialContent, rec.Body.String())
+	}
+
+	mediaType, params, err := mime.ParseMediaType(rec.Header().Get("Content-Type"))
+	if err != nil || mediaType != "multipart/byteranges" {
+		t.Fatalf("Content-Type = %q", rec.Header().Get("Content-Type"))
+	}
+
+	want := []struct {
+		contentRange string
+		body         []byte
+	}{
+		{contentRange: "bytes 0-9/1000", body: fixture.data[:10]},
+		{contentRange: "bytes 500-549/1000", body: fixture.data[500:550]},
+		{contentRange: "bytes 980-999/1000", body: fixture.data[980:]},
+	}
+
+	reader := multipart.NewReader(rec.Body, params["boundary"])
+	for i, w := range want {
+		part, err := reader.NextPart()
+		if err != nil {
+			t.Fatalf("part %d: %v", i, err)
+		}
+		if got := part.Header.Get("Content-Range"); got != w.contentRange {
+			t.Errorf("part %d Content-Range = %q, want %q", i, got, w.contentRange)
+		}
+		if got := part.Header.Get("Content-Type"); got != "audio/mpeg" {
+			t.Errorf("part %d Content-Type = %q", i, got)
+		}
+		body, err := io.ReadAll(part)
+		if err != nil {
+			t.Fatalf("part %d: %v", i, err)
+		}
+		if !bytes.Equal(body, w.body) {
+			t.Errorf("part %d body length = %d, want %d", i, len(body), len(w.body))
+		}
+	}
+	if _, err := reader.NextPart(); err != io.EOF {
+		t.Errorf("extra part after %d ranges: %v", len(want), err)
+	}
+
+	// Первый диапазон начинается с нуля и длиннее пробного запроса - это начало воспроизведения
+	if fixture.stats.plays != 1 {
+		t.Errorf("plays = %d, want 1", fixture.stats.plays)
+	}
+}
@@ -0,0 +1,591 @@
+package controller
+
+import (
+	"MusicService/internal/middleware"
+	"MusicService/internal/model"
+	"MusicService/internal/service"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SubsonicController реализует подмножество Subsonic API 1.16 (http://www.subsonic.org/pages/api.jsp)
+// поверх сервисов треков, плейлистов и статистики. Ответы отдаются в XML или JSON в зависимости
+// от параметра f; ошибки передаются в теле ответа с кодом HTTP 200, как того требует протокол
+type SubsonicController struct {
+	subsonicService service.SubsonicService
+	trackService    service.TrackService
+	playlistService service.PlaylistService
+	statsService    service.StatsService
+}
+
+func NewSubsonicController(subsonicService service.SubsonicService, trackService service.TrackService,
+	playlistService service.PlaylistService, statsService service.StatsService) *SubsonicController {
+	return &SubsonicController{
+		subsonicService: subsonicService,
+		trackService:    trackService,
+		playlistService: playlistService,
+		statsService:    statsService,
+	}
+}
+
+// SubsonicRoute регистрирует метод API. Клиенты обращаются к методам как с суффиксом .view,
+// так и без него, передавая параметры в query или в теле формы (расширение OpenSubsonic formPost)
+func SubsonicRoute(group gin.IRoutes, name string, handler gin.HandlerFunc) {
+	for _, path := range []string{"/" + name, "/" + name + ".view"} {
+		group.GET(path, handler)
+		group.POST(path, handler)
+	}
+}
+
+// Authenticate проверяет параметры u и p либо u, t и s
+func (c *SubsonicController) Authenticate(ctx *gin.Context) {
+	username := ctx.Request.FormValue("u")
+	password := ctx.Request.FormValue("p")
+	token := ctx.Request.FormValue("t")
+	salt := ctx.Request.FormValue("s")
+
+	if username == "" || (password == "" && (token == "" || salt == "")) {
+		subsonicError(ctx, model.SubsonicErrMissingParameter, "Required parameter is missing")
+		return
+	}
+
+	user, err := c.subsonicService.Authenticate(username, password, token, salt)
+	if err != nil {
+		var locked *service.LoginLockedError
+		switch {
+		case errors.Is(err, service.ErrSubsonicWrongCredentials):
+			subsonicError(ctx, model.SubsonicErrWrongCredentials, err.Error())
+		case errors.As(err, &locked):
+			// В протоколе нет кода для блокировки; Retry-After подскажет клиенту, когда повторить
+			ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(locked.RetryAfter.Seconds()))))
+			subsonicError(ctx, model.SubsonicErrWrongCredentials, err.Error())
+		default:
+			log.Printf("Subsonic authentication failed: %v", err)
+			subsonicError(ctx, model.SubsonicErrGeneric, "Authentication failed")
+		}
+		return
+	}
+
+	ctx.Set("userID", user.ID)
+	ctx.Set("username", user.Username)
+	ctx.Set("role", user.Role)
+	ctx.Next()
+}
+
+func (c *SubsonicController) Ping(ctx *gin.Context) {
+	subsonicRespond(ctx, &model.SubsonicResponse{})
+}
+
+func (c *SubsonicController) GetLicense(ctx *gin.Context) {
+	subsonicRespond(ctx, &model.SubsonicResponse{License: &model.SubsonicLicense{Valid: true}})
+}
+
+func (c *SubsonicController) GetOpenSubsonicExtensions(ctx *gin.Context) {
+	subsonicRespond(ctx, &model.SubsonicResponse{
+		OpenSubsonicExtensions: []model.SubsonicExtension{{Name: "formPost", Versions: []int{1}}},
+	})
+}
+
+// GetMusicFolders возвращает единственную папку: библиотека не разделена по каталогам
+func (c *SubsonicController) GetMusicFolders(ctx *gin.Context) {
+	subsonicRespond(ctx, &model.SubsonicResponse{
+		MusicFolders: &model.SubsonicMusicFolders{Folders: []model.SubsonicMusicFolder{{ID: 1, Name: "Music"}}},
+	})
+}
+
+func (c *SubsonicController) GetArtists(ctx *gin.Context) {
+	artists, err := c.subsonicService.GetArtists(ctx.GetUint("userID"))
+	if err != nil {
+		subsonicFail(ctx, err)
+		return
+	}
+
+	subsonicRespond(ctx, &model.SubsonicResponse{Artists: artists})
+}
+
+func (c *SubsonicController) GetArtist(ctx *gin.Context) {
+	id, ok := requireSubsonicParam(ctx, "id")
+	if !ok {
+		return
+	}
+
+	artist, err := c.subsonicService.GetArtist(ctx.GetUint("userID"), id)
+	if err != nil {
+		subsonicFail(ctx, err)
+		return
+	}
+
+	subsonicRespond(ctx, &model.SubsonicResponse{Artist: artist})
+}
+
+func (c *SubsonicController) GetAlbum(ctx *gin.Context) {
+	id, ok := requireSubsonicParam(ctx, "id")
+	if !ok {
+		return
+	}
+
+	album, err := c.subsonicService.GetAlbum(ctx.GetUint("userID"), id)
+	if err != nil {
+		subsonicFail(ctx, err)
+		return
+	}
+
+	subsonicRespond(ctx, &model.SubsonicResponse{Album: album})
+}
+
+func (c *SubsonicController) GetSong(ctx *gin.Context) {
+	id, ok := requireSubsonicID(ctx, "id")
+	if !ok {
+		return
+	}
+
+	songs, err := c.subsonicService.GetSongs(ctx.GetUint("userID"), []uint{id})
+	if err != nil {
+		subsonicFail(ctx, err)
+		return
+	}
+
+	subsonicRespond(ctx, &model.SubsonicResponse{Song: &songs[0]})
+}
+
+// Stream отдает трек, перекодируя его при необходимости. Формат в запросе - лишь пожелание клиента,
+// поэтому при неподдерживаемом формате или недоступном перекодировании отдается исходный файл
+func (c *SubsonicController) Stream(ctx *gin.Context) {
+	id, ok := requireSubsonicID(ctx, "id")
+	if !ok {
+		return
+	}
+
+	params := model.StreamParams{Format: ctx.Request.FormValue("format")}
+	params.MaxBitRate, _ = strconv.Atoi(ctx.Request.FormValue("maxBitRate"))
+
+	stream, err := c.trackService.StreamTrack(id, params)
+	if errors.Is(err, service.ErrUnsupportedTranscodeFormat) || errors.Is(err, service.ErrTranscodingUnavailable) {
+		stream, err = c.trackService.StreamTrack(id, model.StreamParams{Format: "raw"})
+	}
+	if err != nil {
+		subsonicError(ctx, model.SubsonicErrNotFound, "Song not found")
+		return
+	}
+
+	ranges, ok := resolveRanges(ctx, stream)
+	if !ok {
+		return
+	}
+	serveStream(ctx, stream, ranges)
+}
+
+func (c *SubsonicController) Download(ctx *gin.Context) {
+	id, ok := requireSubsonicID(ctx, "id")
+	if !ok {
+		return
+	}
+
+	stream, err := c.trackService.StreamTrack(id, model.StreamParams{Format: "raw"})
+	if err != nil {
+		subsonicError(ctx, model.SubsonicErrNotFound, "Song not found")
+		return
+	}
+
+	ranges, ok := resolveRanges(ctx, stream)
+	if !ok {
+		return
+	}
+	serveStream(ctx, stream, ranges)
+}
+
+// GetCoverArt отдает обложку трека. Параметр size игнорируется: изображения хранятся в исходном размере
+func (c *SubsonicController) GetCoverArt(ctx *gin.Context) {
+	id, ok := requireSubsonicID(ctx, "id")
+	if !ok {
+		return
+	}
+
+	reader, contentType, err := c.trackService.GetTrackImage(id)
+	if err != nil {
+		subsonicError(ctx, model.SubsonicErrNotFound, "Cover art not found")
+		return
+	}
+	defer reader.Close()
+
+	ctx.DataFromReader(http.StatusOK, -1, contentType, reader, nil)
+}
+
+func (c *SubsonicController) Search3(ctx *gin.Context) {
+	var params model.SubsonicSearchParams
+	if err := ctx.ShouldBind(&params); err != nil {
+		subsonicError(ctx, model.SubsonicErrMissingParameter, "Invalid search parameters")
+		return
+	}
+
+	result, err := c.subsonicService.Search(ctx.GetUint("userID"), params)
+	if err != nil {
+		subsonicFail(ctx, err)
+		return
+	}
+
+	subsonicRespond(ctx, &model.SubsonicResponse{SearchResult3: result})
+}
+
+func (c *SubsonicController) GetPlaylists(ctx *gin.Context) {
+	playlists, err := c.playlistService.GetUserPlaylists(ctx.GetUint("userID"))
+	if err != nil {
+		subsonicFail(ctx, err)
+		return
+	}
+
+	result := &model.SubsonicPlaylists{}
+	for i := range playlists {
+		result.Playlists = append(result.Playlists, newSubsonicPlaylist(ctx, &playlists[i]))
+	}
+
+	subsonicRespond(ctx, &model.SubsonicResponse{Playlists: result})
+}
+
+func (c *SubsonicController) GetPlaylist(ctx *gin.Context) {
+	id, ok := requireSubsonicID(ctx, "id")
+	if !ok {
+		return
+	}
+
+	playlist, ok := c.findPlaylist(ctx, id)
+	if !ok {
+		return
+	}
+
+	c.respondPlaylist(ctx, playlist)
+}
+
+// CreatePlaylist создает плейлист или, если передан playlistId, заменяет его треки
+func (c *SubsonicController) CreatePlaylist(ctx *gin.Context) {
+	songIDs, ok := subsonicIDs(ctx, "songId")
+	if !ok {
+		return
+	}
+	name := ctx.Request.FormValue("name")
+
+	var playlistID uint
+	if ctx.Request.FormValue("playlistId") != "" {
+		id, ok := requireSubsonicID(ctx, "playlistId")
+		if !ok {
+			return
+		}
+		playlist, ok := c.findPlaylist(ctx, id)
+		if !ok {
+			return
+		}
+
+		if name != "" {
+			req := &model.PlaylistRequest{Name: name, Description: playlist.Description}
+			if _, err := c.playlistService.UpdatePlaylist(id, req, middleware.CurrentActor(ctx)); err != nil {
+				subsonicFail(ctx, err)
+				return
+			}
+		}
+		for _, entry := range playlist.Entries {
+			if err := c.playlistService.RemoveEntry(id, entry.ID, middleware.CurrentActor(ctx)); err != nil {
+				subsonicFail(ctx, err)
+				return
+			}
+		}
+		playlistID = id
+	} else {
+		if name == "" {
+			subsonicError(ctx, model.SubsonicErrMissingParameter, "Required parameter is missing: name")
+			return
+		}
+		playlist, err := c.playlistService.CreatePlaylist(&model.PlaylistRequest{Name: name}, ctx.GetUint("userID"))
+		if err != nil {
+			subsonicFail(ctx, err)
+			return
+		}
+		playlistID = playlist.ID
+	}
+
+	if !c.addPlaylistTracks(ctx, playlistID, songIDs) {
+		return
+	}
+
+	playlist, err := c.playlistService.GetPlaylistByID(playlistID, middleware.CurrentActor(ctx))
+	if err != nil {
+		subsonicFail(ctx, err)
+		return
+	}
+	c.respondPlaylist(ctx, playlist)
+}
+
+func (c *SubsonicController) UpdatePlaylist(ctx *gin.Context) {
+	id, ok := requireSubsonicID(ctx, "playlistId")
+	if !ok {
+		return
+	}
+	songIDs, ok := subsonicIDs(ctx, "songIdToAdd")
+	if !ok {
+		return
+	}
+
+	playlist, ok := c.findPlaylist(ctx, id)
+	if !ok {
+		return
+	}
+
+	form := ctx.Request.Form
+	if form.Has("name") || form.Has("comment") {
+		req := &model.PlaylistRequest{Name: playlist.Name, Description: playlist.Description}
+		if form.Has("name") {
+			req.Name = form.Get("name")
+		}
+		if form.Has("comment") {
+			req.Description = form.Get("comment")
+		}
+		if _, err := c.playlistService.UpdatePlaylist(id, req, middleware.CurrentActor(ctx)); err != nil {
+			subsonicFail(ctx, err)
+			return
+		}
+	}
+
+	// Индексы относятся к состоянию плейлиста до изменения
+	for _, value := range form["songIndexToRemove"] {
+		index, err := strconv.Atoi(value)
+		if err != nil || index < 0 || index >= len(playlist.Entries) {
+			subsonicError(ctx, model.SubsonicErrNotFound, "Playlist entry not found")
+			return
+		}
+		if err := c.playlistService.RemoveEntry(id, playlist.Entries[index].ID, middleware.CurrentActor(ctx)); err != nil {
+			subsonicFail(ctx, err)
+			return
+		}
+	}
+
+	if !c.addPlaylistTracks(ctx, id, songIDs) {
+		return
+	}
+
+	subsonicRespond(ctx, &model.SubsonicResponse{})
+}
+
+func (c *SubsonicController) DeletePlaylist(ctx *gin.Context) {
+	id, ok := requireSubsonicID(ctx, "id")
+	if !ok {
+		return
+	}
+
+	if err := c.playlistService.DeletePlaylist(id, middleware.CurrentActor(ctx)); err != nil {
+		subsonicFail(ctx, err)
+		return
+	}
+
+	subsonicRespond(ctx, &model.SubsonicResponse{})
+}
+
+// Scrobble записывает прослушивания. При submission=false клиент лишь сообщает о текущем треке,
+// что в статистике не учитывается
+func (c *SubsonicController) Scrobble(ctx *gin.Context) {
+	ids, ok := subsonicIDs(ctx, "id")
+	if !ok {
+		return
+	}
+	if len(ids) == 0 {
+		subsonicError(ctx, model.SubsonicErrMissingParameter, "Required parameter is missing: id")
+		return
+	}
+
+	if ctx.Request.FormValue("submission") != "false" {
+		userID := ctx.GetUint("userID")
+		for _, id := range ids {
+			if err := c.statsService.RecordTrackPlay(userID, id); err != nil {
+				log.Printf("Failed to record play of track %d: %v", id, err)
+			}
+		}
+	}
+
+	subsonicRespond(ctx, &model.SubsonicResponse{})
+}
+
+func (c *SubsonicController) Star(ctx *gin.Context) {
+	var params model.SubsonicStarParams
+	if err := ctx.ShouldBind(&params); err != nil {
+		subsonicError(ctx, model.SubsonicErrMissingParameter, "Invalid parameters")
+		return
+	}
+
+	if err := c.subsonicService.Star(ctx.GetUint("userID"), params); err != nil {
+		subsonicFail(ctx, err)
+		return
+	}
+
+	subsonicRespond(ctx, &model.SubsonicResponse{})
+}
+
+func (c *SubsonicController) Unstar(ctx *gin.Context) {
+	var params model.SubsonicStarParams
+	if err := ctx.ShouldBind(&params); err != nil {
+		subsonicError(ctx, model.SubsonicErrMissingParameter, "Invalid parameters")
+		return
+	}
+
+	if err := c.subsonicService.Unstar(ctx.GetUint("userID"), params); err != nil {
+		subsonicFail(ctx, err)
+		return
+	}
+
+	subsonicRespond(ctx, &model.SubsonicResponse{})
+}
+
+func (c *SubsonicController) GetStarred2(ctx *gin.Context) {
+	starred, err := c.subsonicService.GetStarred(ctx.GetUint("userID"))
+	if err != nil {
+		subsonicFail(ctx, err)
+		return
+	}
+
+	subsonicRespond(ctx, &model.SubsonicResponse{Starred2: starred})
+}
+
+// findPlaylist загружает плейлист, если он доступен текущему пользователю
+func (c *SubsonicController) findPlaylist(ctx *gin.Context, id uint) (*model.PlaylistResponse, bool) {
+	playlist, err := c.playlistService.GetPlaylistByID(id, middleware.CurrentActor(ctx))
+	if err != nil {
+		subsonicFail(ctx, err)
+		return nil, false
+	}
+	return playlist, true
+}
+
+func (c *SubsonicController) addPlaylistTracks(ctx *gin.Context, playlistID uint, trackIDs []uint) bool {
+	for _, trackID := range trackIDs {
+		req := &model.AddTrackToPlaylistRequest{TrackID: trackID}
+		if _, err := c.playlistService.AddTrackToPlaylist(playlistID, req, middleware.CurrentActor(ctx)); err != nil {
+			subsonicFail(ctx, err)
+			return false
+		}
+	}
+	return true
+}
+
+func (c *SubsonicController) respondPlaylist(ctx *gin.Context, playlist *model.PlaylistResponse) {
+	trackIDs := make([]uint, len(playlist.Tracks))
+	for i, track := range playlist.Tracks {
+		trackIDs[i] = track.ID
+	}
+
+	result := &model.SubsonicPlaylistWithSongs{SubsonicPlaylist: newSubsonicPlaylist(ctx, playlist)}
+	if len(trackIDs) > 0 {
+		songs, err := c.subsonicService.GetSongs(ctx.GetUint("userID"), trackIDs)
+		if err != nil {
+			subsonicFail(ctx, err)
+			return
+		}
+		result.Entries = songs
+	}
+
+	subsonicRespond(ctx, &model.SubsonicResponse{Playlist: result})
+}
+
+func newSubsonicPlaylist(ctx *gin.Context, playlist *model.PlaylistResponse) model.SubsonicPlaylist {
+	created, _ := time.Parse(time.RFC3339, playlist.CreatedAt)
+
+	result := model.SubsonicPlaylist{
+		ID:        strconv.FormatUint(uint64(playlist.ID), 10),
+		Name:      playlist.Name,
+		Comment:   playlist.Description,
+		Owner:     ctx.GetString("username"),
+		SongCount: len(playlist.Tracks),
+		Created:   created,
+		Changed:   created,
+	}
+	for _, track := range playlist.Tracks {
+		result.Duration += track.Duration
+		if result.CoverArt == "" && track.ImageURL != "" {
+			result.CoverArt = strconv.FormatUint(uint64(track.ID), 10)
+		}
+	}
+
+	return result
+}
+
+func subsonicRespond(ctx *gin.Context, resp *model.SubsonicResponse) {
+	resp.Status = "ok"
+	if resp.Error != nil {
+		resp.Status = "failed"
+	}
+	resp.Version = model.SubsonicAPIVersion
+	resp.Type = model.SubsonicServerType
+	resp.ServerVersion = "1.0"
+	resp.OpenSubsonic = true
+
+	switch ctx.Request.FormValue("f") {
+	case "json":
+		ctx.JSON(http.StatusOK, gin.H{"subsonic-response": resp})
+	case "jsonp":
+		ctx.JSONP(http.StatusOK, gin.H{"subsonic-response": resp})
+	default:
+		ctx.XML(http.StatusOK, resp)
+	}
+}
+
+func subsonicError(ctx *gin.Context, code int, message string) {
+	subsonicRespond(ctx, &model.SubsonicResponse{Error: &model.SubsonicError{Code: code, Message: message}})
+	ctx.Abort()
+}
+
+func subsonicFail(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrSubsonicNotFound), errors.Is(err, service.ErrTrackNotFound),
+		errors.Is(err, service.ErrPlaylistNotFound), errors.Is(err, service.ErrPlaylistEntryNotFound):
+		subsonicError(ctx, model.SubsonicErrNotFound, err.Error())
+	case errors.Is(err, service.ErrForbidden):
+		subsonicError(ctx, model.SubsonicErrNotAuthorized, "User is not authorized for the given operation")
+	default:
+		log.Printf("Subsonic request %s failed: %v", ctx.Request.URL.Path, err)
+		subsonicError(ctx, model.SubsonicErrGeneric, "Internal server error")
+	}
+}
+
+func requireSubsonicParam(ctx *gin.Context, name string) (string, bool) {
+	value := ctx.Request.FormValue(name)
+	if value == "" {
+		subsonicError(ctx, model.SubsonicErrMissingParameter, "Required parameter is missing: "+name)
+		return "", false
+	}
+	return value, true
+}
+
+func requireSubsonicID(ctx *gin.Context, name string) (uint, bool) {
+	value, ok := requireSubsonicParam(ctx, name)
+	if !ok {
+		return 0, false
+	}
+
+	id, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		subsonicError(ctx, model.SubsonicErrNotFound, "Invalid "+name)
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// subsonicIDs разбирает повторяющийся параметр с числовыми идентификаторами
+func subsonicIDs(ctx *gin.Context, name string) ([]uint, bool) {
+	if ctx.Request.Form == nil {
+		_ = ctx.Request.ParseForm()
+	}
+
+	var ids []uint
+	for _, value := range ctx.Request.Form[name] {
+		id, err := strconv.ParseUint(strings.TrimSpace(value), 10, 32)
+		if err != nil {
+			subsonicError(ctx, model.SubsonicErrNotFound, "Invalid "+name)
+			return nil, false
+		}
+		ids = append(ids, uint(id))
+	}
+	return ids, true
+}
@@ -0,0 +1,159 @@
+package controller
+
+import (
+	"MusicService/internal/model"
+	"MusicService/internal/service"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newSubsonicFixture(user testUser, stars *fakeStarRepository) (*fakePlaylistRepository, *gin.Engine) {
+	tracks, playlists := newPlaylistRepositories()
+
+	subsonicService := service.NewSubsonicService(nil, tracks, nil, nil, stars, nil, nil)
+	controller := NewSubsonicController(subsonicService, nil, service.NewPlaylistService(playlists, tracks), nil)
+	router := newTestRouter(user)
+	SubsonicRoute(router, "createPlaylist", controller.CreatePlaylist)
+	SubsonicRoute(router, "updatePlaylist", controller.UpdatePlaylist)
+	SubsonicRoute(router, "deletePlaylist", controller.DeletePlaylist)
+	SubsonicRoute(router, "star", controller.Star)
+	SubsonicRoute(router, "unstar", controller.Unstar)
+
+	return playlists, router
+}
+
+// serveSubsonic выполняет запрос Subsonic API и возвращает код ошибки из тела ответа или -1 при успехе
+func serveSubsonic(t *testing.T, router *gin.Engine, target string) int {
+	t.Helper()
+
+	rec := serve(t, router, http.MethodGet, target+"&f=json", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("HTTP status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	var body struct {
+		Response model.SubsonicResponse `json:"subsonic-response"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode %s: %v", rec.Body.String(), err)
+	}
+	if body.Response.Error != nil {
+		return body.Response.Error.Code
+	}
+	return -1
+}
+
+func TestSubsonicPlaylistPolicy(t *testing.T) {
+	endpoints := []struct {
+		name   string
+		target string
+		// applied сообщает, изменился ли плейлист testPlaylistID после запроса
+		applied func(playlist *model.Playlist, exists bool) bool
+	}{
+		{
+			name: "createPlaylist replace", target: "/createPlaylist?playlistId=10&songId=102",
+			applied: func(p *model.Playlist, _ bool) bool {
+				return len(p.Entries) == 1 && p.Entries[0].TrackID == 102
+			},
+		},
+		{
+			name: "updatePlaylist", target: "/updatePlaylist?playlistId=10&name=Renamed",
+			applied: func(p *model.Playlist, _ bool) bool { return p.Name == "Renamed" },
+		},
+		{
+			name: "updatePlaylist remove", target: "/updatePlaylist?playlistId=10&songIndexToRemove=0",
+			applied: func(p *model.Playlist, _ bool) bool { return slices.Equal(entryIDs(p), []uint{2}) },
+		},
+		{
+			name: "updatePlaylist add", target: "/updatePlaylist?playlistId=10&songIdToAdd=102",
+			applied: func(p *model.Playlist, _ bool) bool { return len(p.Entries) == 3 },
+		},
+		{
+			name: "deletePlaylist", target: "/deletePlaylist?id=10",
+			applied: func(_ *model.Playlist, exists bool) bool { return !exists },
+		},
+	}
+
+	for _, endpoint := range endpoints {
+		for _, user := range []testUser{owner, other, admin} {
+			allowed := user != other
+
+			t.Run(endpoint.name+"/"+user.name, func(t *testing.T) {
+				playlists, router := newSubsonicFixture(user, &fakeStarRepository{})
+
+				code := serveSubsonic(t, router, endpoint.target)
+				want := -1
+				if !allowed {
+					want = model.SubsonicErrNotAuthorized
+				}
+				if code != want {
+					t.Fatalf("error code = %d, want %d", code, want)
+				}
+
+				playlist, exists := playlists.playlists[testPlaylistID]
+				if playlist == nil {
+					playlist = &model.Playlist{}
+				}
+				if got := endpoint.applied(playlist, exists); got != allowed {
+					t.Errorf("change applied = %v, want %v", got, allowed)
+				}
+			})
+		}
+	}
+}
+
+func TestSubsonicCreatePlaylistOwnedByCaller(t *testing.T) {
+	for _, user := range []testUser{owner, other, admin} {
+		t.Run(user.name, func(t *testing.T) {
+			playlists, router := newSubsonicFixture(user, &fakeStarRepository{})
+
+			if code := serveSubsonic(t, router, "/createPlaylist?name=Mix&songId=102"); code != -1 {
+				t.Fatalf("error code = %d, want success", code)
+			}
+
+			created := playlists.playlists[testPlaylistID+1]
+			if created == nil || created.UserID != user.id || len(created.Entries) != 1 {
+				t.Fatalf("created playlist = %+v, want one track owned by user %d", created, user.id)
+			}
+			if len(playlists.playlists[testPlaylistID].Entries) != 2 {
+				t.Error("creating a playlist changed an existing one")
+			}
+		})
+	}
+}
+
+// Избранное у каждого пользователя свое: star и unstar не затрагивают чужое избранное,
+// в том числе у администратора
+func TestSubsonicStarPolicy(t *testing.T) {
+	for _, user := range []testUser{owner, other, admin} {
+		t.Run(user.name, func(t *testing.T) {
+			stars := &fakeStarRepository{stars: []model.Star{
+				{UserID: ownerID, ItemType: model.StarItemSong, ItemID: "100"},
+			}}
+			_, router := newSubsonicFixture(user, stars)
+
+			if code := serveSubsonic(t, router, "/star?id=101"); code != -1 {
+				t.Fatalf("star error code = %d, want success", code)
+			}
+			if !stars.has(user.id, model.StarItemSong, "101") {
+				t.Errorf("star was not recorded for user %d: %+v", user.id, stars.stars)
+			}
+			for _, star := range stars.stars {
+				if star.ItemID == "101" && star.UserID != user.id {
+					t.Errorf("star recorded for user %d instead of %d", star.UserID, user.id)
+				}
+			}
+
+			if code := serveSubsonic(t, router, "/unstar?id=100"); code != -1 {
+				t.Fatalf("unstar error code = %d, want success", code)
+			}
+			if got, want := stars.has(ownerID, model.StarItemSong, "100"), user != owner; got != want {
+				t.Errorf("owner's star present = %v, want %v", got, want)
+			}
+		})
+	}
+}
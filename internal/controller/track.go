@@ -1,11 +1,16 @@
 package controller
 
 import (
+	"MusicService/internal/middleware"
 	"MusicService/internal/model"
 	"MusicService/internal/service"
 	"MusicService/pkg/response"
+	"errors"
+	"log"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
@@ -21,20 +26,29 @@ func NewTrackController(trackService service.TrackService, statsService service.
 
 // UploadTrack загружает новый трек
 // @Summary Загрузить новый трек
-// @Description Загружает аудиофайл и создает запись о треке
+// @Description Загружает аудиофайл и создает запись о треке. Поддерживаются MP3, FLAC, Ogg Vorbis, Opus, WAV, AAC/M4A
+// @Description и изображения JPEG, PNG, WebP, GIF; формат определяется по содержимому файла. Длительность, параметры потока и теги
+// @Description извлекаются из файла (ID3, Vorbis comment, MP4); заполненные поля формы имеют приоритет над тегами.
+// @Description Если загрузка ограничена настройкой сервера, доступно только ролям uploader и admin
 // @Tags Tracks
 // @Accept multipart/form-data
 // @Produce json
 // @Security BearerAuth
 // @Param file formData file true "Аудиофайл"
-// @Param image formData file false "Изображение"
-// @Param title formData string true "Название трека"
-// @Param artist formData string true "Исполнитель"
+// @Param image formData file false "Изображение (по умолчанию - обложка, встроенная в аудиофайл)"
+// @Param title formData string false "Название трека (обязательно, если в файле нет тегов)"
+// @Param artist formData string false "Исполнитель (обязательно, если в файле нет тегов)"
 // @Param album formData string false "Альбом"
+// @Param album_artist formData string false "Исполнитель альбома"
 // @Param genre formData string false "Жанр"
+// @Param year formData int false "Год"
+// @Param track_number formData int false "Номер трека"
+// @Param disc_number formData int false "Номер диска"
 // @Success 201 {object} model.TrackResponse
 // @Failure 400 {object} response.Response
 // @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 415 {object} response.Response
 // @Failure 500 {object} response.Response
 // @Router /api/tracks [post]
 func (c *TrackController) UploadTrack(ctx *gin.Context) {
@@ -46,7 +60,12 @@ func (c *TrackController) UploadTrack(ctx *gin.Context) {
 		return
 	}
 
+	// Изображение необязательно: при его отсутствии используется обложка из тегов файла
 	imageFile, err := ctx.FormFile("image")
+	if err != nil && !errors.Is(err, http.ErrMissingFile) {
+		response.Error(ctx, http.StatusBadRequest, "Invalid image file")
+		return
+	}
 
 	var req model.TrackUploadRequest
 	if err := ctx.ShouldBind(&req); err != nil {
@@ -56,7 +75,14 @@ func (c *TrackController) UploadTrack(ctx *gin.Context) {
 
 	track, err := c.trackService.UploadTrack(audioFile, imageFile, &req, userID)
 	if err != nil {
-		response.Error(ctx, http.StatusInternalServerError, "Failed to upload track")
+		switch {
+		case errors.Is(err, service.ErrTrackInfoRequired):
+			response.Error(ctx, http.StatusBadRequest, err.Error())
+		case errors.Is(err, service.ErrUnsupportedMediaType):
+			response.Error(ctx, http.StatusUnsupportedMediaType, err.Error())
+		default:
+			response.Error(ctx, http.StatusInternalServerError, "Failed to upload track")
+		}
 		return
 	}
 
@@ -65,21 +91,31 @@ func (c *TrackController) UploadTrack(ctx *gin.Context) {
 
 // GetAllTracks возвращает все треки
 // @Summary Получить все треки
-// @Description Возвращает список всех треков в системе
+// @Description Возвращает постраничный список всех треков в системе
 // @Tags Tracks
 // @Produce json
 // @Security BearerAuth
-// @Success 200 {array} model.TrackResponse
+// @Param page query int false "Номер страницы (с 1)"
+// @Param per_page query int false "Записей на странице (по умолчанию 50, не больше 200)"
+// @Param cursor query string false "Курсор next_cursor из предыдущего ответа; заменяет page"
+// @Param sort query string false "Сортировка: title, artist, created_at, duration, play_count; \"-\" в начале - по убыванию (по умолчанию -created_at)"
+// @Success 200 {object} response.PaginatedResponse{data=[]model.TrackResponse}
+// @Failure 400 {object} response.Response
 // @Failure 500 {object} response.Response
 // @Router /api/tracks [get]
 func (c *TrackController) GetAllTracks(ctx *gin.Context) {
-	tracks, err := c.trackService.GetAllTracks()
+	page, ok := bindPage(ctx)
+	if !ok {
+		return
+	}
+
+	tracks, err := c.trackService.GetAllTracks(page)
 	if err != nil {
-		response.Error(ctx, http.StatusInternalServerError, "Failed to get tracks")
+		writePageError(ctx, err, "Failed to get tracks")
 		return
 	}
 
-	response.Success(ctx, http.StatusOK, tracks)
+	writePage(ctx, tracks)
 }
 
 // GetTrackByID возвращает трек по ID
@@ -112,15 +148,23 @@ func (c *TrackController) GetTrackByID(ctx *gin.Context) {
 
 // StreamTrack возвращает аудиопоток трека
 // @Summary Воспроизвести трек
-// @Description Возвращает аудиопоток для проигрывания трека
+// @Description Возвращает аудиопоток для проигрывания трека. Поддерживает запросы диапазонов (Range, If-Range).
+// @Description При указании format или maxBitRate трек перекодируется; готовые версии кэшируются
 // @Tags Tracks
-// @Produce audio/mpeg
+// @Produce audio/mpeg,audio/flac,audio/ogg,audio/wav,audio/aac,audio/mp4
 // @Security BearerAuth
 // @Param id path int true "ID трека"
+// @Param format query string false "Формат: mp3, opus, aac или raw (исходный файл)"
+// @Param maxBitRate query int false "Максимальный битрейт, кбит/с"
+// @Param Range header string false "Диапазон байт, например bytes=0-1023"
+// @Param If-Range header string false "ETag или дата последнего изменения"
 // @Success 200 {file} binary
+// @Success 206 {file} binary
 // @Failure 400 {object} response.Response
 // @Failure 404 {object} response.Response
+// @Failure 416 {object} response.Response
 // @Failure 500 {object} response.Response
+// @Failure 503 {object} response.Response
 // @Router /api/tracks/stream/{id} [get]
 func (c *TrackController) StreamTrack(ctx *gin.Context) {
 	userID := ctx.MustGet("userID").(uint)
@@ -131,18 +175,163 @@ func (c *TrackController) StreamTrack(ctx *gin.Context) {
 		return
 	}
 
-	reader, contentType, err := c.trackService.StreamTrack(uint(id))
+	var params model.StreamParams
+	if err := ctx.ShouldBindQuery(&params); err != nil {
+		response.Error(ctx, http.StatusBadRequest, "Invalid stream parameters")
+		return
+	}
+
+	stream, err := c.trackService.StreamTrack(uint(id), params)
 	if err != nil {
-		response.Error(ctx, http.StatusNotFound, "Track not found")
+		switch {
+		case errors.Is(err, service.ErrUnsupportedTranscodeFormat):
+			response.Error(ctx, http.StatusBadRequest, err.Error())
+		case errors.Is(err, service.ErrTranscodingUnavailable):
+			response.Error(ctx, http.StatusServiceUnavailable, err.Error())
+		default:
+			response.Error(ctx, http.StatusNotFound, "Track not found")
+		}
+		return
+	}
+
+	ranges, ok := resolveRanges(ctx, stream)
+	if !ok {
 		return
 	}
-	defer reader.Close()
 
+	if isPlaybackStart(ctx, ranges) {
+		if err = c.statsService.RecordTrackPlay(userID, uint(id)); err != nil {
+			log.Printf("Failed to record play of track %d: %v", id, err)
+		}
+	}
+
+	serveStream(ctx, stream, ranges)
+}
+
+// GetHLSMasterPlaylist возвращает мастер-плейлист HLS
+// @Summary Мастер-плейлист HLS
+// @Description Возвращает мастер-плейлист HLS с вариантами трека разного битрейта (AAC) для адаптивного воспроизведения.
+// @Description Для плееров, которые не умеют передавать заголовок Authorization, токен можно указать в параметре access_token -
+// @Description он будет добавлен ко всем ссылкам плейлистов
+// @Tags Tracks
+// @Produce application/vnd.apple.mpegurl
+// @Security BearerAuth
+// @Param id path int true "ID трека"
+// @Param access_token query string false "JWT токен доступа"
+// @Success 200 {string} string
+// @Failure 400 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Failure 503 {object} response.Response
+// @Router /api/tracks/{id}/hls/master.m3u8 [get]
+func (c *TrackController) GetHLSMasterPlaylist(ctx *gin.Context) {
+	userID := ctx.MustGet("userID").(uint)
+
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "Invalid track ID")
+		return
+	}
+
+	playlist, err := c.trackService.GetHLSMasterPlaylist(uint(id))
+	if err != nil {
+		writeHLSError(ctx, err)
+		return
+	}
+
+	// Мастер-плейлист запрашивается один раз за воспроизведение, в отличие от сегментов
 	if err = c.statsService.RecordTrackPlay(userID, uint(id)); err != nil {
-		response.Error(ctx, http.StatusInternalServerError, "Failed to record play of the track")
+		log.Printf("Failed to record play of track %d: %v", id, err)
 	}
 
-	ctx.DataFromReader(http.StatusOK, -1, contentType, reader, nil)
+	servePlaylist(ctx, playlist)
+}
+
+// GetHLSVariant возвращает медиаплейлист или сегмент варианта HLS
+// @Summary Медиаплейлист и сегменты HLS
+// @Description Возвращает медиаплейлист (index.m3u8) или сегмент MPEG-TS (segment_NNN.ts) варианта трека.
+// @Description Вариант нарезается при первом запросе плейлиста и кэшируется
+// @Tags Tracks
+// @Produce application/vnd.apple.mpegurl,video/mp2t
+// @Security BearerAuth
+// @Param id path int true "ID трека"
+// @Param variant path int true "Битрейт варианта, кбит/с"
+// @Param file path string true "index.m3u8 или имя сегмента"
+// @Param access_token query string false "JWT токен доступа"
+// @Success 200 {file} binary
+// @Success 206 {file} binary
+// @Failure 400 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Failure 503 {object} response.Response
+// @Router /api/tracks/{id}/hls/{variant}/{file} [get]
+func (c *TrackController) GetHLSVariant(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "Invalid track ID")
+		return
+	}
+
+	bitRate, err := strconv.Atoi(ctx.Param("variant"))
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "Invalid variant")
+		return
+	}
+
+	if file := ctx.Param("file"); file != "index.m3u8" {
+		stream, err := c.trackService.GetHLSSegment(uint(id), bitRate, file)
+		if err != nil {
+			writeHLSError(ctx, err)
+			return
+		}
+
+		ranges, ok := resolveRanges(ctx, stream)
+		if !ok {
+			return
+		}
+		serveStream(ctx, stream, ranges)
+		return
+	}
+
+	playlist, err := c.trackService.GetHLSMediaPlaylist(uint(id), bitRate)
+	if err != nil {
+		writeHLSError(ctx, err)
+		return
+	}
+
+	servePlaylist(ctx, playlist)
+}
+
+func writeHLSError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrTrackNotFound):
+		response.Error(ctx, http.StatusNotFound, "Track not found")
+	case errors.Is(err, service.ErrHLSVariantNotFound), errors.Is(err, service.ErrHLSSegmentNotFound):
+		response.Error(ctx, http.StatusNotFound, err.Error())
+	case errors.Is(err, service.ErrTranscodingUnavailable):
+		response.Error(ctx, http.StatusServiceUnavailable, err.Error())
+	default:
+		log.Printf("HLS request failed: %v", err)
+		response.Error(ctx, http.StatusInternalServerError, "Failed to prepare HLS stream")
+	}
+}
+
+// servePlaylist отдает плейлист HLS. Если клиент передал токен в параметре access_token,
+// он добавляется к ссылкам плейлиста: относительные URI не наследуют query исходного запроса
+func servePlaylist(ctx *gin.Context, playlist string) {
+	if token := ctx.Query("access_token"); token != "" {
+		suffix := "?access_token=" + url.QueryEscape(token)
+		lines := strings.Split(playlist, "\n")
+		for i, line := range lines {
+			if line != "" && !strings.HasPrefix(line, "#") {
+				lines[i] = line + suffix
+			}
+		}
+		playlist = strings.Join(lines, "\n")
+	}
+
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Data(http.StatusOK, "application/vnd.apple.mpegurl", []byte(playlist))
 }
 
 // DeleteTrack удаляет трек
@@ -166,8 +355,15 @@ func (c *TrackController) DeleteTrack(ctx *gin.Context) {
 		return
 	}
 
-	if err := c.trackService.DeleteTrack(uint(id)); err != nil {
-		response.Error(ctx, http.StatusInternalServerError, "Failed to delete track")
+	if err := c.trackService.DeleteTrack(uint(id), middleware.CurrentActor(ctx)); err != nil {
+		switch {
+		case errors.Is(err, service.ErrTrackNotFound):
+			response.Error(ctx, http.StatusNotFound, err.Error())
+		case errors.Is(err, service.ErrForbidden):
+			response.Error(ctx, http.StatusForbidden, "Access denied")
+		default:
+			response.Error(ctx, http.StatusInternalServerError, "Failed to delete track")
+		}
 		return
 	}
 
@@ -176,15 +372,29 @@ func (c *TrackController) DeleteTrack(ctx *gin.Context) {
 
 // SearchTracks ищет треки по параметрам
 // @Summary Поиск треков
-// @Description Поиск треков по названию, исполнителю, альбому или жанру
+// @Description Полнотекстовый поиск по названию, исполнителю, альбому и жанру с учетом словоформ (русский и английский).
+// @Description Последнее слово ищется как префикс. Результаты по умолчанию упорядочены по релевантности, в highlight
+// @Description совпадения выделены <mark>. С fuzzy=true, если точный поиск ничего не нашел, запрос ищется в написании
+// @Description другим алфавитом ("kino" - "Кино"), затем по сходству с учетом опечаток; в did_you_mean возвращается
+// @Description наиболее похожий исполнитель или название. В facets - счетчики по жанру, исполнителю, альбому, десятилетию,
+// @Description длительности и формату; счетчики фасета не учитывают его собственный фильтр. Фильтры фасетов принимают
+// @Description несколько значений (genre=rock&genre=metal), трек подходит под любое из них
 // @Tags Tracks
 // @Produce json
 // @Security BearerAuth
 // @Param q query string false "Поисковый запрос"
-// @Param artist query string false "Исполнитель"
-// @Param album query string false "Альбом"
-// @Param genre query string false "Жанр"
-// @Success 200 {array} model.TrackResponse
+// @Param artist query []string false "Исполнители, без учета регистра" collectionFormat(multi)
+// @Param album query []string false "Альбомы" collectionFormat(multi)
+// @Param genre query []string false "Жанры" collectionFormat(multi)
+// @Param decade query []int false "Десятилетия: 1990, 2000" collectionFormat(multi)
+// @Param duration query []string false "Длительность: under_3m, 3_5m, 5_10m, over_10m" collectionFormat(multi)
+// @Param format query []string false "Формат файла: mp3, flac, ogg, opus, wav, aac, m4a" collectionFormat(multi)
+// @Param fuzzy query bool false "Нечеткий поиск и транслитерация, если точный поиск ничего не нашел"
+// @Param page query int false "Номер страницы (с 1)"
+// @Param per_page query int false "Записей на странице (по умолчанию 50, не больше 200)"
+// @Param cursor query string false "Курсор next_cursor из предыдущего ответа; заменяет page"
+// @Param sort query string false "Сортировка: relevance (только с q), title, artist, created_at, duration, play_count; \"-\" в начале - по убыванию (по умолчанию -relevance с q, иначе -created_at)"
+// @Success 200 {object} response.PaginatedResponse{data=[]model.TrackResponse}
 // @Failure 400 {object} response.Response
 // @Failure 500 {object} response.Response
 // @Router /api/tracks/search [get]
@@ -194,14 +404,30 @@ func (c *TrackController) SearchTracks(ctx *gin.Context) {
 		response.Error(ctx, http.StatusBadRequest, "Invalid search parameters")
 		return
 	}
+	page, ok := bindPage(ctx)
+	if !ok {
+		return
+	}
 
-	tracks, err := c.trackService.SearchTracks(params)
+	result, err := c.trackService.SearchTracks(params, page)
 	if err != nil {
-		response.Error(ctx, http.StatusInternalServerError, "Failed to search tracks")
+		writePageError(ctx, err, "Failed to search tracks")
 		return
 	}
 
-	response.Success(ctx, http.StatusOK, tracks)
+	tracks := result.Page
+	response.Success(ctx, http.StatusOK, trackSearchResponse{
+		PaginatedResponse: response.NewPaginatedResponse(tracks.Items, tracks.Total, tracks.Page, tracks.PerPage, tracks.NextCursor),
+		Facets:            result.Facets,
+		DidYouMean:        result.DidYouMean,
+	})
+}
+
+// trackSearchResponse - страница результатов поиска с фасетами и подсказкой "возможно, вы искали"
+type trackSearchResponse struct {
+	response.PaginatedResponse
+	Facets     *model.TrackFacets `json:"facets"`
+	DidYouMean string             `json:"did_you_mean,omitempty"`
 }
 
 // GetTrackImage godoc
@@ -212,6 +438,7 @@ func (c *TrackController) SearchTracks(ctx *gin.Context) {
 // @Param id path int true "ID трека"
 // @Success 200 {file} byte
 // @Failure 400 {object} response.Response
+// @Failure 404 {object} response.Response
 // @Failure 500 {object} response.Response
 // @Router /api/tracks/{id}/image [get]
 func (c *TrackController) GetTrackImage(ctx *gin.Context) {
@@ -223,7 +450,14 @@ func (c *TrackController) GetTrackImage(ctx *gin.Context) {
 
 	reader, contentType, err := c.trackService.GetTrackImage(uint(id))
 	if err != nil {
-		response.Error(ctx, http.StatusNotFound, "Image not found")
+		switch {
+		case errors.Is(err, service.ErrTrackNotFound):
+			response.Error(ctx, http.StatusNotFound, "Track not found")
+		case errors.Is(err, service.ErrTrackImageNotFound):
+			response.Error(ctx, http.StatusNotFound, "Image not found")
+		default:
+			response.Error(ctx, http.StatusInternalServerError, "Failed to get image")
+		}
 		return
 	}
 	defer reader.Close()
@@ -233,12 +467,17 @@ func (c *TrackController) GetTrackImage(ctx *gin.Context) {
 
 // GetUserTracks возвращает все треки пользователя
 // @Summary Получить все треки пользователя
-// @Description Возвращает список всех треков пользователя в системе
+// @Description Возвращает постраничный список треков, загруженных пользователем
 // @Tags Tracks
 // @Produce json
 // @Security BearerAuth
 // @Param userId path int true "ID пользователя"
-// @Success 200 {array} model.TrackResponse
+// @Param page query int false "Номер страницы (с 1)"
+// @Param per_page query int false "Записей на странице (по умолчанию 50, не больше 200)"
+// @Param cursor query string false "Курсор next_cursor из предыдущего ответа; заменяет page"
+// @Param sort query string false "Сортировка: title, artist, created_at, duration, play_count; \"-\" в начале - по убыванию (по умолчанию -created_at)"
+// @Success 200 {object} response.PaginatedResponse{data=[]model.TrackResponse}
+// @Failure 400 {object} response.Response
 // @Failure 500 {object} response.Response
 // @Router /api/tracks/user/{userId} [get]
 func (c *TrackController) GetUserTracks(ctx *gin.Context) {
@@ -248,11 +487,16 @@ func (c *TrackController) GetUserTracks(ctx *gin.Context) {
 		return
 	}
 
-	tracks, err := c.trackService.GetUserTracks(uint(userId))
+	page, ok := bindPage(ctx)
+	if !ok {
+		return
+	}
+
+	tracks, err := c.trackService.GetUserTracks(uint(userId), page)
 	if err != nil {
-		response.Error(ctx, http.StatusInternalServerError, "Failed to get tracks")
+		writePageError(ctx, err, "Failed to get tracks")
 		return
 	}
 
-	response.Success(ctx, http.StatusOK, tracks)
+	writePage(ctx, tracks)
 }
@@ -0,0 +1,62 @@
+package controller
+
+import (
+	"MusicService/internal/model"
+	"MusicService/internal/service"
+	"MusicService/internal/storage/storagetest"
+	"net/http"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+const testBucket = "music"
+
+func TestDeleteTrackPolicy(t *testing.T) {
+	tests := []struct {
+		user    testUser
+		want    int
+		deleted bool
+	}{
+		{user: owner, want: http.StatusOK, deleted: true},
+		{user: other, want: http.StatusForbidden},
+		{user: admin, want: http.StatusOK, deleted: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.user.name, func(t *testing.T) {
+			tracks := &fakeTrackRepository{tracks: map[uint]*model.Track{
+				1: {Model: gorm.Model{ID: 1}, Title: "Song", FilePath: "tracks/1.mp3", UploadedBy: ownerID},
+			}}
+			store := storagetest.NewMemory(t)
+			store.Put(testBucket, "tracks/1.mp3", []byte("audio"), "audio/mpeg")
+
+			controller := NewTrackController(service.NewTrackService(tracks, store, nil, testBucket), nil)
+			router := newTestRouter(tt.user)
+			router.DELETE("/tracks/:id", controller.DeleteTrack)
+
+			rec := serve(t, router, http.MethodDelete, "/tracks/1", nil)
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body.String())
+			}
+
+			_, exists := tracks.tracks[1]
+			_, stored := store.Get(testBucket, "tracks/1.mp3")
+			if exists == tt.deleted || stored == tt.deleted {
+				t.Errorf("track present = %v, file present = %v, want deleted = %v", exists, stored, tt.deleted)
+			}
+		})
+	}
+}
+
+func TestDeleteTrackNotFound(t *testing.T) {
+	tracks := &fakeTrackRepository{tracks: map[uint]*model.Track{}}
+	controller := NewTrackController(service.NewTrackService(tracks, storagetest.NewMemory(t), nil, testBucket), nil)
+	router := newTestRouter(admin)
+	router.DELETE("/tracks/:id", controller.DeleteTrack)
+
+	rec := serve(t, router, http.MethodDelete, "/tracks/1", nil)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,145 @@
+package controller
+
+import (
+	"MusicService/internal/model"
+	"MusicService/internal/service"
+	"MusicService/pkg/response"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TwoFactorController struct {
+	twoFactorService service.TwoFactorService
+}
+
+func NewTwoFactorController(twoFactorService service.TwoFactorService) *TwoFactorController {
+	return &TwoFactorController{twoFactorService: twoFactorService}
+}
+
+// Setup godoc
+// @Summary Начать настройку 2FA
+// @Description Генерирует секрет TOTP и otpauth-ссылку для приложения-аутентификатора.
+// @Description Двухфакторная аутентификация включается только после подтверждения первым кодом
+// @Tags TwoFactor
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} model.TwoFactorSetupResponse
+// @Failure 401 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /api/user/2fa/setup [post]
+func (c *TwoFactorController) Setup(ctx *gin.Context) {
+	setup, err := c.twoFactorService.Setup(ctx.GetUint("userID"))
+	if err != nil {
+		writeTwoFactorError(ctx, err)
+		return
+	}
+
+	response.Success(ctx, http.StatusOK, setup)
+}
+
+// Enable godoc
+// @Summary Включить 2FA
+// @Description Подтверждает настройку первым кодом из приложения и возвращает коды восстановления.
+// @Description Коды показываются один раз
+// @Tags TwoFactor
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body model.TwoFactorCodeRequest true "Код из приложения"
+// @Success 200 {object} model.RecoveryCodesResponse
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /api/user/2fa/enable [post]
+func (c *TwoFactorController) Enable(ctx *gin.Context) {
+	var req model.TwoFactorCodeRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		response.Error(ctx, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	codes, err := c.twoFactorService.Enable(ctx.GetUint("userID"), req.Code)
+	if err != nil {
+		writeTwoFactorError(ctx, err)
+		return
+	}
+
+	response.Success(ctx, http.StatusOK, codes)
+}
+
+// Disable godoc
+// @Summary Отключить 2FA
+// @Description Отключает двухфакторную аутентификацию. Требуются пароль и код из приложения или код восстановления
+// @Tags TwoFactor
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body model.DisableTwoFactorRequest true "Пароль и код"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /api/user/2fa/disable [post]
+func (c *TwoFactorController) Disable(ctx *gin.Context) {
+	var req model.DisableTwoFactorRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		response.Error(ctx, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if err := c.twoFactorService.Disable(ctx.GetUint("userID"), &req); err != nil {
+		writeTwoFactorError(ctx, err)
+		return
+	}
+
+	response.Success(ctx, http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// RegenerateRecoveryCodes godoc
+// @Summary Новые коды восстановления
+// @Description Выдает новый набор кодов восстановления; прежние коды перестают действовать
+// @Tags TwoFactor
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body model.TwoFactorCodeRequest true "Код из приложения"
+// @Success 200 {object} model.RecoveryCodesResponse
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /api/user/2fa/recovery-codes [post]
+func (c *TwoFactorController) RegenerateRecoveryCodes(ctx *gin.Context) {
+	var req model.TwoFactorCodeRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		response.Error(ctx, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	codes, err := c.twoFactorService.RegenerateRecoveryCodes(ctx.GetUint("userID"), req.Code)
+	if err != nil {
+		writeTwoFactorError(ctx, err)
+		return
+	}
+
+	response.Success(ctx, http.StatusOK, codes)
+}
+
+func writeTwoFactorError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrTwoFactorAlreadyEnabled):
+		response.Error(ctx, http.StatusConflict, err.Error())
+	case errors.Is(err, service.ErrTwoFactorNotEnabled),
+		errors.Is(err, service.ErrTwoFactorNotSetUp),
+		errors.Is(err, service.ErrInvalidTwoFactorCode):
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+	case errors.Is(err, service.ErrIncorrectPassword):
+		response.Error(ctx, http.StatusForbidden, err.Error())
+	default:
+		response.Error(ctx, http.StatusInternalServerError, "Failed to process two-factor authentication")
+	}
+}
@@ -4,17 +4,19 @@ import (
 	"MusicService/internal/model"
 	"MusicService/internal/service"
 	"MusicService/pkg/response"
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
 
 type UserController struct {
-	userService service.UserService
+	userService         service.UserService
+	verificationService service.VerificationService
 }
 
-func NewUserController(userService service.UserService) *UserController {
-	return &UserController{userService: userService}
+func NewUserController(userService service.UserService, verificationService service.VerificationService) *UserController {
+	return &UserController{userService: userService, verificationService: verificationService}
 }
 
 // GetProfile godoc
@@ -46,12 +48,13 @@ func (c *UserController) GetProfile(ctx *gin.Context) {
 
 // UpdateProfile godoc
 // @Summary Обновить профиль пользователя
-// @Description Обновляет информацию о текущем авторизованном пользователе
+// @Description Обновляет информацию о текущем авторизованном пользователе. Новый email сохраняется как ожидающий
+// @Description (pendingEmail) и заменяет текущий после перехода по ссылке, отправленной на новый адрес
 // @Tags User
 // @Accept json
 // @Produce json
 // @Security BearerAuth
-// @Param request body model.UserResponse true "Данные для обновления"
+// @Param request body model.UpdateProfileRequest true "Данные для обновления"
 // @Success 200 {object} model.UserResponse
 // @Failure 400 {object} response.Response
 // @Failure 401 {object} response.Response
@@ -61,7 +64,7 @@ func (c *UserController) GetProfile(ctx *gin.Context) {
 func (c *UserController) UpdateProfile(ctx *gin.Context) {
 	userID := ctx.GetUint("userID")
 
-	var update model.UserResponse
+	var update model.UpdateProfileRequest
 	if err := ctx.ShouldBindJSON(&update); err != nil {
 		response.Error(ctx, http.StatusBadRequest, "Invalid request payload")
 		return
@@ -69,51 +72,138 @@ func (c *UserController) UpdateProfile(ctx *gin.Context) {
 
 	profile, err := c.userService.UpdateProfile(userID, &update)
 	if err != nil {
-		status := http.StatusInternalServerError
-		switch err.Error() {
-		case "email already exists":
-			status = http.StatusConflict
-		case "username already exists":
-			status = http.StatusConflict
+		if errors.Is(err, service.ErrEmailExists) || errors.Is(err, service.ErrUsernameExists) {
+			response.Error(ctx, http.StatusConflict, err.Error())
+			return
 		}
-		response.Error(ctx, status, err.Error())
+		response.Error(ctx, http.StatusInternalServerError, "Failed to update profile")
 		return
 	}
 
 	response.Success(ctx, http.StatusOK, profile)
 }
 
-//// ChangePassword godoc
-//// @Summary Изменить пароль
-//// @Description Изменяет пароль текущего авторизованного пользователя
-//// @Tags User
-//// @Accept json
-//// @Produce json
-//// @Security BearerAuth
-//// @Param request body model.ChangePasswordRequest true "Данные для смены пароля"
-//// @Success 200 {object} response.Response
-//// @Failure 400 {object} response.Response
-//// @Failure 401 {object} response.Response
-//// @Failure 403 {object} response.Response
-//// @Failure 500 {object} response.Response
-//// @Router /api/user/change-password [post]
-//func (c *UserController) ChangePassword(ctx *gin.Context) {
-//	userID := ctx.GetUint("userID")
-//
-//	var req model.ChangePasswordRequest
-//	if err := ctx.ShouldBindJSON(&req); err != nil {
-//		response.Error(ctx, http.StatusBadRequest, "Invalid request payload")
-//		return
-//	}
-//
-//	if err := c.userService.ChangePassword(userID, &req); err != nil {
-//		status := http.StatusInternalServerError
-//		if err.Error() == "current password is incorrect" {
-//			status = http.StatusForbidden
-//		}
-//		response.Error(ctx, status, err.Error())
-//		return
-//	}
-//
-//	response.Success(ctx, http.StatusOK, gin.H{"message": "Password changed successfully"})
-//}
+// VerifyEmail godoc
+// @Summary Подтвердить email
+// @Description Подтверждает адрес по токену из письма: адрес, указанный при регистрации, или новый адрес при смене email
+// @Tags User
+// @Accept json
+// @Produce json
+// @Param request body model.VerifyEmailRequest true "Токен из письма"
+// @Success 200 {object} model.UserResponse
+// @Failure 400 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /auth/verify-email [post]
+func (c *UserController) VerifyEmail(ctx *gin.Context) {
+	var req model.VerifyEmailRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		response.Error(ctx, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	user, err := c.verificationService.VerifyEmail(req.Token)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidVerificationToken):
+			response.Error(ctx, http.StatusBadRequest, err.Error())
+		case errors.Is(err, service.ErrEmailExists):
+			response.Error(ctx, http.StatusConflict, err.Error())
+		default:
+			response.Error(ctx, http.StatusInternalServerError, "Failed to verify email")
+		}
+		return
+	}
+
+	response.Success(ctx, http.StatusOK, user)
+}
+
+// ResendVerification godoc
+// @Summary Повторно отправить письмо подтверждения
+// @Description Отправляет новую ссылку для подтверждения email; прежние ссылки перестают действовать
+// @Tags User
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /api/user/verify-email/resend [post]
+func (c *UserController) ResendVerification(ctx *gin.Context) {
+	if err := c.verificationService.ResendVerification(ctx.GetUint("userID")); err != nil {
+		if errors.Is(err, service.ErrEmailAlreadyVerified) {
+			response.Error(ctx, http.StatusConflict, err.Error())
+			return
+		}
+		response.Error(ctx, http.StatusInternalServerError, "Failed to send verification email")
+		return
+	}
+
+	response.Success(ctx, http.StatusOK, gin.H{"message": "Verification email sent"})
+}
+
+// SetSubsonicPassword godoc
+// @Summary Задать пароль Subsonic
+// @Description Задает отдельный пароль для входа из клиентов Subsonic (DSub, Symfonium, Feishin и др.) через /rest.
+// @Description Пароль аккаунта для этого не подходит: аутентификация token + salt требует хранить пароль в обратимом виде
+// @Tags User
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body model.SubsonicPasswordRequest true "Новый пароль Subsonic"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /api/user/subsonic-password [put]
+func (c *UserController) SetSubsonicPassword(ctx *gin.Context) {
+	userID := ctx.GetUint("userID")
+
+	var req model.SubsonicPasswordRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		response.Error(ctx, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if err := c.userService.SetSubsonicPassword(userID, req.Password); err != nil {
+		response.Error(ctx, http.StatusInternalServerError, "Failed to set Subsonic password")
+		return
+	}
+
+	response.Success(ctx, http.StatusOK, gin.H{"message": "Subsonic password updated successfully"})
+}
+
+// ChangePassword godoc
+// @Summary Изменить пароль
+// @Description Изменяет пароль текущего авторизованного пользователя. Сессии на других устройствах завершаются
+// @Tags User
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body model.ChangePasswordRequest true "Данные для смены пароля"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /api/user/change-password [post]
+func (c *UserController) ChangePassword(ctx *gin.Context) {
+	userID := ctx.GetUint("userID")
+
+	var req model.ChangePasswordRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		response.Error(ctx, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if err := c.userService.ChangePassword(userID, ctx.GetString("sessionID"), &req); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrIncorrectPassword) {
+			status = http.StatusForbidden
+		}
+		response.Error(ctx, status, err.Error())
+		return
+	}
+
+	response.Success(ctx, http.StatusOK, gin.H{"message": "Password changed successfully"})
+}
@@ -1,17 +1,40 @@
 package middleware
 
 import (
+	"MusicService/internal/model"
+	"MusicService/internal/policy"
+	"MusicService/internal/repository"
 	"MusicService/pkg/jwt"
 	"MusicService/pkg/response"
+	"MusicService/pkg/tokenhash"
+	"log"
 	"net/http"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
-func AuthMiddleware(jwtService jwt.JWTService) gin.HandlerFunc {
+// touchInterval - как часто обновлять время последнего использования сессии или API-ключа
+const touchInterval = time.Minute
+
+// AuthMiddleware проверяет токен, его сессию и учетную запись, чтобы выход с устройства,
+// блокировка и смена роли вступали в силу сразу, а не по истечении токена.
+// Вместо JWT в заголовке Bearer можно передать API-ключ; его области сохраняются в контексте
+// и проверяются RequireScope.
+// Нативные HLS-плееры не позволяют задать заголовки, поэтому на маршрутах с префиксом queryTokenRoute
+// (шаблон маршрута gin) токен принимается и из параметра access_token. На остальных маршрутах
+// параметр игнорируется, чтобы токен не попадал в журналы и историю браузера
+func AuthMiddleware(jwtService jwt.JWTService, userRepo repository.UserRepository, sessionRepo repository.SessionRepository,
+	apiKeyRepo repository.APIKeyRepository, queryTokenRoute string) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		authHeader := ctx.GetHeader("Authorization")
+		if authHeader == "" && queryTokenRoute != "" && strings.HasPrefix(ctx.FullPath(), queryTokenRoute) {
+			if token := ctx.Query("access_token"); token != "" {
+				authHeader = "Bearer " + token
+			}
+		}
 		if authHeader == "" {
 			response.Error(ctx, http.StatusUnauthorized, "Authorization header is required")
 			ctx.Abort()
@@ -27,24 +50,147 @@ func AuthMiddleware(jwtService jwt.JWTService) gin.HandlerFunc {
 
 		token := headerParts[1]
 
-		claims, err := jwtService.ValidateToken(token)
+		var userID uint
+		var ok bool
+		if strings.HasPrefix(token, model.APIKeyPrefix) {
+			userID, ok = authenticateAPIKey(ctx, apiKeyRepo, token)
+		} else {
+			userID, ok = authenticateSession(ctx, jwtService, sessionRepo, token)
+		}
+		if !ok {
+			ctx.Abort()
+			return
+		}
+
+		user, err := userRepo.FindByID(userID)
 		if err != nil {
 			response.Error(ctx, http.StatusUnauthorized, "Invalid token")
 			ctx.Abort()
 			return
 		}
+		if user.Disabled {
+			response.Error(ctx, http.StatusForbidden, "Account is disabled")
+			ctx.Abort()
+			return
+		}
 
-		ctx.Set("userID", claims.UserID)
+		ctx.Set("userID", user.ID)
+		ctx.Set("role", user.Role)
+		ctx.Set("emailVerified", user.EmailVerified)
 
 		ctx.Next()
 	}
 }
 
-//
-//func AdminMiddleware() gin.HandlerFunc {
-//	return func(ctx *gin.Context) {
-//
-//		response.Error(ctx, http.StatusForbidden, "Admin access required")
-//		ctx.Abort()
-//	}
-//}
+func authenticateSession(ctx *gin.Context, jwtService jwt.JWTService, sessionRepo repository.SessionRepository, token string) (uint, bool) {
+	claims, err := jwtService.ValidateToken(token)
+	if err != nil {
+		response.Error(ctx, http.StatusUnauthorized, "Invalid token")
+		return 0, false
+	}
+
+	session, err := sessionRepo.FindByID(claims.SessionID)
+	if err != nil || session.UserID != claims.UserID {
+		response.Error(ctx, http.StatusUnauthorized, "Invalid token")
+		return 0, false
+	}
+	if session.RevokedAt != nil {
+		response.Error(ctx, http.StatusUnauthorized, "Session has been revoked")
+		return 0, false
+	}
+
+	if time.Since(session.LastUsedAt) > touchInterval {
+		if err := sessionRepo.Touch(session.ID, ctx.ClientIP()); err != nil {
+			log.Printf("Failed to update session %s: %v", session.ID, err)
+		}
+	}
+
+	ctx.Set("sessionID", session.ID)
+	return session.UserID, true
+}
+
+func authenticateAPIKey(ctx *gin.Context, apiKeyRepo repository.APIKeyRepository, token string) (uint, bool) {
+	key, err := apiKeyRepo.FindByHash(tokenhash.Sum(token))
+	if err != nil || key.RevokedAt != nil {
+		response.Error(ctx, http.StatusUnauthorized, "Invalid API key")
+		return 0, false
+	}
+	if key.ExpiresAt != nil && time.Now().After(*key.ExpiresAt) {
+		response.Error(ctx, http.StatusUnauthorized, "API key has expired")
+		return 0, false
+	}
+
+	if key.LastUsedAt == nil || time.Since(*key.LastUsedAt) > touchInterval {
+		if err := apiKeyRepo.Touch(key.ID); err != nil {
+			log.Printf("Failed to update API key %d: %v", key.ID, err)
+		}
+	}
+
+	ctx.Set("apiKeyScopes", strings.Split(key.Scopes, ","))
+	return key.UserID, true
+}
+
+// CurrentActor возвращает пользователя запроса для проверки прав в сервисах
+func CurrentActor(ctx *gin.Context) policy.Actor {
+	return policy.Actor{UserID: ctx.GetUint("userID"), Admin: ctx.GetString("role") == model.RoleAdmin}
+}
+
+// RequireRole пропускает только пользователей с одной из перечисленных ролей
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if !slices.Contains(roles, ctx.GetString("role")) {
+			response.Error(ctx, http.StatusForbidden, "Insufficient permissions")
+			ctx.Abort()
+			return
+		}
+
+		ctx.Next()
+	}
+}
+
+// RequireScope пропускает запросы с API-ключом, только если у ключа есть область scope.
+// Запросы с токеном доступа из сессии проходят без ограничений
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if !HasScope(ctx, scope) {
+			response.Error(ctx, http.StatusForbidden, "API key does not have the "+scope+" scope")
+			ctx.Abort()
+			return
+		}
+
+		ctx.Next()
+	}
+}
+
+// HasScope сообщает, доступна ли запросу область scope: для сессии - всегда, для API-ключа - если она выдана ключу
+func HasScope(ctx *gin.Context, scope string) bool {
+	scopes, isAPIKey := ctx.Get("apiKeyScopes")
+	return !isAPIKey || slices.Contains(scopes.([]string), scope)
+}
+
+// RequireSession отклоняет запросы с API-ключом: управление учетной записью и администрирование
+// доступны только после входа
+func RequireSession() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if _, isAPIKey := ctx.Get("apiKeyScopes"); isAPIKey {
+			response.Error(ctx, http.StatusForbidden, "API keys cannot be used for this endpoint")
+			ctx.Abort()
+			return
+		}
+
+		ctx.Next()
+	}
+}
+
+// RequireVerifiedEmail пропускает только пользователей с подтвержденным email
+func RequireVerifiedEmail() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if !ctx.GetBool("emailVerified") {
+			response.Error(ctx, http.StatusForbidden, "Email verification required")
+			ctx.Abort()
+			return
+		}
+
+		ctx.Next()
+	}
+}
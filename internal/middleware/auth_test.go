@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"MusicService/internal/model"
+	"MusicService/internal/repository"
+	"MusicService/pkg/jwt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type fakeUserRepository struct {
+	repository.UserRepository
+	users map[uint]*model.User
+}
+
+func (r *fakeUserRepository) FindByID(id uint) (*model.User, error) {
+	if user, ok := r.users[id]; ok {
+		return user, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+type fakeSessionRepository struct {
+	repository.SessionRepository
+	sessions map[string]*model.Session
+}
+
+func (r *fakeSessionRepository) FindByID(id string) (*model.Session, error) {
+	if session, ok := r.sessions[id]; ok {
+		return session, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *fakeSessionRepository) Touch(id string, ip string) error {
+	return nil
+}
+
+func TestAuthMiddlewareQueryToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	jwtService := jwt.NewJWTService("secret", time.Hour)
+	token, err := jwtService.GenerateToken(1, model.RoleUser, "session-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	userRepo := &fakeUserRepository{users: map[uint]*model.User{1: {Model: gorm.Model{ID: 1}, Role: model.RoleUser}}}
+	sessionRepo := &fakeSessionRepository{sessions: map[string]*model.Session{
+		"session-1": {ID: "session-1", UserID: 1, LastUsedAt: time.Now()},
+	}}
+
+	router := gin.New()
+	api := router.Group("/api", AuthMiddleware(jwtService, userRepo, sessionRepo, nil, "/api/tracks/:id/hls/"))
+	ok := func(ctx *gin.Context) { ctx.Status(http.StatusOK) }
+	api.GET("/tracks/:id", ok)
+	api.GET("/tracks/:id/hls/master.m3u8", ok)
+	api.GET("/tracks/:id/hls/:variant/:file", ok)
+
+	tests := []struct {
+		name   string
+		target string
+		header bool
+		want   int
+	}{
+		{name: "header on any route", target: "/api/tracks/1", header: true, want: http.StatusOK},
+		{name: "query on master playlist", target: "/api/tracks/1/hls/master.m3u8?access_token=" + token, want: http.StatusOK},
+		{name: "query on segment", target: "/api/tracks/1/hls/128/segment_000.ts?access_token=" + token, want: http.StatusOK},
+		{name: "query on other route", target: "/api/tracks/1?access_token=" + token, want: http.StatusUnauthorized},
+		{name: "no token on hls route", target: "/api/tracks/1/hls/master.m3u8", want: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.header {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"MusicService/pkg/ratelimit"
+	"MusicService/pkg/response"
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimitKey выбирает, по какому признаку считать запросы. Пустой ключ - запрос не ограничивается
+type RateLimitKey func(ctx *gin.Context) string
+
+// ByIP считает запросы с одного IP-адреса
+func ByIP(ctx *gin.Context) string {
+	return "ip:" + ctx.ClientIP()
+}
+
+// ByUser считает запросы пользователя; используется после AuthMiddleware
+func ByUser(ctx *gin.Context) string {
+	userID := ctx.GetUint("userID")
+	if userID == 0 {
+		return ByIP(ctx)
+	}
+	return "user:" + strconv.FormatUint(uint64(userID), 10)
+}
+
+// ByEmail считает попытки входа в одну учетную запись по полю email тела запроса.
+// Читается не больше 64 КБ тела; прочитанное возвращается перед остатком, чтобы обработчик
+// получил тело целиком
+func ByEmail(ctx *gin.Context) string {
+	body, err := io.ReadAll(io.LimitReader(ctx.Request.Body, 64<<10))
+	if err != nil {
+		return ""
+	}
+	ctx.Request.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), ctx.Request.Body))
+
+	var req struct {
+		Email string `json:"email"`
+	}
+	if json.Unmarshal(body, &req) != nil || req.Email == "" {
+		return ""
+	}
+	return "email:" + strings.ToLower(req.Email)
+}
+
+// RateLimit ограничивает частоту запросов по алгоритму token bucket. name разделяет счетчики
+// разных лимитов с одинаковыми ключами. Если хранилище недоступно, запрос пропускается:
+// отказ ограничителя не должен останавливать сервис
+func RateLimit(store ratelimit.Store, name string, limit ratelimit.Limit, key RateLimitKey) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if !limit.Enabled() {
+			ctx.Next()
+			return
+		}
+
+		k := key(ctx)
+		if k == "" {
+			ctx.Next()
+			return
+		}
+
+		result, err := store.Take(ctx.Request.Context(), "ratelimit:"+name+":"+k, limit)
+		if err != nil {
+			log.Printf("Rate limiter %s failed: %v", name, err)
+			ctx.Next()
+			return
+		}
+
+		ctx.Header("X-RateLimit-Limit", strconv.Itoa(limit.Burst))
+		ctx.Header("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		if !result.Allowed {
+			response.TooManyRequests(ctx, result.RetryAfter, "Too many requests, please try again later")
+			return
+		}
+
+		ctx.Next()
+	}
+}
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestByEmailKeepsBody(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		wantKey string
+	}{
+		{"small body", `{"email":"Alice@Example.com","password":"secret"}`, "email:alice@example.com"},
+		{"body over the read limit", `{"email":"alice@example.com","password":"` + strings.Repeat("x", 100<<10) + `"}`, ""},
+		{"no email", `{"password":"secret"}`, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var key, received string
+			router := gin.New()
+			router.POST("/login", func(ctx *gin.Context) {
+				key = ByEmail(ctx)
+				body, err := io.ReadAll(ctx.Request.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				received = string(body)
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(tt.body))
+			router.ServeHTTP(httptest.NewRecorder(), req)
+
+			if key != tt.wantKey {
+				t.Errorf("key = %q, want %q", key, tt.wantKey)
+			}
+			if received != tt.body {
+				t.Errorf("handler received %d bytes, want the full %d-byte body", len(received), len(tt.body))
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package model
+
+import "time"
+
+// Состояния выгрузки данных
+const (
+	DataExportPending = "pending"
+	DataExportRunning = "running"
+	DataExportReady   = "ready"
+	DataExportFailed  = "failed"
+)
+
+// DataExport - задание на выгрузку данных пользователя в ZIP-архив
+type DataExport struct {
+	ID           string `gorm:"primaryKey;type:uuid"`
+	UserID       uint   `gorm:"not null;index"`
+	Status       string `gorm:"not null"`
+	IncludeAudio bool   `gorm:"not null;default:false"`
+	ObjectPath   string // путь архива в MinIO
+	Size         int64
+	Error        string
+	CreatedAt    time.Time
+	CompletedAt  *time.Time
+	ExpiresAt    *time.Time // после этого архив удаляется
+}
+
+type CreateDataExportRequest struct {
+	IncludeAudio bool `json:"includeAudio"` // добавить в архив аудиофайлы загруженных треков
+}
+
+type DataExportResponse struct {
+	ID           string  `json:"id"`
+	Status       string  `json:"status"`
+	IncludeAudio bool    `json:"includeAudio"`
+	Size         int64   `json:"size,omitempty"`
+	Error        string  `json:"error,omitempty"`
+	CreatedAt    string  `json:"createdAt"`
+	CompletedAt  *string `json:"completedAt,omitempty"`
+	ExpiresAt    *string `json:"expiresAt,omitempty"`
+	DownloadURL  string  `json:"downloadUrl,omitempty"` // подписанная ссылка, только для готового архива
+}
+
+// Что сделать с загруженными треками, плейлистами и историей прослушиваний при удалении учетной записи
+const (
+	AccountDataDelete    = "delete"    // удалить вместе с файлами в хранилище
+	AccountDataAnonymize = "anonymize" // оставить, отвязав от пользователя
+)
+
+type DeleteAccountRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code"` // код 2FA, если она включена
+	Data     string `json:"data" binding:"required,oneof=delete anonymize"`
+}
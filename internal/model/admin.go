@@ -0,0 +1,25 @@
+package model
+
+// AdminUserResponse - сведения о пользователе для администратора
+type AdminUserResponse struct {
+	UserResponse
+	Disabled  bool   `json:"disabled"`
+	CreatedAt string `json:"createdAt"`
+}
+
+type AdminUserSearchParams struct {
+	Query string `form:"q"` // подстрока имени пользователя или email
+}
+
+type UpdateUserRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=user uploader admin"`
+}
+
+// SystemStats - общие показатели сервиса
+type SystemStats struct {
+	Users        int64 `json:"users"`
+	Tracks       int64 `json:"tracks"`
+	Playlists    int64 `json:"playlists"`
+	StorageBytes int64 `json:"storageBytes"` // объем бакета MinIO, включая обложки и кэш перекодирования
+	Plays        int64 `json:"plays"`
+}
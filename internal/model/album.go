@@ -0,0 +1,35 @@
+package model
+
+import "gorm.io/gorm"
+
+type Album struct {
+	gorm.Model
+	Title           string `gorm:"not null"`
+	NormalizedTitle string `gorm:"not null;uniqueIndex:idx_albums_artist_title"`
+	ArtistID        uint   `gorm:"not null;uniqueIndex:idx_albums_artist_title"` // исполнитель альбома
+	Artist          Artist `json:"-"`
+	Year            int
+	Genre           string
+	Tracks          []Track `json:"-" gorm:"foreignKey:AlbumID"`
+	// Считаются подзапросами при выборке, в таблице не хранятся
+	TrackCount   int64 `json:"-" gorm:"->;-:migration"`
+	Duration     int64 `json:"-" gorm:"->;-:migration"` // in seconds
+	CoverTrackID uint  `json:"-" gorm:"->;-:migration"` // первый трек с обложкой, 0 - обложки нет
+}
+
+type AlbumResponse struct {
+	ID         uint   `json:"id"`
+	Title      string `json:"title"`
+	ArtistID   uint   `json:"artist_id"`
+	Artist     string `json:"artist"`
+	Year       int    `json:"year,omitempty"`
+	Genre      string `json:"genre,omitempty"`
+	TrackCount int    `json:"track_count"`
+	Duration   int    `json:"duration"`
+	ImageURL   string `json:"image_url,omitempty"`
+}
+
+type AlbumDetailResponse struct {
+	AlbumResponse
+	Tracks []TrackResponse `json:"tracks"`
+}
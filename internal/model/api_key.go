@@ -0,0 +1,49 @@
+package model
+
+import "time"
+
+// Области действия API-ключей. Запросы с токеном доступа из сессии не ограничены областями
+const (
+	APIKeyScopeRead      = "read"      // просмотр и прослушивание каталога
+	APIKeyScopeUpload    = "upload"    // загрузка и удаление своих треков
+	APIKeyScopePlaylists = "playlists" // управление плейлистами
+	APIKeyScopeStats     = "stats"     // статистика прослушиваний
+)
+
+// APIKeyPrefix отличает API-ключи от JWT в заголовке Authorization
+const APIKeyPrefix = "msk_"
+
+// APIKey - долгоживущий ключ для скриптов и интеграций. Хранится только SHA-256 от ключа
+type APIKey struct {
+	ID         uint   `gorm:"primarykey"`
+	UserID     uint   `gorm:"not null;index"`
+	Name       string `gorm:"not null"`
+	Prefix     string `gorm:"not null"` // начало ключа, чтобы пользователь мог его узнать в списке
+	KeyHash    string `gorm:"not null;uniqueIndex"`
+	Scopes     string `gorm:"not null"` // области через запятую
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+	CreatedAt  time.Time
+}
+
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" binding:"required,max=100"`
+	Scopes    []string   `json:"scopes" binding:"required,min=1,dive,oneof=read upload playlists stats"`
+	ExpiresAt *time.Time `json:"expiresAt"` // RFC 3339; без срока ключ действует до отзыва
+}
+
+type APIKeyResponse struct {
+	ID         uint     `json:"id"`
+	Name       string   `json:"name"`
+	Prefix     string   `json:"prefix"`
+	Scopes     []string `json:"scopes"`
+	ExpiresAt  *string  `json:"expiresAt"`
+	LastUsedAt *string  `json:"lastUsedAt"`
+	CreatedAt  string   `json:"createdAt"`
+}
+
+type CreatedAPIKeyResponse struct {
+	APIKeyResponse
+	Key string `json:"key"` // показывается один раз
+}
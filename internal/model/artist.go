@@ -0,0 +1,26 @@
+package model
+
+import "gorm.io/gorm"
+
+type Artist struct {
+	gorm.Model
+	Name string `gorm:"not null"`
+	// NormalizedName - имя в нижнем регистре со схлопнутыми пробелами, по нему исполнители считаются одинаковыми
+	NormalizedName string  `gorm:"uniqueIndex;not null"`
+	Albums         []Album `json:"-" gorm:"foreignKey:ArtistID"`
+	// Считаются подзапросами при выборке, в таблице не хранятся
+	AlbumCount   int64 `json:"-" gorm:"->;-:migration"`
+	CoverTrackID uint  `json:"-" gorm:"->;-:migration"` // первый трек с обложкой, 0 - обложки нет
+}
+
+type ArtistResponse struct {
+	ID         uint   `json:"id"`
+	Name       string `json:"name"`
+	AlbumCount int    `json:"album_count"`
+	ImageURL   string `json:"image_url,omitempty"`
+}
+
+type ArtistDetailResponse struct {
+	ArtistResponse
+	Albums []AlbumResponse `json:"albums"`
+}
@@ -0,0 +1,14 @@
+package model
+
+import "time"
+
+// ExternalIdentity связывает пользователя с учетной записью у внешнего провайдера OpenID Connect.
+// Subject - неизменный идентификатор пользователя у провайдера (claim sub)
+type ExternalIdentity struct {
+	ID        uint   `gorm:"primarykey"`
+	UserID    uint   `gorm:"not null;index"`
+	Provider  string `gorm:"not null;uniqueIndex:idx_external_identity_subject"`
+	Subject   string `gorm:"not null;uniqueIndex:idx_external_identity_subject"`
+	Email     string // адрес у провайдера на момент привязки
+	CreatedAt time.Time
+}
@@ -0,0 +1,24 @@
+package model
+
+const (
+	DefaultPerPage = 50
+	MaxPerPage     = 200
+)
+
+// PageParams - параметры постраничного вывода. Без cursor страница выбирается по номеру (page),
+// с cursor - продолжается с места, где закончилась предыдущая выдача (keyset)
+type PageParams struct {
+	Page    int    `form:"page" binding:"omitempty,min=1"`
+	PerPage int    `form:"per_page" binding:"omitempty,min=1"`
+	Cursor  string `form:"cursor"`
+	Sort    string `form:"sort"` // поле из белого списка ресурса, "-" в начале - по убыванию
+}
+
+// Page - страница выдачи с общим числом записей и курсором следующей страницы
+type Page[T any] struct {
+	Items      []T
+	Total      int64
+	Page       int // 0 при выборке по курсору
+	PerPage    int
+	NextCursor string // пустой на последней странице
+}
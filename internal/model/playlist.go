@@ -1,13 +1,28 @@
 package model
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type Playlist struct {
 	gorm.Model
 	Name        string `gorm:"not null"`
 	Description string
-	UserID      uint    `gorm:"not null"`
-	Tracks      []Track `gorm:"many2many:playlist_tracks;"`
+	UserID      uint            `gorm:"not null"`
+	Entries     []PlaylistEntry `gorm:"foreignKey:PlaylistID;constraint:OnDelete:CASCADE"`
+}
+
+// PlaylistEntry - позиция трека в плейлисте. Один трек может входить в плейлист несколько раз
+type PlaylistEntry struct {
+	ID         uint      `gorm:"primarykey"`
+	PlaylistID uint      `gorm:"not null;index:idx_playlist_entries_position"`
+	Position   int       `gorm:"not null;index:idx_playlist_entries_position"` // с нуля
+	TrackID    uint      `gorm:"not null;index"`
+	Track      Track     `gorm:"foreignKey:TrackID"`
+	AddedBy    uint      `gorm:"not null"` // user ID
+	AddedAt    time.Time `gorm:"not null;autoCreateTime"`
 }
 
 type PlaylistRequest struct {
@@ -16,13 +31,34 @@ type PlaylistRequest struct {
 }
 
 type PlaylistResponse struct {
-	ID          uint            `json:"id"`
-	Name        string          `json:"name"`
-	Description string          `json:"description"`
-	Tracks      []TrackResponse `json:"tracks"`
-	CreatedAt   string          `json:"createdAt"`
+	ID          uint                    `json:"id"`
+	Name        string                  `json:"name"`
+	Description string                  `json:"description"`
+	UserID      uint                    `json:"userId"`
+	Tracks      []TrackResponse         `json:"tracks"` // в порядке плейлиста
+	Entries     []PlaylistEntryResponse `json:"entries"`
+	CreatedAt   string                  `json:"createdAt"`
+}
+
+type PlaylistEntryResponse struct {
+	ID       uint          `json:"id"`
+	Position int           `json:"position"`
+	AddedAt  string        `json:"addedAt"`
+	AddedBy  uint          `json:"addedBy"`
+	Track    TrackResponse `json:"track"`
 }
 
 type AddTrackToPlaylistRequest struct {
 	TrackID uint `json:"trackId" binding:"required"`
+	// Position - позиция вставки с нуля; по умолчанию трек добавляется в конец
+	Position *int `json:"position" binding:"omitempty,min=0"`
+}
+
+type MovePlaylistEntryRequest struct {
+	Position *int `json:"position" binding:"required,min=0"`
+}
+
+// ReorderPlaylistRequest - новый порядок плейлиста: все ID записей, каждый ровно один раз
+type ReorderPlaylistRequest struct {
+	EntryIDs []uint `json:"entryIds" binding:"required"`
 }
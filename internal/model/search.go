@@ -0,0 +1,29 @@
+package model
+
+// Типы подсказок поисковой строки в порядке показа
+const (
+	SuggestionArtist   = "artist"
+	SuggestionTrack    = "track"
+	SuggestionAlbum    = "album"
+	SuggestionPlaylist = "playlist"
+	SuggestionGenre    = "genre"
+)
+
+// Suggestion - подсказка поисковой строки: ровно столько полей, сколько нужно, чтобы показать ее
+// и перейти к объекту
+type Suggestion struct {
+	Type     string `json:"type"`
+	ID       uint   `json:"id,omitempty"` // у жанров нет
+	Text     string `json:"text"`
+	Subtitle string `json:"subtitle,omitempty"` // исполнитель трека или альбома
+}
+
+type SuggestParams struct {
+	Query string `form:"q" binding:"required"`
+	Limit int    `form:"limit" binding:"omitempty,min=1,max=10"` // подсказок каждого типа, по умолчанию 5
+}
+
+type SuggestResponse struct {
+	Query       string       `json:"query"`
+	Suggestions []Suggestion `json:"suggestions"`
+}
@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+const (
+	StarItemSong   = "song"
+	StarItemAlbum  = "album"
+	StarItemArtist = "artist"
+)
+
+// Star - отметка "избранное" пользователя для трека, альбома или исполнителя
+type Star struct {
+	ID        uint      `gorm:"primarykey"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_stars_item"`
+	ItemType  string    `gorm:"not null;uniqueIndex:idx_stars_item"` // song, album или artist
+	ItemID    string    `gorm:"not null;uniqueIndex:idx_stars_item"`
+	CreatedAt time.Time `gorm:"not null"`
+}
@@ -19,6 +19,7 @@ type TrackPlayStats struct {
 }
 
 type ArtistPlayStats struct {
+	ArtistID  uint   `json:"artist_id" db:"artist_id"`
 	Artist    string `json:"artist" db:"artist"`
 	PlayCount int    `json:"play_count" db:"play_count"`
 }
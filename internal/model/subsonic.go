@@ -0,0 +1,190 @@
+package model
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+const (
+	SubsonicAPIVersion = "1.16.1"
+	SubsonicServerType = "music-service"
+)
+
+// Коды ошибок Subsonic API
+const (
+	SubsonicErrGeneric          = 0
+	SubsonicErrMissingParameter = 10
+	SubsonicErrWrongCredentials = 40
+	SubsonicErrNotAuthorized    = 50
+	SubsonicErrNotFound         = 70
+)
+
+// SubsonicResponse - корневой элемент ответа. Одна и та же структура сериализуется
+// в XML и в JSON (внутри объекта "subsonic-response"), поэтому теги xml и json совпадают
+type SubsonicResponse struct {
+	XMLName       xml.Name `xml:"http://subsonic.org/restapi subsonic-response" json:"-"`
+	Status        string   `xml:"status,attr" json:"status"`
+	Version       string   `xml:"version,attr" json:"version"`
+	Type          string   `xml:"type,attr" json:"type"`
+	ServerVersion string   `xml:"serverVersion,attr" json:"serverVersion"`
+	OpenSubsonic  bool     `xml:"openSubsonic,attr" json:"openSubsonic"`
+
+	Error                  *SubsonicError             `xml:"error,omitempty" json:"error,omitempty"`
+	License                *SubsonicLicense           `xml:"license,omitempty" json:"license,omitempty"`
+	MusicFolders           *SubsonicMusicFolders      `xml:"musicFolders,omitempty" json:"musicFolders,omitempty"`
+	Artists                *SubsonicArtists           `xml:"artists,omitempty" json:"artists,omitempty"`
+	Artist                 *SubsonicArtistWithAlbums  `xml:"artist,omitempty" json:"artist,omitempty"`
+	Album                  *SubsonicAlbumWithSongs    `xml:"album,omitempty" json:"album,omitempty"`
+	Song                   *SubsonicSong              `xml:"song,omitempty" json:"song,omitempty"`
+	SearchResult3          *SubsonicSearchResult3     `xml:"searchResult3,omitempty" json:"searchResult3,omitempty"`
+	Playlists              *SubsonicPlaylists         `xml:"playlists,omitempty" json:"playlists,omitempty"`
+	Playlist               *SubsonicPlaylistWithSongs `xml:"playlist,omitempty" json:"playlist,omitempty"`
+	Starred2               *SubsonicStarred2          `xml:"starred2,omitempty" json:"starred2,omitempty"`
+	OpenSubsonicExtensions []SubsonicExtension        `xml:"openSubsonicExtensions,omitempty" json:"openSubsonicExtensions,omitempty"`
+}
+
+type SubsonicError struct {
+	Code    int    `xml:"code,attr" json:"code"`
+	Message string `xml:"message,attr" json:"message"`
+}
+
+type SubsonicLicense struct {
+	Valid bool `xml:"valid,attr" json:"valid"`
+}
+
+type SubsonicMusicFolders struct {
+	Folders []SubsonicMusicFolder `xml:"musicFolder" json:"musicFolder"`
+}
+
+type SubsonicMusicFolder struct {
+	ID   int    `xml:"id,attr" json:"id"`
+	Name string `xml:"name,attr" json:"name"`
+}
+
+type SubsonicArtists struct {
+	IgnoredArticles string          `xml:"ignoredArticles,attr" json:"ignoredArticles"`
+	Index           []SubsonicIndex `xml:"index" json:"index,omitempty"`
+}
+
+type SubsonicIndex struct {
+	Name    string           `xml:"name,attr" json:"name"`
+	Artists []SubsonicArtist `xml:"artist" json:"artist"`
+}
+
+type SubsonicArtist struct {
+	ID         string     `xml:"id,attr" json:"id"`
+	Name       string     `xml:"name,attr" json:"name"`
+	CoverArt   string     `xml:"coverArt,attr,omitempty" json:"coverArt,omitempty"`
+	AlbumCount int        `xml:"albumCount,attr" json:"albumCount"`
+	Starred    *time.Time `xml:"starred,attr,omitempty" json:"starred,omitempty"`
+}
+
+type SubsonicArtistWithAlbums struct {
+	SubsonicArtist
+	Albums []SubsonicAlbum `xml:"album" json:"album,omitempty"`
+}
+
+type SubsonicAlbum struct {
+	ID        string     `xml:"id,attr" json:"id"`
+	Name      string     `xml:"name,attr" json:"name"`
+	Artist    string     `xml:"artist,attr" json:"artist"`
+	ArtistID  string     `xml:"artistId,attr" json:"artistId"`
+	CoverArt  string     `xml:"coverArt,attr,omitempty" json:"coverArt,omitempty"`
+	SongCount int        `xml:"songCount,attr" json:"songCount"`
+	Duration  int        `xml:"duration,attr" json:"duration"`
+	Created   time.Time  `xml:"created,attr" json:"created"`
+	Year      int        `xml:"year,attr,omitempty" json:"year,omitempty"`
+	Genre     string     `xml:"genre,attr,omitempty" json:"genre,omitempty"`
+	Starred   *time.Time `xml:"starred,attr,omitempty" json:"starred,omitempty"`
+}
+
+type SubsonicAlbumWithSongs struct {
+	SubsonicAlbum
+	Songs []SubsonicSong `xml:"song" json:"song,omitempty"`
+}
+
+// SubsonicSong - элемент Child спецификации Subsonic для аудиофайла
+type SubsonicSong struct {
+	ID           string     `xml:"id,attr" json:"id"`
+	Parent       string     `xml:"parent,attr,omitempty" json:"parent,omitempty"`
+	IsDir        bool       `xml:"isDir,attr" json:"isDir"`
+	Title        string     `xml:"title,attr" json:"title"`
+	Album        string     `xml:"album,attr,omitempty" json:"album,omitempty"`
+	Artist       string     `xml:"artist,attr,omitempty" json:"artist,omitempty"`
+	Track        int        `xml:"track,attr,omitempty" json:"track,omitempty"`
+	Year         int        `xml:"year,attr,omitempty" json:"year,omitempty"`
+	Genre        string     `xml:"genre,attr,omitempty" json:"genre,omitempty"`
+	CoverArt     string     `xml:"coverArt,attr,omitempty" json:"coverArt,omitempty"`
+	ContentType  string     `xml:"contentType,attr,omitempty" json:"contentType,omitempty"`
+	Suffix       string     `xml:"suffix,attr,omitempty" json:"suffix,omitempty"`
+	Duration     int        `xml:"duration,attr,omitempty" json:"duration,omitempty"`
+	BitRate      int        `xml:"bitRate,attr,omitempty" json:"bitRate,omitempty"`
+	SamplingRate int        `xml:"samplingRate,attr,omitempty" json:"samplingRate,omitempty"`
+	ChannelCount int        `xml:"channelCount,attr,omitempty" json:"channelCount,omitempty"`
+	Path         string     `xml:"path,attr,omitempty" json:"path,omitempty"`
+	DiscNumber   int        `xml:"discNumber,attr,omitempty" json:"discNumber,omitempty"`
+	Created      time.Time  `xml:"created,attr" json:"created"`
+	AlbumID      string     `xml:"albumId,attr,omitempty" json:"albumId,omitempty"`
+	ArtistID     string     `xml:"artistId,attr,omitempty" json:"artistId,omitempty"`
+	Type         string     `xml:"type,attr" json:"type"`
+	MediaType    string     `xml:"mediaType,attr" json:"mediaType"`
+	Starred      *time.Time `xml:"starred,attr,omitempty" json:"starred,omitempty"`
+}
+
+type SubsonicSearchResult3 struct {
+	Artists []SubsonicArtist `xml:"artist" json:"artist,omitempty"`
+	Albums  []SubsonicAlbum  `xml:"album" json:"album,omitempty"`
+	Songs   []SubsonicSong   `xml:"song" json:"song,omitempty"`
+}
+
+type SubsonicPlaylists struct {
+	Playlists []SubsonicPlaylist `xml:"playlist" json:"playlist,omitempty"`
+}
+
+type SubsonicPlaylist struct {
+	ID        string    `xml:"id,attr" json:"id"`
+	Name      string    `xml:"name,attr" json:"name"`
+	Comment   string    `xml:"comment,attr,omitempty" json:"comment,omitempty"`
+	Owner     string    `xml:"owner,attr" json:"owner"`
+	Public    bool      `xml:"public,attr" json:"public"`
+	SongCount int       `xml:"songCount,attr" json:"songCount"`
+	Duration  int       `xml:"duration,attr" json:"duration"`
+	Created   time.Time `xml:"created,attr" json:"created"`
+	Changed   time.Time `xml:"changed,attr" json:"changed"`
+	CoverArt  string    `xml:"coverArt,attr,omitempty" json:"coverArt,omitempty"`
+}
+
+type SubsonicPlaylistWithSongs struct {
+	SubsonicPlaylist
+	Entries []SubsonicSong `xml:"entry" json:"entry,omitempty"`
+}
+
+type SubsonicStarred2 struct {
+	Artists []SubsonicArtist `xml:"artist" json:"artist,omitempty"`
+	Albums  []SubsonicAlbum  `xml:"album" json:"album,omitempty"`
+	Songs   []SubsonicSong   `xml:"song" json:"song,omitempty"`
+}
+
+type SubsonicExtension struct {
+	Name     string `xml:"name,attr" json:"name"`
+	Versions []int  `xml:"versions" json:"versions"`
+}
+
+// SubsonicSearchParams - параметры search3
+type SubsonicSearchParams struct {
+	Query        string `form:"query"`
+	ArtistCount  int    `form:"artistCount,default=20"`
+	ArtistOffset int    `form:"artistOffset"`
+	AlbumCount   int    `form:"albumCount,default=20"`
+	AlbumOffset  int    `form:"albumOffset"`
+	SongCount    int    `form:"songCount,default=20"`
+	SongOffset   int    `form:"songOffset"`
+}
+
+// SubsonicStarParams - идентификаторы для star/unstar. В id допускаются идентификаторы
+// треков, альбомов и исполнителей, в albumId и artistId - только соответствующих сущностей
+type SubsonicStarParams struct {
+	IDs       []string `form:"id"`
+	AlbumIDs  []string `form:"albumId"`
+	ArtistIDs []string `form:"artistId"`
+}
@@ -0,0 +1,71 @@
+package model
+
+import "time"
+
+// Session - вход пользователя с одного устройства. Токены доступа ссылаются на сессию через claim sid,
+// поэтому отзыв сессии сразу делает их недействительными
+type Session struct {
+	ID         string `gorm:"primaryKey;size:36"`
+	UserID     uint   `gorm:"not null;index"`
+	UserAgent  string
+	IP         string
+	CreatedAt  time.Time
+	LastUsedAt time.Time `gorm:"not null"`
+	ExpiresAt  time.Time `gorm:"not null"` // истечение последнего токена обновления
+	RevokedAt  *time.Time
+}
+
+// RefreshToken - токен обновления сессии. В базе хранится только SHA-256 от токена
+type RefreshToken struct {
+	ID        uint       `gorm:"primarykey"`
+	SessionID string     `gorm:"not null;index;size:36"`
+	TokenHash string     `gorm:"not null;uniqueIndex"`
+	ExpiresAt time.Time  `gorm:"not null"`
+	UsedAt    *time.Time // токен обменян на новый; повторное использование означает утечку
+	CreatedAt time.Time
+}
+
+// Назначения токенов UserToken
+const (
+	TokenPurposePasswordReset  = "password_reset"
+	TokenPurposeVerifyEmail    = "verify_email"    // подтверждение адреса, указанного при регистрации
+	TokenPurposeChangeEmail    = "change_email"    // подтверждение нового адреса из PendingEmail
+	TokenPurposeLoginChallenge = "login_challenge" // второй шаг входа с двухфакторной аутентификацией
+)
+
+// UserToken - одноразовый токен для действия по ссылке из письма. Хранится только SHA-256 от токена
+type UserToken struct {
+	ID        uint      `gorm:"primarykey"`
+	UserID    uint      `gorm:"not null;index"`
+	Purpose   string    `gorm:"not null"`
+	TokenHash string    `gorm:"not null;uniqueIndex"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	Attempts  int `gorm:"not null;default:0"` // неудачные попытки, для токенов с проверкой кода
+	CreatedAt time.Time
+}
+
+// ClientInfo - сведения об устройстве, с которого выполняется вход
+type ClientInfo struct {
+	UserAgent string
+	IP        string
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refreshToken" binding:"required"`
+}
+
+type TokenResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
+	ExpiresIn    int    `json:"expiresIn"` // время жизни токена доступа, in seconds
+}
+
+type SessionResponse struct {
+	ID         string `json:"id"`
+	UserAgent  string `json:"userAgent"`
+	IP         string `json:"ip"`
+	CreatedAt  string `json:"createdAt"`
+	LastUsedAt string `json:"lastUsedAt"`
+	Current    bool   `json:"current"` // сессия, от имени которой выполнен запрос
+}
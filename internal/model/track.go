@@ -7,40 +7,148 @@ import (
 
 type Track struct {
 	gorm.Model
-	Title      string `gorm:"not null"`
-	Artist     string `gorm:"not null"`
-	Album      string
-	Genre      string
-	Duration   int                // in seconds
-	ImagePath  string             // path in MinIO
-	FilePath   string             `gorm:"not null"` // path in MinIO
-	UploadedBy uint               `gorm:"not null"` // user ID
-	Listens    []ListeningHistory `json:"-" gorm:"foreignKey:TrackID"`
+	Title       string `gorm:"not null"`
+	Artist      string `gorm:"not null"`
+	ArtistID    *uint  `gorm:"index"` // исполнитель трека
+	Album       string
+	AlbumID     *uint `gorm:"index"`
+	AlbumArtist string
+	Genre       string
+	Year        int
+	TrackNumber int
+	DiscNumber  int
+	Duration    int // in seconds
+	BitRate     int // in kbps
+	SampleRate  int // in Hz
+	Channels    int
+	ImagePath   string             // path in MinIO
+	FilePath    string             `gorm:"not null"` // path in MinIO
+	ContentType string             // detected MIME type of the audio file
+	ImageType   string             // detected MIME type of the image
+	UploadedBy  uint               `gorm:"not null"` // user ID
+	Listens     []ListeningHistory `json:"-" gorm:"foreignKey:TrackID"`
+	PlayCount   int64              `json:"-" gorm:"->;-:migration"` // заполняется только при сортировке по прослушиваниям
+	// Заполняются только при полнотекстовом поиске
+	Rank            float32 `json:"-" gorm:"->;-:migration"`
+	TitleHighlight  string  `json:"-" gorm:"->;-:migration"`
+	ArtistHighlight string  `json:"-" gorm:"->;-:migration"`
+	AlbumHighlight  string  `json:"-" gorm:"->;-:migration"`
 }
 
+// SearchDictionaries - конфигурации текстового поиска, которыми индексируется каждое поле трека:
+// simple находит слова как есть, russian и english - словоформы
+var SearchDictionaries = []string{"simple", "russian", "english"}
+
+// Маркеры начала и конца совпадения в подсветке из базы. В ответе текст экранируется,
+// а маркеры заменяются на <mark>
+const (
+	HighlightStart = "\x02"
+	HighlightStop  = "\x03"
+)
+
+// TrackUploadRequest - поля формы загрузки. Непустые значения имеют приоритет над тегами файла
 type TrackUploadRequest struct {
-	Title  string                `form:"title" binding:"required"`
-	Artist string                `form:"artist" binding:"required"`
-	Album  string                `form:"album"`
-	Genre  string                `form:"genre"`
-	Image  *multipart.FileHeader `form:"image"`
+	Title       string                `form:"title"`
+	Artist      string                `form:"artist"`
+	Album       string                `form:"album"`
+	AlbumArtist string                `form:"album_artist"`
+	Genre       string                `form:"genre"`
+	Year        int                   `form:"year"`
+	TrackNumber int                   `form:"track_number"`
+	DiscNumber  int                   `form:"disc_number"`
+	Image       *multipart.FileHeader `form:"image"`
 }
 
 type TrackResponse struct {
-	ID         uint   `json:"id"`
-	Title      string `json:"title"`
-	Artist     string `json:"artist"`
-	Album      string `json:"album"`
-	Genre      string `json:"genre"`
-	Duration   int    `json:"duration"`
-	ImageURL   string `json:"image_url"`
-	CreatedAt  string `json:"createdAt"`
-	UploadedBy uint   `json:"uploadedBy"`
+	ID          uint   `json:"id"`
+	Title       string `json:"title"`
+	Artist      string `json:"artist"`
+	ArtistID    uint   `json:"artist_id,omitempty"`
+	Album       string `json:"album"`
+	AlbumID     uint   `json:"album_id,omitempty"`
+	AlbumArtist string `json:"album_artist,omitempty"`
+	Genre       string `json:"genre"`
+	Year        int    `json:"year,omitempty"`
+	TrackNumber int    `json:"track_number,omitempty"`
+	DiscNumber  int    `json:"disc_number,omitempty"`
+	Duration    int    `json:"duration"`
+	BitRate     int    `json:"bit_rate,omitempty"`
+	SampleRate  int    `json:"sample_rate,omitempty"`
+	Channels    int    `json:"channels,omitempty"`
+	ImageURL    string `json:"image_url"`
+	CreatedAt   string `json:"createdAt"`
+	UploadedBy  uint   `json:"uploadedBy"`
+	PlayCount   int64  `json:"play_count,omitempty"`
+	// Relevance и Highlight заполняются только в результатах поиска по q
+	Relevance float32         `json:"relevance,omitempty"`
+	Highlight *TrackHighlight `json:"highlight,omitempty"`
+}
+
+// TrackHighlight - поля трека, где совпадения с запросом выделены <mark>. Текст экранирован для HTML
+type TrackHighlight struct {
+	Title  string `json:"title"`
+	Artist string `json:"artist"`
+	Album  string `json:"album,omitempty"`
 }
 
+// TrackSearchResult - страница результатов поиска со счетчиками фасетов. DidYouMean заполняется,
+// когда треки нашлись только нечетким поиском, и содержит наиболее похожие исполнителя или название
+type TrackSearchResult struct {
+	Page       *Page[TrackResponse]
+	Facets     *TrackFacets
+	DidYouMean string
+}
+
+// TrackSearchParams - фильтры списка треков. Query ищется полнотекстово по названию, исполнителю,
+// альбому и жанру с учетом словоформ; последнее слово сопоставляется как префикс.
+// Фильтры фасетов принимают несколько значений: трек подходит, если совпадает с любым из них
 type TrackSearchParams struct {
-	Query  string `form:"q"`
-	Artist string `form:"artist"`
-	Album  string `form:"album"`
-	Genre  string `form:"genre"`
+	Query    string   `form:"q"`
+	Artist   []string `form:"artist"` // без учета регистра
+	Album    []string `form:"album"`
+	Genre    []string `form:"genre"`
+	Decade   []int    `form:"decade"`   // первый год десятилетия: 1990
+	Duration []string `form:"duration"` // имена из DurationBuckets
+	Format   []string `form:"format"`   // mp3, flac, ogg, opus, wav, aac, m4a
+	// Fuzzy разрешает искать Query в написании другим алфавитом, а если так ничего не нашлось -
+	// по сходству с учетом опечаток
+	Fuzzy bool `form:"fuzzy"`
+	// UploadedBy ограничивает выборку треками пользователя, из query не читается
+	UploadedBy uint `form:"-"`
+}
+
+// DurationBucket - диапазон длительности трека для фасета duration: [Min, Max) в секундах, Max = 0 - без границы
+type DurationBucket struct {
+	Name     string
+	Min, Max int
+}
+
+var DurationBuckets = []DurationBucket{
+	{Name: "under_3m", Min: 0, Max: 180},
+	{Name: "3_5m", Min: 180, Max: 300},
+	{Name: "5_10m", Min: 300, Max: 600},
+	{Name: "over_10m", Min: 600},
+}
+
+// FacetValue - значение фасета и число найденных треков с ним
+type FacetValue struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// TrackFacets - счетчики для боковой панели фильтров. Счетчики фасета учитывают все фильтры,
+// кроме его собственного, чтобы можно было выбрать еще одно значение
+type TrackFacets struct {
+	Genre    []FacetValue `json:"genre"`
+	Artist   []FacetValue `json:"artist"`
+	Album    []FacetValue `json:"album"`
+	Decade   []FacetValue `json:"decade"`
+	Duration []FacetValue `json:"duration"`
+	Format   []FacetValue `json:"format"`
+}
+
+// StreamParams - параметры перекодирования при воспроизведении
+type StreamParams struct {
+	Format     string `form:"format"`     // mp3, opus, aac или raw (исходный файл)
+	MaxBitRate int    `form:"maxBitRate"` // in kbps, 0 - без ограничения
 }
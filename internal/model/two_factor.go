@@ -0,0 +1,44 @@
+package model
+
+import "time"
+
+// RecoveryCode - одноразовый код восстановления на случай потери устройства с аутентификатором.
+// Хранится только SHA-256 от кода
+type RecoveryCode struct {
+	ID        uint   `gorm:"primarykey"`
+	UserID    uint   `gorm:"not null;index"`
+	CodeHash  string `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+type TwoFactorSetupResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauthUri"` // для QR-кода
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"` // показываются один раз
+}
+
+type DisableTwoFactorRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"` // код из приложения или код восстановления
+}
+
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challengeToken" binding:"required"`
+	Code           string `json:"code" binding:"required"` // код из приложения или код восстановления
+}
+
+// LoginResponse - результат входа по паролю: токены или, если включена двухфакторная
+// аутентификация, токен для второго шага /auth/login/2fa
+type LoginResponse struct {
+	*TokenResponse
+	TwoFactorRequired bool   `json:"twoFactorRequired,omitempty"`
+	ChallengeToken    string `json:"challengeToken,omitempty"`
+}
@@ -2,11 +2,34 @@ package model
 
 import "gorm.io/gorm"
 
+// Роли пользователей: user может слушать музыку, вести плейлисты и загружать треки, admin - также
+// управлять пользователями и любым контентом. uploader отличается от user, только если загрузка
+// ограничена настройкой AUTH.RESTRICT_UPLOADS_TO_UPLOADERS
+const (
+	RoleUser     = "user"
+	RoleUploader = "uploader"
+	RoleAdmin    = "admin"
+)
+
 type User struct {
 	gorm.Model
 	Username string `gorm:"unique;not null"`
 	Email    string `gorm:"unique;not null"`
 	Password string `gorm:"not null"`
+	Role     string `gorm:"not null;default:user"`
+	Disabled bool   `gorm:"not null;default:false"`
+	// EmailVerified - владелец подтвердил адрес Email по ссылке из письма
+	EmailVerified bool `gorm:"not null;default:false"`
+	// PendingEmail - новый адрес, который заменит Email после подтверждения
+	PendingEmail string
+	// SubsonicPassword - отдельный пароль для клиентов Subsonic, хранится в зашифрованном виде
+	SubsonicPassword string
+	// TOTPSecret - секрет двухфакторной аутентификации в зашифрованном виде. Задается при настройке,
+	// но действует только после подтверждения первым кодом (TOTPEnabled)
+	TOTPSecret  string
+	TOTPEnabled bool `gorm:"not null;default:false"`
+	// TOTPLastStep - шаг последнего принятого кода; коды с этим и более ранними шагами отклоняются
+	TOTPLastStep int64
 }
 
 type RegisterRequest struct {
@@ -24,4 +47,36 @@ type UserResponse struct {
 	ID       uint   `json:"id"`
 	Username string `json:"username"`
 	Email    string `json:"email"`
+	Role     string `json:"role"`
+	// Поля ниже только для чтения; email меняется через подтверждение нового адреса
+	EmailVerified    bool   `json:"emailVerified"`
+	PendingEmail     string `json:"pendingEmail,omitempty"`
+	TwoFactorEnabled bool   `json:"twoFactorEnabled"`
+}
+
+type SubsonicPasswordRequest struct {
+	Password string `json:"password" binding:"required,min=8"`
+}
+
+type UpdateProfileRequest struct {
+	Username string `json:"username" binding:"required"`
+	Email    string `json:"email" binding:"required,email"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"currentPassword" binding:"required"`
+	NewPassword     string `json:"newPassword" binding:"required,min=8"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"newPassword" binding:"required,min=8"`
 }
@@ -0,0 +1,61 @@
+// Package policy описывает, какие действия над ресурсами разрешены пользователю.
+// Сервисы проверяют права перед изменением данных и возвращают ErrForbidden при отказе
+package policy
+
+import (
+	"MusicService/internal/model"
+	"errors"
+	"fmt"
+)
+
+type Action string
+
+const (
+	ActionView   Action = "view"
+	ActionUpdate Action = "update"
+	ActionDelete Action = "delete"
+)
+
+// ErrForbidden - общий признак отказа в доступе, проверяется через errors.Is
+var ErrForbidden = errors.New("access denied")
+
+// ForbiddenError описывает, какое действие и над каким ресурсом было запрещено
+type ForbiddenError struct {
+	Action   Action
+	Resource string
+	ID       uint
+}
+
+func (e *ForbiddenError) Error() string {
+	return fmt.Sprintf("access denied: cannot %s %s %d", e.Action, e.Resource, e.ID)
+}
+
+func (e *ForbiddenError) Is(target error) bool {
+	return target == ErrForbidden
+}
+
+// Actor - пользователь, от имени которого выполняется действие
+type Actor struct {
+	UserID uint
+	Admin  bool
+}
+
+func (a Actor) owns(ownerID uint) bool {
+	return a.UserID != 0 && a.UserID == ownerID
+}
+
+// Track: треки видны всем, изменять и удалять их может загрузивший пользователь или администратор
+func Track(actor Actor, action Action, track *model.Track) error {
+	if action == ActionView || actor.owns(track.UploadedBy) || actor.Admin {
+		return nil
+	}
+	return &ForbiddenError{Action: action, Resource: "track", ID: track.ID}
+}
+
+// Playlist: плейлисты видны всем, изменять и удалять их может владелец или администратор
+func Playlist(actor Actor, action Action, playlist *model.Playlist) error {
+	if action == ActionView || actor.owns(playlist.UserID) || actor.Admin {
+		return nil
+	}
+	return &ForbiddenError{Action: action, Resource: "playlist", ID: playlist.ID}
+}
@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"MusicService/internal/model"
+
+	"gorm.io/gorm"
+)
+
+type AccountRepository interface {
+	DeleteUser(userID uint, anonymize bool) error
+}
+
+type accountRepository struct {
+	db *gorm.DB
+}
+
+func NewAccountRepository(db *gorm.DB) AccountRepository {
+	return &accountRepository{db: db}
+}
+
+// DeleteUser удаляет пользователя и все связанные с ним записи в одной транзакции.
+// При anonymize загруженные треки, плейлисты и история прослушиваний остаются, но отвязываются
+// от пользователя (user ID 0); иначе они удаляются. Файлы в хранилище удаляет вызывающий
+func (r *accountRepository) DeleteUser(userID uint, anonymize bool) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if anonymize {
+			if err := anonymizeUserContent(tx, userID); err != nil {
+				return err
+			}
+		} else if err := deleteUserContent(tx, userID); err != nil {
+			return err
+		}
+
+		// Записи в чужих плейлистах остаются, но без автора
+		if err := tx.Model(&model.PlaylistEntry{}).Where("added_by = ?", userID).Update("added_by", 0).Error; err != nil {
+			return err
+		}
+
+		sessions := tx.Model(&model.Session{}).Select("id").Where("user_id = ?", userID)
+		if err := tx.Where("session_id IN (?)", sessions).Delete(&model.RefreshToken{}).Error; err != nil {
+			return err
+		}
+
+		for _, table := range []any{
+			&model.Session{},
+			&model.UserToken{},
+			&model.RecoveryCode{},
+			&model.APIKey{},
+			&model.ExternalIdentity{},
+			&model.Star{},
+			&model.DataExport{},
+		} {
+			if err := tx.Where("user_id = ?", userID).Delete(table).Error; err != nil {
+				return err
+			}
+		}
+
+		// Удаление окончательное: мягко удаленная запись сохранила бы email и имя пользователя
+		return tx.Unscoped().Delete(&model.User{}, userID).Error
+	})
+}
+
+func anonymizeUserContent(tx *gorm.DB, userID uint) error {
+	if err := tx.Unscoped().Model(&model.Track{}).Where("uploaded_by = ?", userID).Update("uploaded_by", 0).Error; err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Model(&model.Playlist{}).Where("user_id = ?", userID).Update("user_id", 0).Error; err != nil {
+		return err
+	}
+	return tx.Unscoped().Model(&model.ListeningHistory{}).Where("user_id = ?", userID).Update("user_id", 0).Error
+}
+
+func deleteUserContent(tx *gorm.DB, userID uint) error {
+	// Треки удаляются мягко, как и при обычном удалении: на них ссылается история прослушиваний
+	// других пользователей. Метаданные отвязываются от пользователя, а файлы удаляются из хранилища
+	tracks := tx.Unscoped().Model(&model.Track{}).Select("id").Where("uploaded_by = ?", userID)
+
+	var playlistIDs []uint
+	if err := tx.Model(&model.PlaylistEntry{}).Where("track_id IN (?)", tracks).Distinct().Pluck("playlist_id", &playlistIDs).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("track_id IN (?)", tracks).Delete(&model.PlaylistEntry{}).Error; err != nil {
+		return err
+	}
+	for _, playlistID := range playlistIDs {
+		if err := renumberEntries(tx, playlistID); err != nil {
+			return err
+		}
+	}
+	if err := tx.Where("uploaded_by = ?", userID).Delete(&model.Track{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Model(&model.Track{}).Where("uploaded_by = ?", userID).Update("uploaded_by", 0).Error; err != nil {
+		return err
+	}
+
+	ownPlaylists := tx.Unscoped().Model(&model.Playlist{}).Select("id").Where("user_id = ?", userID)
+	if err := tx.Where("playlist_id IN (?)", ownPlaylists).Delete(&model.PlaylistEntry{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&model.Playlist{}).Error; err != nil {
+		return err
+	}
+
+	return tx.Unscoped().Where("user_id = ?", userID).Delete(&model.ListeningHistory{}).Error
+}
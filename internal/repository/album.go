@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"MusicService/internal/model"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type AlbumRepository interface {
+	GetOrCreate(artistID uint, title, normalizedTitle string) (*model.Album, error)
+	GetByID(id uint) (*model.Album, error)
+	GetAll() ([]model.Album, error)
+	List(page model.PageParams) (*model.Page[model.Album], error)
+	GetByIDs(ids []uint) ([]model.Album, error)
+	Match(query string, offset, limit int) ([]model.Album, error)
+	Update(album *model.Album) error
+}
+
+type albumRepository struct {
+	db *gorm.DB
+}
+
+func NewAlbumRepository(db *gorm.DB) AlbumRepository {
+	return &albumRepository{db: db}
+}
+
+// Подзапросы статистики альбома по неудаленным трекам
+const (
+	albumTrackCountColumn = "(SELECT COUNT(*) FROM tracks WHERE tracks.album_id = albums.id AND tracks.deleted_at IS NULL)"
+	albumDurationColumn   = "(SELECT COALESCE(SUM(tracks.duration), 0) FROM tracks WHERE tracks.album_id = albums.id AND tracks.deleted_at IS NULL)"
+	albumCoverTrackColumn = `COALESCE((SELECT tracks.id FROM tracks
+		WHERE tracks.album_id = albums.id AND tracks.deleted_at IS NULL AND tracks.image_path <> ''
+		ORDER BY tracks.disc_number, tracks.track_number, tracks.title LIMIT 1), 0)`
+)
+
+// withAlbumStats добавляет к выборке альбомов число треков, длительность и трек с обложкой,
+// чтобы не загружать ради них треки
+func withAlbumStats(db *gorm.DB) *gorm.DB {
+	return addSelect(db, "albums",
+		albumTrackCountColumn+" AS track_count",
+		albumDurationColumn+" AS duration",
+		albumCoverTrackColumn+" AS cover_track_id")
+}
+
+// albumHasTracks скрывает альбомы, все треки которых удалены
+func albumHasTracks(db *gorm.DB) *gorm.DB {
+	return db.Where("EXISTS (SELECT 1 FROM tracks WHERE tracks.album_id = albums.id AND tracks.deleted_at IS NULL)")
+}
+
+func preloadAlbumArtist(db *gorm.DB) *gorm.DB {
+	return db.Preload("Artist")
+}
+
+// albumSortKeys - поля, по которым можно сортировать список альбомов
+var albumSortKeys = map[string]sortKey[model.Album]{
+	"title": {
+		column: "albums.normalized_title",
+		cast:   "text",
+		value:  func(a *model.Album) string { return a.NormalizedTitle },
+	},
+	"artist": {
+		column: "album_artist.normalized_name",
+		cast:   "text",
+		value:  func(a *model.Album) string { return a.Artist.NormalizedName },
+		join: func(db *gorm.DB) *gorm.DB {
+			return db.Joins("JOIN artists AS album_artist ON album_artist.id = albums.artist_id")
+		},
+	},
+	"year": {
+		column: "albums.year",
+		cast:   "bigint",
+		value:  func(a *model.Album) string { return strconv.Itoa(a.Year) },
+	},
+	"created_at": {
+		column: "albums.created_at",
+		cast:   "timestamptz",
+		value:  func(a *model.Album) string { return a.CreatedAt.Format(time.RFC3339Nano) },
+	},
+	"track_count": {
+		column: albumTrackCountColumn,
+		cast:   "bigint",
+		value:  func(a *model.Album) string { return strconv.FormatInt(a.TrackCount, 10) },
+	},
+}
+
+func (r *albumRepository) GetOrCreate(artistID uint, title, normalizedTitle string) (*model.Album, error) {
+	album := model.Album{Title: title, NormalizedTitle: normalizedTitle, ArtistID: artistID}
+	if err := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&album).Error; err != nil {
+		return nil, err
+	}
+
+	err := r.db.Where("artist_id = ? AND normalized_title = ?", artistID, normalizedTitle).First(&album).Error
+	return &album, err
+}
+
+// GetByID загружает альбом вместе с исполнителем и треками в порядке дисков и номеров
+func (r *albumRepository) GetByID(id uint) (*model.Album, error) {
+	var album model.Album
+	err := r.db.Scopes(withAlbumStats, preloadAlbumArtist).Preload("Tracks", func(db *gorm.DB) *gorm.DB {
+		return db.Order("disc_number, track_number, title")
+	}).First(&album, id).Error
+	return &album, err
+}
+
+// GetAll возвращает все альбомы с треками вместе с исполнителями, без треков
+func (r *albumRepository) GetAll() ([]model.Album, error) {
+	var albums []model.Album
+	err := r.db.Scopes(withAlbumStats, preloadAlbumArtist, albumHasTracks).Order("normalized_title").Find(&albums).Error
+	return albums, err
+}
+
+func (r *albumRepository) List(page model.PageParams) (*model.Page[model.Album], error) {
+	query := r.db.Model(&model.Album{}).Scopes(albumHasTracks)
+	return paginate(query, page, albumSortKeys, "title", "albums.id", func(a *model.Album) uint { return a.ID },
+		withAlbumStats, preloadAlbumArtist)
+}
+
+// GetByIDs возвращает найденные альбомы из ids с исполнителями, без треков
+func (r *albumRepository) GetByIDs(ids []uint) ([]model.Album, error) {
+	var albums []model.Album
+	err := r.db.Scopes(withAlbumStats, preloadAlbumArtist).Where("albums.id IN ?", ids).Order("normalized_title").Find(&albums).Error
+	return albums, err
+}
+
+// Match возвращает альбомы с треками, название или исполнитель которых содержит query
+// без учета регистра, в порядке исполнителя и названия
+func (r *albumRepository) Match(query string, offset, limit int) ([]model.Album, error) {
+	pattern := likeContains(query)
+	var albums []model.Album
+	err := r.db.Scopes(withAlbumStats, preloadAlbumArtist, albumHasTracks).
+		Joins("JOIN artists AS album_artist ON album_artist.id = albums.artist_id").
+		Where("albums.title ILIKE ? OR album_artist.name ILIKE ?", pattern, pattern).
+		Order("album_artist.normalized_name, albums.normalized_title, albums.id").
+		Offset(offset).Limit(limit).Find(&albums).Error
+	return albums, err
+}
+
+func (r *albumRepository) Update(album *model.Album) error {
+	return r.db.Save(album).Error
+}
@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"MusicService/internal/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type APIKeyRepository interface {
+	Create(key *model.APIKey) error
+	FindByHash(hash string) (*model.APIKey, error)
+	FindByID(id uint) (*model.APIKey, error)
+	GetActiveByUserID(userID uint) ([]model.APIKey, error)
+	Touch(id uint) error
+	Revoke(id uint) error
+}
+
+type apiKeyRepository struct {
+	db *gorm.DB
+}
+
+func NewAPIKeyRepository(db *gorm.DB) APIKeyRepository {
+	return &apiKeyRepository{db: db}
+}
+
+func (r *apiKeyRepository) Create(key *model.APIKey) error {
+	return r.db.Create(key).Error
+}
+
+func (r *apiKeyRepository) FindByHash(hash string) (*model.APIKey, error) {
+	var key model.APIKey
+	err := r.db.Where("key_hash = ?", hash).First(&key).Error
+	return &key, err
+}
+
+func (r *apiKeyRepository) FindByID(id uint) (*model.APIKey, error) {
+	var key model.APIKey
+	err := r.db.First(&key, id).Error
+	return &key, err
+}
+
+// GetActiveByUserID возвращает неотозванные ключи пользователя, включая истекшие
+func (r *apiKeyRepository) GetActiveByUserID(userID uint) ([]model.APIKey, error) {
+	var keys []model.APIKey
+	err := r.db.Where("user_id = ? AND revoked_at IS NULL", userID).Order("created_at DESC").Find(&keys).Error
+	return keys, err
+}
+
+func (r *apiKeyRepository) Touch(id uint) error {
+	return r.db.Model(&model.APIKey{}).Where("id = ?", id).Update("last_used_at", time.Now()).Error
+}
+
+func (r *apiKeyRepository) Revoke(id uint) error {
+	return r.db.Model(&model.APIKey{}).Where("id = ? AND revoked_at IS NULL", id).Update("revoked_at", time.Now()).Error
+}
@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"MusicService/internal/model"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ArtistRepository interface {
+	GetOrCreate(name, normalizedName string) (*model.Artist, error)
+	GetByID(id uint) (*model.Artist, error)
+	GetAll() ([]model.Artist, error)
+	List(page model.PageParams) (*model.Page[model.Artist], error)
+	GetByIDs(ids []uint) ([]model.Artist, error)
+	Match(query string, offset, limit int) ([]model.Artist, error)
+}
+
+type artistRepository struct {
+	db *gorm.DB
+}
+
+func NewArtistRepository(db *gorm.DB) ArtistRepository {
+	return &artistRepository{db: db}
+}
+
+// Подзапросы статистики исполнителя. Учитываются только альбомы, в которых остались треки
+const (
+	artistAlbumCountColumn = `(SELECT COUNT(*) FROM albums WHERE albums.artist_id = artists.id AND albums.deleted_at IS NULL
+		AND EXISTS (SELECT 1 FROM tracks WHERE tracks.album_id = albums.id AND tracks.deleted_at IS NULL))`
+	artistCoverTrackColumn = `COALESCE((SELECT tracks.id FROM tracks JOIN albums ON albums.id = tracks.album_id
+		WHERE albums.artist_id = artists.id AND albums.deleted_at IS NULL AND tracks.deleted_at IS NULL AND tracks.image_path <> ''
+		ORDER BY albums.year, albums.normalized_title, tracks.disc_number, tracks.track_number, tracks.title LIMIT 1), 0)`
+)
+
+// withArtistStats добавляет к выборке исполнителей число альбомов и трек с обложкой,
+// чтобы не загружать ради них альбомы с треками
+func withArtistStats(db *gorm.DB) *gorm.DB {
+	return addSelect(db, "artists",
+		artistAlbumCountColumn+" AS album_count",
+		artistCoverTrackColumn+" AS cover_track_id")
+}
+
+// hasTracks оставляет исполнителей, у которых есть хотя бы один неудаленный трек
+func hasTracks(db *gorm.DB) *gorm.DB {
+	return db.Where("EXISTS (SELECT 1 FROM tracks WHERE tracks.artist_id = artists.id AND tracks.deleted_at IS NULL)")
+}
+
+// artistSortKeys - поля, по которым можно сортировать список исполнителей
+var artistSortKeys = map[string]sortKey[model.Artist]{
+	"name": {
+		column: "artists.normalized_name",
+		cast:   "text",
+		value:  func(a *model.Artist) string { return a.NormalizedName },
+	},
+	"created_at": {
+		column: "artists.created_at",
+		cast:   "timestamptz",
+		value:  func(a *model.Artist) string { return a.CreatedAt.Format(time.RFC3339Nano) },
+	},
+	"album_count": {
+		column: artistAlbumCountColumn,
+		cast:   "bigint",
+		value:  func(a *model.Artist) string { return strconv.FormatInt(a.AlbumCount, 10) },
+	},
+}
+
+// GetOrCreate возвращает исполнителя с заданным нормализованным именем, создавая его при отсутствии.
+// Конфликт уникального индекса при параллельной загрузке разрешается повторным чтением
+func (r *artistRepository) GetOrCreate(name, normalizedName string) (*model.Artist, error) {
+	artist := model.Artist{Name: name, NormalizedName: normalizedName}
+	if err := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&artist).Error; err != nil {
+		return nil, err
+	}
+
+	err := r.db.Where("normalized_name = ?", normalizedName).First(&artist).Error
+	return &artist, err
+}
+
+// GetByID загружает исполнителя с альбомами, в которых остались треки, и их треками
+func (r *artistRepository) GetByID(id uint) (*model.Artist, error) {
+	var artist model.Artist
+	err := r.db.Scopes(withArtistStats).Preload("Albums", func(db *gorm.DB) *gorm.DB {
+		return withAlbumStats(db).Scopes(albumHasTracks).Order("year, normalized_title")
+	}).Preload("Albums.Tracks", func(db *gorm.DB) *gorm.DB {
+		return db.Order("disc_number, track_number, title")
+	}).First(&artist, id).Error
+	return &artist, err
+}
+
+// GetAll возвращает всех исполнителей с треками в алфавитном порядке, без альбомов
+func (r *artistRepository) GetAll() ([]model.Artist, error) {
+	var artists []model.Artist
+	err := r.db.Scopes(withArtistStats, hasTracks).Order("normalized_name").Find(&artists).Error
+	return artists, err
+}
+
+func (r *artistRepository) List(page model.PageParams) (*model.Page[model.Artist], error) {
+	query := r.db.Model(&model.Artist{}).Scopes(hasTracks)
+	return paginate(query, page, artistSortKeys, "name", "artists.id", func(a *model.Artist) uint { return a.ID }, withArtistStats)
+}
+
+// GetByIDs возвращает найденных исполнителей из ids в алфавитном порядке, без альбомов
+func (r *artistRepository) GetByIDs(ids []uint) ([]model.Artist, error) {
+	var artists []model.Artist
+	err := r.db.Scopes(withArtistStats).Where("artists.id IN ?", ids).Order("normalized_name").Find(&artists).Error
+	return artists, err
+}
+
+// Match возвращает исполнителей с треками, имя которых содержит query без учета регистра,
+// в алфавитном порядке
+func (r *artistRepository) Match(query string, offset, limit int) ([]model.Artist, error) {
+	var artists []model.Artist
+	err := r.db.Scopes(withArtistStats, hasTracks).
+		Where("artists.name ILIKE ?", likeContains(query)).
+		Order("normalized_name, id").Offset(offset).Limit(limit).Find(&artists).Error
+	return artists, err
+}
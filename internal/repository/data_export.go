@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"MusicService/internal/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type DataExportRepository interface {
+	Create(export *model.DataExport) error
+	FindByID(id string) (*model.DataExport, error)
+	GetByUserID(userID uint) ([]model.DataExport, error)
+	Update(export *model.DataExport) error
+	Delete(id string) error
+	GetExpired() ([]model.DataExport, error)
+	FailUnfinished() error
+}
+
+type dataExportRepository struct {
+	db *gorm.DB
+}
+
+func NewDataExportRepository(db *gorm.DB) DataExportRepository {
+	return &dataExportRepository{db: db}
+}
+
+func (r *dataExportRepository) Create(export *model.DataExport) error {
+	return r.db.Create(export).Error
+}
+
+func (r *dataExportRepository) FindByID(id string) (*model.DataExport, error) {
+	var export model.DataExport
+	err := r.db.Where("id = ?", id).First(&export).Error
+	return &export, err
+}
+
+func (r *dataExportRepository) GetByUserID(userID uint) ([]model.DataExport, error) {
+	var exports []model.DataExport
+	err := r.db.Where("user_id = ?", userID).Order("created_at DESC").Find(&exports).Error
+	return exports, err
+}
+
+func (r *dataExportRepository) Update(export *model.DataExport) error {
+	return r.db.Save(export).Error
+}
+
+func (r *dataExportRepository) Delete(id string) error {
+	return r.db.Where("id = ?", id).Delete(&model.DataExport{}).Error
+}
+
+func (r *dataExportRepository) GetExpired() ([]model.DataExport, error) {
+	var exports []model.DataExport
+	err := r.db.Where("expires_at < ?", time.Now()).Find(&exports).Error
+	return exports, err
+}
+
+// FailUnfinished помечает неудачными выгрузки, прерванные остановкой сервиса
+func (r *dataExportRepository) FailUnfinished() error {
+	return r.db.Model(&model.DataExport{}).
+		Where("status IN ?", []string{model.DataExportPending, model.DataExportRunning}).
+		Updates(map[string]any{"status": model.DataExportFailed, "error": "interrupted by server restart"}).Error
+}
@@ -0,0 +1,30 @@
+package repository
+
+import (
+	"MusicService/internal/model"
+
+	"gorm.io/gorm"
+)
+
+type ExternalIdentityRepository interface {
+	Create(identity *model.ExternalIdentity) error
+	FindBySubject(provider, subject string) (*model.ExternalIdentity, error)
+}
+
+type externalIdentityRepository struct {
+	db *gorm.DB
+}
+
+func NewExternalIdentityRepository(db *gorm.DB) ExternalIdentityRepository {
+	return &externalIdentityRepository{db: db}
+}
+
+func (r *externalIdentityRepository) Create(identity *model.ExternalIdentity) error {
+	return r.db.Create(identity).Error
+}
+
+func (r *externalIdentityRepository) FindBySubject(provider, subject string) (*model.ExternalIdentity, error) {
+	var identity model.ExternalIdentity
+	err := r.db.Where("provider = ? AND subject = ?", provider, subject).First(&identity).Error
+	return &identity, err
+}
@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"MusicService/internal/model"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidSort   = errors.New("unsupported sort field")
+	ErrInvalidCursor = errors.New("invalid pagination cursor")
+)
+
+// sortKey - поле сортировки из белого списка ресурса
+type sortKey[T any] struct {
+	column string                  // SQL-выражение
+	cast   string                  // тип, к которому приводится значение из курсора
+	value  func(*T) string         // значение поля у записи для курсора
+	join   func(*gorm.DB) *gorm.DB // join и select, без которых выражение недоступно
+}
+
+// withSortKey возвращает копию keys с дополнительным полем сортировки
+func withSortKey[T any](keys map[string]sortKey[T], name string, key sortKey[T]) map[string]sortKey[T] {
+	extended := maps.Clone(keys)
+	extended[name] = key
+	return extended
+}
+
+// addSelect дописывает выражения к уже выбранным колонкам запроса; без них выбирается table.*
+func addSelect(db *gorm.DB, table string, columns ...string) *gorm.DB {
+	selects := slices.Clone(db.Statement.Selects)
+	if len(selects) == 0 {
+		selects = []string{table + ".*"}
+	}
+	return db.Select(append(selects, columns...))
+}
+
+// cursor - позиция последней записи страницы: значение поля сортировки и id
+type cursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    uint   `json:"id"`
+}
+
+func encodeCursor(c cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (*cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// paginate выбирает страницу query. Вторым ключом сортировки всегда идет id, чтобы порядок был
+// однозначным и курсор не пропускал записи с одинаковым значением поля. scopes применяются только
+// к выборке страницы, не к подсчету (например, Preload)
+func paginate[T any](query *gorm.DB, params model.PageParams, keys map[string]sortKey[T], defaultSort, idColumn string, id func(*T) uint, scopes ...func(*gorm.DB) *gorm.DB) (*model.Page[T], error) {
+	sort := params.Sort
+	if sort == "" {
+		sort = defaultSort
+	}
+	desc := strings.HasPrefix(sort, "-")
+	key, ok := keys[strings.TrimPrefix(sort, "-")]
+	if !ok {
+		return nil, ErrInvalidSort
+	}
+
+	perPage := params.PerPage
+	switch {
+	case perPage <= 0:
+		perPage = model.DefaultPerPage
+	case perPage > model.MaxPerPage:
+		perPage = model.MaxPerPage
+	}
+	page := &model.Page[T]{PerPage: perPage}
+
+	query = query.Session(&gorm.Session{})
+	if err := query.Count(&page.Total).Error; err != nil {
+		return nil, err
+	}
+
+	direction, op := "ASC", ">"
+	if desc {
+		direction, op = "DESC", "<"
+	}
+	find := query.Scopes(scopes...)
+	if key.join != nil {
+		find = key.join(find)
+	}
+	find = find.Order(key.column + " " + direction).Order(idColumn + " " + direction).Limit(perPage + 1)
+
+	if params.Cursor != "" {
+		c, err := decodeCursor(params.Cursor)
+		if err != nil || c.Sort != sort {
+			return nil, ErrInvalidCursor
+		}
+		find = find.Where(fmt.Sprintf("(%s, %s) %s (CAST(? AS %s), ?)", key.column, idColumn, op, key.cast), c.Value, c.ID)
+	} else {
+		page.Page = max(params.Page, 1)
+		find = find.Offset((page.Page - 1) * perPage)
+	}
+
+	items := make([]T, 0, perPage+1)
+	if err := find.Find(&items).Error; err != nil {
+		return nil, err
+	}
+	if len(items) > perPage {
+		items = items[:perPage]
+		last := &items[perPage-1]
+		page.NextCursor = encodeCursor(cursor{Sort: sort, Value: key.value(last), ID: id(last)})
+	}
+	page.Items = items
+
+	return page, nil
+}
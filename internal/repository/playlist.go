@@ -2,18 +2,31 @@ package repository
 
 import (
 	"MusicService/internal/model"
+	"errors"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrPlaylistEntryNotFound = errors.New("playlist entry not found")
+	ErrInvalidPlaylistOrder  = errors.New("entry list must contain every entry of the playlist exactly once")
 )
 
 type PlaylistRepository interface {
 	Create(playlist *model.Playlist) error
 	GetByID(id uint) (*model.Playlist, error)
+	GetInfo(id uint) (*model.Playlist, error)
 	GetByUserID(userID uint) ([]model.Playlist, error)
+	ListByUserID(userID uint, page model.PageParams) (*model.Page[model.Playlist], error)
 	Update(playlist *model.Playlist) error
 	Delete(id uint) error
-	AddTrack(playlistID uint, trackID uint) error
+	AddTrack(playlistID, trackID, addedBy uint, position *int) (*model.PlaylistEntry, error)
 	RemoveTrack(playlistID uint, trackID uint) error
+	RemoveEntry(playlistID, entryID uint) error
+	MoveEntry(playlistID, entryID uint, position int) error
+	Reorder(playlistID uint, entryIDs []uint) error
 }
 
 type playlistRepository struct {
@@ -30,40 +43,196 @@ func (r *playlistRepository) Create(playlist *model.Playlist) error {
 
 func (r *playlistRepository) GetByID(id uint) (*model.Playlist, error) {
 	var playlist model.Playlist
-	err := r.db.Preload("Tracks").First(&playlist, id).Error
+	err := preloadEntries(r.db).First(&playlist, id).Error
+	return &playlist, err
+}
+
+// GetInfo загружает плейлист без записей, например для проверки прав
+func (r *playlistRepository) GetInfo(id uint) (*model.Playlist, error) {
+	var playlist model.Playlist
+	err := r.db.First(&playlist, id).Error
 	return &playlist, err
 }
 
 func (r *playlistRepository) GetByUserID(userID uint) ([]model.Playlist, error) {
 	var playlists []model.Playlist
-	err := r.db.Where("user_id = ?", userID).Preload("Tracks").Find(&playlists).Error
+	err := preloadEntries(r.db).Where("user_id = ?", userID).Find(&playlists).Error
 	return playlists, err
 }
 
+// playlistSortKeys - поля, по которым можно сортировать списки плейлистов
+var playlistSortKeys = map[string]sortKey[model.Playlist]{
+	"name": {
+		column: "playlists.name",
+		cast:   "text",
+		value:  func(p *model.Playlist) string { return p.Name },
+	},
+	"created_at": {
+		column: "playlists.created_at",
+		cast:   "timestamptz",
+		value:  func(p *model.Playlist) string { return p.CreatedAt.Format(time.RFC3339Nano) },
+	},
+}
+
+// ListByUserID возвращает страницу плейлистов пользователя вместе с их записями
+func (r *playlistRepository) ListByUserID(userID uint, page model.PageParams) (*model.Page[model.Playlist], error) {
+	query := r.db.Model(&model.Playlist{}).Where("user_id = ?", userID)
+	return paginate(query, page, playlistSortKeys, "-created_at", "playlists.id", func(p *model.Playlist) uint { return p.ID }, preloadEntries)
+}
+
+// preloadEntries загружает записи плейлиста по порядку вместе с треками
+func preloadEntries(db *gorm.DB) *gorm.DB {
+	return db.Preload("Entries", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position")
+	}).Preload("Entries.Track")
+}
+
 func (r *playlistRepository) Update(playlist *model.Playlist) error {
-	return r.db.Save(playlist).Error
+	return r.db.Omit("Entries").Save(playlist).Error
 }
 
 func (r *playlistRepository) Delete(id uint) error {
 	return r.db.Delete(&model.Playlist{}, id).Error
 }
 
-func (r *playlistRepository) AddTrack(playlistID uint, trackID uint) error {
-	playlist, err := r.GetByID(playlistID)
-	if err != nil {
-		return err
-	}
+// AddTrack вставляет трек на позицию position, сдвигая последующие записи.
+// Позиция за концом плейлиста или nil означают добавление в конец
+func (r *playlistRepository) AddTrack(playlistID, trackID, addedBy uint, position *int) (*model.PlaylistEntry, error) {
+	var entry *model.PlaylistEntry
+	err := r.withLockedPlaylist(playlistID, func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&model.PlaylistEntry{}).Where("playlist_id = ?", playlistID).Count(&count).Error; err != nil {
+			return err
+		}
+
+		pos := int(count)
+		if position != nil && *position < pos {
+			pos = max(*position, 0)
+		}
+
+		if err := tx.Model(&model.PlaylistEntry{}).
+			Where("playlist_id = ? AND position >= ?", playlistID, pos).
+			Update("position", gorm.Expr("position + 1")).Error; err != nil {
+			return err
+		}
+
+		entry = &model.PlaylistEntry{PlaylistID: playlistID, TrackID: trackID, Position: pos, AddedBy: addedBy}
+		return tx.Omit("Track").Create(entry).Error
+	})
 
-	track := &model.Track{Model: gorm.Model{ID: trackID}}
-	return r.db.Model(playlist).Association("Tracks").Append(track)
+	return entry, err
 }
 
+// RemoveTrack удаляет все вхождения трека в плейлист
 func (r *playlistRepository) RemoveTrack(playlistID uint, trackID uint) error {
-	playlist, err := r.GetByID(playlistID)
-	if err != nil {
-		return err
-	}
+	return r.withLockedPlaylist(playlistID, func(tx *gorm.DB) error {
+		result := tx.Where("playlist_id = ? AND track_id = ?", playlistID, trackID).Delete(&model.PlaylistEntry{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrPlaylistEntryNotFound
+		}
+		return renumberEntries(tx, playlistID)
+	})
+}
+
+func (r *playlistRepository) RemoveEntry(playlistID, entryID uint) error {
+	return r.withLockedPlaylist(playlistID, func(tx *gorm.DB) error {
+		result := tx.Where("playlist_id = ? AND id = ?", playlistID, entryID).Delete(&model.PlaylistEntry{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrPlaylistEntryNotFound
+		}
+		return renumberEntries(tx, playlistID)
+	})
+}
+
+// MoveEntry перемещает запись на позицию position; позиция за концом плейлиста означает последнюю
+func (r *playlistRepository) MoveEntry(playlistID, entryID uint, position int) error {
+	return r.withLockedPlaylist(playlistID, func(tx *gorm.DB) error {
+		var entry model.PlaylistEntry
+		err := tx.Where("playlist_id = ? AND id = ?", playlistID, entryID).First(&entry).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrPlaylistEntryNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		var count int64
+		if err := tx.Model(&model.PlaylistEntry{}).Where("playlist_id = ?", playlistID).Count(&count).Error; err != nil {
+			return err
+		}
+		position = min(max(position, 0), int(count)-1)
+
+		entries := tx.Model(&model.PlaylistEntry{}).Where("playlist_id = ?", playlistID)
+		switch {
+		case position < entry.Position:
+			err = entries.Where("position >= ? AND position < ?", position, entry.Position).
+				Update("position", gorm.Expr("position + 1")).Error
+		case position > entry.Position:
+			err = entries.Where("position > ? AND position <= ?", entry.Position, position).
+				Update("position", gorm.Expr("position - 1")).Error
+		default:
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		return tx.Model(&entry).Update("position", position).Error
+	})
+}
+
+// Reorder задает порядок плейлиста списком ID всех его записей
+func (r *playlistRepository) Reorder(playlistID uint, entryIDs []uint) error {
+	return r.withLockedPlaylist(playlistID, func(tx *gorm.DB) error {
+		var existing []uint
+		if err := tx.Model(&model.PlaylistEntry{}).Where("playlist_id = ?", playlistID).Pluck("id", &existing).Error; err != nil {
+			return err
+		}
+
+		if len(existing) != len(entryIDs) {
+			return ErrInvalidPlaylistOrder
+		}
+		known := make(map[uint]bool, len(existing))
+		for _, id := range existing {
+			known[id] = true
+		}
+		for _, id := range entryIDs {
+			if !known[id] {
+				return ErrInvalidPlaylistOrder
+			}
+			delete(known, id)
+		}
+
+		for position, id := range entryIDs {
+			if err := tx.Model(&model.PlaylistEntry{}).Where("id = ?", id).Update("position", position).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// withLockedPlaylist выполняет fn в транзакции, заблокировав строку плейлиста,
+// чтобы параллельные изменения не перемешали позиции
+func (r *playlistRepository) withLockedPlaylist(playlistID uint, fn func(tx *gorm.DB) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var playlist model.Playlist
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&playlist, playlistID).Error; err != nil {
+			return err
+		}
+		return fn(tx)
+	})
+}
 
-	track := &model.Track{Model: gorm.Model{ID: trackID}}
-	return r.db.Model(playlist).Association("Tracks").Delete(track)
+// renumberEntries восстанавливает непрерывную нумерацию позиций после удаления
+func renumberEntries(tx *gorm.DB, playlistID uint) error {
+	return tx.Exec(`UPDATE playlist_entries SET position = ordered.rn - 1
+		FROM (SELECT id, row_number() OVER (ORDER BY position, id) AS rn FROM playlist_entries WHERE playlist_id = ?) AS ordered
+		WHERE playlist_entries.id = ordered.id`, playlistID).Error
 }
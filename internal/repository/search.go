@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"MusicService/internal/model"
+	"context"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+type SearchRepository interface {
+	SuggestCatalog(ctx context.Context, prefix string, limit int) ([]model.Suggestion, error)
+	SuggestPlaylists(ctx context.Context, userID uint, prefix string, limit int) ([]model.Suggestion, error)
+}
+
+type searchRepository struct {
+	db *gorm.DB
+}
+
+func NewSearchRepository(db *gorm.DB) SearchRepository {
+	return &searchRepository{db: db}
+}
+
+// Каждая ветка ищет по префиксу в нижнем регистре и упорядочивает через ~<~, чтобы и отбор,
+// и сортировку обслуживал индекс с text_pattern_ops
+const catalogSuggestQuery = `
+(SELECT 'track' AS type, id, title AS text, artist AS subtitle FROM tracks
+	WHERE lower(title) LIKE @prefix AND deleted_at IS NULL
+	ORDER BY lower(title) USING ~<~ LIMIT @limit)
+UNION ALL
+(SELECT 'artist', id, name, '' FROM artists
+	WHERE normalized_name LIKE @prefix AND deleted_at IS NULL
+	ORDER BY normalized_name USING ~<~ LIMIT @limit)
+UNION ALL
+(SELECT 'album', albums.id, albums.title, artists.name FROM albums
+	JOIN artists ON artists.id = albums.artist_id
+	WHERE albums.normalized_title LIKE @prefix AND albums.deleted_at IS NULL
+	ORDER BY albums.normalized_title USING ~<~ LIMIT @limit)
+UNION ALL
+(SELECT 'genre', 0, min(genre), '' FROM tracks
+	WHERE lower(genre) LIKE @prefix AND deleted_at IS NULL
+	GROUP BY lower(genre)
+	ORDER BY lower(genre) USING ~<~ LIMIT @limit)`
+
+// SuggestCatalog возвращает до limit треков, исполнителей, альбомов и жанров каждого типа,
+// начинающихся с prefix. prefix ожидается в нижнем регистре
+func (r *searchRepository) SuggestCatalog(ctx context.Context, prefix string, limit int) ([]model.Suggestion, error) {
+	var suggestions []model.Suggestion
+	err := r.db.WithContext(ctx).
+		Raw(catalogSuggestQuery, map[string]interface{}{"prefix": likePrefix(prefix), "limit": limit}).
+		Scan(&suggestions).Error
+	return suggestions, err
+}
+
+// SuggestPlaylists возвращает до limit плейлистов пользователя, начинающихся с prefix
+func (r *searchRepository) SuggestPlaylists(ctx context.Context, userID uint, prefix string, limit int) ([]model.Suggestion, error) {
+	var suggestions []model.Suggestion
+	err := r.db.WithContext(ctx).Model(&model.Playlist{}).
+		Select("'playlist' AS type, id, name AS text").
+		Where("user_id = ? AND lower(name) LIKE ?", userID, likePrefix(prefix)).
+		Order("lower(name) USING ~<~").
+		Limit(limit).
+		Scan(&suggestions).Error
+	return suggestions, err
+}
+
+// likePrefix экранирует спецсимволы LIKE и превращает строку в шаблон префикса
+func likePrefix(prefix string) string {
+	return likeEscape(prefix) + "%"
+}
+
+// likeContains экранирует спецсимволы LIKE и превращает строку в шаблон подстроки
+func likeContains(s string) string {
+	return "%" + likeEscape(s) + "%"
+}
+
+func likeEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
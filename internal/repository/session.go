@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"MusicService/internal/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type SessionRepository interface {
+	Create(session *model.Session) error
+	FindByID(id string) (*model.Session, error)
+	GetActiveByUserID(userID uint) ([]model.Session, error)
+	Touch(id string, ip string) error
+	Extend(id string, expiresAt time.Time) error
+	Revoke(id string) error
+	RevokeAllForUser(userID uint) error
+	RevokeOthers(userID uint, keepID string) error
+}
+
+type sessionRepository struct {
+	db *gorm.DB
+}
+
+func NewSessionRepository(db *gorm.DB) SessionRepository {
+	return &sessionRepository{db: db}
+}
+
+func (r *sessionRepository) Create(session *model.Session) error {
+	return r.db.Create(session).Error
+}
+
+func (r *sessionRepository) FindByID(id string) (*model.Session, error) {
+	var session model.Session
+	err := r.db.Where("id = ?", id).First(&session).Error
+	return &session, err
+}
+
+// GetActiveByUserID возвращает неотозванные и неистекшие сессии, начиная с последней активной
+func (r *sessionRepository) GetActiveByUserID(userID uint) ([]model.Session, error) {
+	var sessions []model.Session
+	err := r.db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_used_at DESC").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+// Touch обновляет время последнего использования и адрес клиента
+func (r *sessionRepository) Touch(id string, ip string) error {
+	return r.db.Model(&model.Session{}).Where("id = ?", id).
+		Updates(map[string]any{"last_used_at": time.Now(), "ip": ip}).Error
+}
+
+func (r *sessionRepository) Extend(id string, expiresAt time.Time) error {
+	return r.db.Model(&model.Session{}).Where("id = ?", id).Update("expires_at", expiresAt).Error
+}
+
+func (r *sessionRepository) Revoke(id string) error {
+	return r.db.Model(&model.Session{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now()).Error
+}
+
+func (r *sessionRepository) RevokeAllForUser(userID uint) error {
+	return r.db.Model(&model.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// RevokeOthers завершает все сессии пользователя, кроме keepID
+func (r *sessionRepository) RevokeOthers(userID uint, keepID string) error {
+	return r.db.Model(&model.Session{}).
+		Where("user_id = ? AND id <> ? AND revoked_at IS NULL", userID, keepID).
+		Update("revoked_at", time.Now()).Error
+}
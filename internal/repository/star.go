@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"MusicService/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type StarRepository interface {
+	Create(stars []model.Star) error
+	Delete(userID uint, itemType string, itemIDs []string) error
+	GetByUserID(userID uint) ([]model.Star, error)
+}
+
+type starRepository struct {
+	db *gorm.DB
+}
+
+func NewStarRepository(db *gorm.DB) StarRepository {
+	return &starRepository{db: db}
+}
+
+// Create добавляет отметки, пропуская уже существующие
+func (r *starRepository) Create(stars []model.Star) error {
+	if len(stars) == 0 {
+		return nil
+	}
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&stars).Error
+}
+
+func (r *starRepository) Delete(userID uint, itemType string, itemIDs []string) error {
+	if len(itemIDs) == 0 {
+		return nil
+	}
+	return r.db.Where("user_id = ? AND item_type = ? AND item_id IN ?", userID, itemType, itemIDs).
+		Delete(&model.Star{}).Error
+}
+
+func (r *starRepository) GetByUserID(userID uint) ([]model.Star, error) {
+	var stars []model.Star
+	err := r.db.Where("user_id = ?", userID).Order("created_at desc").Find(&stars).Error
+	return stars, err
+}
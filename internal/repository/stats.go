@@ -2,15 +2,19 @@ package repository
 
 import (
 	"MusicService/internal/model"
+	"strconv"
+
 	"gorm.io/gorm"
 )
 
 type StatsRepository interface {
-	GetTrackPlaysStats(userID uint) ([]model.TrackPlayStats, error)
-	GetArtistPlaysStats(userID uint) ([]model.ArtistPlayStats, error)
+	GetTrackPlaysStats(userID uint, page model.PageParams) (*model.Page[model.TrackPlayStats], error)
+	GetArtistPlaysStats(userID uint, page model.PageParams) (*model.Page[model.ArtistPlayStats], error)
 	GetRecentTracks(userID uint, limit int) ([]model.Track, error)
 	GetRecentArtists(userID uint, limit int) ([]string, error)
 	CreateListeningHistory(history *model.ListeningHistory) error
+	GetListeningHistory(userID uint) ([]model.ListeningHistory, error)
+	GetSystemStats() (*model.SystemStats, error)
 }
 
 type statsRepository struct {
@@ -21,32 +25,61 @@ func NewStatsRepository(db *gorm.DB) StatsRepository {
 	return &statsRepository{db: db}
 }
 
-func (r *statsRepository) GetTrackPlaysStats(userID uint) ([]model.TrackPlayStats, error) {
-	var stats []model.TrackPlayStats
+// trackPlaysSortKeys и artistPlaysSortKeys - поля, по которым можно сортировать статистику прослушиваний
+var (
+	trackPlaysSortKeys = map[string]sortKey[model.TrackPlayStats]{
+		"play_count": {
+			column: "stats.play_count",
+			cast:   "bigint",
+			value:  func(s *model.TrackPlayStats) string { return strconv.Itoa(s.PlayCount) },
+		},
+		"title": {
+			column: "stats.title",
+			cast:   "text",
+			value:  func(s *model.TrackPlayStats) string { return s.Title },
+		},
+		"artist": {
+			column: "stats.artist",
+			cast:   "text",
+			value:  func(s *model.TrackPlayStats) string { return s.Artist },
+		},
+	}
+	artistPlaysSortKeys = map[string]sortKey[model.ArtistPlayStats]{
+		"play_count": {
+			column: "stats.play_count",
+			cast:   "bigint",
+			value:  func(s *model.ArtistPlayStats) string { return strconv.Itoa(s.PlayCount) },
+		},
+		"artist": {
+			column: "stats.artist",
+			cast:   "text",
+			value:  func(s *model.ArtistPlayStats) string { return s.Artist },
+		},
+	}
+)
 
-	err := r.db.Model(&model.ListeningHistory{}).
-		Select("tracks.id as track_id, tracks.title, tracks.artist, count(listening_histories.id) as play_count").
-		Joins("left join tracks on tracks.id = listening_histories.track_id").
+func (r *statsRepository) GetTrackPlaysStats(userID uint, page model.PageParams) (*model.Page[model.TrackPlayStats], error) {
+	plays := r.db.Model(&model.ListeningHistory{}).
+		Select("tracks.id as id, tracks.title, tracks.artist, count(listening_histories.id) as play_count").
+		Joins("join tracks on tracks.id = listening_histories.track_id").
 		Where("listening_histories.user_id = ?", userID).
-		Group("tracks.id, tracks.title, tracks.artist").
-		Order("play_count desc").
-		Scan(&stats).Error
+		Group("tracks.id, tracks.title, tracks.artist")
 
-	return stats, err
+	// агрегат оборачивается в подзапрос, чтобы сортировать и продолжать по курсору через WHERE
+	query := r.db.Table("(?) AS stats", plays)
+	return paginate(query, page, trackPlaysSortKeys, "-play_count", "stats.id", func(s *model.TrackPlayStats) uint { return s.ID })
 }
 
-func (r *statsRepository) GetArtistPlaysStats(userID uint) ([]model.ArtistPlayStats, error) {
-	var stats []model.ArtistPlayStats
-
-	err := r.db.Model(&model.ListeningHistory{}).
-		Select("tracks.artist, count(listening_histories.id) as play_count").
+func (r *statsRepository) GetArtistPlaysStats(userID uint, page model.PageParams) (*model.Page[model.ArtistPlayStats], error) {
+	plays := r.db.Model(&model.ListeningHistory{}).
+		Select("artists.id as artist_id, artists.name as artist, count(listening_histories.id) as play_count").
 		Joins("join tracks on tracks.id = listening_histories.track_id").
+		Joins("join artists on artists.id = tracks.artist_id").
 		Where("listening_histories.user_id = ?", userID).
-		Group("tracks.artist").
-		Order("play_count desc").
-		Scan(&stats).Error
+		Group("artists.id, artists.name")
 
-	return stats, err
+	query := r.db.Table("(?) AS stats", plays)
+	return paginate(query, page, artistPlaysSortKeys, "-play_count", "stats.artist_id", func(s *model.ArtistPlayStats) uint { return s.ArtistID })
 }
 
 func (r *statsRepository) GetRecentTracks(userID uint, limit int) ([]model.Track, error) {
@@ -65,13 +98,14 @@ func (r *statsRepository) GetRecentArtists(userID uint, limit int) ([]string, er
 	var artists []string
 
 	err := r.db.Model(&model.ListeningHistory{}).
-		Select("tracks.artist").
+		Select("artists.name").
 		Joins("join tracks on tracks.id = listening_histories.track_id").
+		Joins("join artists on artists.id = tracks.artist_id").
 		Where("listening_histories.user_id = ?", userID).
-		Group("tracks.artist").
+		Group("artists.id, artists.name").
 		Order("max(listening_histories.created_at) desc").
 		Limit(limit).
-		Pluck("tracks.artist", &artists).Error
+		Pluck("artists.name", &artists).Error
 
 	return artists, err
 }
@@ -79,3 +113,33 @@ func (r *statsRepository) GetRecentArtists(userID uint, limit int) ([]string, er
 func (r *statsRepository) CreateListeningHistory(history *model.ListeningHistory) error {
 	return r.db.Create(history).Error
 }
+
+// GetSystemStats считает пользователей, треки, плейлисты и прослушивания по всему сервису
+func (r *statsRepository) GetSystemStats() (*model.SystemStats, error) {
+	var stats model.SystemStats
+	counts := []struct {
+		model any
+		count *int64
+	}{
+		{&model.User{}, &stats.Users},
+		{&model.Track{}, &stats.Tracks},
+		{&model.Playlist{}, &stats.Playlists},
+		{&model.ListeningHistory{}, &stats.Plays},
+	}
+
+	for _, c := range counts {
+		if err := r.db.Model(c.model).Count(c.count).Error; err != nil {
+			return nil, err
+		}
+	}
+	return &stats, nil
+}
+
+// GetListeningHistory возвращает все прослушивания пользователя по времени, включая удаленные треки
+func (r *statsRepository) GetListeningHistory(userID uint) ([]model.ListeningHistory, error) {
+	var history []model.ListeningHistory
+	err := r.db.Preload("Track", func(db *gorm.DB) *gorm.DB {
+		return db.Unscoped()
+	}).Where("user_id = ?", userID).Order("created_at").Find(&history).Error
+	return history, err
+}
@@ -2,19 +2,38 @@ package repository
 
 import (
 	"MusicService/internal/model"
+	"MusicService/pkg/sniff"
+	"MusicService/pkg/translit"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
 	"gorm.io/gorm"
 )
 
 type TrackRepository interface {
-	Create(track *model.Track) error
+	CreateLinked(track *model.Track, link LinkFunc) error
 	GetByID(id uint) (*model.Track, error)
 	GetAll() ([]model.Track, error)
 	GetUserTracks(userId uint) ([]model.Track, error)
 	Delete(id uint) error
-	Search(params model.TrackSearchParams) ([]model.Track, error)
+	List(params model.TrackSearchParams, page model.PageParams) (*model.Page[model.Track], error)
+	ListSimilar(params model.TrackSearchParams, page model.PageParams) (*model.Page[model.Track], error)
+	SuggestSimilar(text string) (string, error)
+	Facets(params model.TrackSearchParams, similar bool) (*model.TrackFacets, error)
 	GetByPlaylistID(playlistID uint) ([]model.Track, error)
+	GetByIDs(ids []uint) ([]model.Track, error)
+	Match(query string, offset, limit int) ([]model.Track, error)
+	GetUnlinked() ([]model.Track, error)
+	UpdateLinks(track *model.Track) error
 }
 
+// LinkFunc привязывает трек к исполнителю и альбому через репозитории, работающие в транзакции
+type LinkFunc func(artistRepo ArtistRepository, albumRepo AlbumRepository) error
+
 type trackRepository struct {
 	db *gorm.DB
 }
@@ -23,8 +42,15 @@ func NewTrackRepository(db *gorm.DB) TrackRepository {
 	return &trackRepository{db: db}
 }
 
-func (r *trackRepository) Create(track *model.Track) error {
-	return r.db.Create(track).Error
+// CreateLinked создает трек в одной транзакции с его исполнителем и альбомом: если трек
+// не сохранится, созданные для него исполнитель и альбом тоже не останутся в базе
+func (r *trackRepository) CreateLinked(track *model.Track, link LinkFunc) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := link(NewArtistRepository(tx), NewAlbumRepository(tx)); err != nil {
+			return err
+		}
+		return tx.Create(track).Error
+	})
 }
 
 func (r *trackRepository) GetByID(id uint) (*model.Track, error) {
@@ -49,33 +75,416 @@ func (r *trackRepository) GetUserTracks(uploadedBy uint) ([]model.Track, error)
 	return tracks, err
 }
 
+// Delete удаляет трек вместе с его записями в плейлистах
 func (r *trackRepository) Delete(id uint) error {
-	return r.db.Delete(&model.Track{}, id).Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var playlistIDs []uint
+		if err := tx.Model(&model.PlaylistEntry{}).Where("track_id = ?", id).Distinct().Pluck("playlist_id", &playlistIDs).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("track_id = ?", id).Delete(&model.PlaylistEntry{}).Error; err != nil {
+			return err
+		}
+		for _, playlistID := range playlistIDs {
+			if err := renumberEntries(tx, playlistID); err != nil {
+				return err
+			}
+		}
+
+		return tx.Delete(&model.Track{}, id).Error
+	})
 }
 
-func (r *trackRepository) Search(params model.TrackSearchParams) ([]model.Track, error) {
-	var tracks []model.Track
+// trackSortKeys - поля, по которым можно сортировать списки треков
+var trackSortKeys = map[string]sortKey[model.Track]{
+	"title": {
+		column: "tracks.title",
+		cast:   "text",
+		value:  func(t *model.Track) string { return t.Title },
+	},
+	"artist": {
+		column: "tracks.artist",
+		cast:   "text",
+		value:  func(t *model.Track) string { return t.Artist },
+	},
+	"created_at": {
+		column: "tracks.created_at",
+		cast:   "timestamptz",
+		value:  func(t *model.Track) string { return t.CreatedAt.Format(time.RFC3339Nano) },
+	},
+	"duration": {
+		column: "tracks.duration",
+		cast:   "bigint",
+		value:  func(t *model.Track) string { return strconv.Itoa(t.Duration) },
+	},
+	"play_count": {
+		column: "COALESCE(plays.play_count, 0)",
+		cast:   "bigint",
+		value:  func(t *model.Track) string { return strconv.FormatInt(t.PlayCount, 10) },
+		join: func(db *gorm.DB) *gorm.DB {
+			db = db.Joins("LEFT JOIN (SELECT track_id, COUNT(*) AS play_count FROM listening_histories WHERE deleted_at IS NULL GROUP BY track_id) AS plays ON plays.track_id = tracks.id")
+			return addSelect(db, "tracks", "COALESCE(plays.play_count, 0) AS play_count")
+		},
+	},
+}
+
+// trackSearchSortKeys дополняет trackSortKeys релевантностью, доступной только при поиске по тексту
+var trackSearchSortKeys = withSortKey(trackSortKeys, "relevance", sortKey[model.Track]{
+	column: "ts_rank_cd(tracks.search_vector, search.query)",
+	cast:   "real",
+	value:  func(t *model.Track) string { return strconv.FormatFloat(float64(t.Rank), 'g', -1, 32) },
+})
+
+// maxSearchTerms ограничивает число слов запроса, попадающих в tsquery
+const maxSearchTerms = 10
+
+// searchTerms разбивает запрос на слова из букв и цифр в нижнем регистре
+func searchTerms(query string) []string {
+	terms := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(terms) > maxSearchTerms {
+		terms = terms[:maxSearchTerms]
+	}
+	return terms
+}
+
+// trackSearchQuery строит tsquery, где каждое слово должно найтись хотя бы в одном словаре.
+// Последнее слово сопоставляется как префикс, чтобы искать по мере ввода. С transliterate
+// слово также ищется в написании другим алфавитом
+func trackSearchQuery(terms []string, transliterate bool) (string, []interface{}) {
+	var words []string
+	var args []interface{}
+	for i, term := range terms {
+		spellings := []string{term}
+		if alternate := translit.Alternate(term); transliterate && alternate != "" {
+			spellings = append(spellings, alternate)
+		}
+
+		var variants []string
+		for _, spelling := range spellings {
+			if i == len(terms)-1 {
+				spelling += ":*"
+			}
+			for _, dictionary := range model.SearchDictionaries {
+				variants = append(variants, fmt.Sprintf("to_tsquery('%s', ?)", dictionary))
+				args = append(args, spelling)
+			}
+		}
+		words = append(words, "("+strings.Join(variants, " || ")+")")
+	}
+	return strings.Join(words, " && "), args
+}
+
+// trigramText - текст трека для нечеткого поиска, совпадает с выражением индекса idx_tracks_trigram
+const trigramText = "(lower(tracks.title) || ' ' || lower(tracks.artist))"
+
+// trackSimilarSortKeys дополняет trackSortKeys релевантностью нечеткого поиска - наибольшим
+// сходством слов запроса или его транслитерации с текстом трека
+var trackSimilarSortKeys = withSortKey(trackSortKeys, "relevance", sortKey[model.Track]{
+	column: "GREATEST(word_similarity(search.term, " + trigramText + "), word_similarity(search.alternate, " + trigramText + "))",
+	cast:   "real",
+	value:  func(t *model.Track) string { return strconv.FormatFloat(float64(t.Rank), 'g', -1, 32) },
+})
+
+// similarSearch присоединяет к запросу запрос пользователя и его транслитерацию и оставляет треки,
+// похожие на любое из написаний (pg_trgm, оператор <% с порогом word_similarity_threshold)
+func similarSearch(query *gorm.DB, text string) *gorm.DB {
+	term := strings.Join(searchTerms(text), " ")
+	alternate := translit.Alternate(term)
+	if alternate == "" {
+		alternate = term
+	}
+	return query.Joins("CROSS JOIN (SELECT CAST(? AS text) AS term, CAST(? AS text) AS alternate) AS search", term, alternate).
+		Where("search.term <% " + trigramText + " OR search.alternate <% " + trigramText)
+}
+
+// headline выделяет совпадения в колонке маркерами model.HighlightStart и model.HighlightStop
+func headline(column, alias string) string {
+	return fmt.Sprintf("ts_headline('simple', coalesce(%s, ''), search.query, 'HighlightAll=true, StartSel=%s, StopSel=%s') AS %s",
+		column, model.HighlightStart, model.HighlightStop, alias)
+}
+
+// List возвращает страницу треков, подходящих под фильтры. Пустые параметры - все треки.
+// С текстовым запросом треки по умолчанию упорядочены по релевантности
+func (r *trackRepository) List(params model.TrackSearchParams, page model.PageParams) (*model.Page[model.Track], error) {
+	query := r.filter(params)
+
+	keys, defaultSort := trackSortKeys, "-created_at"
+	var scopes []func(*gorm.DB) *gorm.DB
+	if len(searchTerms(params.Query)) > 0 {
+		query = fullTextSearch(query, params)
+		keys, defaultSort = trackSearchSortKeys, "-relevance"
+		scopes = append(scopes, func(db *gorm.DB) *gorm.DB {
+			return addSelect(db, "tracks",
+				"ts_rank_cd(tracks.search_vector, search.query) AS rank",
+				headline("tracks.title", "title_highlight"),
+				headline("tracks.artist", "artist_highlight"),
+				headline("tracks.album", "album_highlight"))
+		})
+	}
+
+	return paginate(query, page, keys, defaultSort, "tracks.id", func(t *model.Track) uint { return t.ID }, scopes...)
+}
+
+// fullTextSearch присоединяет к запросу tsquery из params.Query и оставляет совпавшие треки
+func fullTextSearch(query *gorm.DB, params model.TrackSearchParams) *gorm.DB {
+	tsquery, args := trackSearchQuery(searchTerms(params.Query), params.Fuzzy)
+	return query.Joins("CROSS JOIN (SELECT "+tsquery+" AS query) AS search", args...).
+		Where("tracks.search_vector @@ search.query")
+}
+
+// ListSimilar ищет треки, название или исполнитель которых похожи на params.Query с учетом опечаток
+// и написания другим алфавитом. Треки по умолчанию упорядочены по сходству
+func (r *trackRepository) ListSimilar(params model.TrackSearchParams, page model.PageParams) (*model.Page[model.Track], error) {
+	if len(searchTerms(params.Query)) == 0 {
+		return r.List(params, page)
+	}
+
+	query := similarSearch(r.filter(params), params.Query)
+	rank := func(db *gorm.DB) *gorm.DB {
+		return addSelect(db, "tracks", trackSimilarSortKeys["relevance"].column+" AS rank")
+	}
+	return paginate(query, page, trackSimilarSortKeys, "-relevance", "tracks.id", func(t *model.Track) uint { return t.ID }, rank)
+}
+
+// SuggestSimilar возвращает исполнителя или название трека, наиболее похожие на запрос,
+// или пустую строку, если похожих нет
+func (r *trackRepository) SuggestSimilar(text string) (string, error) {
+	if len(searchTerms(text)) == 0 {
+		return "", nil
+	}
+
+	similarity := func(column string) string {
+		return fmt.Sprintf("GREATEST(similarity(search.term, lower(%[1]s)), similarity(search.alternate, lower(%[1]s)))", column)
+	}
+
+	var suggestions []string
+	err := similarSearch(r.db.Model(&model.Track{}), text).
+		Select(fmt.Sprintf("CASE WHEN %s >= %s THEN tracks.artist ELSE tracks.title END",
+			similarity("tracks.artist"), similarity("tracks.title"))).
+		Order(trackSimilarSortKeys["relevance"].column+" DESC").
+		Limit(1).
+		Pluck("suggestion", &suggestions).Error
+	if err != nil || len(suggestions) == 0 {
+		return "", err
+	}
+	return suggestions[0], nil
+}
+
+// filter применяет к выборке треков все фильтры, кроме текстового запроса
+func (r *trackRepository) filter(params model.TrackSearchParams) *gorm.DB {
 	query := r.db.Model(&model.Track{})
 
-	if params.Query != "" {
-		query = query.Where("title LIKE ? OR artist LIKE ?", "%"+params.Query+"%", "%"+params.Query+"%")
+	if len(params.Artist) > 0 {
+		artists := make([]string, len(params.Artist))
+		for i, artist := range params.Artist {
+			artists[i] = strings.ToLower(artist)
+		}
+		query = query.Where("lower(tracks.artist) IN ?", artists)
+	}
+	if len(params.Album) > 0 {
+		query = query.Where("tracks.album IN ?", params.Album)
 	}
-	if params.Artist != "" {
-		query = query.Where("artist LIKE ?", "%"+params.Artist+"%")
+	if len(params.Genre) > 0 {
+		query = query.Where("tracks.genre IN ?", params.Genre)
 	}
-	if params.Album != "" {
-		query = query.Where("album = ?", params.Album)
+	if len(params.Decade) > 0 {
+		query = query.Where("tracks.year > 0 AND tracks.year / 10 * 10 IN ?", params.Decade)
 	}
-	if params.Genre != "" {
-		query = query.Where("genre = ?", params.Genre)
+	if len(params.Duration) > 0 {
+		var conditions []string
+		var args []interface{}
+		for _, bucket := range model.DurationBuckets {
+			if !slices.Contains(params.Duration, bucket.Name) {
+				continue
+			}
+			if bucket.Max > 0 {
+				conditions = append(conditions, "(tracks.duration >= ? AND tracks.duration < ?)")
+				args = append(args, bucket.Min, bucket.Max)
+			} else {
+				conditions = append(conditions, "tracks.duration >= ?")
+				args = append(args, bucket.Min)
+			}
+		}
+		if len(conditions) == 0 {
+			conditions = []string{"false"}
+		}
+		query = query.Where(strings.Join(conditions, " OR "), args...)
 	}
+	if len(params.Format) > 0 {
+		// неизвестные форматы ничему не соответствуют: IN с пустым списком не находит треков
+		mimeTypes := []string{}
+		for _, name := range params.Format {
+			if t, ok := sniff.ByName(name); ok && t.Kind == sniff.KindAudio {
+				mimeTypes = append(mimeTypes, t.MIME)
+			}
+		}
+		query = query.Where("tracks.content_type IN ?", mimeTypes)
+	}
+	if params.UploadedBy != 0 {
+		query = query.Where("tracks.uploaded_by = ?", params.UploadedBy)
+	}
+	return query
+}
 
-	err := query.Find(&tracks).Error
-	return tracks, err
+// maxFacetValues ограничивает число значений в фасетах с открытым списком: жанр, исполнитель, альбом
+const maxFacetValues = 20
+
+// trackFacet описывает, как посчитать один фасет
+type trackFacet struct {
+	value   string                         // SQL-выражение значения
+	where   string                         // отбрасывает треки без значения
+	order   string                         // порядок значений
+	limit   int                            // 0 - все значения
+	clear   func(*model.TrackSearchParams) // убирает собственный фильтр фасета
+	dest    func(*model.TrackFacets) *[]model.FacetValue
+	display func(string) string // представление значения в ответе
+}
+
+var trackFacets = []trackFacet{
+	{
+		value: "tracks.genre",
+		where: "tracks.genre <> ''",
+		order: "count DESC, value",
+		limit: maxFacetValues,
+		clear: func(p *model.TrackSearchParams) { p.Genre = nil },
+		dest:  func(f *model.TrackFacets) *[]model.FacetValue { return &f.Genre },
+	},
+	{
+		value: "tracks.artist",
+		where: "tracks.artist <> ''",
+		order: "count DESC, value",
+		limit: maxFacetValues,
+		clear: func(p *model.TrackSearchParams) { p.Artist = nil },
+		dest:  func(f *model.TrackFacets) *[]model.FacetValue { return &f.Artist },
+	},
+	{
+		value: "tracks.album",
+		where: "tracks.album <> ''",
+		order: "count DESC, value",
+		limit: maxFacetValues,
+		clear: func(p *model.TrackSearchParams) { p.Album = nil },
+		dest:  func(f *model.TrackFacets) *[]model.FacetValue { return &f.Album },
+	},
+	{
+		value: "CAST(tracks.year / 10 * 10 AS text)",
+		where: "tracks.year > 0",
+		order: "min(tracks.year)",
+		clear: func(p *model.TrackSearchParams) { p.Decade = nil },
+		dest:  func(f *model.TrackFacets) *[]model.FacetValue { return &f.Decade },
+	},
+	{
+		value: durationBucketCase(),
+		where: "true",
+		order: "min(tracks.duration)",
+		clear: func(p *model.TrackSearchParams) { p.Duration = nil },
+		dest:  func(f *model.TrackFacets) *[]model.FacetValue { return &f.Duration },
+	},
+	{
+		value: "tracks.content_type",
+		where: "tracks.content_type <> ''",
+		order: "count DESC, value",
+		clear: func(p *model.TrackSearchParams) { p.Format = nil },
+		dest:  func(f *model.TrackFacets) *[]model.FacetValue { return &f.Format },
+		display: func(mimeType string) string {
+			if t, ok := sniff.ByMIME(mimeType); ok {
+				return t.Name
+			}
+			return mimeType
+		},
+	},
 }
 
+// durationBucketCase строит выражение, относящее длительность трека к одному из model.DurationBuckets
+func durationBucketCase() string {
+	var b strings.Builder
+	b.WriteString("CASE")
+	for _, bucket := range model.DurationBuckets {
+		if bucket.Max > 0 {
+			fmt.Fprintf(&b, " WHEN tracks.duration < %d THEN '%s'", bucket.Max, bucket.Name)
+		} else {
+			fmt.Fprintf(&b, " ELSE '%s'", bucket.Name)
+		}
+	}
+	b.WriteString(" END")
+	return b.String()
+}
+
+// Facets считает фасеты по трекам, найденным с params: полнотекстово или, с similar, по сходству
+func (r *trackRepository) Facets(params model.TrackSearchParams, similar bool) (*model.TrackFacets, error) {
+	facets := &model.TrackFacets{}
+	for _, facet := range trackFacets {
+		facetParams := params
+		facet.clear(&facetParams)
+
+		query := r.filter(facetParams)
+		switch {
+		case len(searchTerms(params.Query)) == 0:
+		case similar:
+			query = similarSearch(query, params.Query)
+		default:
+			query = fullTextSearch(query, params)
+		}
+
+		query = query.Select(facet.value + " AS value, count(*) AS count").
+			Where(facet.where).
+			Group("value").
+			Order(facet.order)
+		if facet.limit > 0 {
+			query = query.Limit(facet.limit)
+		}
+
+		values := []model.FacetValue{}
+		if err := query.Scan(&values).Error; err != nil {
+			return nil, err
+		}
+		if facet.display != nil {
+			for i := range values {
+				values[i].Value = facet.display(values[i].Value)
+			}
+		}
+		*facet.dest(facets) = values
+	}
+	return facets, nil
+}
+
+// GetByPlaylistID возвращает треки плейлиста в его порядке, включая повторы
 func (r *trackRepository) GetByPlaylistID(playlistID uint) ([]model.Track, error) {
 	var tracks []model.Track
-	err := r.db.Model(&model.Playlist{}).Where("id = ?", playlistID).Association("Tracks").Find(&tracks)
+	err := r.db.Joins("JOIN playlist_entries ON playlist_entries.track_id = tracks.id").
+		Where("playlist_entries.playlist_id = ?", playlistID).
+		Order("playlist_entries.position").
+		Find(&tracks).Error
+	return tracks, err
+}
+
+// GetByIDs возвращает найденные треки из ids в порядке исполнителя, альбома и номера
+func (r *trackRepository) GetByIDs(ids []uint) ([]model.Track, error) {
+	var tracks []model.Track
+	err := r.db.Where("id IN ?", ids).Order(trackLibraryOrder).Find(&tracks).Error
+	return tracks, err
+}
+
+// Match возвращает треки, название, исполнитель или альбом которых содержит query без учета регистра
+func (r *trackRepository) Match(query string, offset, limit int) ([]model.Track, error) {
+	pattern := likeContains(query)
+	var tracks []model.Track
+	err := r.db.Where("title ILIKE ? OR artist ILIKE ? OR album ILIKE ?", pattern, pattern, pattern).
+		Order(trackLibraryOrder).Offset(offset).Limit(limit).Find(&tracks).Error
 	return tracks, err
 }
+
+// trackLibraryOrder - порядок треков при просмотре библиотеки: по исполнителю, альбому, диску и номеру
+const trackLibraryOrder = "lower(artist), lower(album), disc_number, track_number, title, id"
+
+// GetUnlinked возвращает треки, еще не привязанные к исполнителю
+func (r *trackRepository) GetUnlinked() ([]model.Track, error) {
+	var tracks []model.Track
+	err := r.db.Where("artist_id IS NULL").Order("id").Find(&tracks).Error
+	return tracks, err
+}
+
+func (r *trackRepository) UpdateLinks(track *model.Track) error {
+	return r.db.Model(track).Select("artist_id", "album_id").Updates(track).Error
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"MusicService/internal/model"
+	"strings"
 
 	"gorm.io/gorm"
 )
@@ -9,8 +10,13 @@ import (
 type UserRepository interface {
 	Create(user *model.User) error
 	FindByEmail(email string) (*model.User, error)
+	FindByUsername(username string) (*model.User, error)
 	FindByID(id uint) (*model.User, error)
 	Update(user *model.User) error
+	AdvanceTOTPStep(id uint, step int64) (bool, error)
+	Search(query string) ([]model.User, error)
+	CountByRole(role string) (int64, error)
+	FindFirst() (*model.User, error)
 }
 
 type userRepository struct {
@@ -31,6 +37,12 @@ func (r *userRepository) FindByEmail(email string) (*model.User, error) {
 	return &user, err
 }
 
+func (r *userRepository) FindByUsername(username string) (*model.User, error) {
+	var user model.User
+	err := r.db.Where("username = ?", username).First(&user).Error
+	return &user, err
+}
+
 func (r *userRepository) FindByID(id uint) (*model.User, error) {
 	var user model.User
 	err := r.db.First(&user, id).Error
@@ -40,3 +52,37 @@ func (r *userRepository) FindByID(id uint) (*model.User, error) {
 func (r *userRepository) Update(user *model.User) error {
 	return r.db.Save(user).Error
 }
+
+// AdvanceTOTPStep запоминает шаг принятого кода TOTP. Возвращает false, если код с этим
+// или более поздним шагом уже был принят, в том числе параллельным запросом
+func (r *userRepository) AdvanceTOTPStep(id uint, step int64) (bool, error) {
+	result := r.db.Model(&model.User{}).
+		Where("id = ? AND totp_last_step < ?", id, step).
+		Update("totp_last_step", step)
+	return result.RowsAffected == 1, result.Error
+}
+
+// Search ищет пользователей по подстроке имени или email; пустой запрос возвращает всех
+func (r *userRepository) Search(query string) ([]model.User, error) {
+	var users []model.User
+	db := r.db.Order("id")
+	if query = strings.TrimSpace(query); query != "" {
+		pattern := "%" + strings.ToLower(query) + "%"
+		db = db.Where("LOWER(username) LIKE ? OR LOWER(email) LIKE ?", pattern, pattern)
+	}
+	err := db.Find(&users).Error
+	return users, err
+}
+
+func (r *userRepository) CountByRole(role string) (int64, error) {
+	var count int64
+	err := r.db.Model(&model.User{}).Where("role = ?", role).Count(&count).Error
+	return count, err
+}
+
+// FindFirst возвращает пользователя, зарегистрированного раньше всех
+func (r *userRepository) FindFirst() (*model.User, error) {
+	var user model.User
+	err := r.db.Order("id").First(&user).Error
+	return &user, err
+}
@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"MusicService/internal/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type UserTokenRepository interface {
+	Create(token *model.UserToken) error
+	FindByHash(purpose, hash string) (*model.UserToken, error)
+	MarkUsed(id uint) (bool, error)
+	InvalidateForUser(userID uint, purpose string) error
+	RecordFailedAttempt(id uint, maxAttempts int) error
+}
+
+type userTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewUserTokenRepository(db *gorm.DB) UserTokenRepository {
+	return &userTokenRepository{db: db}
+}
+
+func (r *userTokenRepository) Create(token *model.UserToken) error {
+	return r.db.Create(token).Error
+}
+
+func (r *userTokenRepository) FindByHash(purpose, hash string) (*model.UserToken, error) {
+	var token model.UserToken
+	err := r.db.Where("purpose = ? AND token_hash = ?", purpose, hash).First(&token).Error
+	return &token, err
+}
+
+// MarkUsed помечает токен использованным. Возвращает false, если токен уже был использован
+func (r *userTokenRepository) MarkUsed(id uint) (bool, error) {
+	result := r.db.Model(&model.UserToken{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now())
+	return result.RowsAffected == 1, result.Error
+}
+
+// InvalidateForUser гасит все неиспользованные токены пользователя с указанным назначением
+func (r *userTokenRepository) InvalidateForUser(userID uint, purpose string) error {
+	return r.db.Model(&model.UserToken{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+		Update("used_at", time.Now()).Error
+}
+
+// RecordFailedAttempt учитывает неудачную попытку и гасит токен, когда попытки исчерпаны
+func (r *userTokenRepository) RecordFailedAttempt(id uint, maxAttempts int) error {
+	return r.db.Model(&model.UserToken{}).Where("id = ? AND used_at IS NULL", id).
+		Updates(map[string]any{
+			"attempts": gorm.Expr("attempts + 1"),
+			"used_at":  gorm.Expr("CASE WHEN attempts + 1 >= ? THEN ?::timestamptz ELSE NULL END", maxAttempts, time.Now()),
+		}).Error
+}
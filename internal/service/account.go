@@ -0,0 +1,107 @@
+package service
+
+import (
+	"MusicService/internal/model"
+	"MusicService/internal/repository"
+	"MusicService/internal/storage"
+	"MusicService/pkg/mailer"
+	"errors"
+	"fmt"
+	"log"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var ErrLastAdmin = errors.New("the only administrator cannot delete their account")
+
+// AccountService удаляет учетную запись пользователя по его запросу
+type AccountService interface {
+	DeleteAccount(userID uint, req *model.DeleteAccountRequest) error
+}
+
+type accountService struct {
+	accountRepo repository.AccountRepository
+	userRepo    repository.UserRepository
+	trackRepo   repository.TrackRepository
+	twoFactor   TwoFactorService
+	minioClient storage.MinIOClient
+	bucketName  string
+	mailer      mailer.Mailer
+}
+
+func NewAccountService(accountRepo repository.AccountRepository, userRepo repository.UserRepository,
+	trackRepo repository.TrackRepository, twoFactor TwoFactorService, minioClient storage.MinIOClient,
+	bucketName string, mailer mailer.Mailer) AccountService {
+	return &accountService{
+		accountRepo: accountRepo,
+		userRepo:    userRepo,
+		trackRepo:   trackRepo,
+		twoFactor:   twoFactor,
+		minioClient: minioClient,
+		bucketName:  bucketName,
+		mailer:      mailer,
+	}
+}
+
+// DeleteAccount удаляет учетную запись после проверки пароля и, если включена, 2FA.
+// Загруженные треки, плейлисты и история прослушиваний удаляются или обезличиваются
+// в зависимости от req.Data; выгрузки данных удаляются всегда
+func (s *accountService) DeleteAccount(userID uint, req *model.DeleteAccountRequest) error {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+		return ErrIncorrectPassword
+	}
+	if user.TOTPEnabled {
+		ok, err := s.twoFactor.VerifyCode(user, req.Code)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrInvalidTwoFactorCode
+		}
+	}
+
+	if user.Role == model.RoleAdmin {
+		admins, err := s.userRepo.CountByRole(model.RoleAdmin)
+		if err != nil {
+			return err
+		}
+		if admins <= 1 {
+			return ErrLastAdmin
+		}
+	}
+
+	anonymize := req.Data == model.AccountDataAnonymize
+	var tracks []model.Track
+	if !anonymize {
+		if tracks, err = s.trackRepo.GetUserTracks(userID); err != nil {
+			return err
+		}
+	}
+
+	if err := s.accountRepo.DeleteUser(userID, anonymize); err != nil {
+		return err
+	}
+
+	// Записи в базе уже удалены; оставшиеся файлы только занимают место, поэтому ошибки не прерывают удаление
+	for i := range tracks {
+		if err := removeTrackObjects(s.minioClient, s.bucketName, &tracks[i]); err != nil {
+			log.Printf("Failed to remove files of track %d: %v", tracks[i].ID, err)
+		}
+	}
+	if err := s.minioClient.RemoveObjectsWithPrefix(s.bucketName, fmt.Sprintf("exports/%d/", userID)); err != nil {
+		log.Printf("Failed to remove data exports of user %d: %v", userID, err)
+	}
+
+	log.Printf("Deleted account of user %d (%s content)", userID, req.Data)
+	sendMailAsync(s.mailer, mailer.Message{
+		To:      user.Email,
+		Subject: "Your account has been deleted",
+		Body:    fmt.Sprintf("Hello, %s!\n\nYour account and personal data have been deleted.\n", user.Username),
+	})
+	return nil
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"MusicService/internal/model"
+	"MusicService/internal/repository"
+	"MusicService/internal/storage"
+	"errors"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrUserNotFound     = errors.New("user not found")
+	ErrCannotModifySelf = errors.New("administrators cannot change their own role or status")
+)
+
+type AdminService interface {
+	SearchUsers(params model.AdminUserSearchParams) ([]model.AdminUserResponse, error)
+	SetUserRole(adminID, userID uint, role string) (*model.AdminUserResponse, error)
+	SetUserDisabled(adminID, userID uint, disabled bool) (*model.AdminUserResponse, error)
+	GetSystemStats() (*model.SystemStats, error)
+}
+
+type adminService struct {
+	userRepo    repository.UserRepository
+	statsRepo   repository.StatsRepository
+	minioClient storage.MinIOClient
+	bucketName  string
+}
+
+func NewAdminService(userRepo repository.UserRepository, statsRepo repository.StatsRepository,
+	minioClient storage.MinIOClient, bucketName string) AdminService {
+	return &adminService{
+		userRepo:    userRepo,
+		statsRepo:   statsRepo,
+		minioClient: minioClient,
+		bucketName:  bucketName,
+	}
+}
+
+func (s *adminService) SearchUsers(params model.AdminUserSearchParams) ([]model.AdminUserResponse, error) {
+	users, err := s.userRepo.Search(params.Query)
+	if err != nil {
+		return nil, err
+	}
+
+	response := []model.AdminUserResponse{}
+	for i := range users {
+		response = append(response, newAdminUserResponse(&users[i]))
+	}
+	return response, nil
+}
+
+func (s *adminService) SetUserRole(adminID, userID uint, role string) (*model.AdminUserResponse, error) {
+	return s.updateUser(adminID, userID, func(user *model.User) {
+		user.Role = role
+	})
+}
+
+func (s *adminService) SetUserDisabled(adminID, userID uint, disabled bool) (*model.AdminUserResponse, error) {
+	return s.updateUser(adminID, userID, func(user *model.User) {
+		user.Disabled = disabled
+	})
+}
+
+// updateUser изменяет чужую учетную запись. Менять собственную роль или статус запрещено,
+// чтобы администратор случайно не лишил сервис последнего администратора
+func (s *adminService) updateUser(adminID, userID uint, update func(user *model.User)) (*model.AdminUserResponse, error) {
+	if adminID == userID {
+		return nil, ErrCannotModifySelf
+	}
+
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	update(user)
+	if err := s.userRepo.Update(user); err != nil {
+		return nil, err
+	}
+
+	response := newAdminUserResponse(user)
+	return &response, nil
+}
+
+func (s *adminService) GetSystemStats() (*model.SystemStats, error) {
+	stats, err := s.statsRepo.GetSystemStats()
+	if err != nil {
+		return nil, err
+	}
+
+	if stats.StorageBytes, err = s.minioClient.BucketSize(s.bucketName); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+func newAdminUserResponse(user *model.User) model.AdminUserResponse {
+	return model.AdminUserResponse{
+		UserResponse: *newUserResponse(user),
+		Disabled:     user.Disabled,
+		CreatedAt:    user.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+// EnsureAdmin назначает администратора при запуске: пользователя username из конфигурации,
+// а если он не задан и администраторов нет - первого зарегистрированного пользователя
+func EnsureAdmin(userRepo repository.UserRepository, username string) error {
+	var user *model.User
+	var err error
+
+	if username != "" {
+		user, err = userRepo.FindByUsername(username)
+	} else {
+		var admins int64
+		if admins, err = userRepo.CountByRole(model.RoleAdmin); err != nil || admins > 0 {
+			return err
+		}
+		user, err = userRepo.FindFirst()
+	}
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// Роль будет назначена при следующем запуске после регистрации
+		return nil
+	}
+	if err != nil || user.Role == model.RoleAdmin {
+		return err
+	}
+
+	user.Role = model.RoleAdmin
+	if err := userRepo.Update(user); err != nil {
+		return err
+	}
+
+	log.Printf("Granted admin role to user %s", user.Username)
+	return nil
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"MusicService/internal/model"
+	"MusicService/internal/repository"
+	"MusicService/internal/storage"
+	"errors"
+	"fmt"
+	"io"
+
+	"gorm.io/gorm"
+)
+
+var ErrAlbumNotFound = errors.New("album not found")
+
+type AlbumService interface {
+	GetAllAlbums(page model.PageParams) (*model.Page[model.AlbumResponse], error)
+	GetAlbumByID(id uint) (*model.AlbumDetailResponse, error)
+	GetAlbumImage(id uint) (io.ReadCloser, string, error)
+}
+
+type albumService struct {
+	albumRepo   repository.AlbumRepository
+	minioClient storage.MinIOClient
+	bucketName  string
+}
+
+func NewAlbumService(albumRepo repository.AlbumRepository, minioClient storage.MinIOClient, bucketName string) AlbumService {
+	return &albumService{
+		albumRepo:   albumRepo,
+		minioClient: minioClient,
+		bucketName:  bucketName,
+	}
+}
+
+func (s *albumService) GetAllAlbums(page model.PageParams) (*model.Page[model.AlbumResponse], error) {
+	albums, err := s.albumRepo.List(page)
+	if err != nil {
+		return nil, err
+	}
+
+	return mapPage(albums, newAlbumResponse), nil
+}
+
+func (s *albumService) GetAlbumByID(id uint) (*model.AlbumDetailResponse, error) {
+	album, err := s.getAlbum(id)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &model.AlbumDetailResponse{
+		AlbumResponse: newAlbumResponse(album),
+		Tracks:        make([]model.TrackResponse, 0, len(album.Tracks)),
+	}
+	for _, track := range album.Tracks {
+		response.Tracks = append(response.Tracks, newTrackResponse(&track))
+	}
+
+	return response, nil
+}
+
+// GetAlbumImage возвращает обложку альбома - изображение первого трека, у которого оно есть
+func (s *albumService) GetAlbumImage(id uint) (io.ReadCloser, string, error) {
+	album, err := s.getAlbum(id)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if cover := albumCoverTrack(album); cover != nil {
+		return openTrackImage(s.minioClient, s.bucketName, cover)
+	}
+	return nil, "", ErrCoverNotFound
+}
+
+func (s *albumService) getAlbum(id uint) (*model.Album, error) {
+	album, err := s.albumRepo.GetByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAlbumNotFound
+		}
+		return nil, fmt.Errorf("failed to retrieve album: %w", err)
+	}
+	return album, nil
+}
+
+// newAlbumResponse строит ответ по счетчикам из выборки; треки альбома не нужны
+func newAlbumResponse(album *model.Album) model.AlbumResponse {
+	response := model.AlbumResponse{
+		ID:         album.ID,
+		Title:      album.Title,
+		ArtistID:   album.ArtistID,
+		Artist:     album.Artist.Name,
+		Year:       album.Year,
+		Genre:      album.Genre,
+		TrackCount: int(album.TrackCount),
+		Duration:   int(album.Duration),
+	}
+	if album.CoverTrackID != 0 {
+		response.ImageURL = fmt.Sprintf("/api/albums/%d/image", album.ID)
+	}
+
+	return response
+}
+
+func albumCoverTrack(album *model.Album) *model.Track {
+	for i := range album.Tracks {
+		if album.Tracks[i].ImagePath != "" {
+			return &album.Tracks[i]
+		}
+	}
+	return nil
+}
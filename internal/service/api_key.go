@@ -0,0 +1,110 @@
+package service
+
+import (
+	"MusicService/internal/model"
+	"MusicService/internal/repository"
+	"MusicService/pkg/tokenhash"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrAPIKeyNotFound      = errors.New("API key not found")
+	ErrInvalidAPIKeyExpiry = errors.New("API key expiry must be in the future")
+)
+
+type APIKeyService interface {
+	Create(userID uint, req *model.CreateAPIKeyRequest) (*model.CreatedAPIKeyResponse, error)
+	List(userID uint) ([]model.APIKeyResponse, error)
+	Revoke(userID, keyID uint) error
+}
+
+type apiKeyService struct {
+	apiKeyRepo repository.APIKeyRepository
+}
+
+func NewAPIKeyService(apiKeyRepo repository.APIKeyRepository) APIKeyService {
+	return &apiKeyService{apiKeyRepo: apiKeyRepo}
+}
+
+func (s *apiKeyService) Create(userID uint, req *model.CreateAPIKeyRequest) (*model.CreatedAPIKeyResponse, error) {
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, ErrInvalidAPIKeyExpiry
+	}
+
+	secret, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	rawKey := model.APIKeyPrefix + secret
+
+	scopes := slices.Clone(req.Scopes)
+	slices.Sort(scopes)
+
+	key := &model.APIKey{
+		UserID:    userID,
+		Name:      req.Name,
+		Prefix:    rawKey[:len(model.APIKeyPrefix)+8],
+		KeyHash:   tokenhash.Sum(rawKey),
+		Scopes:    strings.Join(slices.Compact(scopes), ","),
+		ExpiresAt: req.ExpiresAt,
+	}
+	if err := s.apiKeyRepo.Create(key); err != nil {
+		return nil, err
+	}
+
+	return &model.CreatedAPIKeyResponse{APIKeyResponse: newAPIKeyResponse(key), Key: rawKey}, nil
+}
+
+func (s *apiKeyService) List(userID uint) ([]model.APIKeyResponse, error) {
+	keys, err := s.apiKeyRepo.GetActiveByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := []model.APIKeyResponse{}
+	for i := range keys {
+		response = append(response, newAPIKeyResponse(&keys[i]))
+	}
+	return response, nil
+}
+
+func (s *apiKeyService) Revoke(userID, keyID uint) error {
+	key, err := s.apiKeyRepo.FindByID(keyID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrAPIKeyNotFound
+		}
+		return err
+	}
+	// Чужой ключ неотличим от несуществующего
+	if key.UserID != userID || key.RevokedAt != nil {
+		return ErrAPIKeyNotFound
+	}
+
+	return s.apiKeyRepo.Revoke(key.ID)
+}
+
+func newAPIKeyResponse(key *model.APIKey) model.APIKeyResponse {
+	return model.APIKeyResponse{
+		ID:         key.ID,
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		Scopes:     strings.Split(key.Scopes, ","),
+		ExpiresAt:  formatOptionalTime(key.ExpiresAt),
+		LastUsedAt: formatOptionalTime(key.LastUsedAt),
+		CreatedAt:  key.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+func formatOptionalTime(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	formatted := t.Format(time.RFC3339)
+	return &formatted
+}
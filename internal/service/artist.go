@@ -0,0 +1,106 @@
+package service
+
+import (
+	"MusicService/internal/model"
+	"MusicService/internal/repository"
+	"MusicService/internal/storage"
+	"errors"
+	"fmt"
+	"io"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrArtistNotFound = errors.New("artist not found")
+	ErrCoverNotFound  = errors.New("no cover image available")
+)
+
+type ArtistService interface {
+	GetAllArtists(page model.PageParams) (*model.Page[model.ArtistResponse], error)
+	GetArtistByID(id uint) (*model.ArtistDetailResponse, error)
+	GetArtistImage(id uint) (io.ReadCloser, string, error)
+}
+
+type artistService struct {
+	artistRepo  repository.ArtistRepository
+	minioClient storage.MinIOClient
+	bucketName  string
+}
+
+func NewArtistService(artistRepo repository.ArtistRepository, minioClient storage.MinIOClient, bucketName string) ArtistService {
+	return &artistService{
+		artistRepo:  artistRepo,
+		minioClient: minioClient,
+		bucketName:  bucketName,
+	}
+}
+
+func (s *artistService) GetAllArtists(page model.PageParams) (*model.Page[model.ArtistResponse], error) {
+	artists, err := s.artistRepo.List(page)
+	if err != nil {
+		return nil, err
+	}
+
+	return mapPage(artists, newArtistResponse), nil
+}
+
+func (s *artistService) GetArtistByID(id uint) (*model.ArtistDetailResponse, error) {
+	artist, err := s.artistRepo.GetByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrArtistNotFound
+		}
+		return nil, err
+	}
+
+	response := &model.ArtistDetailResponse{
+		ArtistResponse: newArtistResponse(artist),
+		Albums:         make([]model.AlbumResponse, 0, len(artist.Albums)),
+	}
+	for _, album := range artist.Albums {
+		album.Artist = *artist
+		response.Albums = append(response.Albums, newAlbumResponse(&album))
+	}
+
+	return response, nil
+}
+
+// GetArtistImage возвращает обложку первого альбома исполнителя, у которого она есть
+func (s *artistService) GetArtistImage(id uint) (io.ReadCloser, string, error) {
+	artist, err := s.artistRepo.GetByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, "", ErrArtistNotFound
+		}
+		return nil, "", fmt.Errorf("failed to retrieve artist: %w", err)
+	}
+
+	if cover := artistCoverTrack(artist); cover != nil {
+		return openTrackImage(s.minioClient, s.bucketName, cover)
+	}
+	return nil, "", ErrCoverNotFound
+}
+
+// newArtistResponse строит ответ по счетчикам из выборки; альбомы исполнителя не нужны
+func newArtistResponse(artist *model.Artist) model.ArtistResponse {
+	response := model.ArtistResponse{
+		ID:         artist.ID,
+		Name:       artist.Name,
+		AlbumCount: int(artist.AlbumCount),
+	}
+	if artist.CoverTrackID != 0 {
+		response.ImageURL = fmt.Sprintf("/api/artists/%d/image", artist.ID)
+	}
+
+	return response
+}
+
+func artistCoverTrack(artist *model.Artist) *model.Track {
+	for i := range artist.Albums {
+		if cover := albumCoverTrack(&artist.Albums[i]); cover != nil {
+			return cover
+		}
+	}
+	return nil
+}
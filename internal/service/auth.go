@@ -4,25 +4,89 @@ import (
 	"MusicService/internal/model"
 	"MusicService/internal/repository"
 	"MusicService/pkg/jwt"
+	"MusicService/pkg/ratelimit"
+	"MusicService/pkg/tokenhash"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
 	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
 
+	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidCredentials  = errors.New("invalid credentials")
+	ErrAccountDisabled     = errors.New("account is disabled")
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrSessionNotFound     = errors.New("session not found")
+	ErrInvalidChallenge    = errors.New("invalid or expired login challenge")
+	ErrLoginLocked         = errors.New("too many failed login attempts")
+)
+
+// LoginLockedError сообщает, через сколько можно снова попытаться войти
+type LoginLockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginLockedError) Error() string {
+	return fmt.Sprintf("%v, try again in %v", ErrLoginLocked, e.RetryAfter.Round(time.Second))
+}
+
+func (e *LoginLockedError) Is(target error) bool {
+	return target == ErrLoginLocked
+}
+
+const (
+	loginChallengeTTL         = 5 * time.Minute
+	loginChallengeMaxAttempts = 5
 )
 
 type AuthService interface {
 	Register(user *model.RegisterRequest) (*model.UserResponse, error)
-	Login(credentials *model.LoginRequest) (string, error)
+	Login(credentials *model.LoginRequest, client model.ClientInfo) (*model.LoginResponse, error)
+	CompleteTwoFactorLogin(req *model.TwoFactorLoginRequest, client model.ClientInfo) (*model.TokenResponse, error)
+	LoginExternal(user *model.User, client model.ClientInfo) (*model.TokenResponse, error)
+	Refresh(refreshToken string, client model.ClientInfo) (*model.TokenResponse, error)
+	Logout(refreshToken string) error
+	LogoutEverywhere(userID uint) error
+	GetSessions(userID uint, currentSessionID string) ([]model.SessionResponse, error)
+	RevokeSession(userID uint, sessionID string) error
 }
 
 type authService struct {
-	userRepo   repository.UserRepository
-	jwtService jwt.JWTService
+	userRepo         repository.UserRepository
+	refreshTokenRepo repository.RefreshTokenRepository
+	sessionRepo      repository.SessionRepository
+	userTokenRepo    repository.UserTokenRepository
+	verification     VerificationService
+	twoFactor        TwoFactorService
+	lockout          *ratelimit.Lockout
+	jwtService       jwt.JWTService
+	refreshTokenTTL  time.Duration
 }
 
-func NewAuthService(userRepo repository.UserRepository, jwtService jwt.JWTService) AuthService {
+// NewAuthService создает сервис аутентификации. lockout блокирует вход
+// в учетную запись после повторных неудачных попыток
+func NewAuthService(userRepo repository.UserRepository, refreshTokenRepo repository.RefreshTokenRepository,
+	sessionRepo repository.SessionRepository, userTokenRepo repository.UserTokenRepository,
+	verification VerificationService, twoFactor TwoFactorService, lockout *ratelimit.Lockout,
+	jwtService jwt.JWTService, refreshTokenTTL time.Duration) AuthService {
 	return &authService{
-		userRepo:   userRepo,
-		jwtService: jwtService,
+		userRepo:         userRepo,
+		refreshTokenRepo: refreshTokenRepo,
+		sessionRepo:      sessionRepo,
+		userTokenRepo:    userTokenRepo,
+		verification:     verification,
+		twoFactor:        twoFactor,
+		lockout:          lockout,
+		jwtService:       jwtService,
+		refreshTokenTTL:  refreshTokenTTL,
 	}
 }
 
@@ -37,37 +101,328 @@ func (s *authService) Register(req *model.RegisterRequest) (*model.UserResponse,
 		return nil, err
 	}
 
+	// Роль администратора назначается только при запуске (EnsureAdmin) или через API администратора
 	user := &model.User{
 		Username: req.Username,
 		Email:    req.Email,
 		Password: string(hashedPassword),
+		Role:     model.RoleUser,
 	}
 
 	if err := s.userRepo.Create(user); err != nil {
 		return nil, err
 	}
 
-	return &model.UserResponse{
-		ID:       user.ID,
-		Username: user.Username,
-		Email:    user.Email,
-	}, nil
+	// Пользователь уже создан; письмо можно запросить повторно
+	if err := s.verification.SendVerification(user); err != nil {
+		log.Printf("Failed to send verification email to user %d: %v", user.ID, err)
+	}
+
+	return newUserResponse(user), nil
 }
 
-func (s *authService) Login(req *model.LoginRequest) (string, error) {
+// Login проверяет пароль. Если у пользователя включена двухфакторная аутентификация, токены
+// не выдаются: вместо них возвращается короткоживущий токен для CompleteTwoFactorLogin
+func (s *authService) Login(req *model.LoginRequest, client model.ClientInfo) (*model.LoginResponse, error) {
+	if err := checkLoginLockout(s.lockout, req.Email); err != nil {
+		return nil, err
+	}
+
 	user, err := s.userRepo.FindByEmail(req.Email)
 	if err != nil {
-		return "", errors.New("invalid credentials")
+		recordLoginFailure(s.lockout, req.Email)
+		return nil, ErrInvalidCredentials
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
-		return "", errors.New("invalid credentials")
+		recordLoginFailure(s.lockout, req.Email)
+		return nil, ErrInvalidCredentials
+	}
+
+	if user.Disabled {
+		return nil, ErrAccountDisabled
 	}
 
-	token, err := s.jwtService.GenerateToken(user.ID)
+	if user.TOTPEnabled {
+		challenge, err := issueUserToken(s.userTokenRepo, user.ID, model.TokenPurposeLoginChallenge, loginChallengeTTL)
+		if err != nil {
+			return nil, err
+		}
+		return &model.LoginResponse{TwoFactorRequired: true, ChallengeToken: challenge}, nil
+	}
+
+	tokens, err := s.startSession(user, client)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	resetLoginLockout(s.lockout, user.Email)
+	return &model.LoginResponse{TokenResponse: tokens}, nil
+}
+
+// CompleteTwoFactorLogin завершает вход кодом из приложения-аутентификатора или кодом
+// восстановления. После нескольких неверных кодов токен входа гасится и вход нужно начинать заново
+func (s *authService) CompleteTwoFactorLogin(req *model.TwoFactorLoginRequest, client model.ClientInfo) (*model.TokenResponse, error) {
+	challenge, err := s.userTokenRepo.FindByHash(model.TokenPurposeLoginChallenge, tokenhash.Sum(req.ChallengeToken))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidChallenge
+		}
+		return nil, err
+	}
+	if challenge.UsedAt != nil || time.Now().After(challenge.ExpiresAt) {
+		return nil, ErrInvalidChallenge
+	}
+
+	user, err := s.userRepo.FindByID(challenge.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Disabled {
+		return nil, ErrAccountDisabled
+	}
+	// Неверные коды учитываются вместе с неверными паролями, иначе, зная пароль, код можно
+	// было бы перебирать, каждый раз начиная вход заново
+	if err := checkLoginLockout(s.lockout, user.Email); err != nil {
+		return nil, err
+	}
+
+	ok, err := s.twoFactor.VerifyCode(user, req.Code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		recordLoginFailure(s.lockout, user.Email)
+		if err := s.userTokenRepo.RecordFailedAttempt(challenge.ID, loginChallengeMaxAttempts); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	fresh, err := s.userTokenRepo.MarkUsed(challenge.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !fresh {
+		return nil, ErrInvalidChallenge
+	}
+
+	tokens, err := s.startSession(user, client)
+	if err != nil {
+		return nil, err
+	}
+	resetLoginLockout(s.lockout, user.Email)
+	return tokens, nil
+}
+
+// LoginExternal выполняет вход пользователя, которого уже проверил внешний провайдер.
+// Двухфакторная аутентификация в этом случае остается на стороне провайдера
+func (s *authService) LoginExternal(user *model.User, client model.ClientInfo) (*model.TokenResponse, error) {
+	if user.Disabled {
+		return nil, ErrAccountDisabled
+	}
+	return s.startSession(user, client)
+}
+
+// checkLoginLockout возвращает LoginLockedError, если вход в учетную запись временно заблокирован.
+// Сбой хранилища блокировок не мешает входу
+func checkLoginLockout(lockout *ratelimit.Lockout, email string) error {
+	retryAfter, err := lockout.Check(context.Background(), lockoutKey(email))
+	if err != nil {
+		log.Printf("Failed to check login lockout: %v", err)
+		return nil
+	}
+	if retryAfter > 0 {
+		return &LoginLockedError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+func recordLoginFailure(lockout *ratelimit.Lockout, email string) {
+	locked, err := lockout.Fail(context.Background(), lockoutKey(email))
+	if err != nil {
+		log.Printf("Failed to record login failure: %v", err)
+		return
+	}
+	if locked > 0 {
+		log.Printf("Login for %s locked for %v after repeated failures", email, locked)
+	}
+}
+
+func resetLoginLockout(lockout *ratelimit.Lockout, email string) {
+	if err := lockout.Reset(context.Background(), lockoutKey(email)); err != nil {
+		log.Printf("Failed to reset login lockout: %v", err)
+	}
+}
+
+// lockoutKey - блокировка привязана к адресу, поэтому действует и для незарегистрированных адресов,
+// не выдавая, существует ли учетная запись
+func lockoutKey(email string) string {
+	return "login:" + strings.ToLower(email)
+}
+
+// startSession создает сессию для нового входа и выдает ее первую пару токенов
+func (s *authService) startSession(user *model.User, client model.ClientInfo) (*model.TokenResponse, error) {
+	now := time.Now()
+	session := &model.Session{
+		ID:         uuid.NewString(),
+		UserID:     user.ID,
+		UserAgent:  client.UserAgent,
+		IP:         client.IP,
+		LastUsedAt: now,
+		ExpiresAt:  now.Add(s.refreshTokenTTL),
+	}
+	if err := s.sessionRepo.Create(session); err != nil {
+		return nil, err
+	}
+
+	return s.issueTokens(user, session.ID)
+}
+
+// Refresh обменивает токен обновления на новую пару токенов. Каждый токен обновления одноразовый:
+// повторное предъявление уже обменянного токена означает, что он украден, поэтому сессия
+// отзывается целиком - и у злоумышленника, и у законного владельца
+func (s *authService) Refresh(refreshToken string, client model.ClientInfo) (*model.TokenResponse, error) {
+	stored, session, err := s.findRefreshToken(refreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if session.RevokedAt != nil || time.Now().After(stored.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	fresh, err := s.refreshTokenRepo.MarkUsed(stored.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !fresh {
+		log.Printf("Refresh token reuse detected for user %d, revoking session %s", session.UserID, session.ID)
+		if err := s.sessionRepo.Revoke(session.ID); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidRefreshToken
+	}
+
+	user, err := s.userRepo.FindByID(session.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Disabled {
+		return nil, ErrAccountDisabled
+	}
+
+	if err := s.sessionRepo.Touch(session.ID, client.IP); err != nil {
+		return nil, err
+	}
+	return s.issueTokens(user, session.ID)
+}
+
+// Logout завершает сессию, к которой относится токен обновления
+func (s *authService) Logout(refreshToken string) error {
+	_, session, err := s.findRefreshToken(refreshToken)
+	if err != nil {
+		return err
+	}
+
+	return s.sessionRepo.Revoke(session.ID)
+}
+
+// LogoutEverywhere завершает все сессии пользователя
+func (s *authService) LogoutEverywhere(userID uint) error {
+	return s.sessionRepo.RevokeAllForUser(userID)
+}
+
+func (s *authService) GetSessions(userID uint, currentSessionID string) ([]model.SessionResponse, error) {
+	sessions, err := s.sessionRepo.GetActiveByUserID(userID)
+	if err != nil {
+		return nil, err
 	}
 
-	return token, nil
+	response := []model.SessionResponse{}
+	for _, session := range sessions {
+		response = append(response, model.SessionResponse{
+			ID:         session.ID,
+			UserAgent:  session.UserAgent,
+			IP:         session.IP,
+			CreatedAt:  session.CreatedAt.Format(time.RFC3339),
+			LastUsedAt: session.LastUsedAt.Format(time.RFC3339),
+			Current:    session.ID == currentSessionID,
+		})
+	}
+	return response, nil
+}
+
+func (s *authService) RevokeSession(userID uint, sessionID string) error {
+	session, err := s.sessionRepo.FindByID(sessionID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrSessionNotFound
+		}
+		return err
+	}
+	// Чужая сессия неотличима от несуществующей
+	if session.UserID != userID || session.RevokedAt != nil {
+		return ErrSessionNotFound
+	}
+
+	return s.sessionRepo.Revoke(session.ID)
+}
+
+func (s *authService) findRefreshToken(refreshToken string) (*model.RefreshToken, *model.Session, error) {
+	stored, err := s.refreshTokenRepo.FindByHash(tokenhash.Sum(refreshToken))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrInvalidRefreshToken
+		}
+		return nil, nil, err
+	}
+
+	session, err := s.sessionRepo.FindByID(stored.SessionID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrInvalidRefreshToken
+		}
+		return nil, nil, err
+	}
+	return stored, session, nil
+}
+
+func (s *authService) issueTokens(user *model.User, sessionID string) (*model.TokenResponse, error) {
+	accessToken, err := s.jwtService.GenerateToken(user.ID, user.Role, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt := time.Now().Add(s.refreshTokenTTL)
+	err = s.refreshTokenRepo.Create(&model.RefreshToken{
+		SessionID: sessionID,
+		TokenHash: tokenhash.Sum(refreshToken),
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.sessionRepo.Extend(sessionID, expiresAt); err != nil {
+		return nil, err
+	}
+
+	return &model.TokenResponse{
+		Token:        accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(s.jwtService.TokenTTL().Seconds()),
+	}, nil
+}
+
+// randomToken генерирует непрозрачный токен из 256 случайных бит
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
 }
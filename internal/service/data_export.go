@@ -0,0 +1,394 @@
+package service
+
+import (
+	"MusicService/internal/model"
+	"MusicService/internal/repository"
+	"MusicService/internal/storage"
+	"MusicService/pkg/mailer"
+	"archive/zip"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrDataExportNotFound   = errors.New("data export not found")
+	ErrDataExportInProgress = errors.New("a data export is already in progress")
+)
+
+// DataExportService собирает данные пользователя в ZIP-архив: профиль, метаданные загруженных
+// треков (по желанию и сами аудиофайлы), плейлисты и историю прослушиваний
+type DataExportService interface {
+	Request(userID uint, req *model.CreateDataExportRequest) (*model.DataExportResponse, error)
+	List(userID uint) ([]model.DataExportResponse, error)
+	Get(userID uint, exportID string) (*model.DataExportResponse, error)
+	FailUnfinished() error
+	RemoveExpired() error
+}
+
+type dataExportService struct {
+	exportRepo   repository.DataExportRepository
+	userRepo     repository.UserRepository
+	trackRepo    repository.TrackRepository
+	playlistRepo repository.PlaylistRepository
+	statsRepo    repository.StatsRepository
+	minioClient  storage.MinIOClient
+	bucketName   string
+	mailer       mailer.Mailer
+	linkTTL      time.Duration
+	retention    time.Duration
+}
+
+// NewDataExportService создает сервис выгрузки. Готовый архив хранится retention,
+// подписанная ссылка на него действует linkTTL
+func NewDataExportService(exportRepo repository.DataExportRepository, userRepo repository.UserRepository,
+	trackRepo repository.TrackRepository, playlistRepo repository.PlaylistRepository, statsRepo repository.StatsRepository,
+	minioClient storage.MinIOClient, bucketName string, mailer mailer.Mailer, linkTTL, retention time.Duration) DataExportService {
+	return &dataExportService{
+		exportRepo:   exportRepo,
+		userRepo:     userRepo,
+		trackRepo:    trackRepo,
+		playlistRepo: playlistRepo,
+		statsRepo:    statsRepo,
+		minioClient:  minioClient,
+		bucketName:   bucketName,
+		mailer:       mailer,
+		linkTTL:      linkTTL,
+		retention:    retention,
+	}
+}
+
+// Request ставит выгрузку в очередь. Архив собирается в фоне, по готовности пользователю
+// приходит письмо со ссылкой. Предыдущие архивы пользователя удаляются
+func (s *dataExportService) Request(userID uint, req *model.CreateDataExportRequest) (*model.DataExportResponse, error) {
+	exports, err := s.exportRepo.GetByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range exports {
+		if exports[i].Status == model.DataExportPending || exports[i].Status == model.DataExportRunning {
+			return nil, ErrDataExportInProgress
+		}
+	}
+	for i := range exports {
+		s.remove(&exports[i])
+	}
+
+	export := &model.DataExport{
+		ID:           uuid.NewString(),
+		UserID:       userID,
+		Status:       model.DataExportPending,
+		IncludeAudio: req.IncludeAudio,
+	}
+	if err := s.exportRepo.Create(export); err != nil {
+		return nil, err
+	}
+
+	go s.run(export)
+
+	return s.newDataExportResponse(export)
+}
+
+func (s *dataExportService) List(userID uint) ([]model.DataExportResponse, error) {
+	exports, err := s.exportRepo.GetByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := []model.DataExportResponse{}
+	for i := range exports {
+		item, err := s.newDataExportResponse(&exports[i])
+		if err != nil {
+			return nil, err
+		}
+		response = append(response, *item)
+	}
+	return response, nil
+}
+
+func (s *dataExportService) Get(userID uint, exportID string) (*model.DataExportResponse, error) {
+	export, err := s.exportRepo.FindByID(exportID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDataExportNotFound
+		}
+		return nil, err
+	}
+	if export.UserID != userID {
+		return nil, ErrDataExportNotFound
+	}
+
+	return s.newDataExportResponse(export)
+}
+
+// FailUnfinished вызывается при запуске: помечает неудачными выгрузки, прерванные остановкой сервиса
+func (s *dataExportService) FailUnfinished() error {
+	return s.exportRepo.FailUnfinished()
+}
+
+// RemoveExpired удаляет архивы с истекшим сроком хранения; вызывается периодически
+func (s *dataExportService) RemoveExpired() error {
+	expired, err := s.exportRepo.GetExpired()
+	if err != nil {
+		return err
+	}
+	for i := range expired {
+		s.remove(&expired[i])
+	}
+	return nil
+}
+
+func (s *dataExportService) remove(export *model.DataExport) {
+	if export.ObjectPath != "" {
+		if err := s.minioClient.RemoveObject(s.bucketName, export.ObjectPath); err != nil {
+			log.Printf("Failed to remove data export %s: %v", export.ID, err)
+			return
+		}
+	}
+	if err := s.exportRepo.Delete(export.ID); err != nil {
+		log.Printf("Failed to delete data export %s: %v", export.ID, err)
+	}
+}
+
+func (s *dataExportService) run(export *model.DataExport) {
+	export.Status = model.DataExportRunning
+	if err := s.exportRepo.Update(export); err != nil {
+		log.Printf("Failed to start data export %s: %v", export.ID, err)
+		return
+	}
+
+	user, err := s.userRepo.FindByID(export.UserID)
+	if err == nil {
+		err = s.build(export, user)
+	}
+	now := time.Now()
+	export.CompletedAt = &now
+	if err != nil {
+		log.Printf("Data export %s failed: %v", export.ID, err)
+		export.Status = model.DataExportFailed
+		export.Error = "failed to build the archive"
+		if err := s.exportRepo.Update(export); err != nil {
+			log.Printf("Failed to update data export %s: %v", export.ID, err)
+		}
+		return
+	}
+
+	expiresAt := now.Add(s.retention)
+	export.Status = model.DataExportReady
+	export.ExpiresAt = &expiresAt
+	if err := s.exportRepo.Update(export); err != nil {
+		log.Printf("Failed to update data export %s: %v", export.ID, err)
+		return
+	}
+
+	link, err := s.minioClient.PresignedGetObject(s.bucketName, export.ObjectPath, s.linkTTL)
+	if err != nil {
+		log.Printf("Failed to sign data export %s link: %v", export.ID, err)
+		return
+	}
+	sendMailAsync(s.mailer, mailer.Message{
+		To:      user.Email,
+		Subject: "Your data export is ready",
+		Body: fmt.Sprintf("Hello, %s!\n\nYour data export is ready. Download it using the link below:\n%s\n\n"+
+			"The link is valid for %v. You can get a new link in your account settings until %s.\n",
+			user.Username, link, s.linkTTL, expiresAt.Format(time.RFC1123)),
+	})
+}
+
+// build собирает архив во временном файле и загружает его в хранилище
+func (s *dataExportService) build(export *model.DataExport, user *model.User) error {
+	file, err := os.CreateTemp("", "data-export-*.zip")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	zw := zip.NewWriter(file)
+	if err := s.writeArchive(zw, export, user); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	size, err := file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	objectPath := fmt.Sprintf("exports/%d/%s.zip", export.UserID, export.ID)
+	if _, err := s.minioClient.PutObject(s.bucketName, objectPath, file, size, "application/zip"); err != nil {
+		return err
+	}
+
+	export.ObjectPath = objectPath
+	export.Size = size
+	return nil
+}
+
+func (s *dataExportService) writeArchive(zw *zip.Writer, export *model.DataExport, user *model.User) error {
+	profile := struct {
+		*model.UserResponse
+		CreatedAt string `json:"createdAt"`
+	}{newUserResponse(user), user.CreatedAt.Format(time.RFC3339)}
+	if err := writeZipJSON(zw, "profile.json", profile); err != nil {
+		return err
+	}
+
+	tracks, err := s.trackRepo.GetUserTracks(user.ID)
+	if err != nil {
+		return err
+	}
+	trackResponses := []model.TrackResponse{}
+	trackRows := [][]string{{"id", "title", "artist", "album", "genre", "year", "duration", "uploaded_at"}}
+	for i := range tracks {
+		track := &tracks[i]
+		trackResponses = append(trackResponses, newTrackResponse(track))
+		trackRows = append(trackRows, []string{
+			strconv.FormatUint(uint64(track.ID), 10), track.Title, track.Artist, track.Album, track.Genre,
+			strconv.Itoa(track.Year), strconv.Itoa(track.Duration), track.CreatedAt.Format(time.RFC3339),
+		})
+	}
+	if err := writeZipJSON(zw, "tracks.json", trackResponses); err != nil {
+		return err
+	}
+	if err := writeZipCSV(zw, "tracks.csv", trackRows); err != nil {
+		return err
+	}
+
+	if export.IncludeAudio {
+		for i := range tracks {
+			if err := s.writeAudio(zw, &tracks[i]); err != nil {
+				return err
+			}
+		}
+	}
+
+	playlists, err := s.playlistRepo.GetByUserID(user.ID)
+	if err != nil {
+		return err
+	}
+	playlistResponses := []model.PlaylistResponse{}
+	for i := range playlists {
+		playlistResponses = append(playlistResponses, newPlaylistResponse(&playlists[i]))
+	}
+	if err := writeZipJSON(zw, "playlists.json", playlistResponses); err != nil {
+		return err
+	}
+
+	history, err := s.statsRepo.GetListeningHistory(user.ID)
+	if err != nil {
+		return err
+	}
+	type play struct {
+		PlayedAt string `json:"playedAt"`
+		TrackID  uint   `json:"trackId"`
+		Title    string `json:"title"`
+		Artist   string `json:"artist"`
+		Album    string `json:"album"`
+	}
+	plays := []play{}
+	playRows := [][]string{{"played_at", "track_id", "title", "artist", "album"}}
+	for _, entry := range history {
+		p := play{
+			PlayedAt: entry.CreatedAt.Format(time.RFC3339),
+			TrackID:  entry.TrackID,
+			Title:    entry.Track.Title,
+			Artist:   entry.Track.Artist,
+			Album:    entry.Track.Album,
+		}
+		plays = append(plays, p)
+		playRows = append(playRows, []string{p.PlayedAt, strconv.FormatUint(uint64(p.TrackID), 10), p.Title, p.Artist, p.Album})
+	}
+	if err := writeZipJSON(zw, "listening_history.json", plays); err != nil {
+		return err
+	}
+	return writeZipCSV(zw, "listening_history.csv", playRows)
+}
+
+func (s *dataExportService) writeAudio(zw *zip.Writer, track *model.Track) error {
+	obj, err := s.minioClient.GetObject(s.bucketName, track.FilePath)
+	if err != nil {
+		return err
+	}
+	defer obj.Close()
+
+	name := fmt.Sprintf("audio/%d - %s - %s%s", track.ID, safeFileName(track.Artist), safeFileName(track.Title), path.Ext(track.FilePath))
+	// Аудио уже сжато, повторное сжатие только тратит время
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store, Modified: track.CreatedAt})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, obj)
+	return err
+}
+
+func (s *dataExportService) newDataExportResponse(export *model.DataExport) (*model.DataExportResponse, error) {
+	response := &model.DataExportResponse{
+		ID:           export.ID,
+		Status:       export.Status,
+		IncludeAudio: export.IncludeAudio,
+		Size:         export.Size,
+		Error:        export.Error,
+		CreatedAt:    export.CreatedAt.Format(time.RFC3339),
+		CompletedAt:  formatOptionalTime(export.CompletedAt),
+		ExpiresAt:    formatOptionalTime(export.ExpiresAt),
+	}
+
+	if export.Status == model.DataExportReady && export.ExpiresAt != nil && time.Now().Before(*export.ExpiresAt) {
+		link, err := s.minioClient.PresignedGetObject(s.bucketName, export.ObjectPath, s.linkTTL)
+		if err != nil {
+			return nil, err
+		}
+		response.DownloadURL = link.String()
+	}
+	return response, nil
+}
+
+func writeZipJSON(zw *zip.Writer, name string, v any) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+func writeZipCSV(zw *zip.Writer, name string, rows [][]string) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.WriteAll(rows); err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+func safeFileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|`, r) || r < ' ' {
+			return '_'
+		}
+		return r
+	}, name)
+}
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"MusicService/internal/model"
+	"MusicService/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+// fakeUserRepository хранит пользователей в памяти по имени
+type fakeUserRepository struct {
+	repository.UserRepository
+	users map[string]*model.User
+}
+
+func (r *fakeUserRepository) Create(user *model.User) error {
+	user.ID = uint(len(r.users) + 1)
+	r.users[user.Username] = user
+	return nil
+}
+
+func (r *fakeUserRepository) FindByUsername(username string) (*model.User, error) {
+	if user, ok := r.users[username]; ok {
+		return user, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *fakeUserRepository) FindByEmail(email string) (*model.User, error) {
+	for _, user := range r.users {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *fakeUserRepository) FindByID(id uint) (*model.User, error) {
+	for _, user := range r.users {
+		if user.ID == id {
+			return user, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *fakeUserRepository) AdvanceTOTPStep(id uint, step int64) (bool, error) {
+	user, err := r.FindByID(id)
+	if err != nil || user.TOTPLastStep >= step {
+		return false, err
+	}
+	user.TOTPLastStep = step
+	return true, nil
+}
@@ -0,0 +1,245 @@
+package service
+
+import (
+	"MusicService/internal/model"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	hlsSegmentDuration = 6 * time.Second
+	hlsPlaylistName    = "index.m3u8"
+	hlsPlaylistMIME    = "application/vnd.apple.mpegurl"
+	hlsSegmentMIME     = "video/mp2t"
+	// hlsAudioCodec - AAC-LC в нотации RFC 6381 для атрибута CODECS
+	hlsAudioCodec = "mp4a.40.2"
+)
+
+// hlsBitRates - битрейты вариантов HLS в мастер-плейлисте, in kbps
+var hlsBitRates = []int{64, 128, 192, 256}
+
+var (
+	ErrHLSVariantNotFound = errors.New("hls variant not found")
+	ErrHLSSegmentNotFound = errors.New("hls segment not found")
+)
+
+var hlsSegmentName = regexp.MustCompile(`^segment_\d{3,}\.ts$`)
+
+// keyedMutex - блокировки по ключу. Запись удаляется, когда блокировку никто не держит и не ждет,
+// поэтому число записей не превышает числа одновременных запросов
+type keyedMutex struct {
+	mu    sync.Mutex
+	locks map[string]*keyedLock
+}
+
+type keyedLock struct {
+	sync.Mutex
+	refs int
+}
+
+// lock захватывает блокировку key и возвращает функцию для ее освобождения
+func (k *keyedMutex) lock(key string) (unlock func()) {
+	k.mu.Lock()
+	if k.locks == nil {
+		k.locks = make(map[string]*keyedLock)
+	}
+	l, ok := k.locks[key]
+	if !ok {
+		l = &keyedLock{}
+		k.locks[key] = l
+	}
+	l.refs++
+	k.mu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+
+		k.mu.Lock()
+		if l.refs--; l.refs == 0 {
+			delete(k.locks, key)
+		}
+		k.mu.Unlock()
+	}
+}
+
+func hlsPrefix(trackID uint) string {
+	return fmt.Sprintf("hls/%d/", trackID)
+}
+
+func hlsObjectName(trackID uint, bitRate int, name string) string {
+	return fmt.Sprintf("%s%d/%s", hlsPrefix(trackID), bitRate, name)
+}
+
+// hlsVariants возвращает битрейты вариантов, не превышающие битрейт исходного файла
+func hlsVariants(sourceBitRate int) []int {
+	var variants []int
+	for _, b := range hlsBitRates {
+		if sourceBitRate <= 0 || b <= sourceBitRate {
+			variants = append(variants, b)
+		}
+	}
+	if len(variants) == 0 {
+		variants = hlsBitRates[:1]
+	}
+	return variants
+}
+
+func (s *trackService) GetHLSMasterPlaylist(id uint) (string, error) {
+	track, err := s.findTrack(id)
+	if err != nil {
+		return "", err
+	}
+	if s.transcoder == nil {
+		return "", ErrTranscodingUnavailable
+	}
+
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n#EXT-X-VERSION:3\n")
+	for _, bitRate := range hlsVariants(track.BitRate) {
+		// Запас ~10% на накладные расходы контейнера MPEG-TS
+		fmt.Fprintf(&b, "#EXT-X-STREAM-INF:BANDWIDTH=%d,AVERAGE-BANDWIDTH=%d,CODECS=\"%s\"\n",
+			bitRate*1100, bitRate*1000, hlsAudioCodec)
+		fmt.Fprintf(&b, "%d/%s\n", bitRate, hlsPlaylistName)
+	}
+
+	return b.String(), nil
+}
+
+func (s *trackService) GetHLSMediaPlaylist(id uint, bitRate int) (string, error) {
+	track, err := s.findTrack(id)
+	if err != nil {
+		return "", err
+	}
+	if !slices.Contains(hlsVariants(track.BitRate), bitRate) {
+		return "", ErrHLSVariantNotFound
+	}
+
+	playlistPath := hlsObjectName(track.ID, bitRate, hlsPlaylistName)
+	if playlist, err := s.readObject(playlistPath); err == nil {
+		return playlist, nil
+	}
+
+	if s.transcoder == nil {
+		return "", ErrTranscodingUnavailable
+	}
+
+	unlock := s.hlsLocks.lock(playlistPath)
+	defer unlock()
+
+	// Вариант мог быть нарезан параллельным запросом, пока мы ждали блокировку
+	if playlist, err := s.readObject(playlistPath); err == nil {
+		return playlist, nil
+	}
+
+	if err := s.generateHLSVariant(track.ID, track.FilePath, bitRate); err != nil {
+		return "", err
+	}
+
+	return s.readObject(playlistPath)
+}
+
+func (s *trackService) GetHLSSegment(id uint, bitRate int, segment string) (*TrackStream, error) {
+	if !hlsSegmentName.MatchString(segment) {
+		return nil, ErrHLSSegmentNotFound
+	}
+
+	track, err := s.findTrack(id)
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := s.openStoredStream(hlsObjectName(track.ID, bitRate, segment), hlsSegmentMIME)
+	if err != nil {
+		return nil, ErrHLSSegmentNotFound
+	}
+	return stream, nil
+}
+
+// generateHLSVariant нарезает трек во временный каталог и сохраняет результат в MinIO.
+// Плейлист загружается последним, поэтому его наличие означает, что все сегменты уже доступны.
+func (s *trackService) generateHLSVariant(trackID uint, sourcePath string, bitRate int) error {
+	source, err := s.minioClient.GetObject(s.bucketName, sourcePath)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	dir, err := os.MkdirTemp("", "hls-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	profile := TranscodeProfile{Format: "aac", BitRate: bitRate}
+	if err := s.transcoder.SegmentHLS(source, profile, hlsSegmentDuration, dir); err != nil {
+		return err
+	}
+
+	segments, err := filepath.Glob(filepath.Join(dir, "segment_*.ts"))
+	if err != nil {
+		return err
+	}
+
+	for _, segment := range segments {
+		if err := s.putFile(segment, hlsObjectName(trackID, bitRate, filepath.Base(segment)), hlsSegmentMIME); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("Generated HLS variant %d kbps for track %d (%d segments)", bitRate, trackID, len(segments))
+	return s.putFile(filepath.Join(dir, hlsPlaylistName), hlsObjectName(trackID, bitRate, hlsPlaylistName), hlsPlaylistMIME)
+}
+
+func (s *trackService) findTrack(id uint) (*model.Track, error) {
+	track, err := s.trackRepo.GetByID(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrTrackNotFound
+	}
+	return track, err
+}
+
+func (s *trackService) putFile(path, objectName, contentType string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	_, err = s.minioClient.PutObject(s.bucketName, objectName, file, info.Size(), contentType)
+	return err
+}
+
+func (s *trackService) readObject(objectName string) (string, error) {
+	if _, err := s.minioClient.StatObject(s.bucketName, objectName); err != nil {
+		return "", err
+	}
+
+	object, err := s.minioClient.GetObject(s.bucketName, objectName)
+	if err != nil {
+		return "", err
+	}
+	defer object.Close()
+
+	data, err := io.ReadAll(object)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,35 @@
+package service
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestKeyedMutexReleasesEntries(t *testing.T) {
+	var locks keyedMutex
+	var wg sync.WaitGroup
+	keys := []string{"a/index.m3u8", "b/index.m3u8"}
+	counters := map[string]*int{keys[0]: new(int), keys[1]: new(int)}
+
+	for i := 0; i < 50; i++ {
+		for _, key := range keys {
+			wg.Add(1)
+			go func(key string) {
+				defer wg.Done()
+				unlock := locks.lock(key)
+				defer unlock()
+				*counters[key]++
+			}(key)
+		}
+	}
+	wg.Wait()
+
+	for _, key := range keys {
+		if *counters[key] != 50 {
+			t.Fatalf("counter %s = %d, want 50", key, *counters[key])
+		}
+	}
+	if len(locks.locks) != 0 {
+		t.Fatalf("locks left after release: %d", len(locks.locks))
+	}
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"MusicService/internal/model"
+	"MusicService/internal/repository"
+	"log"
+	"strings"
+)
+
+// normalizeName приводит имя исполнителя или название альбома к виду, по которому они сравниваются:
+// нижний регистр, без пробелов по краям, внутренние пробелы схлопнуты
+func normalizeName(name string) string {
+	return strings.ToLower(cleanName(name))
+}
+
+func cleanName(name string) string {
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// trackAlbumArtist возвращает исполнителя альбома трека: AlbumArtist, либо Artist
+func trackAlbumArtist(track *model.Track) string {
+	return firstNonEmpty(track.AlbumArtist, track.Artist)
+}
+
+// libraryLinker привязывает треки к исполнителям и альбомам, создавая их при необходимости
+type libraryLinker struct {
+	artistRepo repository.ArtistRepository
+	albumRepo  repository.AlbumRepository
+}
+
+func (l *libraryLinker) link(track *model.Track) error {
+	artist, err := l.artistRepo.GetOrCreate(cleanName(track.Artist), normalizeName(track.Artist))
+	if err != nil {
+		return err
+	}
+	track.ArtistID = &artist.ID
+
+	track.AlbumID = nil
+	if normalizeName(track.Album) == "" {
+		return nil
+	}
+
+	albumArtist := artist
+	if name := trackAlbumArtist(track); normalizeName(name) != artist.NormalizedName {
+		if albumArtist, err = l.artistRepo.GetOrCreate(cleanName(name), normalizeName(name)); err != nil {
+			return err
+		}
+	}
+
+	album, err := l.albumRepo.GetOrCreate(albumArtist.ID, cleanName(track.Album), normalizeName(track.Album))
+	if err != nil {
+		return err
+	}
+	track.AlbumID = &album.ID
+
+	// Год и жанр альбома берутся из первого трека, где они указаны
+	if (album.Year == 0 && track.Year != 0) || (album.Genre == "" && track.Genre != "") {
+		album.Year = firstNonZero(album.Year, track.Year)
+		album.Genre = firstNonEmpty(album.Genre, track.Genre)
+		if err := l.albumRepo.Update(album); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// BackfillLibrary привязывает к исполнителям и альбомам треки, загруженные до их появления.
+// Вызывается при запуске после миграции схемы; уже привязанные треки не затрагиваются
+func BackfillLibrary(trackRepo repository.TrackRepository, artistRepo repository.ArtistRepository, albumRepo repository.AlbumRepository) error {
+	tracks, err := trackRepo.GetUnlinked()
+	if err != nil {
+		return err
+	}
+	if len(tracks) == 0 {
+		return nil
+	}
+
+	linker := &libraryLinker{artistRepo: artistRepo, albumRepo: albumRepo}
+	for i := range tracks {
+		if err := linker.link(&tracks[i]); err != nil {
+			return err
+		}
+		if err := trackRepo.UpdateLinks(&tracks[i]); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("Linked %d tracks to artists and albums", len(tracks))
+	return nil
+}
@@ -0,0 +1,264 @@
+package service
+
+import (
+	"MusicService/internal/model"
+	"MusicService/internal/repository"
+	"MusicService/pkg/oidc"
+	"MusicService/pkg/secretbox"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// OIDCFlowTTL - сколько времени дается на вход на странице провайдера
+const OIDCFlowTTL = 10 * time.Minute
+
+var (
+	ErrInvalidOIDCState     = errors.New("invalid or expired sign-in state")
+	ErrOIDCLoginFailed      = errors.New("single sign-on failed")
+	ErrOIDCEmailRequired    = errors.New("identity provider did not return an email address")
+	ErrOIDCEmailNotVerified = errors.New("email address is not verified by the identity provider")
+	ErrOIDCAccountConflict  = errors.New("an account with this email exists but its email is not verified")
+)
+
+// OIDCService выполняет вход через внешнего провайдера OpenID Connect
+type OIDCService interface {
+	// Begin возвращает адрес страницы входа провайдера и зашифрованное состояние входа,
+	// которое клиент должен вернуть в Complete (обычно через cookie)
+	Begin(ctx context.Context) (authURL, flow string, err error)
+	Complete(ctx context.Context, flow, state, code string, client model.ClientInfo) (*model.TokenResponse, error)
+}
+
+// oidcFlow - данные одного входа: state защищает от CSRF, nonce - от подмены ID токена,
+// verifier - секрет PKCE
+type oidcFlow struct {
+	State     string `json:"state"`
+	Nonce     string `json:"nonce"`
+	Verifier  string `json:"verifier"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+type oidcService struct {
+	config       oidc.Config
+	providerName string
+	userRepo     repository.UserRepository
+	identityRepo repository.ExternalIdentityRepository
+	authService  AuthService
+	secretBox    secretbox.Box
+
+	mu       sync.Mutex
+	provider *oidc.Provider
+}
+
+// NewOIDCService создает сервис входа через провайдера providerName. Метаданные провайдера
+// загружаются при первом входе, поэтому сервис запускается, даже если провайдер еще недоступен
+func NewOIDCService(config oidc.Config, providerName string, userRepo repository.UserRepository,
+	identityRepo repository.ExternalIdentityRepository, authService AuthService, secretBox secretbox.Box) OIDCService {
+	return &oidcService{
+		config:       config,
+		providerName: providerName,
+		userRepo:     userRepo,
+		identityRepo: identityRepo,
+		authService:  authService,
+		secretBox:    secretBox,
+	}
+}
+
+func (s *oidcService) Begin(ctx context.Context) (string, string, error) {
+	provider, err := s.discover(ctx)
+	if err != nil {
+		return "", "", err
+	}
+
+	flow := oidcFlow{ExpiresAt: time.Now().Add(OIDCFlowTTL).Unix()}
+	if flow.State, err = oidc.NewState(); err != nil {
+		return "", "", err
+	}
+	if flow.Nonce, err = oidc.NewState(); err != nil {
+		return "", "", err
+	}
+	if flow.Verifier, err = oidc.NewVerifier(); err != nil {
+		return "", "", err
+	}
+
+	data, err := json.Marshal(flow)
+	if err != nil {
+		return "", "", err
+	}
+	sealed, err := s.secretBox.Seal(string(data))
+	if err != nil {
+		return "", "", err
+	}
+
+	return provider.AuthCodeURL(flow.State, flow.Nonce, flow.Verifier), sealed, nil
+}
+
+func (s *oidcService) Complete(ctx context.Context, sealed, state, code string, client model.ClientInfo) (*model.TokenResponse, error) {
+	flow, err := s.openFlow(sealed)
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(flow.State), []byte(state)) != 1 {
+		return nil, ErrInvalidOIDCState
+	}
+
+	provider, err := s.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := provider.Exchange(ctx, code, flow.Verifier, flow.Nonce)
+	if err != nil {
+		log.Printf("OIDC login via %s failed: %v", s.providerName, err)
+		return nil, ErrOIDCLoginFailed
+	}
+
+	user, err := s.resolveUser(claims)
+	if err != nil {
+		return nil, err
+	}
+	return s.authService.LoginExternal(user, client)
+}
+
+func (s *oidcService) discover(ctx context.Context) (*oidc.Provider, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.provider == nil {
+		provider, err := oidc.Discover(ctx, s.config, nil)
+		if err != nil {
+			return nil, err
+		}
+		s.provider = provider
+	}
+	return s.provider, nil
+}
+
+func (s *oidcService) openFlow(sealed string) (*oidcFlow, error) {
+	data, err := s.secretBox.Open(sealed)
+	if err != nil {
+		return nil, ErrInvalidOIDCState
+	}
+
+	var flow oidcFlow
+	if err := json.Unmarshal([]byte(data), &flow); err != nil || flow.State == "" {
+		return nil, ErrInvalidOIDCState
+	}
+	if time.Now().Unix() > flow.ExpiresAt {
+		return nil, ErrInvalidOIDCState
+	}
+	return &flow, nil
+}
+
+// resolveUser находит пользователя по привязанной внешней учетной записи. При первом входе
+// учетная запись привязывается к пользователю с тем же email или для нее создается новый пользователь
+func (s *oidcService) resolveUser(claims *oidc.Claims) (*model.User, error) {
+	identity, err := s.identityRepo.FindBySubject(s.providerName, claims.Subject)
+	if err == nil {
+		return s.userRepo.FindByID(identity.UserID)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if claims.Email == "" {
+		return nil, ErrOIDCEmailRequired
+	}
+
+	user, err := s.userRepo.FindByEmail(claims.Email)
+	switch {
+	case err == nil:
+		// Привязка по email допустима, только если адрес подтвержден с обеих сторон: иначе
+		// можно было бы захватить чужую учетную запись, указав ее адрес у провайдера или при регистрации
+		if !claims.EmailVerified {
+			return nil, ErrOIDCEmailNotVerified
+		}
+		if !user.EmailVerified {
+			return nil, ErrOIDCAccountConflict
+		}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		if user, err = s.createUser(claims); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+
+	err = s.identityRepo.Create(&model.ExternalIdentity{
+		UserID:   user.ID,
+		Provider: s.providerName,
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Linked %s identity %s to user %d", s.providerName, claims.Subject, user.ID)
+	return user, nil
+}
+
+// createUser создает пользователя для нового входа. Пароль случайный: войти по паролю
+// можно будет после его сброса по email
+func (s *oidcService) createUser(claims *oidc.Claims) (*model.User, error) {
+	username, err := s.availableUsername(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	password, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &model.User{
+		Username:      username,
+		Email:         claims.Email,
+		Password:      string(hashedPassword),
+		Role:          model.RoleUser,
+		EmailVerified: claims.EmailVerified,
+	}
+	if err := s.userRepo.Create(user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// availableUsername берет имя пользователя у провайдера, а если оно занято - добавляет случайный суффикс
+func (s *oidcService) availableUsername(claims *oidc.Claims) (string, error) {
+	base := claims.PreferredUsername
+	if base == "" {
+		base, _, _ = strings.Cut(claims.Email, "@")
+	}
+
+	candidate := base
+	for range 5 {
+		_, err := s.userRepo.FindByUsername(candidate)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return candidate, nil
+		}
+		if err != nil {
+			return "", err
+		}
+
+		suffix, err := randomToken()
+		if err != nil {
+			return "", err
+		}
+		candidate = fmt.Sprintf("%s-%s", base, suffix[:6])
+	}
+	return "", fmt.Errorf("no free username for %q", base)
+}
@@ -0,0 +1,227 @@
+package service
+
+import (
+	"MusicService/internal/model"
+	"MusicService/internal/repository"
+	"MusicService/pkg/oidc/oidctest"
+	"MusicService/pkg/secretbox"
+	"context"
+	"errors"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+const testOIDCProvider = "test"
+
+type fakeExternalIdentityRepository struct {
+	repository.ExternalIdentityRepository
+	identities map[string]*model.ExternalIdentity
+}
+
+func (r *fakeExternalIdentityRepository) Create(identity *model.ExternalIdentity) error {
+	r.identities[identity.Provider+"/"+identity.Subject] = identity
+	return nil
+}
+
+func (r *fakeExternalIdentityRepository) FindBySubject(provider, subject string) (*model.ExternalIdentity, error) {
+	if identity, ok := r.identities[provider+"/"+subject]; ok {
+		return identity, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// fakeAuthService выдает в качестве токена ID пользователя, вошедшего через провайдера
+type fakeAuthService struct {
+	AuthService
+}
+
+func (fakeAuthService) LoginExternal(user *model.User, client model.ClientInfo) (*model.TokenResponse, error) {
+	return &model.TokenResponse{Token: strconv.FormatUint(uint64(user.ID), 10)}, nil
+}
+
+type oidcFixture struct {
+	service    OIDCService
+	provider   *oidctest.Provider
+	users      *fakeUserRepository
+	identities *fakeExternalIdentityRepository
+}
+
+func newOIDCFixture(t *testing.T, users ...*model.User) *oidcFixture {
+	t.Helper()
+
+	box, err := secretbox.New("test-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := &oidcFixture{
+		provider:   oidctest.NewProvider(t),
+		users:      &fakeUserRepository{users: make(map[string]*model.User)},
+		identities: &fakeExternalIdentityRepository{identities: make(map[string]*model.ExternalIdentity)},
+	}
+	for _, user := range users {
+		f.users.users[user.Username] = user
+	}
+	f.service = NewOIDCService(f.provider.Config(), testOIDCProvider, f.users, f.identities, fakeAuthService{}, box)
+	return f
+}
+
+// login проходит вход identity целиком; tamper позволяет подменить параметры запроса авторизации
+func (f *oidcFixture) login(t *testing.T, identity oidctest.Identity, tamper func(url.Values)) (*model.TokenResponse, error) {
+	t.Helper()
+
+	authURL, flow, err := f.service.Begin(context.Background())
+	if err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	if tamper != nil {
+		u, err := url.Parse(authURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		query := u.Query()
+		tamper(query)
+		u.RawQuery = query.Encode()
+		authURL = u.String()
+	}
+
+	code, state := f.provider.Authorize(t, authURL, identity)
+	return f.service.Complete(context.Background(), flow, state, code, model.ClientInfo{})
+}
+
+func TestOIDCCompleteCreatesAndLinksUser(t *testing.T) {
+	f := newOIDCFixture(t)
+	identity := oidctest.Identity{Subject: "sub-1", Email: "alice@example.com", EmailVerified: true, PreferredUsername: "alice"}
+
+	tokens, err := f.login(t, identity, nil)
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	user, err := f.users.FindByUsername("alice")
+	if err != nil {
+		t.Fatalf("user was not created: %v", err)
+	}
+	if tokens.Token != strconv.FormatUint(uint64(user.ID), 10) || !user.EmailVerified {
+		t.Errorf("signed in as %s, created user %+v", tokens.Token, user)
+	}
+	// Первый пользователь не становится администратором сам по себе
+	if user.Role != model.RoleUser {
+		t.Errorf("created user role = %q, want %q", user.Role, model.RoleUser)
+	}
+
+	// Повторный вход находит пользователя по привязке, даже если email у провайдера сменился
+	identity.Email = "alice@example.org"
+	tokens, err = f.login(t, identity, nil)
+	if err != nil {
+		t.Fatalf("second Complete: %v", err)
+	}
+	if tokens.Token != strconv.FormatUint(uint64(user.ID), 10) || len(f.users.users) != 1 {
+		t.Errorf("second login signed in as %s with %d users", tokens.Token, len(f.users.users))
+	}
+}
+
+func TestOIDCCompleteStateMismatch(t *testing.T) {
+	f := newOIDCFixture(t)
+	identity := oidctest.Identity{Subject: "sub-1", Email: "alice@example.com", EmailVerified: true}
+
+	authURL, flow, err := f.service.Begin(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, state := f.provider.Authorize(t, authURL, identity)
+	_, otherFlow, err := f.service.Begin(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		flow  string
+		state string
+	}{
+		{"foreign state", flow, state + "x"},
+		{"flow of another login", otherFlow, state},
+		{"tampered flow", flow[:len(flow)-2] + "AA", state},
+		{"empty flow", "", state},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := f.service.Complete(context.Background(), tt.flow, tt.state, code, model.ClientInfo{})
+			if !errors.Is(err, ErrInvalidOIDCState) {
+				t.Fatalf("Complete error = %v, want %v", err, ErrInvalidOIDCState)
+			}
+		})
+	}
+
+	if len(f.identities.identities) != 0 {
+		t.Errorf("identities linked after state mismatch: %v", f.identities.identities)
+	}
+}
+
+func TestOIDCCompleteRejectsForeignToken(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(url.Values)
+	}{
+		{"nonce mismatch", func(q url.Values) { q.Set("nonce", "other-nonce") }},
+		{"PKCE mismatch", func(q url.Values) { q.Set("code_challenge", "other-challenge") }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newOIDCFixture(t)
+			identity := oidctest.Identity{Subject: "sub-1", Email: "alice@example.com", EmailVerified: true}
+
+			_, err := f.login(t, identity, tt.tamper)
+			if !errors.Is(err, ErrOIDCLoginFailed) {
+				t.Fatalf("Complete error = %v, want %v", err, ErrOIDCLoginFailed)
+			}
+			if len(f.users.users) != 0 || len(f.identities.identities) != 0 {
+				t.Errorf("login failed but left users %v and identities %v", f.users.users, f.identities.identities)
+			}
+		})
+	}
+}
+
+func TestOIDCLinkByEmail(t *testing.T) {
+	tests := []struct {
+		name             string
+		providerVerified bool
+		accountVerified  bool
+		wantErr          error
+	}{
+		{"verified on both sides", true, true, nil},
+		{"unverified at provider", false, true, ErrOIDCEmailNotVerified},
+		{"unverified account", true, false, ErrOIDCAccountConflict},
+		{"unverified on both sides", false, false, ErrOIDCEmailNotVerified},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			existing := &model.User{Username: "alice", Email: "alice@example.com", EmailVerified: tt.accountVerified}
+			existing.ID = 7
+			f := newOIDCFixture(t, existing)
+			identity := oidctest.Identity{Subject: "sub-1", Email: existing.Email, EmailVerified: tt.providerVerified, PreferredUsername: "mallory"}
+
+			tokens, err := f.login(t, identity, nil)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Complete error = %v, want %v", err, tt.wantErr)
+			}
+
+			linked, linkErr := f.identities.FindBySubject(testOIDCProvider, identity.Subject)
+			if tt.wantErr != nil {
+				if linkErr == nil || len(f.users.users) != 1 {
+					t.Errorf("rejected login linked %+v with %d users", linked, len(f.users.users))
+				}
+				return
+			}
+			if linkErr != nil || linked.UserID != existing.ID || tokens.Token != "7" {
+				t.Errorf("identity %+v, tokens %+v: want link to user %d", linked, tokens, existing.ID)
+			}
+		})
+	}
+}
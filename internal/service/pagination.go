@@ -0,0 +1,26 @@
+package service
+
+import (
+	"MusicService/internal/model"
+	"MusicService/internal/repository"
+)
+
+var (
+	ErrInvalidSort   = repository.ErrInvalidSort
+	ErrInvalidCursor = repository.ErrInvalidCursor
+)
+
+// mapPage переводит записи страницы в ответы API, сохраняя счетчики и курсор
+func mapPage[T, R any](page *model.Page[T], convert func(*T) R) *model.Page[R] {
+	items := make([]R, 0, len(page.Items))
+	for i := range page.Items {
+		items = append(items, convert(&page.Items[i]))
+	}
+	return &model.Page[R]{
+		Items:      items,
+		Total:      page.Total,
+		Page:       page.Page,
+		PerPage:    page.PerPage,
+		NextCursor: page.NextCursor,
+	}
+}
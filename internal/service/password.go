@@ -0,0 +1,102 @@
+package service
+
+import (
+	"MusicService/internal/model"
+	"MusicService/internal/repository"
+	"MusicService/pkg/mailer"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const passwordResetTTL = time.Hour
+
+var ErrInvalidResetToken = errors.New("invalid or expired reset token")
+
+// PasswordService восстанавливает доступ к аккаунту по ссылке из письма
+type PasswordService interface {
+	RequestReset(email string) error
+	ResetPassword(req *model.ResetPasswordRequest) error
+}
+
+type passwordService struct {
+	userRepo      repository.UserRepository
+	userTokenRepo repository.UserTokenRepository
+	sessionRepo   repository.SessionRepository
+	mailer        mailer.Mailer
+	publicURL     string
+}
+
+func NewPasswordService(userRepo repository.UserRepository, userTokenRepo repository.UserTokenRepository,
+	sessionRepo repository.SessionRepository, mailer mailer.Mailer, publicURL string) PasswordService {
+	return &passwordService{
+		userRepo:      userRepo,
+		userTokenRepo: userTokenRepo,
+		sessionRepo:   sessionRepo,
+		mailer:        mailer,
+		publicURL:     strings.TrimSuffix(publicURL, "/"),
+	}
+}
+
+// RequestReset отправляет ссылку для сброса пароля. Ответ не зависит от того, существует ли
+// пользователь, а письмо отправляется в фоне, чтобы по времени ответа нельзя было проверить email
+func (s *passwordService) RequestReset(email string) error {
+	user, err := s.userRepo.FindByEmail(email)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if user.Disabled {
+		return nil
+	}
+
+	token, err := issueUserToken(s.userTokenRepo, user.ID, model.TokenPurposePasswordReset, passwordResetTTL)
+	if err != nil {
+		return err
+	}
+
+	msg := mailer.Message{
+		To:      user.Email,
+		Subject: "Password reset",
+		Body: fmt.Sprintf("Hello, %s!\n\nTo set a new password, open the link below within %d minutes:\n%s/reset-password?token=%s\n\n"+
+			"If you did not request a password reset, ignore this email.\n",
+			user.Username, int(passwordResetTTL.Minutes()), s.publicURL, url.QueryEscape(token)),
+	}
+	sendMailAsync(s.mailer, msg)
+	return nil
+}
+
+// ResetPassword задает новый пароль по токену из письма и завершает все сессии пользователя
+func (s *passwordService) ResetPassword(req *model.ResetPasswordRequest) error {
+	token, err := consumeUserToken(s.userTokenRepo, model.TokenPurposePasswordReset, req.Token)
+	if err != nil {
+		return err
+	}
+	if token == nil {
+		return ErrInvalidResetToken
+	}
+
+	user, err := s.userRepo.FindByID(token.UserID)
+	if err != nil {
+		return err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	user.Password = string(hashedPassword)
+	if err := s.userRepo.Update(user); err != nil {
+		return err
+	}
+
+	return s.sessionRepo.RevokeAllForUser(user.ID)
+}
@@ -2,18 +2,33 @@ package service
 
 import (
 	"MusicService/internal/model"
+	"MusicService/internal/policy"
 	"MusicService/internal/repository"
+	"errors"
 	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrPlaylistNotFound      = errors.New("playlist not found")
+	ErrPlaylistEntryNotFound = errors.New("track not found in playlist")
+	ErrInvalidPlaylistOrder  = repository.ErrInvalidPlaylistOrder
+	ErrForbidden             = policy.ErrForbidden
 )
 
 type PlaylistService interface {
 	CreatePlaylist(req *model.PlaylistRequest, userID uint) (*model.PlaylistResponse, error)
 	GetUserPlaylists(userID uint) ([]model.PlaylistResponse, error)
-	GetPlaylistByID(id uint) (*model.PlaylistResponse, error)
-	UpdatePlaylist(id uint, req *model.PlaylistRequest) (*model.PlaylistResponse, error)
-	DeletePlaylist(id uint) error
-	AddTrackToPlaylist(playlistID uint, req *model.AddTrackToPlaylistRequest) error
-	RemoveTrackFromPlaylist(playlistID uint, trackID uint) error
+	ListUserPlaylists(userID uint, page model.PageParams) (*model.Page[model.PlaylistResponse], error)
+	GetPlaylistByID(id uint, actor policy.Actor) (*model.PlaylistResponse, error)
+	UpdatePlaylist(id uint, req *model.PlaylistRequest, actor policy.Actor) (*model.PlaylistResponse, error)
+	DeletePlaylist(id uint, actor policy.Actor) error
+	AddTrackToPlaylist(playlistID uint, req *model.AddTrackToPlaylistRequest, actor policy.Actor) (*model.PlaylistEntryResponse, error)
+	RemoveTrackFromPlaylist(playlistID uint, trackID uint, actor policy.Actor) error
+	RemoveEntry(playlistID, entryID uint, actor policy.Actor) error
+	MoveEntry(playlistID, entryID uint, position int, actor policy.Actor) error
+	ReorderEntries(playlistID uint, entryIDs []uint, actor policy.Actor) error
 }
 
 type playlistService struct {
@@ -39,13 +54,8 @@ func (s *playlistService) CreatePlaylist(req *model.PlaylistRequest, userID uint
 		return nil, err
 	}
 
-	return &model.PlaylistResponse{
-		ID:          playlist.ID,
-		Name:        playlist.Name,
-		Description: playlist.Description,
-		Tracks:      []model.TrackResponse{},
-		CreatedAt:   playlist.CreatedAt.Format(time.RFC3339),
-	}, nil
+	response := newPlaylistResponse(playlist)
+	return &response, nil
 }
 
 func (s *playlistService) GetUserPlaylists(userID uint) ([]model.PlaylistResponse, error) {
@@ -56,71 +66,36 @@ func (s *playlistService) GetUserPlaylists(userID uint) ([]model.PlaylistRespons
 
 	var response []model.PlaylistResponse
 	for _, playlist := range playlists {
-		tracks, err := s.trackRepo.GetByPlaylistID(playlist.ID)
-		if err != nil {
-			return nil, err
-		}
-
-		var trackResponses []model.TrackResponse
-		for _, track := range tracks {
-			trackResponses = append(trackResponses, model.TrackResponse{
-				ID:        track.ID,
-				Title:     track.Title,
-				Artist:    track.Artist,
-				Album:     track.Album,
-				Genre:     track.Genre,
-				Duration:  track.Duration,
-				CreatedAt: track.CreatedAt.Format(time.RFC3339),
-			})
-		}
-
-		response = append(response, model.PlaylistResponse{
-			ID:          playlist.ID,
-			Name:        playlist.Name,
-			Description: playlist.Description,
-			Tracks:      trackResponses,
-			CreatedAt:   playlist.CreatedAt.Format(time.RFC3339),
-		})
+		response = append(response, newPlaylistResponse(&playlist))
 	}
 
 	return response, nil
 }
 
-func (s *playlistService) GetPlaylistByID(id uint) (*model.PlaylistResponse, error) {
-	playlist, err := s.playlistRepo.GetByID(id)
+func (s *playlistService) ListUserPlaylists(userID uint, page model.PageParams) (*model.Page[model.PlaylistResponse], error) {
+	playlists, err := s.playlistRepo.ListByUserID(userID, page)
 	if err != nil {
 		return nil, err
 	}
 
-	tracks, err := s.trackRepo.GetByPlaylistID(playlist.ID)
+	return mapPage(playlists, newPlaylistResponse), nil
+}
+
+func (s *playlistService) GetPlaylistByID(id uint, actor policy.Actor) (*model.PlaylistResponse, error) {
+	playlist, err := s.playlistRepo.GetByID(id)
 	if err != nil {
-		return nil, err
+		return nil, mapPlaylistError(err)
 	}
-
-	var trackResponses []model.TrackResponse
-	for _, track := range tracks {
-		trackResponses = append(trackResponses, model.TrackResponse{
-			ID:        track.ID,
-			Title:     track.Title,
-			Artist:    track.Artist,
-			Album:     track.Album,
-			Genre:     track.Genre,
-			Duration:  track.Duration,
-			CreatedAt: track.CreatedAt.Format(time.RFC3339),
-		})
+	if err := policy.Playlist(actor, policy.ActionView, playlist); err != nil {
+		return nil, err
 	}
 
-	return &model.PlaylistResponse{
-		ID:          playlist.ID,
-		Name:        playlist.Name,
-		Description: playlist.Description,
-		Tracks:      trackResponses,
-		CreatedAt:   playlist.CreatedAt.Format(time.RFC3339),
-	}, nil
+	response := newPlaylistResponse(playlist)
+	return &response, nil
 }
 
-func (s *playlistService) UpdatePlaylist(id uint, req *model.PlaylistRequest) (*model.PlaylistResponse, error) {
-	playlist, err := s.playlistRepo.GetByID(id)
+func (s *playlistService) UpdatePlaylist(id uint, req *model.PlaylistRequest, actor policy.Actor) (*model.PlaylistResponse, error) {
+	playlist, err := s.authorize(id, actor, policy.ActionUpdate)
 	if err != nil {
 		return nil, err
 	}
@@ -132,17 +107,116 @@ func (s *playlistService) UpdatePlaylist(id uint, req *model.PlaylistRequest) (*
 		return nil, err
 	}
 
-	return s.GetPlaylistByID(id)
+	return s.GetPlaylistByID(id, actor)
 }
 
-func (s *playlistService) DeletePlaylist(id uint) error {
+func (s *playlistService) DeletePlaylist(id uint, actor policy.Actor) error {
+	if _, err := s.authorize(id, actor, policy.ActionDelete); err != nil {
+		return err
+	}
 	return s.playlistRepo.Delete(id)
 }
 
-func (s *playlistService) AddTrackToPlaylist(playlistID uint, req *model.AddTrackToPlaylistRequest) error {
-	return s.playlistRepo.AddTrack(playlistID, req.TrackID)
+func (s *playlistService) AddTrackToPlaylist(playlistID uint, req *model.AddTrackToPlaylistRequest, actor policy.Actor) (*model.PlaylistEntryResponse, error) {
+	if _, err := s.authorize(playlistID, actor, policy.ActionUpdate); err != nil {
+		return nil, err
+	}
+
+	track, err := s.trackRepo.GetByID(req.TrackID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTrackNotFound
+		}
+		return nil, err
+	}
+
+	entry, err := s.playlistRepo.AddTrack(playlistID, track.ID, actor.UserID, req.Position)
+	if err != nil {
+		return nil, mapPlaylistError(err)
+	}
+
+	entry.Track = *track
+	response := newPlaylistEntryResponse(entry)
+	return &response, nil
+}
+
+// RemoveTrackFromPlaylist удаляет все вхождения трека в плейлист
+func (s *playlistService) RemoveTrackFromPlaylist(playlistID uint, trackID uint, actor policy.Actor) error {
+	if _, err := s.authorize(playlistID, actor, policy.ActionUpdate); err != nil {
+		return err
+	}
+	return mapPlaylistError(s.playlistRepo.RemoveTrack(playlistID, trackID))
+}
+
+func (s *playlistService) RemoveEntry(playlistID, entryID uint, actor policy.Actor) error {
+	if _, err := s.authorize(playlistID, actor, policy.ActionUpdate); err != nil {
+		return err
+	}
+	return mapPlaylistError(s.playlistRepo.RemoveEntry(playlistID, entryID))
+}
+
+func (s *playlistService) MoveEntry(playlistID, entryID uint, position int, actor policy.Actor) error {
+	if _, err := s.authorize(playlistID, actor, policy.ActionUpdate); err != nil {
+		return err
+	}
+	return mapPlaylistError(s.playlistRepo.MoveEntry(playlistID, entryID, position))
+}
+
+func (s *playlistService) ReorderEntries(playlistID uint, entryIDs []uint, actor policy.Actor) error {
+	if _, err := s.authorize(playlistID, actor, policy.ActionUpdate); err != nil {
+		return err
+	}
+	return mapPlaylistError(s.playlistRepo.Reorder(playlistID, entryIDs))
+}
+
+// authorize загружает плейлист без записей и проверяет право actor на действие
+func (s *playlistService) authorize(id uint, actor policy.Actor, action policy.Action) (*model.Playlist, error) {
+	playlist, err := s.playlistRepo.GetInfo(id)
+	if err != nil {
+		return nil, mapPlaylistError(err)
+	}
+	if err := policy.Playlist(actor, action, playlist); err != nil {
+		return nil, err
+	}
+	return playlist, nil
+}
+
+func mapPlaylistError(err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ErrPlaylistNotFound
+	case errors.Is(err, repository.ErrPlaylistEntryNotFound):
+		return ErrPlaylistEntryNotFound
+	}
+	return err
 }
 
-func (s *playlistService) RemoveTrackFromPlaylist(playlistID uint, trackID uint) error {
-	return s.playlistRepo.RemoveTrack(playlistID, trackID)
+func newPlaylistResponse(playlist *model.Playlist) model.PlaylistResponse {
+	response := model.PlaylistResponse{
+		ID:          playlist.ID,
+		Name:        playlist.Name,
+		Description: playlist.Description,
+		UserID:      playlist.UserID,
+		Tracks:      []model.TrackResponse{},
+		Entries:     []model.PlaylistEntryResponse{},
+		CreatedAt:   playlist.CreatedAt.Format(time.RFC3339),
+	}
+
+	for i := range playlist.Entries {
+		entry := newPlaylistEntryResponse(&playlist.Entries[i])
+		response.Tracks = append(response.Tracks, entry.Track)
+		response.Entries = append(response.Entries, entry)
+	}
+
+	return response
+}
+
+func newPlaylistEntryResponse(entry *model.PlaylistEntry) model.PlaylistEntryResponse {
+	return model.PlaylistEntryResponse{
+		ID:       entry.ID,
+		Position: entry.Position,
+		AddedAt:  entry.AddedAt.Format(time.RFC3339),
+		AddedBy:  entry.AddedBy,
+		Track:    newTrackResponse(&entry.Track),
+	}
 }
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"MusicService/internal/model"
+	"MusicService/internal/repository"
+	"MusicService/pkg/lru"
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+)
+
+const defaultSuggestLimit = 5
+
+// suggestionOrder - порядок типов подсказок в ответе
+var suggestionOrder = []string{
+	model.SuggestionArtist,
+	model.SuggestionTrack,
+	model.SuggestionAlbum,
+	model.SuggestionPlaylist,
+	model.SuggestionGenre,
+}
+
+type SearchService interface {
+	// Suggest возвращает подсказки для поисковой строки. С userID = 0 плейлисты не предлагаются
+	Suggest(ctx context.Context, userID uint, params model.SuggestParams) (*model.SuggestResponse, error)
+}
+
+type searchService struct {
+	searchRepo repository.SearchRepository
+	cache      *lru.Cache[string, []model.Suggestion]
+	timeout    time.Duration
+}
+
+// NewSearchService создает сервис подсказок. Подсказки каталога кэшируются в памяти на cacheTTL,
+// общий для всех пользователей; на запросы к базе отводится не больше timeout
+func NewSearchService(searchRepo repository.SearchRepository, cacheSize int, cacheTTL, timeout time.Duration) SearchService {
+	return &searchService{
+		searchRepo: searchRepo,
+		cache:      lru.New[string, []model.Suggestion](cacheSize, cacheTTL),
+		timeout:    timeout,
+	}
+}
+
+// Suggest ищет каталог и плейлисты пользователя параллельно. Если база не уложилась в отведенное время,
+// возвращается то, что успело найтись, и такой ответ не кэшируется
+func (s *searchService) Suggest(ctx context.Context, userID uint, params model.SuggestParams) (*model.SuggestResponse, error) {
+	prefix := normalizeName(params.Query)
+	limit := params.Limit
+	if limit <= 0 {
+		limit = defaultSuggestLimit
+	}
+	result := &model.SuggestResponse{Query: prefix, Suggestions: []model.Suggestion{}}
+	if prefix == "" {
+		return result, nil
+	}
+
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+
+	var (
+		wg                  sync.WaitGroup
+		catalog, playlists  []model.Suggestion
+		catalogErr, listErr error
+		cacheKey            = fmt.Sprintf("%d:%s", limit, prefix)
+		catalogCached       bool
+	)
+	if catalog, catalogCached = s.cache.Get(cacheKey); !catalogCached {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			catalog, catalogErr = s.searchRepo.SuggestCatalog(ctx, prefix, limit)
+		}()
+	}
+	if userID != 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			playlists, listErr = s.searchRepo.SuggestPlaylists(ctx, userID, prefix, limit)
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range []error{catalogErr, listErr} {
+		if err == nil {
+			continue
+		}
+		if ctx.Err() == nil {
+			return nil, err
+		}
+		log.Printf("Search suggestions for %q exceeded the time budget: %v", prefix, err)
+	}
+	if !catalogCached && catalogErr == nil {
+		s.cache.Add(cacheKey, catalog)
+	}
+
+	result.Suggestions = append(append(result.Suggestions, catalog...), playlists...)
+	slices.SortStableFunc(result.Suggestions, func(a, b model.Suggestion) int {
+		return slices.Index(suggestionOrder, a.Type) - slices.Index(suggestionOrder, b.Type)
+	})
+	return result, nil
+}
@@ -6,8 +6,8 @@ import (
 )
 
 type StatsService interface {
-	GetTrackPlaysStats(userID uint) ([]model.TrackPlayStats, error)
-	GetArtistPlaysStats(userID uint) ([]model.ArtistPlayStats, error)
+	GetTrackPlaysStats(userID uint, page model.PageParams) (*model.Page[model.TrackPlayStats], error)
+	GetArtistPlaysStats(userID uint, page model.PageParams) (*model.Page[model.ArtistPlayStats], error)
 	GetRecentTracks(id uint, limit int) ([]model.Track, error)
 	GetRecentArtists(id uint, limit int) ([]string, error)
 	RecordTrackPlay(userID, trackID uint) error
@@ -23,12 +23,12 @@ func NewStatsService(statsRepo repository.StatsRepository) StatsService {
 	}
 }
 
-func (s *statsService) GetTrackPlaysStats(userID uint) ([]model.TrackPlayStats, error) {
-	return s.statsRepo.GetTrackPlaysStats(userID)
+func (s *statsService) GetTrackPlaysStats(userID uint, page model.PageParams) (*model.Page[model.TrackPlayStats], error) {
+	return s.statsRepo.GetTrackPlaysStats(userID, page)
 }
 
-func (s *statsService) GetArtistPlaysStats(userID uint) ([]model.ArtistPlayStats, error) {
-	return s.statsRepo.GetArtistPlaysStats(userID)
+func (s *statsService) GetArtistPlaysStats(userID uint, page model.PageParams) (*model.Page[model.ArtistPlayStats], error) {
+	return s.statsRepo.GetArtistPlaysStats(userID, page)
 }
 
 func (s *statsService) GetRecentTracks(userID uint, limit int) ([]model.Track, error) {
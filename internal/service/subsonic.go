@@ -0,0 +1,580 @@
+package service
+
+import (
+	"MusicService/internal/model"
+	"MusicService/internal/repository"
+	"MusicService/pkg/ratelimit"
+	"MusicService/pkg/secretbox"
+	"MusicService/pkg/sniff"
+	"crypto/md5"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrSubsonicWrongCredentials = errors.New("wrong username or password")
+	ErrSubsonicNotFound         = errors.New("the requested data was not found")
+)
+
+// subsonicIgnoredArticles - артикли, которые не учитываются при построении индекса исполнителей
+const subsonicIgnoredArticles = "The El La Los Las Le Les"
+
+// SubsonicService строит представление библиотеки для Subsonic API. Исполнители и альбомы - сущности
+// библиотеки с идентификаторами "ar-<id>" и "al-<id>", треки идентифицируются числовым id
+type SubsonicService interface {
+	Authenticate(username, password, token, salt string) (*model.User, error)
+	GetArtists(userID uint) (*model.SubsonicArtists, error)
+	GetArtist(userID uint, id string) (*model.SubsonicArtistWithAlbums, error)
+	GetAlbum(userID uint, id string) (*model.SubsonicAlbumWithSongs, error)
+	GetSongs(userID uint, ids []uint) ([]model.SubsonicSong, error)
+	Search(userID uint, params model.SubsonicSearchParams) (*model.SubsonicSearchResult3, error)
+	Star(userID uint, params model.SubsonicStarParams) error
+	Unstar(userID uint, params model.SubsonicStarParams) error
+	GetStarred(userID uint) (*model.SubsonicStarred2, error)
+}
+
+type subsonicService struct {
+	userRepo   repository.UserRepository
+	trackRepo  repository.TrackRepository
+	artistRepo repository.ArtistRepository
+	albumRepo  repository.AlbumRepository
+	starRepo   repository.StarRepository
+	secretBox  secretbox.Box
+	lockout    *ratelimit.Lockout
+}
+
+func NewSubsonicService(userRepo repository.UserRepository, trackRepo repository.TrackRepository, artistRepo repository.ArtistRepository,
+	albumRepo repository.AlbumRepository, starRepo repository.StarRepository, secretBox secretbox.Box,
+	lockout *ratelimit.Lockout) SubsonicService {
+	return &subsonicService{
+		userRepo:   userRepo,
+		trackRepo:  trackRepo,
+		artistRepo: artistRepo,
+		albumRepo:  albumRepo,
+		starRepo:   starRepo,
+		secretBox:  secretBox,
+		lockout:    lockout,
+	}
+}
+
+// Authenticate проверяет учетные данные Subsonic: token = md5(password + salt) либо пароль
+// в открытом виде или в формате "enc:<hex>". Токен сверяется только с паролем Subsonic,
+// открытый пароль - также с паролем аккаунта, если у пользователя не включена двухфакторная
+// аутентификация: иначе пароль аккаунта позволял бы обойти второй фактор.
+// Неудачные попытки учитываются в той же блокировке входа, что и вход по паролю в AuthService.
+// Успешный запрос блокировку не сбрасывает: клиенты передают пароль в каждом запросе
+func (s *subsonicService) Authenticate(username, password, token, salt string) (*model.User, error) {
+	user, err := s.userRepo.FindByUsername(username)
+	if err != nil || user.Disabled {
+		return nil, ErrSubsonicWrongCredentials
+	}
+	if err := checkLoginLockout(s.lockout, user.Email); err != nil {
+		return nil, err
+	}
+
+	ok, err := s.checkPassword(user, password, token, salt)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		recordLoginFailure(s.lockout, user.Email)
+		return nil, ErrSubsonicWrongCredentials
+	}
+	return user, nil
+}
+
+// checkPassword сверяет учетные данные запроса; ошибка означает сбой, а не неверный пароль
+func (s *subsonicService) checkPassword(user *model.User, password, token, salt string) (bool, error) {
+	var secret string
+	if user.SubsonicPassword != "" {
+		var err error
+		if secret, err = s.secretBox.Open(user.SubsonicPassword); err != nil {
+			return false, err
+		}
+	}
+
+	if token != "" {
+		if secret == "" {
+			return false, nil
+		}
+		sum := md5.Sum([]byte(secret + salt))
+		return subtle.ConstantTimeCompare([]byte(hex.EncodeToString(sum[:])), []byte(strings.ToLower(token))) == 1, nil
+	}
+
+	if encoded, ok := strings.CutPrefix(password, "enc:"); ok {
+		decoded, err := hex.DecodeString(encoded)
+		if err != nil {
+			return false, nil
+		}
+		password = string(decoded)
+	}
+	if password == "" {
+		return false, nil
+	}
+
+	if secret != "" && subtle.ConstantTimeCompare([]byte(secret), []byte(password)) == 1 {
+		return true, nil
+	}
+	return !user.TOTPEnabled && bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) == nil, nil
+}
+
+// GetArtists возвращает исполнителей альбомов; исполнители, у которых есть только треки
+// в чужих альбомах, в индекс не попадают
+func (s *subsonicService) GetArtists(userID uint) (*model.SubsonicArtists, error) {
+	artists, err := s.artistRepo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	stars, err := s.loadStars(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortStableFunc(artists, func(a, b model.Artist) int {
+		return strings.Compare(subsonicSortName(a.Name), subsonicSortName(b.Name))
+	})
+
+	result := &model.SubsonicArtists{IgnoredArticles: subsonicIgnoredArticles}
+	for i := range artists {
+		if artists[i].AlbumCount == 0 {
+			continue
+		}
+		name := subsonicIndexName(artists[i].Name)
+		if n := len(result.Index); n == 0 || result.Index[n-1].Name != name {
+			result.Index = append(result.Index, model.SubsonicIndex{Name: name})
+		}
+		index := &result.Index[len(result.Index)-1]
+		index.Artists = append(index.Artists, newSubsonicArtist(&artists[i], stars))
+	}
+
+	return result, nil
+}
+
+func (s *subsonicService) GetArtist(userID uint, id string) (*model.SubsonicArtistWithAlbums, error) {
+	artistID, ok := parseSubsonicID(id, "ar-")
+	if !ok {
+		return nil, ErrSubsonicNotFound
+	}
+
+	artist, err := s.artistRepo.GetByID(artistID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrSubsonicNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	stars, err := s.loadStars(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &model.SubsonicArtistWithAlbums{SubsonicArtist: newSubsonicArtist(artist, stars)}
+	for i := range artist.Albums {
+		album := &artist.Albums[i]
+		album.Artist = *artist
+		result.Albums = append(result.Albums, newSubsonicAlbum(album, stars))
+	}
+
+	return result, nil
+}
+
+func (s *subsonicService) GetAlbum(userID uint, id string) (*model.SubsonicAlbumWithSongs, error) {
+	albumID, ok := parseSubsonicID(id, "al-")
+	if !ok {
+		return nil, ErrSubsonicNotFound
+	}
+
+	album, err := s.albumRepo.GetByID(albumID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrSubsonicNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	stars, err := s.loadStars(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &model.SubsonicAlbumWithSongs{SubsonicAlbum: newSubsonicAlbum(album, stars)}
+	for i := range album.Tracks {
+		result.Songs = append(result.Songs, newSubsonicSong(&album.Tracks[i], stars))
+	}
+	return result, nil
+}
+
+// GetSongs возвращает треки в порядке ids. Отсутствующие треки пропускаются,
+// если не найден ни один - возвращается ErrSubsonicNotFound
+func (s *subsonicService) GetSongs(userID uint, ids []uint) ([]model.SubsonicSong, error) {
+	stars, err := s.loadStars(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	songs := make([]model.SubsonicSong, 0, len(ids))
+	for _, id := range ids {
+		track, err := s.trackRepo.GetByID(id)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		songs = append(songs, newSubsonicSong(track, stars))
+	}
+
+	if len(songs) == 0 && len(ids) > 0 {
+		return nil, ErrSubsonicNotFound
+	}
+	return songs, nil
+}
+
+// Search ищет подстроку без учета регистра. Пустой запрос возвращает всю библиотеку:
+// так клиенты вроде Symfonium синхронизируют ее постранично
+func (s *subsonicService) Search(userID uint, params model.SubsonicSearchParams) (*model.SubsonicSearchResult3, error) {
+	stars, err := s.loadStars(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	query := strings.Trim(params.Query, `"* `)
+	result := &model.SubsonicSearchResult3{}
+
+	if params.ArtistOffset >= 0 && params.ArtistCount > 0 {
+		artists, err := s.artistRepo.Match(query, params.ArtistOffset, params.ArtistCount)
+		if err != nil {
+			return nil, err
+		}
+		for i := range artists {
+			result.Artists = append(result.Artists, newSubsonicArtist(&artists[i], stars))
+		}
+	}
+
+	if params.AlbumOffset >= 0 && params.AlbumCount > 0 {
+		albums, err := s.albumRepo.Match(query, params.AlbumOffset, params.AlbumCount)
+		if err != nil {
+			return nil, err
+		}
+		for i := range albums {
+			result.Albums = append(result.Albums, newSubsonicAlbum(&albums[i], stars))
+		}
+	}
+
+	if params.SongOffset >= 0 && params.SongCount > 0 {
+		tracks, err := s.trackRepo.Match(query, params.SongOffset, params.SongCount)
+		if err != nil {
+			return nil, err
+		}
+		for i := range tracks {
+			result.Songs = append(result.Songs, newSubsonicSong(&tracks[i], stars))
+		}
+	}
+
+	return result, nil
+}
+
+func (s *subsonicService) Star(userID uint, params model.SubsonicStarParams) error {
+	items, err := s.resolveStarItems(params)
+	if err != nil {
+		return err
+	}
+
+	var stars []model.Star
+	for itemType, ids := range items {
+		for _, id := range ids {
+			stars = append(stars, model.Star{UserID: userID, ItemType: itemType, ItemID: id})
+		}
+	}
+
+	return s.starRepo.Create(stars)
+}
+
+func (s *subsonicService) Unstar(userID uint, params model.SubsonicStarParams) error {
+	items, err := s.resolveStarItems(params)
+	if err != nil {
+		return err
+	}
+
+	for itemType, ids := range items {
+		if err := s.starRepo.Delete(userID, itemType, ids); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *subsonicService) GetStarred(userID uint) (*model.SubsonicStarred2, error) {
+	list, err := s.starRepo.GetByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	stars := make(subsonicStars, len(list))
+	var artistIDs, albumIDs, trackIDs []uint
+	for _, star := range list {
+		stars[star.ItemType+":"+star.ItemID] = star.CreatedAt
+		switch star.ItemType {
+		case model.StarItemArtist:
+			if id, ok := parseSubsonicID(star.ItemID, "ar-"); ok {
+				artistIDs = append(artistIDs, id)
+			}
+		case model.StarItemAlbum:
+			if id, ok := parseSubsonicID(star.ItemID, "al-"); ok {
+				albumIDs = append(albumIDs, id)
+			}
+		case model.StarItemSong:
+			if id, ok := parseSubsonicID(star.ItemID, ""); ok {
+				trackIDs = append(trackIDs, id)
+			}
+		}
+	}
+
+	result := &model.SubsonicStarred2{}
+	if len(artistIDs) > 0 {
+		artists, err := s.artistRepo.GetByIDs(artistIDs)
+		if err != nil {
+			return nil, err
+		}
+		for i := range artists {
+			result.Artists = append(result.Artists, newSubsonicArtist(&artists[i], stars))
+		}
+	}
+	if len(albumIDs) > 0 {
+		albums, err := s.albumRepo.GetByIDs(albumIDs)
+		if err != nil {
+			return nil, err
+		}
+		for i := range albums {
+			result.Albums = append(result.Albums, newSubsonicAlbum(&albums[i], stars))
+		}
+	}
+	if len(trackIDs) > 0 {
+		tracks, err := s.trackRepo.GetByIDs(trackIDs)
+		if err != nil {
+			return nil, err
+		}
+		for i := range tracks {
+			result.Songs = append(result.Songs, newSubsonicSong(&tracks[i], stars))
+		}
+	}
+
+	return result, nil
+}
+
+// resolveStarItems раскладывает идентификаторы по типам. В параметре id тип определяется по префиксу
+func (s *subsonicService) resolveStarItems(params model.SubsonicStarParams) (map[string][]string, error) {
+	items := make(map[string][]string)
+
+	for _, id := range params.IDs {
+		switch {
+		case strings.HasPrefix(id, "al-"):
+			params.AlbumIDs = append(params.AlbumIDs, id)
+		case strings.HasPrefix(id, "ar-"):
+			params.ArtistIDs = append(params.ArtistIDs, id)
+		default:
+			trackID, ok := parseSubsonicID(id, "")
+			if !ok {
+				return nil, ErrSubsonicNotFound
+			}
+			if _, err := s.trackRepo.GetByID(trackID); err != nil {
+				return nil, ErrSubsonicNotFound
+			}
+			items[model.StarItemSong] = append(items[model.StarItemSong], subsonicSongID(trackID))
+		}
+	}
+
+	for _, id := range params.AlbumIDs {
+		albumID, ok := parseSubsonicID(id, "al-")
+		if !ok {
+			return nil, ErrSubsonicNotFound
+		}
+		items[model.StarItemAlbum] = append(items[model.StarItemAlbum], subsonicAlbumID(albumID))
+	}
+	for _, id := range params.ArtistIDs {
+		artistID, ok := parseSubsonicID(id, "ar-")
+		if !ok {
+			return nil, ErrSubsonicNotFound
+		}
+		items[model.StarItemArtist] = append(items[model.StarItemArtist], subsonicArtistID(artistID))
+	}
+
+	return items, nil
+}
+
+func (s *subsonicService) loadStars(userID uint) (subsonicStars, error) {
+	list, err := s.starRepo.GetByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	stars := make(subsonicStars, len(list))
+	for _, star := range list {
+		stars[star.ItemType+":"+star.ItemID] = star.CreatedAt
+	}
+	return stars, nil
+}
+
+// subsonicStars - время добавления в избранное по ключу "<тип>:<id>"
+type subsonicStars map[string]time.Time
+
+func (s subsonicStars) get(itemType, id string) *time.Time {
+	if t, ok := s[itemType+":"+id]; ok {
+		return &t
+	}
+	return nil
+}
+
+// newSubsonicArtist ожидает исполнителя со счетчиками из выборки репозитория
+func newSubsonicArtist(artist *model.Artist, stars subsonicStars) model.SubsonicArtist {
+	id := subsonicArtistID(artist.ID)
+	result := model.SubsonicArtist{
+		ID:         id,
+		Name:       artist.Name,
+		AlbumCount: int(artist.AlbumCount),
+		Starred:    stars.get(model.StarItemArtist, id),
+	}
+	if artist.CoverTrackID != 0 {
+		result.CoverArt = subsonicSongID(artist.CoverTrackID)
+	}
+
+	return result
+}
+
+// newSubsonicAlbum ожидает альбом со счетчиками из выборки репозитория и загруженным исполнителем
+func newSubsonicAlbum(album *model.Album, stars subsonicStars) model.SubsonicAlbum {
+	id := subsonicAlbumID(album.ID)
+	result := model.SubsonicAlbum{
+		ID:        id,
+		Name:      album.Title,
+		Artist:    album.Artist.Name,
+		ArtistID:  subsonicArtistID(album.ArtistID),
+		SongCount: int(album.TrackCount),
+		Duration:  int(album.Duration),
+		Created:   album.CreatedAt,
+		Year:      album.Year,
+		Genre:     album.Genre,
+		Starred:   stars.get(model.StarItemAlbum, id),
+	}
+	if album.CoverTrackID != 0 {
+		result.CoverArt = subsonicSongID(album.CoverTrackID)
+	}
+
+	return result
+}
+
+func newSubsonicSong(track *model.Track, stars subsonicStars) model.SubsonicSong {
+	id := subsonicSongID(track.ID)
+
+	suffix := strings.TrimPrefix(filepath.Ext(track.FilePath), ".")
+	if t, ok := sniff.ByMIME(track.ContentType); ok {
+		suffix = strings.TrimPrefix(t.Extension(), ".")
+	}
+
+	song := model.SubsonicSong{
+		ID:           id,
+		Title:        track.Title,
+		Album:        track.Album,
+		Artist:       track.Artist,
+		Track:        track.TrackNumber,
+		Year:         track.Year,
+		Genre:        track.Genre,
+		ContentType:  track.ContentType,
+		Suffix:       suffix,
+		Duration:     track.Duration,
+		BitRate:      track.BitRate,
+		SamplingRate: track.SampleRate,
+		ChannelCount: track.Channels,
+		Path:         subsonicPath(trackAlbumArtist(track), track, suffix),
+		DiscNumber:   track.DiscNumber,
+		Created:      track.CreatedAt,
+		Type:         "music",
+		MediaType:    "song",
+		Starred:      stars.get(model.StarItemSong, id),
+	}
+	if track.AlbumID != nil {
+		song.AlbumID = subsonicAlbumID(*track.AlbumID)
+		song.Parent = song.AlbumID
+	}
+	if track.ArtistID != nil {
+		song.ArtistID = subsonicArtistID(*track.ArtistID)
+	}
+	if track.ImagePath != "" {
+		song.CoverArt = id
+	}
+
+	return song
+}
+
+// subsonicPath строит условный путь к файлу: клиенты показывают его и используют при скачивании
+func subsonicPath(artist string, track *model.Track, suffix string) string {
+	name := track.Title
+	if track.TrackNumber > 0 {
+		name = fmt.Sprintf("%02d - %s", track.TrackNumber, track.Title)
+	}
+
+	replacer := strings.NewReplacer("/", "_", "\\", "_")
+	parts := []string{replacer.Replace(artist)}
+	if track.Album != "" {
+		parts = append(parts, replacer.Replace(track.Album))
+	}
+	parts = append(parts, replacer.Replace(name)+"."+suffix)
+
+	return strings.Join(parts, "/")
+}
+
+func subsonicArtistID(id uint) string {
+	return "ar-" + subsonicSongID(id)
+}
+
+func subsonicAlbumID(id uint) string {
+	return "al-" + subsonicSongID(id)
+}
+
+func subsonicSongID(id uint) string {
+	return strconv.FormatUint(uint64(id), 10)
+}
+
+// parseSubsonicID извлекает числовой id из идентификатора с префиксом типа
+func parseSubsonicID(id, prefix string) (uint, bool) {
+	value, ok := strings.CutPrefix(id, prefix)
+	if !ok {
+		return 0, false
+	}
+	parsed, err := strconv.ParseUint(value, 10, 32)
+	if err != nil || parsed == 0 {
+		return 0, false
+	}
+	return uint(parsed), true
+}
+
+// subsonicSortName убирает артикль из начала имени для сортировки и построения индекса
+func subsonicSortName(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, article := range strings.Fields(subsonicIgnoredArticles) {
+		if rest, ok := strings.CutPrefix(name, strings.ToLower(article)+" "); ok {
+			return strings.TrimSpace(rest)
+		}
+	}
+	return name
+}
+
+func subsonicIndexName(name string) string {
+	for _, r := range subsonicSortName(name) {
+		if unicode.IsLetter(r) {
+			return string(unicode.ToUpper(r))
+		}
+		break
+	}
+	return "#"
+}
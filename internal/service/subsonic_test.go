@@ -0,0 +1,114 @@
+package service
+
+import (
+	"MusicService/internal/model"
+	"MusicService/pkg/ratelimit"
+	"MusicService/pkg/secretbox"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestSubsonicAuthenticate(t *testing.T) {
+	box, err := secretbox.New("test-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte("account-pass"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := box.Seal("subsonic-pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	users := &fakeUserRepository{users: map[string]*model.User{
+		"plain": {Username: "plain", Password: string(hash), SubsonicPassword: sealed},
+		"totp":  {Username: "totp", Password: string(hash), SubsonicPassword: sealed, TOTPEnabled: true},
+	}}
+	svc := NewSubsonicService(users, nil, nil, nil, nil, box, nil)
+
+	token := func(password, salt string) string {
+		sum := md5.Sum([]byte(password + salt))
+		return hex.EncodeToString(sum[:])
+	}
+
+	tests := []struct {
+		name     string
+		username string
+		password string
+		token    string
+		salt     string
+		ok       bool
+	}{
+		{name: "account password", username: "plain", password: "account-pass", ok: true},
+		{name: "encoded account password", username: "plain", password: "enc:" + hex.EncodeToString([]byte("account-pass")), ok: true},
+		{name: "subsonic password", username: "plain", password: "subsonic-pass", ok: true},
+		{name: "wrong password", username: "plain", password: "nope"},
+		{name: "account password token", username: "plain", token: token("account-pass", "s4lt"), salt: "s4lt"},
+		{name: "totp account password", username: "totp", password: "account-pass"},
+		{name: "totp encoded account password", username: "totp", password: "enc:" + hex.EncodeToString([]byte("account-pass"))},
+		{name: "totp subsonic password", username: "totp", password: "subsonic-pass", ok: true},
+		{name: "totp subsonic token", username: "totp", token: token("subsonic-pass", "s4lt"), salt: "s4lt", ok: true},
+		{name: "unknown user", username: "ghost", password: "account-pass"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, err := svc.Authenticate(tt.username, tt.password, tt.token, tt.salt)
+			if tt.ok {
+				if err != nil || user.Username != tt.username {
+					t.Fatalf("Authenticate = %v, %v; want user %q", user, err, tt.username)
+				}
+				return
+			}
+			if !errors.Is(err, ErrSubsonicWrongCredentials) {
+				t.Fatalf("Authenticate error = %v, want %v", err, ErrSubsonicWrongCredentials)
+			}
+		})
+	}
+}
+
+func TestSubsonicAuthenticateLockout(t *testing.T) {
+	box, err := secretbox.New("test-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte("account-pass"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	users := &fakeUserRepository{users: map[string]*model.User{
+		"alice": {Username: "alice", Email: "Alice@example.com", Password: string(hash)},
+	}}
+	lockout := ratelimit.NewLockout(ratelimit.NewMemoryStore(), ratelimit.LockoutPolicy{
+		Threshold:    2,
+		BaseDuration: time.Minute,
+		Window:       time.Hour,
+	})
+	svc := NewSubsonicService(users, nil, nil, nil, nil, box, lockout)
+
+	for range 2 {
+		if _, err := svc.Authenticate("alice", "nope", "", ""); !errors.Is(err, ErrSubsonicWrongCredentials) {
+			t.Fatalf("Authenticate error = %v, want %v", err, ErrSubsonicWrongCredentials)
+		}
+	}
+
+	// Даже верный пароль не принимается, пока учетная запись заблокирована
+	if _, err := svc.Authenticate("alice", "account-pass", "", ""); !errors.Is(err, ErrLoginLocked) {
+		t.Fatalf("Authenticate error = %v, want %v", err, ErrLoginLocked)
+	}
+
+	// Блокировка общая со входом по паролю в AuthService
+	retryAfter, err := lockout.Check(context.Background(), lockoutKey("alice@example.com"))
+	if err != nil || retryAfter <= 0 {
+		t.Fatalf("login lockout = %v, %v; want the account locked", retryAfter, err)
+	}
+}
@@ -2,40 +2,84 @@ package service
 
 import (
 	"MusicService/internal/model"
+	"MusicService/internal/policy"
 	"MusicService/internal/repository"
 	"MusicService/internal/storage"
+	"MusicService/pkg/audiometa"
+	"MusicService/pkg/sniff"
+	"bytes"
+	"errors"
 	"fmt"
+	"html"
 	"io"
 	"log"
 	"mime/multipart"
-	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrTrackNotFound          = errors.New("track not found")
+	ErrTrackImageNotFound     = errors.New("track has no associated image")
+	ErrTrackInfoRequired      = errors.New("title and artist are required when the file has no tags")
+	ErrUnsupportedMediaType   = errors.New("unsupported media type")
+	ErrTranscodingUnavailable = errors.New("transcoding is not available")
 )
 
 type TrackService interface {
 	UploadTrack(audioFile *multipart.FileHeader, imageFile *multipart.FileHeader, req *model.TrackUploadRequest, userID uint) (*model.TrackResponse, error)
 	GetTrackByID(id uint) (*model.TrackResponse, error)
-	GetAllTracks() ([]model.TrackResponse, error)
-	StreamTrack(id uint) (io.ReadCloser, string, error)
-	DeleteTrack(id uint) error
-	SearchTracks(params model.TrackSearchParams) ([]model.TrackResponse, error)
+	GetAllTracks(page model.PageParams) (*model.Page[model.TrackResponse], error)
+	StreamTrack(id uint, params model.StreamParams) (*TrackStream, error)
+	GetHLSMasterPlaylist(id uint) (string, error)
+	GetHLSMediaPlaylist(id uint, bitRate int) (string, error)
+	GetHLSSegment(id uint, bitRate int, segment string) (*TrackStream, error)
+	DeleteTrack(id uint, actor policy.Actor) error
+	SearchTracks(params model.TrackSearchParams, page model.PageParams) (*model.TrackSearchResult, error)
 	GetTrackImage(id uint) (io.ReadCloser, string, error)
-	GetUserTracks(userId uint) ([]model.TrackResponse, error)
+	GetUserTracks(userId uint, page model.PageParams) (*model.Page[model.TrackResponse], error)
+}
+
+// TrackStream описывает аудиофайл трека в хранилище и позволяет читать его по диапазонам.
+// Для перекодируемого на лету потока размер неизвестен (Size < 0) и доступно только чтение целиком.
+type TrackStream struct {
+	ContentType  string
+	Size         int64
+	ETag         string
+	LastModified time.Time
+
+	open func(offset, length int64) (io.ReadCloser, error)
+}
+
+// Open открывает на чтение length байт файла начиная с offset
+func (t *TrackStream) Open(offset, length int64) (io.ReadCloser, error) {
+	return t.open(offset, length)
+}
+
+// Seekable сообщает, поддерживает ли поток чтение по диапазонам
+func (t *TrackStream) Seekable() bool {
+	return t.Size >= 0
 }
 
 type trackService struct {
 	trackRepo   repository.TrackRepository
 	minioClient storage.MinIOClient
+	transcoder  Transcoder
 	bucketName  string
+	// hlsLocks не дает одновременно нарезать один и тот же вариант HLS несколькими запросами
+	hlsLocks keyedMutex
 }
 
-func NewTrackService(trackRepo repository.TrackRepository, minioClient storage.MinIOClient, bucketName string) TrackService {
+// NewTrackService создает сервис треков. transcoder может быть nil - тогда доступна только отдача исходных файлов
+func NewTrackService(trackRepo repository.TrackRepository, minioClient storage.MinIOClient, transcoder Transcoder,
+	bucketName string) TrackService {
 	return &trackService{
 		trackRepo:   trackRepo,
 		minioClient: minioClient,
+		transcoder:  transcoder,
 		bucketName:  bucketName,
 	}
 }
@@ -47,177 +91,547 @@ func (s *trackService) UploadTrack(audioFile *multipart.FileHeader, imageFile *m
 	}
 	defer src.Close()
 
-	ext := filepath.Ext(audioFile.Filename)
-	audioFilename := uuid.New().String() + ext
-
-	_, err = s.minioClient.PutObject(s.bucketName, audioFilename, src, audioFile.Size)
+	audioType, err := detectMediaType(src, audioFile.Filename, sniff.KindAudio)
 	if err != nil {
 		return nil, err
 	}
 
-	src, err = imageFile.Open()
+	var image *trackImage
+	if imageFile != nil {
+		if image, err = openUploadedImage(imageFile); err != nil {
+			return nil, err
+		}
+		defer image.reader.Close()
+	}
+
+	meta, err := audiometa.Read(src)
 	if err != nil {
+		log.Printf("Failed to read metadata of '%s': %v", audioFile.Filename, err)
+		meta = &audiometa.Metadata{}
+	}
+
+	track := newTrackFromMetadata(meta, req)
+	if track.Title == "" || track.Artist == "" {
+		return nil, ErrTrackInfoRequired
+	}
+
+	if image == nil && meta.Picture != nil {
+		image = embeddedImage(meta.Picture)
+	}
+
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
 		return nil, err
 	}
-	defer src.Close()
 
-	ext = filepath.Ext(imageFile.Filename)
-	imageFilename := uuid.New().String() + ext
+	audioFilename := uuid.New().String() + audioType.Extension()
 
-	_, err = s.minioClient.PutObject(s.bucketName, imageFilename, src, imageFile.Size)
+	_, err = s.minioClient.PutObject(s.bucketName, audioFilename, src, audioFile.Size, audioType.MIME)
 	if err != nil {
 		return nil, err
 	}
 
-	// TODO: In a real application, you'd want to extract duration and other metadata from the audio file
-	track := &model.Track{
-		Title:      req.Title,
-		Artist:     req.Artist,
-		Album:      req.Album,
-		Genre:      req.Genre,
-		Duration:   180, // TODO Placeholder - should extract from audio file
-		FilePath:   audioFilename,
-		ImagePath:  imageFilename,
-		UploadedBy: userID,
+	var imageFilename string
+	if image != nil {
+		imageFilename = uuid.New().String() + image.mediaType.Extension()
+		if _, err := s.minioClient.PutObject(s.bucketName, imageFilename, image.reader, image.size, image.mediaType.MIME); err != nil {
+			_ = s.minioClient.RemoveObject(s.bucketName, audioFilename)
+			return nil, err
+		}
+		track.ImageType = image.mediaType.MIME
 	}
 
-	if err := s.trackRepo.Create(track); err != nil {
+	track.FilePath = audioFilename
+	track.ContentType = audioType.MIME
+	track.ImagePath = imageFilename
+	track.UploadedBy = userID
+
+	// Исполнитель и альбом создаются только вместе с треком, уже сохраненным в хранилище
+	err = s.trackRepo.CreateLinked(track, func(artistRepo repository.ArtistRepository, albumRepo repository.AlbumRepository) error {
+		linker := &libraryLinker{artistRepo: artistRepo, albumRepo: albumRepo}
+		return linker.link(track)
+	})
+	if err != nil {
 		_ = s.minioClient.RemoveObject(s.bucketName, audioFilename)
-		_ = s.minioClient.RemoveObject(s.bucketName, imageFilename)
+		if imageFilename != "" {
+			_ = s.minioClient.RemoveObject(s.bucketName, imageFilename)
+		}
 		return nil, err
 	}
 
-	return &model.TrackResponse{
-		ID:         track.ID,
-		Title:      track.Title,
-		Artist:     track.Artist,
-		Album:      track.Album,
-		Genre:      track.Genre,
-		Duration:   track.Duration,
-		CreatedAt:  track.CreatedAt.Format(time.RFC3339),
-		UploadedBy: track.UploadedBy,
-	}, nil
+	response := newTrackResponse(track)
+	return &response, nil
 }
 
-func (s *trackService) GetTrackByID(id uint) (*model.TrackResponse, error) {
-	track, err := s.trackRepo.GetByID(id)
+// detectMediaType определяет формат файла по сигнатуре и проверяет, что он ожидаемого вида
+// и не противоречит расширению имени файла
+func detectMediaType(r io.ReadSeeker, filename string, kind sniff.Kind) (sniff.Type, error) {
+	kindName := "audio"
+	if kind == sniff.KindImage {
+		kindName = "image"
+	}
+
+	mediaType, err := sniff.DetectReader(r)
+	if err != nil || mediaType.Kind != kind {
+		return sniff.Type{}, fmt.Errorf("%w: '%s' is not a supported %s file", ErrUnsupportedMediaType, filename, kindName)
+	}
+	if !mediaType.MatchesFilename(filename) {
+		return sniff.Type{}, fmt.Errorf("%w: extension of '%s' does not match its %s content", ErrUnsupportedMediaType, filename, mediaType.Name)
+	}
+
+	return mediaType, nil
+}
+
+// trackImage - проверенное изображение трека, готовое к сохранению в хранилище
+type trackImage struct {
+	reader    io.ReadCloser
+	size      int64
+	mediaType sniff.Type
+}
+
+func openUploadedImage(imageFile *multipart.FileHeader) (*trackImage, error) {
+	src, err := imageFile.Open()
 	if err != nil {
 		return nil, err
 	}
 
+	mediaType, err := detectMediaType(src, imageFile.Filename, sniff.KindImage)
+	if err != nil {
+		src.Close()
+		return nil, err
+	}
+
+	return &trackImage{reader: src, size: imageFile.Size, mediaType: mediaType}, nil
+}
+
+// embeddedImage возвращает обложку из тегов файла, если она в поддерживаемом формате
+func embeddedImage(picture *audiometa.Picture) *trackImage {
+	mediaType, err := sniff.Detect(picture.Data)
+	if err != nil || mediaType.Kind != sniff.KindImage {
+		log.Printf("Skipping embedded picture of unsupported type '%s'", picture.MIMEType)
+		return nil
+	}
+
+	return &trackImage{
+		reader:    io.NopCloser(bytes.NewReader(picture.Data)),
+		size:      int64(len(picture.Data)),
+		mediaType: mediaType,
+	}
+}
+
+// newTrackFromMetadata объединяет теги файла с полями формы; заполненные поля формы имеют приоритет
+func newTrackFromMetadata(meta *audiometa.Metadata, req *model.TrackUploadRequest) *model.Track {
+	return &model.Track{
+		Title:       firstNonEmpty(req.Title, meta.Title),
+		Artist:      firstNonEmpty(req.Artist, meta.Artist),
+		Album:       firstNonEmpty(req.Album, meta.Album),
+		AlbumArtist: firstNonEmpty(req.AlbumArtist, meta.AlbumArtist),
+		Genre:       firstNonEmpty(req.Genre, meta.Genre),
+		Year:        firstNonZero(req.Year, meta.Year),
+		TrackNumber: firstNonZero(req.TrackNumber, meta.TrackNumber),
+		DiscNumber:  firstNonZero(req.DiscNumber, meta.DiscNumber),
+		Duration:    int(meta.Duration.Round(time.Second) / time.Second),
+		BitRate:     meta.BitRate,
+		SampleRate:  meta.SampleRate,
+		Channels:    meta.Channels,
+	}
+}
+
+func newTrackResponse(track *model.Track) model.TrackResponse {
 	var imageURL string
 	if track.ImagePath != "" {
 		imageURL = fmt.Sprintf("/api/tracks/%d/image", track.ID)
 	}
 
-	return &model.TrackResponse{
-		ID:         track.ID,
-		Title:      track.Title,
-		Artist:     track.Artist,
-		Album:      track.Album,
-		Genre:      track.Genre,
-		Duration:   track.Duration,
-		ImageURL:   imageURL,
-		CreatedAt:  track.CreatedAt.Format(time.RFC3339),
-		UploadedBy: track.UploadedBy,
-	}, nil
+	response := model.TrackResponse{
+		ID:          track.ID,
+		Title:       track.Title,
+		Artist:      track.Artist,
+		ArtistID:    derefID(track.ArtistID),
+		Album:       track.Album,
+		AlbumID:     derefID(track.AlbumID),
+		AlbumArtist: track.AlbumArtist,
+		Genre:       track.Genre,
+		Year:        track.Year,
+		TrackNumber: track.TrackNumber,
+		DiscNumber:  track.DiscNumber,
+		Duration:    track.Duration,
+		BitRate:     track.BitRate,
+		SampleRate:  track.SampleRate,
+		Channels:    track.Channels,
+		ImageURL:    imageURL,
+		CreatedAt:   track.CreatedAt.Format(time.RFC3339),
+		UploadedBy:  track.UploadedBy,
+		PlayCount:   track.PlayCount,
+		Relevance:   track.Rank,
+	}
+	if track.TitleHighlight != "" {
+		response.Highlight = &model.TrackHighlight{
+			Title:  markHighlight(track.TitleHighlight),
+			Artist: markHighlight(track.ArtistHighlight),
+			Album:  markHighlight(track.AlbumHighlight),
+		}
+	}
+	return response
+}
+
+// markHighlight экранирует подсветку из базы и заменяет маркеры совпадений на <mark>
+func markHighlight(s string) string {
+	return strings.NewReplacer(model.HighlightStart, "<mark>", model.HighlightStop, "</mark>").Replace(html.EscapeString(s))
+}
+
+func derefID(id *uint) uint {
+	if id == nil {
+		return 0
+	}
+	return *id
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func firstNonZero(values ...int) int {
+	for _, v := range values {
+		if v != 0 {
+			return v
+		}
+	}
+	return 0
 }
 
-func (s *trackService) GetAllTracks() ([]model.TrackResponse, error) {
-	tracks, err := s.trackRepo.GetAll()
+func (s *trackService) GetTrackByID(id uint) (*model.TrackResponse, error) {
+	track, err := s.trackRepo.GetByID(id)
 	if err != nil {
 		return nil, err
 	}
 
-	var imageURL string
+	response := newTrackResponse(track)
+	return &response, nil
+}
+
+func (s *trackService) GetAllTracks(page model.PageParams) (*model.Page[model.TrackResponse], error) {
+	tracks, err := s.trackRepo.List(model.TrackSearchParams{}, page)
+	if err != nil {
+		return nil, err
+	}
+
+	return mapPage(tracks, newTrackResponse), nil
+}
 
-	var response []model.TrackResponse
-	for _, track := range tracks {
-		if track.ImagePath != "" {
-			imageURL = fmt.Sprintf("/api/tracks/%d/image", track.ID)
+func (s *trackService) StreamTrack(id uint, params model.StreamParams) (*TrackStream, error) {
+	track, err := s.trackRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	profile, err := resolveTranscodeProfile(track, params)
+	if err != nil {
+		return nil, err
+	}
+
+	if profile == nil {
+		contentType := track.ContentType
+		if contentType == "" {
+			contentType = "audio/mpeg"
 		}
+		return s.openStoredStream(track.FilePath, contentType)
+	}
 
-		response = append(response, model.TrackResponse{
-			ID:         track.ID,
-			Title:      track.Title,
-			Artist:     track.Artist,
-			Album:      track.Album,
-			Genre:      track.Genre,
-			Duration:   track.Duration,
-			ImageURL:   imageURL,
-			CreatedAt:  track.CreatedAt.Format(time.RFC3339),
-			UploadedBy: track.UploadedBy,
-		})
+	if s.transcoder == nil {
+		return nil, ErrTranscodingUnavailable
 	}
 
-	return response, nil
+	target := transcodeFormats[profile.Format]
+	renditionPath := renditionObjectName(track.ID, *profile, target)
+
+	// Перекодированная версия уже в кэше - отдаем ее с поддержкой диапазонов
+	if stream, err := s.openStoredStream(renditionPath, target.MIME); err == nil {
+		return stream, nil
+	}
+
+	return &TrackStream{
+		ContentType: target.MIME,
+		Size:        -1,
+		open: func(offset, length int64) (io.ReadCloser, error) {
+			return s.transcodeAndCache(track.FilePath, renditionPath, *profile, target.MIME)
+		},
+	}, nil
 }
 
-func (s *trackService) StreamTrack(id uint) (io.ReadCloser, string, error) {
-	track, err := s.trackRepo.GetByID(id)
+func (s *trackService) openStoredStream(objectName, contentType string) (*TrackStream, error) {
+	info, err := s.minioClient.StatObject(s.bucketName, objectName)
+	if err != nil {
+		return nil, err
+	}
+
+	etag := info.ETag
+	if etag != "" && !strings.HasPrefix(etag, `"`) {
+		etag = `"` + etag + `"`
+	}
+
+	return &TrackStream{
+		ContentType:  contentType,
+		Size:         info.Size,
+		ETag:         etag,
+		LastModified: info.LastModified,
+		open: func(offset, length int64) (io.ReadCloser, error) {
+			if offset == 0 && length >= info.Size {
+				return s.minioClient.GetObject(s.bucketName, objectName)
+			}
+			return s.minioClient.GetObjectRange(s.bucketName, objectName, offset, length)
+		},
+	}, nil
+}
+
+// transcodeAndCache запускает перекодирование и параллельно с отдачей клиенту сохраняет результат в MinIO.
+// Если поток прочитан не до конца, загрузка в кэш отменяется.
+func (s *trackService) transcodeAndCache(sourcePath, renditionPath string, profile TranscodeProfile, contentType string) (io.ReadCloser, error) {
+	source, err := s.minioClient.GetObject(s.bucketName, sourcePath)
 	if err != nil {
-		return nil, "", err
+		return nil, err
 	}
 
-	object, err := s.minioClient.GetObject(s.bucketName, track.FilePath)
+	output, err := s.transcoder.Transcode(source, profile)
 	if err != nil {
-		return nil, "", err
+		source.Close()
+		return nil, err
 	}
 
-	contentType := "audio/mpeg"
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		_, err := s.minioClient.PutObject(s.bucketName, renditionPath, pr, -1, contentType)
+		pr.CloseWithError(err)
+		done <- err
+	}()
 
-	return object, contentType, nil
+	return &cachingReader{output: output, source: source, cache: pw, done: done, objectName: renditionPath}, nil
 }
 
-func (s *trackService) DeleteTrack(id uint) error {
-	track, err := s.trackRepo.GetByID(id)
+// cachingReader отдает вывод транскодера и дублирует его в загрузку кэшированной версии
+type cachingReader struct {
+	output     io.ReadCloser
+	source     io.Closer
+	cache      *io.PipeWriter
+	cacheErr   error
+	done       chan error
+	eof        bool
+	objectName string
+}
+
+func (r *cachingReader) Read(p []byte) (int, error) {
+	n, err := r.output.Read(p)
+	if n > 0 && r.cacheErr == nil {
+		// Ошибка кэширования не должна прерывать воспроизведение
+		_, r.cacheErr = r.cache.Write(p[:n])
+	}
+	if err == io.EOF {
+		r.eof = true
+	}
+	return n, err
+}
+
+func (r *cachingReader) Close() error {
+	err := r.output.Close()
+	r.source.Close()
+
+	if r.eof && err == nil {
+		r.cache.Close()
+	} else {
+		r.cache.CloseWithError(errTranscodeAborted)
+	}
+
+	if cacheErr := <-r.done; cacheErr != nil && r.eof && err == nil {
+		log.Printf("Failed to cache transcoded rendition '%s': %v", r.objectName, cacheErr)
+	}
+
+	if errors.Is(err, errTranscodeAborted) {
+		return nil
+	}
+	return err
+}
+
+// transcodeFormats - форматы, в которые возможно перекодирование
+var transcodeFormats = map[string]sniff.Type{
+	"mp3":  sniff.MP3,
+	"opus": sniff.Opus,
+	"aac":  sniff.AAC,
+}
+
+var defaultTranscodeBitRates = map[string]int{
+	"mp3":  192,
+	"opus": 128,
+	"aac":  160,
+}
+
+// transcodeBitRates - допустимые значения битрейта; запрошенное значение округляется вниз,
+// чтобы ограничить количество кэшируемых версий
+var transcodeBitRates = []int{32, 48, 64, 96, 128, 160, 192, 256, 320}
+
+// resolveTranscodeProfile определяет, нужно ли перекодирование. Возвращает nil, если исходный файл
+// уже удовлетворяет запрошенным формату и битрейту.
+func resolveTranscodeProfile(track *model.Track, params model.StreamParams) (*TranscodeProfile, error) {
+	format := strings.ToLower(params.Format)
+	if format == "raw" || (format == "" && params.MaxBitRate <= 0) {
+		return nil, nil
+	}
+
+	source := sniff.MP3
+	if t, ok := sniff.ByMIME(track.ContentType); ok {
+		source = t
+	}
+
+	if format == "" {
+		format = "mp3"
+		if _, ok := transcodeFormats[source.Name]; ok {
+			format = source.Name
+		}
+	}
+	if _, ok := transcodeFormats[format]; !ok {
+		return nil, ErrUnsupportedTranscodeFormat
+	}
+
+	if format == source.Name {
+		if params.MaxBitRate <= 0 || (track.BitRate > 0 && track.BitRate <= params.MaxBitRate) {
+			return nil, nil
+		}
+	}
+
+	bitRate := defaultTranscodeBitRates[format]
+	if params.MaxBitRate > 0 {
+		bitRate = min(bitRate, params.MaxBitRate)
+	}
+	if track.BitRate > 0 {
+		bitRate = min(bitRate, track.BitRate)
+	}
+
+	return &TranscodeProfile{Format: format, BitRate: snapBitRate(bitRate)}, nil
+}
+
+func snapBitRate(bitRate int) int {
+	snapped := transcodeBitRates[0]
+	for _, b := range transcodeBitRates {
+		if b <= bitRate {
+			snapped = b
+		}
+	}
+	return snapped
+}
+
+// renditionObjectName - путь кэшированной версии трека в MinIO
+func renditionObjectName(trackID uint, profile TranscodeProfile, target sniff.Type) string {
+	return fmt.Sprintf("renditions/%d/%s-%d%s", trackID, profile.Format, profile.BitRate, target.Extension())
+}
+
+func (s *trackService) DeleteTrack(id uint, actor policy.Actor) error {
+	track, err := s.findTrack(id)
 	if err != nil {
 		return err
 	}
+	if err := policy.Track(actor, policy.ActionDelete, track); err != nil {
+		return err
+	}
 
-	if err := s.minioClient.RemoveObject(s.bucketName, track.FilePath); err != nil {
+	if err := removeTrackObjects(s.minioClient, s.bucketName, track); err != nil {
 		return err
 	}
 
 	return s.trackRepo.Delete(id)
 }
 
-func (s *trackService) SearchTracks(params model.TrackSearchParams) ([]model.TrackResponse, error) {
-	tracks, err := s.trackRepo.Search(params)
+// removeTrackObjects удаляет из хранилища аудиофайл трека, обложку и производные файлы.
+// Ошибкой считается только неудача с аудиофайлом; остальное лишь занимает место
+func removeTrackObjects(minioClient storage.MinIOClient, bucketName string, track *model.Track) error {
+	if err := minioClient.RemoveObject(bucketName, track.FilePath); err != nil {
+		return err
+	}
+
+	if track.ImagePath != "" {
+		if err := minioClient.RemoveObject(bucketName, track.ImagePath); err != nil {
+			log.Printf("Failed to remove image of track %d: %v", track.ID, err)
+		}
+	}
+	if err := minioClient.RemoveObjectsWithPrefix(bucketName, fmt.Sprintf("renditions/%d/", track.ID)); err != nil {
+		log.Printf("Failed to remove cached renditions of track %d: %v", track.ID, err)
+	}
+	if err := minioClient.RemoveObjectsWithPrefix(bucketName, hlsPrefix(track.ID)); err != nil {
+		log.Printf("Failed to remove HLS segments of track %d: %v", track.ID, err)
+	}
+	return nil
+}
+
+// SearchTracks ищет треки полнотекстово и считает фасеты по найденному. С params.Fuzzy, если точный
+// поиск ничего не дал, запрос ищется также в другом алфавите, а затем по сходству, и в ответ добавляется подсказка
+func (s *trackService) SearchTracks(params model.TrackSearchParams, page model.PageParams) (*model.TrackSearchResult, error) {
+	fuzzy := params.Fuzzy
+	params.Fuzzy = false
+	tracks, err := s.trackRepo.List(params, page)
 	if err != nil {
 		return nil, err
 	}
 
-	var response []model.TrackResponse
-	for _, track := range tracks {
-		response = append(response, model.TrackResponse{
-			ID:         track.ID,
-			Title:      track.Title,
-			Artist:     track.Artist,
-			Album:      track.Album,
-			Genre:      track.Genre,
-			Duration:   track.Duration,
-			CreatedAt:  track.CreatedAt.Format(time.RFC3339),
-			UploadedBy: track.UploadedBy,
-		})
+	var similar bool
+	var didYouMean string
+	if fuzzy && tracks.Total == 0 && params.Query != "" {
+		params.Fuzzy = true
+		if tracks, err = s.trackRepo.List(params, page); err != nil {
+			return nil, err
+		}
+		if tracks.Total == 0 {
+			if tracks, err = s.trackRepo.ListSimilar(params, page); err != nil {
+				return nil, err
+			}
+			similar = true
+		}
+
+		if tracks.Total > 0 {
+			suggestion, err := s.trackRepo.SuggestSimilar(params.Query)
+			if err != nil {
+				return nil, err
+			}
+			if !strings.EqualFold(suggestion, strings.TrimSpace(params.Query)) {
+				didYouMean = suggestion
+			}
+		}
+	}
+
+	facets, err := s.trackRepo.Facets(params, similar)
+	if err != nil {
+		return nil, err
 	}
 
-	return response, nil
+	return &model.TrackSearchResult{
+		Page:       mapPage(tracks, newTrackResponse),
+		Facets:     facets,
+		DidYouMean: didYouMean,
+	}, nil
 }
 
 func (s *trackService) GetTrackImage(trackID uint) (io.ReadCloser, string, error) {
 	track, err := s.trackRepo.GetByID(trackID)
 	if err != nil {
 		log.Printf("Error fetching track ID %d: %v", trackID, err)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, "", ErrTrackNotFound
+		}
 		return nil, "", fmt.Errorf("failed to retrieve track: %w", err)
 	}
 
 	if track.ImagePath == "" {
-		log.Printf("Track ID %d has no associated image", trackID)
-		return nil, "", fmt.Errorf("track has no associated image")
+		return nil, "", ErrTrackImageNotFound
 	}
 
-	obj, err := s.minioClient.GetObject(s.bucketName, track.ImagePath)
+	return openTrackImage(s.minioClient, s.bucketName, track)
+}
+
+// openTrackImage открывает изображение трека в хранилище и определяет его MIME-тип
+func openTrackImage(minioClient storage.MinIOClient, bucketName string, track *model.Track) (io.ReadCloser, string, error) {
+	obj, err := minioClient.GetObject(bucketName, track.ImagePath)
 	if err != nil {
 		log.Printf("MinIO error for path '%s': %v", track.ImagePath, err)
 		return nil, "", fmt.Errorf("failed to retrieve image from storage: %w", err)
@@ -236,8 +650,9 @@ func (s *trackService) GetTrackImage(trackID uint) (io.ReadCloser, string, error
 		return nil, "", fmt.Errorf("image file is empty")
 	}
 
-	contentType := "application/octet-stream"
+	contentType := track.ImageType
 	switch {
+	case contentType != "":
 	case strings.HasSuffix(track.ImagePath, ".jpg"),
 		strings.HasSuffix(track.ImagePath, ".jpeg"):
 		contentType = "image/jpeg"
@@ -245,38 +660,21 @@ func (s *trackService) GetTrackImage(trackID uint) (io.ReadCloser, string, error
 		contentType = "image/png"
 	case strings.HasSuffix(track.ImagePath, ".gif"):
 		contentType = "image/gif"
+	case strings.HasSuffix(track.ImagePath, ".webp"):
+		contentType = "image/webp"
+	default:
+		contentType = "application/octet-stream"
 	}
 
-	log.Printf("Successfully retrieved image for track ID %d (%s)", trackID, track.ImagePath)
+	log.Printf("Successfully retrieved image for track ID %d (%s)", track.ID, track.ImagePath)
 	return obj, contentType, nil
 }
 
-func (s *trackService) GetUserTracks(userId uint) ([]model.TrackResponse, error) {
-	tracks, err := s.trackRepo.GetUserTracks(userId)
+func (s *trackService) GetUserTracks(userId uint, page model.PageParams) (*model.Page[model.TrackResponse], error) {
+	tracks, err := s.trackRepo.List(model.TrackSearchParams{UploadedBy: userId}, page)
 	if err != nil {
 		return nil, err
 	}
 
-	var imageURL string
-
-	var response []model.TrackResponse
-	for _, track := range tracks {
-		if track.ImagePath != "" {
-			imageURL = fmt.Sprintf("/api/tracks/%d/image", track.ID)
-		}
-
-		response = append(response, model.TrackResponse{
-			ID:         track.ID,
-			Title:      track.Title,
-			Artist:     track.Artist,
-			Album:      track.Album,
-			Genre:      track.Genre,
-			Duration:   track.Duration,
-			ImageURL:   imageURL,
-			CreatedAt:  track.CreatedAt.Format(time.RFC3339),
-			UploadedBy: track.UploadedBy,
-		})
-	}
-
-	return response, nil
+	return mapPage(tracks, newTrackResponse), nil
 }
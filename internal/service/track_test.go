@@ -0,0 +1,310 @@
+package service
+
+import (
+	"MusicService/internal/model"
+	"MusicService/internal/repository"
+	"MusicService/internal/storage"
+	"MusicService/internal/storage/storagetest"
+	"bytes"
+	"errors"
+	"io"
+	"mime/multipart"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"gorm.io/gorm"
+)
+
+const testBucket = "music"
+
+// fakeTrackRepository хранит треки в памяти; нереализованные методы паникуют
+type fakeTrackRepository struct {
+	repository.TrackRepository
+	tracks    map[uint]*model.Track
+	artists   []string // исполнители, созданные вместе с сохраненными треками
+	createErr error
+}
+
+type fakeArtistRepository struct {
+	repository.ArtistRepository
+	created []string
+}
+
+func (r *fakeArtistRepository) GetOrCreate(name, normalizedName string) (*model.Artist, error) {
+	r.created = append(r.created, name)
+	artist := &model.Artist{Name: name, NormalizedName: normalizedName}
+	artist.ID = uint(len(r.created))
+	return artist, nil
+}
+
+// fakeAlbumRepository нужен только для треков без альбома
+type fakeAlbumRepository struct {
+	repository.AlbumRepository
+}
+
+// CreateLinked, как и транзакция, не сохраняет ни трек, ни привязки, если createErr задан
+func (r *fakeTrackRepository) CreateLinked(track *model.Track, link repository.LinkFunc) error {
+	artists := &fakeArtistRepository{}
+	if err := link(artists, fakeAlbumRepository{}); err != nil {
+		return err
+	}
+	if r.createErr != nil {
+		return r.createErr
+	}
+	track.ID = uint(len(r.tracks) + 1)
+	r.tracks[track.ID] = track
+	r.artists = append(r.artists, artists.created...)
+	return nil
+}
+
+func (r *fakeTrackRepository) GetByID(id uint) (*model.Track, error) {
+	track, ok := r.tracks[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	copied := *track
+	return &copied, nil
+}
+
+// fakeTranscoder переводит вход в верхний регистр и считает вызовы
+type fakeTranscoder struct {
+	mu       sync.Mutex
+	calls    []TranscodeProfile
+	failWith error
+}
+
+func (t *fakeTranscoder) Transcode(src io.Reader, profile TranscodeProfile) (io.ReadCloser, error) {
+	t.mu.Lock()
+	t.calls = append(t.calls, profile)
+	t.mu.Unlock()
+
+	if t.failWith != nil {
+		return nil, t.failWith
+	}
+	data, err := io.ReadAll(src)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(bytes.ToUpper(data))), nil
+}
+
+func (t *fakeTranscoder) SegmentHLS(src io.Reader, profile TranscodeProfile, segmentDuration time.Duration, outDir string) error {
+	return errors.New("not implemented")
+}
+
+func (t *fakeTranscoder) callCount() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.calls)
+}
+
+func newStreamFixture(t *testing.T, transcoder Transcoder) (TrackService, *storagetest.Memory) {
+	t.Helper()
+
+	store := storagetest.NewMemory(t)
+	store.Put(testBucket, "tracks/1.flac", []byte("lossless audio"), "audio/flac")
+	repo := &fakeTrackRepository{tracks: map[uint]*model.Track{
+		1: {Model: gorm.Model{ID: 1}, FilePath: "tracks/1.flac", ContentType: "audio/flac", BitRate: 900},
+	}}
+
+	return NewTrackService(repo, store, transcoder, testBucket), store
+}
+
+func readStream(t *testing.T, stream *TrackStream, limit int64) []byte {
+	t.Helper()
+
+	body, err := stream.Open(0, stream.Size)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	data, err := io.ReadAll(io.LimitReader(body, limit))
+	if err != nil {
+		t.Fatalf("read stream: %v", err)
+	}
+	if err := body.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	return data
+}
+
+func TestStreamTrackTranscodesAndCaches(t *testing.T) {
+	transcoder := &fakeTranscoder{}
+	svc, store := newStreamFixture(t, transcoder)
+	params := model.StreamParams{Format: "mp3", MaxBitRate: 128}
+
+	stream, err := svc.StreamTrack(1, params)
+	if err != nil {
+		t.Fatalf("StreamTrack: %v", err)
+	}
+	if stream.Seekable() || stream.ContentType != "audio/mpeg" {
+		t.Fatalf("first stream: seekable=%v contentType=%q, want live audio/mpeg", stream.Seekable(), stream.ContentType)
+	}
+	if got := readStream(t, stream, 1<<20); string(got) != "LOSSLESS AUDIO" {
+		t.Fatalf("transcoded body = %q", got)
+	}
+	if want := (TranscodeProfile{Format: "mp3", BitRate: 128}); transcoder.calls[0] != want {
+		t.Errorf("profile = %+v, want %+v", transcoder.calls[0], want)
+	}
+
+	cached, ok := store.Get(testBucket, "renditions/1/mp3-128.mp3")
+	if !ok || string(cached) != "LOSSLESS AUDIO" {
+		t.Fatalf("cached rendition = %q (present %v)", cached, ok)
+	}
+
+	stream, err = svc.StreamTrack(1, params)
+	if err != nil {
+		t.Fatalf("StreamTrack from cache: %v", err)
+	}
+	if !stream.Seekable() || stream.Size != int64(len(cached)) || stream.ETag == "" {
+		t.Fatalf("cached stream: size=%d etag=%q, want seekable stored object", stream.Size, stream.ETag)
+	}
+	if got := readStream(t, stream, 1<<20); string(got) != "LOSSLESS AUDIO" {
+		t.Fatalf("cached body = %q", got)
+	}
+	if transcoder.callCount() != 1 {
+		t.Errorf("transcoder called %d times, want 1", transcoder.callCount())
+	}
+}
+
+func TestStreamTrackAbortedReadIsNotCached(t *testing.T) {
+	transcoder := &fakeTranscoder{}
+	svc, store := newStreamFixture(t, transcoder)
+
+	stream, err := svc.StreamTrack(1, model.StreamParams{Format: "opus"})
+	if err != nil {
+		t.Fatalf("StreamTrack: %v", err)
+	}
+	if got := readStream(t, stream, 4); string(got) != "LOSS" {
+		t.Fatalf("partial body = %q", got)
+	}
+
+	if _, ok := store.Get(testBucket, "renditions/1/opus-128.opus"); ok {
+		t.Fatal("partially read rendition was cached")
+	}
+}
+
+func TestStreamTrackWithoutTranscoding(t *testing.T) {
+	tests := []struct {
+		name       string
+		transcoder Transcoder
+		params     model.StreamParams
+		wantErr    error
+		wantBody   string
+	}{
+		{name: "raw", transcoder: &fakeTranscoder{}, params: model.StreamParams{Format: "raw"}, wantBody: "lossless audio"},
+		{name: "no params", transcoder: &fakeTranscoder{}, wantBody: "lossless audio"},
+		{name: "unsupported format", transcoder: &fakeTranscoder{}, params: model.StreamParams{Format: "wma"}, wantErr: ErrUnsupportedTranscodeFormat},
+		{name: "no transcoder", params: model.StreamParams{Format: "mp3"}, wantErr: ErrTranscodingUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, _ := newStreamFixture(t, tt.transcoder)
+
+			stream, err := svc.StreamTrack(1, tt.params)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("StreamTrack error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got := readStream(t, stream, 1<<20); string(got) != tt.wantBody {
+				t.Errorf("body = %q, want %q", got, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestStreamTrackTranscoderFailure(t *testing.T) {
+	transcoder := &fakeTranscoder{failWith: errors.New("ffmpeg crashed")}
+	svc, store := newStreamFixture(t, transcoder)
+
+	stream, err := svc.StreamTrack(1, model.StreamParams{Format: "aac"})
+	if err != nil {
+		t.Fatalf("StreamTrack: %v", err)
+	}
+	if _, err := stream.Open(0, stream.Size); err == nil {
+		t.Fatal("Open succeeded despite transcoder failure")
+	}
+	if _, ok := store.Get(testBucket, "renditions/1/aac-160.aac"); ok {
+		t.Fatal("rendition cached after transcoder failure")
+	}
+}
+
+// failingUploads отклоняет загрузку объектов в хранилище
+type failingUploads struct {
+	*storagetest.Memory
+}
+
+func (failingUploads) PutObject(bucketName, objectName string, reader io.Reader, objectSize int64, contentType string) (minio.UploadInfo, error) {
+	return minio.UploadInfo{}, errors.New("storage is unavailable")
+}
+
+func audioFileHeader(t *testing.T) *multipart.FileHeader {
+	t.Helper()
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("audio", "song.mp3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write([]byte("ID3\x04\x00\x00\x00\x00\x00\x00mpeg audio"))
+	writer.Close()
+
+	form, err := multipart.NewReader(&body, writer.Boundary()).ReadForm(1 << 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { form.RemoveAll() })
+	return form.File["audio"][0]
+}
+
+func TestUploadTrackLinksOnlySavedTracks(t *testing.T) {
+	req := &model.TrackUploadRequest{Title: "Song", Artist: "Band"}
+
+	tests := []struct {
+		name      string
+		failStore bool
+		createErr error
+		wantErr   bool
+	}{
+		{name: "saved"},
+		{name: "storage failure", failStore: true, wantErr: true},
+		{name: "insert failure", createErr: errors.New("insert failed"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := storagetest.NewMemory(t)
+			var client storage.MinIOClient = store
+			if tt.failStore {
+				client = failingUploads{store}
+			}
+			repo := &fakeTrackRepository{tracks: map[uint]*model.Track{}, createErr: tt.createErr}
+			svc := NewTrackService(repo, client, nil, testBucket)
+
+			track, err := svc.UploadTrack(audioFileHeader(t), nil, req, 1)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("UploadTrack error = %v, want error = %v", err, tt.wantErr)
+			}
+
+			size, err := store.BucketSize(testBucket)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantErr {
+				if len(repo.tracks) != 0 || len(repo.artists) != 0 || size != 0 {
+					t.Errorf("failed upload left %d tracks, artists %v and %d stored bytes", len(repo.tracks), repo.artists, size)
+				}
+				return
+			}
+			if track.Artist != "Band" || !slices.Equal(repo.artists, []string{"Band"}) || size == 0 {
+				t.Errorf("track %+v, artists %v, stored bytes %d", track, repo.artists, size)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TranscodeProfile описывает целевой формат перекодирования
+type TranscodeProfile struct {
+	Format  string // mp3, opus или aac
+	BitRate int    // in kbps
+}
+
+// Transcoder перекодирует аудиопоток. Возвращаемый поток нужно закрыть;
+// закрытие до конца чтения прерывает перекодирование.
+type Transcoder interface {
+	Transcode(src io.Reader, profile TranscodeProfile) (io.ReadCloser, error)
+	// SegmentHLS перекодирует поток в AAC и нарезает его на сегменты HLS в каталоге outDir:
+	// медиаплейлист index.m3u8 и сегменты segment_NNN.ts
+	SegmentHLS(src io.Reader, profile TranscodeProfile, segmentDuration time.Duration, outDir string) error
+}
+
+var (
+	ErrUnsupportedTranscodeFormat = errors.New("unsupported transcode format")
+	errTranscodeAborted           = errors.New("transcoding aborted")
+)
+
+type ffmpegTranscoder struct {
+	path string
+}
+
+// NewFFmpegTranscoder создает Transcoder на основе исполняемого файла ffmpeg
+func NewFFmpegTranscoder(path string) (Transcoder, error) {
+	if path == "" {
+		path = "ffmpeg"
+	}
+
+	resolved, err := exec.LookPath(path)
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg not found: %w", err)
+	}
+
+	return &ffmpegTranscoder{path: resolved}, nil
+}
+
+// ffmpegCodecs сопоставляет формату кодек и контейнер, пригодный для потоковой записи в pipe
+var ffmpegCodecs = map[string][2]string{
+	"mp3":  {"libmp3lame", "mp3"},
+	"opus": {"libopus", "ogg"},
+	"aac":  {"aac", "adts"},
+}
+
+func (t *ffmpegTranscoder) Transcode(src io.Reader, profile TranscodeProfile) (io.ReadCloser, error) {
+	codec, ok := ffmpegCodecs[profile.Format]
+	if !ok {
+		return nil, ErrUnsupportedTranscodeFormat
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cmd := exec.CommandContext(ctx, t.path,
+		"-hide_banner", "-loglevel", "error",
+		"-i", "pipe:0",
+		"-map", "0:a:0", "-vn", "-map_metadata", "-1",
+		"-c:a", codec[0],
+		"-b:a", fmt.Sprintf("%dk", profile.BitRate),
+		"-f", codec[1],
+		"pipe:1",
+	)
+	cmd.Stdin = src
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	if err := cmd.Start(); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	return &ffmpegStream{ReadCloser: stdout, cmd: cmd, cancel: cancel, stderr: &stderr}, nil
+}
+
+func (t *ffmpegTranscoder) SegmentHLS(src io.Reader, profile TranscodeProfile, segmentDuration time.Duration, outDir string) error {
+	cmd := exec.Command(t.path,
+		"-hide_banner", "-loglevel", "error",
+		"-i", "pipe:0",
+		"-map", "0:a:0", "-vn", "-map_metadata", "-1",
+		"-c:a", "aac",
+		"-b:a", fmt.Sprintf("%dk", profile.BitRate),
+		"-f", "hls",
+		"-hls_time", strconv.Itoa(int(segmentDuration.Seconds())),
+		"-hls_playlist_type", "vod",
+		"-hls_segment_type", "mpegts",
+		"-hls_segment_filename", filepath.Join(outDir, "segment_%03d.ts"),
+		filepath.Join(outDir, "index.m3u8"),
+	)
+	cmd.Stdin = src
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("ffmpeg: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+type ffmpegStream struct {
+	io.ReadCloser
+	cmd    *exec.Cmd
+	cancel context.CancelFunc
+	stderr *bytes.Buffer
+	eof    bool
+}
+
+func (s *ffmpegStream) Read(p []byte) (int, error) {
+	n, err := s.ReadCloser.Read(p)
+	if err == io.EOF {
+		s.eof = true
+	}
+	return n, err
+}
+
+func (s *ffmpegStream) Close() error {
+	if !s.eof {
+		s.cancel()
+	}
+	defer s.cancel()
+
+	if err := s.cmd.Wait(); err != nil {
+		if !s.eof {
+			return errTranscodeAborted
+		}
+		return fmt.Errorf("ffmpeg: %w: %s", err, strings.TrimSpace(s.stderr.String()))
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+package service
+
+import (
+	"MusicService/internal/model"
+	"MusicService/internal/repository"
+	"MusicService/pkg/secretbox"
+	"MusicService/pkg/tokenhash"
+	"MusicService/pkg/totp"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const recoveryCodeCount = 10
+
+var (
+	ErrTwoFactorAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotEnabled     = errors.New("two-factor authentication is not enabled")
+	ErrTwoFactorNotSetUp       = errors.New("two-factor authentication has not been set up")
+	ErrInvalidTwoFactorCode    = errors.New("invalid two-factor code")
+)
+
+// TwoFactorService управляет двухфакторной аутентификацией по TOTP
+type TwoFactorService interface {
+	Setup(userID uint) (*model.TwoFactorSetupResponse, error)
+	Enable(userID uint, code string) (*model.RecoveryCodesResponse, error)
+	Disable(userID uint, req *model.DisableTwoFactorRequest) error
+	RegenerateRecoveryCodes(userID uint, code string) (*model.RecoveryCodesResponse, error)
+	VerifyCode(user *model.User, code string) (bool, error)
+}
+
+type twoFactorService struct {
+	userRepo         repository.UserRepository
+	recoveryCodeRepo repository.RecoveryCodeRepository
+	secretBox        secretbox.Box
+	issuer           string
+}
+
+func NewTwoFactorService(userRepo repository.UserRepository, recoveryCodeRepo repository.RecoveryCodeRepository,
+	secretBox secretbox.Box, issuer string) TwoFactorService {
+	return &twoFactorService{
+		userRepo:         userRepo,
+		recoveryCodeRepo: recoveryCodeRepo,
+		secretBox:        secretBox,
+		issuer:           issuer,
+	}
+}
+
+// Setup генерирует новый секрет. 2FA включается только после подтверждения кодом в Enable,
+// поэтому незавершенная настройка не мешает входу
+func (s *twoFactorService) Setup(userID uint) (*model.TwoFactorSetupResponse, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabled {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPSecret, err = s.secretBox.Seal(secret); err != nil {
+		return nil, err
+	}
+	user.TOTPLastStep = 0
+	if err := s.userRepo.Update(user); err != nil {
+		return nil, err
+	}
+
+	return &model.TwoFactorSetupResponse{
+		Secret:     secret,
+		OTPAuthURI: totp.URI(s.issuer, user.Email, secret),
+	}, nil
+}
+
+func (s *twoFactorService) Enable(userID uint, code string) (*model.RecoveryCodesResponse, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabled {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+	if user.TOTPSecret == "" {
+		return nil, ErrTwoFactorNotSetUp
+	}
+
+	ok, err := s.verifyTOTP(user, code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	user.TOTPEnabled = true
+	if err := s.userRepo.Update(user); err != nil {
+		return nil, err
+	}
+
+	return s.issueRecoveryCodes(user.ID)
+}
+
+// Disable отключает 2FA. Требуются и пароль, и код, чтобы украденная сессия не позволила снять защиту
+func (s *twoFactorService) Disable(userID uint, req *model.DisableTwoFactorRequest) error {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+	if !user.TOTPEnabled {
+		return ErrTwoFactorNotEnabled
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+		return ErrIncorrectPassword
+	}
+
+	ok, err := s.VerifyCode(user, req.Code)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvalidTwoFactorCode
+	}
+
+	user.TOTPEnabled = false
+	user.TOTPSecret = ""
+	user.TOTPLastStep = 0
+	if err := s.userRepo.Update(user); err != nil {
+		return err
+	}
+
+	return s.recoveryCodeRepo.DeleteByUserID(user.ID)
+}
+
+// RegenerateRecoveryCodes выдает новый набор кодов восстановления взамен прежнего
+func (s *twoFactorService) RegenerateRecoveryCodes(userID uint, code string) (*model.RecoveryCodesResponse, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.TOTPEnabled {
+		return nil, ErrTwoFactorNotEnabled
+	}
+
+	ok, err := s.verifyTOTP(user, code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	return s.issueRecoveryCodes(user.ID)
+}
+
+// VerifyCode принимает код из приложения-аутентификатора или код восстановления
+func (s *twoFactorService) VerifyCode(user *model.User, code string) (bool, error) {
+	if !user.TOTPEnabled {
+		return false, ErrTwoFactorNotEnabled
+	}
+
+	ok, err := s.verifyTOTP(user, code)
+	if err != nil || ok {
+		return ok, err
+	}
+
+	return s.recoveryCodeRepo.Use(user.ID, tokenhash.Sum(normalizeRecoveryCode(code)))
+}
+
+// verifyTOTP проверяет код и запоминает его шаг, чтобы один и тот же код нельзя было использовать дважды
+func (s *twoFactorService) verifyTOTP(user *model.User, code string) (bool, error) {
+	secret, err := s.secretBox.Open(user.TOTPSecret)
+	if err != nil {
+		return false, err
+	}
+
+	step, ok := totp.Validate(secret, code, time.Now(), 1)
+	if !ok || step <= user.TOTPLastStep {
+		return false, nil
+	}
+
+	// Шаг сохраняется условным обновлением: из двух параллельных запросов с одним кодом пройдет один
+	advanced, err := s.userRepo.AdvanceTOTPStep(user.ID, step)
+	if err != nil || !advanced {
+		return false, err
+	}
+	user.TOTPLastStep = step
+	return true, nil
+}
+
+func (s *twoFactorService) issueRecoveryCodes(userID uint) (*model.RecoveryCodesResponse, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		code, err := newRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code
+		hashes[i] = tokenhash.Sum(normalizeRecoveryCode(code))
+	}
+
+	if err := s.recoveryCodeRepo.Replace(userID, hashes); err != nil {
+		return nil, err
+	}
+	return &model.RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+// newRecoveryCode генерирует 80-битный код вида XXXX-XXXX-XXXX-XXXX
+func newRecoveryCode() (string, error) {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	raw := base32.StdEncoding.EncodeToString(b)
+	return raw[0:4] + "-" + raw[4:8] + "-" + raw[8:12] + "-" + raw[12:16], nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
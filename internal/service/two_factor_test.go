@@ -0,0 +1,52 @@
+package service
+
+import (
+	"MusicService/internal/model"
+	"MusicService/internal/repository"
+	"MusicService/pkg/secretbox"
+	"MusicService/pkg/totp"
+	"testing"
+	"time"
+)
+
+type fakeRecoveryCodeRepository struct {
+	repository.RecoveryCodeRepository
+}
+
+func (fakeRecoveryCodeRepository) Use(userID uint, hash string) (bool, error) {
+	return false, nil
+}
+
+func TestVerifyCodeRejectsReplay(t *testing.T) {
+	box, err := secretbox.New("test-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := box.Seal(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := totp.Code(secret, totp.Step(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stored := &model.User{Username: "alice", TOTPSecret: sealed, TOTPEnabled: true}
+	stored.ID = 1
+	users := &fakeUserRepository{users: map[string]*model.User{"alice": stored}}
+	svc := NewTwoFactorService(users, fakeRecoveryCodeRepository{}, box, "test")
+
+	// Два запроса загрузили пользователя до того, как любой из них принял код
+	first, second := *stored, *stored
+
+	if ok, err := svc.VerifyCode(&first, code); err != nil || !ok {
+		t.Fatalf("first VerifyCode = %v, %v; want accepted", ok, err)
+	}
+	if ok, err := svc.VerifyCode(&second, code); err != nil || ok {
+		t.Fatalf("replayed VerifyCode = %v, %v; want rejected", ok, err)
+	}
+}
@@ -3,19 +3,40 @@ package service
 import (
 	"MusicService/internal/model"
 	"MusicService/internal/repository"
+	"MusicService/pkg/secretbox"
+	"errors"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
 type UserService interface {
 	GetProfile(userID uint) (*model.UserResponse, error)
-	UpdateProfile(userID uint, update *model.UserResponse) (*model.UserResponse, error)
+	UpdateProfile(userID uint, update *model.UpdateProfileRequest) (*model.UserResponse, error)
+	SetSubsonicPassword(userID uint, password string) error
+	ChangePassword(userID uint, sessionID string, req *model.ChangePasswordRequest) error
 }
 
+var (
+	ErrIncorrectPassword = errors.New("current password is incorrect")
+	ErrUsernameExists    = errors.New("username already exists")
+)
+
 type userService struct {
-	userRepo repository.UserRepository
+	userRepo            repository.UserRepository
+	sessionRepo         repository.SessionRepository
+	verificationService VerificationService
+	secretBox           secretbox.Box
 }
 
-func NewUserService(userRepo repository.UserRepository) UserService {
-	return &userService{userRepo: userRepo}
+func NewUserService(userRepo repository.UserRepository, sessionRepo repository.SessionRepository,
+	verificationService VerificationService, secretBox secretbox.Box) UserService {
+	return &userService{
+		userRepo:            userRepo,
+		sessionRepo:         sessionRepo,
+		verificationService: verificationService,
+		secretBox:           secretBox,
+	}
 }
 
 func (s *userService) GetProfile(userID uint) (*model.UserResponse, error) {
@@ -24,29 +45,94 @@ func (s *userService) GetProfile(userID uint) (*model.UserResponse, error) {
 		return nil, err
 	}
 
-	return &model.UserResponse{
-		ID:       user.ID,
-		Username: user.Username,
-		Email:    user.Email,
-	}, nil
+	return newUserResponse(user), nil
 }
 
-func (s *userService) UpdateProfile(userID uint, update *model.UserResponse) (*model.UserResponse, error) {
+// UpdateProfile меняет имя пользователя сразу, а новый email - только после подтверждения
+// по ссылке, отправленной на этот адрес
+func (s *userService) UpdateProfile(userID uint, update *model.UpdateProfileRequest) (*model.UserResponse, error) {
 	user, err := s.userRepo.FindByID(userID)
 	if err != nil {
 		return nil, err
 	}
 
-	user.Username = update.Username
-	user.Email = update.Email
+	if update.Username != user.Username {
+		existing, err := s.userRepo.FindByUsername(update.Username)
+		if err == nil && existing.ID != user.ID {
+			return nil, ErrUsernameExists
+		}
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		user.Username = update.Username
+	}
 
+	if update.Email != user.Email {
+		if err := s.verificationService.RequestEmailChange(user, update.Email); err != nil {
+			return nil, err
+		}
+		return newUserResponse(user), nil
+	}
+
+	// Указан текущий адрес - незавершенная смена email отменяется
+	user.PendingEmail = ""
 	if err := s.userRepo.Update(user); err != nil {
 		return nil, err
 	}
 
+	return newUserResponse(user), nil
+}
+
+func newUserResponse(user *model.User) *model.UserResponse {
 	return &model.UserResponse{
 		ID:       user.ID,
 		Username: user.Username,
 		Email:    user.Email,
-	}, nil
+		Role:     user.Role,
+
+		EmailVerified:    user.EmailVerified,
+		PendingEmail:     user.PendingEmail,
+		TwoFactorEnabled: user.TOTPEnabled,
+	}
+}
+
+// SetSubsonicPassword задает пароль для клиентов Subsonic. Протокол проверяет token = md5(password + salt),
+// поэтому пароль хранится зашифрованным, а не в виде хэша
+func (s *userService) SetSubsonicPassword(userID uint, password string) error {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+
+	sealed, err := s.secretBox.Seal(password)
+	if err != nil {
+		return err
+	}
+
+	user.SubsonicPassword = sealed
+	return s.userRepo.Update(user)
+}
+
+// ChangePassword меняет пароль и завершает все сессии пользователя, кроме текущей
+func (s *userService) ChangePassword(userID uint, sessionID string, req *model.ChangePasswordRequest) error {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.CurrentPassword)); err != nil {
+		return ErrIncorrectPassword
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	user.Password = string(hashedPassword)
+	if err := s.userRepo.Update(user); err != nil {
+		return err
+	}
+
+	return s.sessionRepo.RevokeOthers(userID, sessionID)
 }
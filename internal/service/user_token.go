@@ -0,0 +1,65 @@
+package service
+
+import (
+	"MusicService/internal/model"
+	"MusicService/internal/repository"
+	"MusicService/pkg/mailer"
+	"MusicService/pkg/tokenhash"
+	"errors"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// issueUserToken создает одноразовый токен для ссылки из письма. Предыдущие токены
+// с тем же назначением гасятся, так что действительна только последняя ссылка
+func issueUserToken(repo repository.UserTokenRepository, userID uint, purpose string, ttl time.Duration) (string, error) {
+	if err := repo.InvalidateForUser(userID, purpose); err != nil {
+		return "", err
+	}
+
+	token, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+
+	err = repo.Create(&model.UserToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: tokenhash.Sum(token),
+		ExpiresAt: time.Now().Add(ttl),
+	})
+	return token, err
+}
+
+// consumeUserToken находит токен и помечает его использованным. Возвращает nil без ошибки,
+// если токен не найден, истек или уже использован
+func consumeUserToken(repo repository.UserTokenRepository, purpose, token string) (*model.UserToken, error) {
+	stored, err := repo.FindByHash(purpose, tokenhash.Sum(token))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if stored.UsedAt != nil || time.Now().After(stored.ExpiresAt) {
+		return nil, nil
+	}
+
+	fresh, err := repo.MarkUsed(stored.ID)
+	if err != nil || !fresh {
+		return nil, err
+	}
+	return stored, nil
+}
+
+// sendMailAsync отправляет письмо в фоне: ответ API не должен ждать SMTP-сервер
+// и не должен по времени выдавать, было ли письмо отправлено
+func sendMailAsync(m mailer.Mailer, msg mailer.Message) {
+	go func() {
+		if err := m.Send(msg); err != nil {
+			log.Printf("Failed to send email %q: %v", msg.Subject, err)
+		}
+	}()
+}
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"MusicService/internal/model"
+	"MusicService/internal/repository"
+	"MusicService/pkg/mailer"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const emailVerificationTTL = 48 * time.Hour
+
+var (
+	ErrInvalidVerificationToken = errors.New("invalid or expired verification token")
+	ErrEmailAlreadyVerified     = errors.New("email is already verified")
+	ErrEmailExists              = errors.New("email already exists")
+)
+
+// VerificationService подтверждает адреса электронной почты: указанный при регистрации
+// и новый адрес при смене email
+type VerificationService interface {
+	SendVerification(user *model.User) error
+	ResendVerification(userID uint) error
+	RequestEmailChange(user *model.User, email string) error
+	VerifyEmail(token string) (*model.UserResponse, error)
+}
+
+type verificationService struct {
+	userRepo      repository.UserRepository
+	userTokenRepo repository.UserTokenRepository
+	mailer        mailer.Mailer
+	publicURL     string
+}
+
+func NewVerificationService(userRepo repository.UserRepository, userTokenRepo repository.UserTokenRepository,
+	mailer mailer.Mailer, publicURL string) VerificationService {
+	return &verificationService{
+		userRepo:      userRepo,
+		userTokenRepo: userTokenRepo,
+		mailer:        mailer,
+		publicURL:     strings.TrimSuffix(publicURL, "/"),
+	}
+}
+
+func (s *verificationService) SendVerification(user *model.User) error {
+	token, err := issueUserToken(s.userTokenRepo, user.ID, model.TokenPurposeVerifyEmail, emailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	sendMailAsync(s.mailer, mailer.Message{
+		To:      user.Email,
+		Subject: "Confirm your email",
+		Body: fmt.Sprintf("Hello, %s!\n\nTo confirm your email address, open the link below:\n%s\n",
+			user.Username, s.verificationLink(token)),
+	})
+	return nil
+}
+
+func (s *verificationService) ResendVerification(userID uint) error {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+	if user.EmailVerified {
+		return ErrEmailAlreadyVerified
+	}
+
+	return s.SendVerification(user)
+}
+
+// RequestEmailChange запоминает новый адрес как ожидающий и отправляет на него ссылку.
+// Email пользователя меняется только после перехода по ссылке
+func (s *verificationService) RequestEmailChange(user *model.User, email string) error {
+	if err := s.ensureEmailAvailable(user.ID, email); err != nil {
+		return err
+	}
+
+	user.PendingEmail = email
+	if err := s.userRepo.Update(user); err != nil {
+		return err
+	}
+
+	token, err := issueUserToken(s.userTokenRepo, user.ID, model.TokenPurposeChangeEmail, emailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	sendMailAsync(s.mailer, mailer.Message{
+		To:      email,
+		Subject: "Confirm your new email",
+		Body: fmt.Sprintf("Hello, %s!\n\nTo use this address for your account, open the link below:\n%s\n\n"+
+			"If you did not request this change, ignore this email.\n",
+			user.Username, s.verificationLink(token)),
+	})
+	return nil
+}
+
+// VerifyEmail подтверждает адрес по токену из письма. Токен смены email заменяет адрес
+// на ожидающий, а прежний адрес получает уведомление
+func (s *verificationService) VerifyEmail(token string) (*model.UserResponse, error) {
+	stored, err := consumeUserToken(s.userTokenRepo, model.TokenPurposeVerifyEmail, token)
+	if err == nil && stored == nil {
+		stored, err = consumeUserToken(s.userTokenRepo, model.TokenPurposeChangeEmail, token)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if stored == nil {
+		return nil, ErrInvalidVerificationToken
+	}
+
+	user, err := s.userRepo.FindByID(stored.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	var notice *mailer.Message
+	if stored.Purpose == model.TokenPurposeChangeEmail {
+		if user.PendingEmail == "" {
+			return nil, ErrInvalidVerificationToken
+		}
+		if err := s.ensureEmailAvailable(user.ID, user.PendingEmail); err != nil {
+			return nil, err
+		}
+
+		notice = &mailer.Message{
+			To:      user.Email,
+			Subject: "Your email has been changed",
+			Body: fmt.Sprintf("Hello, %s!\n\nThe email address of your account has been changed to %s.\n",
+				user.Username, user.PendingEmail),
+		}
+		user.Email = user.PendingEmail
+		user.PendingEmail = ""
+	}
+
+	user.EmailVerified = true
+	if err := s.userRepo.Update(user); err != nil {
+		return nil, err
+	}
+	if notice != nil {
+		sendMailAsync(s.mailer, *notice)
+	}
+
+	return newUserResponse(user), nil
+}
+
+func (s *verificationService) ensureEmailAvailable(userID uint, email string) error {
+	existing, err := s.userRepo.FindByEmail(email)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if existing.ID != userID {
+		return ErrEmailExists
+	}
+	return nil
+}
+
+func (s *verificationService) verificationLink(token string) string {
+	return fmt.Sprintf("%s/verify-email?token=%s", s.publicURL, url.QueryEscape(token))
+}
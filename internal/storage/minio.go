@@ -13,12 +13,20 @@ import (
 
 type MinIOClient interface {
 	CreateBucket(bucketName string) error
-	PutObject(bucketName, objectName string, reader io.Reader, objectSize int64) (minio.UploadInfo, error)
+	PutObject(bucketName, objectName string, reader io.Reader, objectSize int64, contentType string) (minio.UploadInfo, error)
 	GetObject(bucketName, objectName string) (*minio.Object, error)
+	GetObjectRange(bucketName, objectName string, offset, length int64) (*minio.Object, error)
+	StatObject(bucketName, objectName string) (minio.ObjectInfo, error)
 	RemoveObject(bucketName, objectName string) error
+	RemoveObjectsWithPrefix(bucketName, prefix string) error
+	BucketSize(bucketName string) (int64, error)
 	PresignedGetObject(bucketName, objectName string, expiry time.Duration) (*url.URL, error)
 }
 
+// unknownSizePartSize - размер части multipart-загрузки потока неизвестной длины. Без него
+// minio-go выделяет буфер под максимальную часть (~544 МиБ) на каждую загрузку
+const unknownSizePartSize = 16 << 20
+
 type minioClient struct {
 	client *minio.Client
 }
@@ -68,16 +76,25 @@ func (m *minioClient) CreateBucket(bucketName string) error {
 	return nil
 }
 
-func (m *minioClient) PutObject(bucketName, objectName string, reader io.Reader, objectSize int64) (minio.UploadInfo, error) {
+// PutObject сохраняет объект с указанным Content-Type (по умолчанию application/octet-stream).
+// objectSize < 0 означает поток неизвестной длины
+func (m *minioClient) PutObject(bucketName, objectName string, reader io.Reader, objectSize int64, contentType string) (minio.UploadInfo, error) {
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	opts := minio.PutObjectOptions{ContentType: contentType}
+	if objectSize < 0 {
+		opts.PartSize = unknownSizePartSize
+	}
+
 	uploadInfo, err := m.client.PutObject(
 		context.Background(),
 		bucketName,
 		objectName,
 		reader,
 		objectSize,
-		minio.PutObjectOptions{
-			ContentType: "application/octet-stream",
-		},
+		opts,
 	)
 	return uploadInfo, err
 }
@@ -92,6 +109,31 @@ func (m *minioClient) GetObject(bucketName, objectName string) (*minio.Object, e
 	return object, err
 }
 
+func (m *minioClient) GetObjectRange(bucketName, objectName string, offset, length int64) (*minio.Object, error) {
+	opts := minio.GetObjectOptions{}
+	if err := opts.SetRange(offset, offset+length-1); err != nil {
+		return nil, err
+	}
+
+	object, err := m.client.GetObject(
+		context.Background(),
+		bucketName,
+		objectName,
+		opts,
+	)
+	return object, err
+}
+
+func (m *minioClient) StatObject(bucketName, objectName string) (minio.ObjectInfo, error) {
+	info, err := m.client.StatObject(
+		context.Background(),
+		bucketName,
+		objectName,
+		minio.StatObjectOptions{},
+	)
+	return info, err
+}
+
 func (m *minioClient) RemoveObject(bucketName, objectName string) error {
 	err := m.client.RemoveObject(
 		context.Background(),
@@ -102,6 +144,34 @@ func (m *minioClient) RemoveObject(bucketName, objectName string) error {
 	return err
 }
 
+func (m *minioClient) RemoveObjectsWithPrefix(bucketName, prefix string) error {
+	ctx := context.Background()
+	objects := m.client.ListObjects(ctx, bucketName, minio.ListObjectsOptions{
+		Prefix:    prefix,
+		Recursive: true,
+	})
+
+	var err error
+	for removeErr := range m.client.RemoveObjects(ctx, bucketName, objects, minio.RemoveObjectsOptions{}) {
+		if removeErr.Err != nil && err == nil {
+			err = removeErr.Err
+		}
+	}
+	return err
+}
+
+// BucketSize суммирует размеры всех объектов бакета
+func (m *minioClient) BucketSize(bucketName string) (int64, error) {
+	var size int64
+	for object := range m.client.ListObjects(context.Background(), bucketName, minio.ListObjectsOptions{Recursive: true}) {
+		if object.Err != nil {
+			return 0, object.Err
+		}
+		size += object.Size
+	}
+	return size, nil
+}
+
 func (m *minioClient) PresignedGetObject(bucketName, objectName string, expiry time.Duration) (*url.URL, error) {
 	url, err := m.client.PresignedGetObject(
 		context.Background(),
@@ -0,0 +1,187 @@
+// Package storagetest содержит хранилище в памяти для тестов кода, работающего с storage.MinIOClient
+package storagetest
+
+import (
+	"MusicService/internal/storage"
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+type object struct {
+	data         []byte
+	contentType  string
+	lastModified time.Time
+}
+
+// Memory хранит объекты в памяти. *minio.Object нельзя создать без клиента, поэтому чтение идет
+// настоящим клиентом minio-go через httptest-сервер, отдающий те же объекты
+type Memory struct {
+	mu      sync.Mutex
+	objects map[string]object
+	client  *minio.Client
+	server  *httptest.Server
+}
+
+var _ storage.MinIOClient = (*Memory)(nil)
+
+// NewMemory создает пустое хранилище; сервер останавливается по завершении теста
+func NewMemory(t testing.TB) *Memory {
+	t.Helper()
+
+	m := &Memory{objects: make(map[string]object)}
+	m.server = httptest.NewServer(http.HandlerFunc(m.serve))
+	t.Cleanup(m.server.Close)
+
+	client, err := minio.New(strings.TrimPrefix(m.server.URL, "http://"), &minio.Options{
+		Creds:  credentials.NewStaticV4("test", "test", ""),
+		Region: "us-east-1",
+	})
+	if err != nil {
+		t.Fatalf("storagetest: %v", err)
+	}
+	m.client = client
+
+	return m
+}
+
+func key(bucketName, objectName string) string {
+	return bucketName + "/" + objectName
+}
+
+func (m *Memory) lookup(name string) (object, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	obj, ok := m.objects[name]
+	return obj, ok
+}
+
+func (m *Memory) serve(w http.ResponseWriter, r *http.Request) {
+	obj, ok := m.lookup(strings.TrimPrefix(r.URL.Path, "/"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	sum := md5.Sum(obj.data)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+	w.Header().Set("Content-Type", obj.contentType)
+	http.ServeContent(w, r, "", obj.lastModified, bytes.NewReader(obj.data))
+}
+
+// Put сохраняет объект напрямую, минуя PutObject
+func (m *Memory) Put(bucketName, objectName string, data []byte, contentType string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects[key(bucketName, objectName)] = object{
+		data:         bytes.Clone(data),
+		contentType:  contentType,
+		lastModified: time.Now().UTC().Truncate(time.Second),
+	}
+}
+
+// Get возвращает содержимое объекта
+func (m *Memory) Get(bucketName, objectName string) ([]byte, bool) {
+	obj, ok := m.lookup(key(bucketName, objectName))
+	return obj.data, ok
+}
+
+func (m *Memory) CreateBucket(bucketName string) error {
+	return nil
+}
+
+func (m *Memory) PutObject(bucketName, objectName string, reader io.Reader, objectSize int64, contentType string) (minio.UploadInfo, error) {
+	if objectSize >= 0 {
+		reader = io.LimitReader(reader, objectSize)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return minio.UploadInfo{}, err
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	m.Put(bucketName, objectName, data, contentType)
+	return minio.UploadInfo{Bucket: bucketName, Key: objectName, Size: int64(len(data))}, nil
+}
+
+func (m *Memory) GetObject(bucketName, objectName string) (*minio.Object, error) {
+	return m.client.GetObject(context.Background(), bucketName, objectName, minio.GetObjectOptions{})
+}
+
+func (m *Memory) GetObjectRange(bucketName, objectName string, offset, length int64) (*minio.Object, error) {
+	opts := minio.GetObjectOptions{}
+	if err := opts.SetRange(offset, offset+length-1); err != nil {
+		return nil, err
+	}
+	return m.client.GetObject(context.Background(), bucketName, objectName, opts)
+}
+
+func (m *Memory) StatObject(bucketName, objectName string) (minio.ObjectInfo, error) {
+	obj, ok := m.lookup(key(bucketName, objectName))
+	if !ok {
+		return minio.ObjectInfo{}, minio.ErrorResponse{
+			StatusCode: http.StatusNotFound,
+			Code:       "NoSuchKey",
+			Message:    "The specified key does not exist.",
+			BucketName: bucketName,
+			Key:        objectName,
+		}
+	}
+
+	sum := md5.Sum(obj.data)
+	return minio.ObjectInfo{
+		Key:          objectName,
+		Size:         int64(len(obj.data)),
+		ETag:         hex.EncodeToString(sum[:]),
+		ContentType:  obj.contentType,
+		LastModified: obj.lastModified,
+	}, nil
+}
+
+func (m *Memory) RemoveObject(bucketName, objectName string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.objects, key(bucketName, objectName))
+	return nil
+}
+
+func (m *Memory) RemoveObjectsWithPrefix(bucketName, prefix string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for name := range m.objects {
+		if strings.HasPrefix(name, key(bucketName, prefix)) {
+			delete(m.objects, name)
+		}
+	}
+	return nil
+}
+
+func (m *Memory) BucketSize(bucketName string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var size int64
+	for name, obj := range m.objects {
+		if strings.HasPrefix(name, bucketName+"/") {
+			size += int64(len(obj.data))
+		}
+	}
+	return size, nil
+}
+
+func (m *Memory) PresignedGetObject(bucketName, objectName string, expiry time.Duration) (*url.URL, error) {
+	return url.Parse(m.server.URL + "/" + key(bucketName, objectName))
+}
@@ -0,0 +1,185 @@
+package audiometa
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported audio format")
+	ErrMalformed         = errors.New("malformed audio file")
+)
+
+type Format string
+
+const (
+	FormatMP3  Format = "mp3"
+	FormatFLAC Format = "flac"
+	FormatOgg  Format = "ogg"
+	FormatOpus Format = "opus"
+	FormatMP4  Format = "mp4"
+	FormatWAV  Format = "wav"
+)
+
+// Metadata содержит теги и технические параметры аудиофайла
+type Metadata struct {
+	Format Format
+
+	Title       string
+	Artist      string
+	Album       string
+	AlbumArtist string
+	Genre       string
+	Year        int
+	TrackNumber int
+	TrackTotal  int
+	DiscNumber  int
+	DiscTotal   int
+
+	Duration   time.Duration
+	BitRate    int // in kbps
+	SampleRate int // in Hz
+	Channels   int
+
+	Picture *Picture
+}
+
+// Picture - встроенная обложка (ID3 APIC, FLAC PICTURE, MP4 covr)
+type Picture struct {
+	MIMEType string
+	Data     []byte
+}
+
+// pictureTypeFrontCover - тип изображения "обложка" в APIC и FLAC PICTURE
+const pictureTypeFrontCover = 3
+
+// setPicture сохраняет обложку, отдавая предпочтение лицевой стороне
+func (m *Metadata) setPicture(mimeType string, pictureType int, data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	if m.Picture != nil && pictureType != pictureTypeFrontCover {
+		return
+	}
+
+	m.Picture = &Picture{
+		MIMEType: normalizePictureMIME(mimeType),
+		Data:     data,
+	}
+}
+
+// normalizePictureMIME приводит значения вида "JPG", "jpeg" или "image/jpg" к "image/jpeg"
+func normalizePictureMIME(mimeType string) string {
+	mimeType = strings.ToLower(strings.TrimSpace(mimeType))
+	if !strings.Contains(mimeType, "/") {
+		mimeType = "image/" + mimeType
+	}
+
+	switch mimeType {
+	case "image/jpg", "image/":
+		return "image/jpeg"
+	}
+	return mimeType
+}
+
+// Read определяет формат файла и извлекает из него теги и параметры потока.
+// Поддерживаются MP3 (ID3v1/ID3v2), FLAC, Ogg Vorbis, Opus, MP4/M4A и WAV (LIST INFO).
+func Read(r io.ReadSeeker) (*Metadata, error) {
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	head := make([]byte, 12)
+	n, err := io.ReadFull(r, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, err
+	}
+	head = head[:n]
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	var meta *Metadata
+	switch {
+	case bytes.HasPrefix(head, []byte("fLaC")):
+		meta, err = readFLAC(r, size)
+	case bytes.HasPrefix(head, []byte("OggS")):
+		meta, err = readOgg(r, size)
+	case len(head) >= 12 && string(head[:4]) == "RIFF" && string(head[8:12]) == "WAVE":
+		meta, err = readWAV(r, size)
+	case len(head) >= 8 && string(head[4:8]) == "ftyp":
+		meta, err = readMP4(r, size)
+	case bytes.HasPrefix(head, []byte("ID3")), isMPEGFrameSync(head):
+		meta, err = readMP3(r, size)
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if ms := int64(meta.Duration / time.Millisecond); meta.BitRate == 0 && ms > 0 {
+		meta.BitRate = int(size * 8 / ms)
+	}
+
+	return meta, nil
+}
+
+// setNumberPair разбирает значения вида "3" или "3/12"
+func setNumberPair(value string, number, total *int) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return
+	}
+
+	first, second, found := strings.Cut(value, "/")
+	if n, err := strconv.Atoi(strings.TrimSpace(first)); err == nil && n > 0 {
+		*number = n
+	}
+	if found {
+		if n, err := strconv.Atoi(strings.TrimSpace(second)); err == nil && n > 0 {
+			*total = n
+		}
+	}
+}
+
+// parseYear извлекает год из дат вида "1999", "1999-05-12" или "1999-05-12T00:00:00Z"
+func parseYear(value string) int {
+	value = strings.TrimSpace(value)
+	if len(value) < 4 {
+		return 0
+	}
+
+	year, err := strconv.Atoi(value[:4])
+	if err != nil || year <= 0 {
+		return 0
+	}
+	return year
+}
+
+func setIfEmpty(dst *string, value string) {
+	if *dst == "" {
+		*dst = strings.TrimSpace(value)
+	}
+}
+
+func setIfZero(dst *int, value int) {
+	if *dst == 0 {
+		*dst = value
+	}
+}
+
+func durationFromSamples(samples int64, sampleRate int) time.Duration {
+	if sampleRate <= 0 || samples <= 0 {
+		return 0
+	}
+	return time.Duration(samples) * time.Second / time.Duration(sampleRate)
+}
@@ -0,0 +1,121 @@
+package audiometa
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+const (
+	flacBlockStreamInfo    = 0
+	flacBlockVorbisComment = 4
+	flacBlockPicture       = 6
+)
+
+// readFLAC разбирает блоки метаданных FLAC: STREAMINFO, VORBIS_COMMENT и PICTURE
+func readFLAC(r io.ReadSeeker, size int64) (*Metadata, error) {
+	meta := &Metadata{Format: FormatFLAC}
+
+	if _, err := r.Seek(4, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	offset := int64(4)
+	header := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			return nil, ErrMalformed
+		}
+		last := header[0]&0x80 != 0
+		blockType := header[0] & 0x7F
+		length := int64(header[1])<<16 | int64(header[2])<<8 | int64(header[3])
+		offset += 4 + length
+		if offset > size {
+			return nil, ErrMalformed
+		}
+
+		switch blockType {
+		case flacBlockStreamInfo, flacBlockVorbisComment, flacBlockPicture:
+			block := make([]byte, length)
+			if _, err := io.ReadFull(r, block); err != nil {
+				return nil, ErrMalformed
+			}
+			switch blockType {
+			case flacBlockStreamInfo:
+				parseFLACStreamInfo(block, meta)
+			case flacBlockVorbisComment:
+				parseVorbisComment(block, meta)
+			default:
+				parseFLACPicture(block, meta)
+			}
+		default:
+			if _, err := r.Seek(length, io.SeekCurrent); err != nil {
+				return nil, err
+			}
+		}
+
+		if last {
+			break
+		}
+	}
+
+	if ms := int64(meta.Duration.Milliseconds()); ms > 0 {
+		meta.BitRate = int((size - offset) * 8 / ms)
+	}
+
+	return meta, nil
+}
+
+func parseFLACStreamInfo(block []byte, meta *Metadata) {
+	if len(block) < 18 {
+		return
+	}
+
+	// Байты 10-17: sample rate (20 бит), channels-1 (3 бита), bps-1 (5 бит), total samples (36 бит)
+	packed := binary.BigEndian.Uint64(block[10:18])
+	meta.SampleRate = int(packed >> 44)
+	meta.Channels = int((packed>>41)&0x07) + 1
+	totalSamples := int64(packed & 0xFFFFFFFFF)
+
+	meta.Duration = durationFromSamples(totalSamples, meta.SampleRate)
+}
+
+// parseFLACPicture разбирает блок PICTURE. Тот же формат используется в
+// комментарии METADATA_BLOCK_PICTURE в Ogg Vorbis и Opus.
+func parseFLACPicture(block []byte, meta *Metadata) {
+	readField := func() ([]byte, bool) {
+		if len(block) < 4 {
+			return nil, false
+		}
+		n := int(binary.BigEndian.Uint32(block))
+		if n < 0 || 4+n > len(block) {
+			return nil, false
+		}
+		field := block[4 : 4+n]
+		block = block[4+n:]
+		return field, true
+	}
+
+	if len(block) < 4 {
+		return
+	}
+	pictureType := int(binary.BigEndian.Uint32(block))
+	block = block[4:]
+
+	mimeType, ok := readField()
+	if !ok {
+		return
+	}
+	if _, ok := readField(); !ok { // description
+		return
+	}
+	if len(block) < 16 { // width, height, color depth, number of colors
+		return
+	}
+	block = block[16:]
+
+	data, ok := readField()
+	if !ok {
+		return
+	}
+	meta.setPicture(string(mimeType), pictureType, data)
+}
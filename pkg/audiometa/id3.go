@@ -0,0 +1,350 @@
+package audiometa
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+const (
+	id3v2HeaderSize = 10
+	id3v1Size       = 128
+)
+
+// id3v2Size возвращает полный размер тега ID3v2 в начале файла (0, если тега нет)
+func id3v2Size(header []byte) int64 {
+	if len(header) < id3v2HeaderSize || string(header[:3]) != "ID3" {
+		return 0
+	}
+
+	size := int64(synchsafe(header[6:10])) + id3v2HeaderSize
+	if header[5]&0x10 != 0 {
+		size += id3v2HeaderSize // footer
+	}
+	return size
+}
+
+// readID3v2 разбирает тег ID3v2.2/2.3/2.4 и заполняет meta
+func readID3v2(r io.ReadSeeker, meta *Metadata) (int64, error) {
+	header := make([]byte, id3v2HeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, err
+	}
+
+	tagSize := id3v2Size(header)
+	if tagSize == 0 {
+		return 0, nil
+	}
+
+	version := header[3]
+	flags := header[5]
+	body := make([]byte, synchsafe(header[6:10]))
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, ErrMalformed
+	}
+
+	if flags&0x80 != 0 && version < 4 {
+		body = removeUnsynchronisation(body)
+	}
+
+	if flags&0x40 != 0 && len(body) >= 4 {
+		var extSize int
+		if version == 4 {
+			extSize = synchsafe(body[:4])
+		} else {
+			extSize = int(binary.BigEndian.Uint32(body[:4])) + 4
+		}
+		if extSize > len(body) {
+			return tagSize, nil
+		}
+		body = body[extSize:]
+	}
+
+	parseID3v2Frames(body, version, meta)
+
+	return tagSize, nil
+}
+
+func parseID3v2Frames(body []byte, version byte, meta *Metadata) {
+	idLen, headerLen := 4, 10
+	if version == 2 {
+		idLen, headerLen = 3, 6
+	}
+
+	for len(body) >= headerLen {
+		id := string(body[:idLen])
+		if id[0] == 0 {
+			break // padding
+		}
+
+		var size int
+		var frameFlags uint16
+		switch version {
+		case 2:
+			size = int(body[3])<<16 | int(body[4])<<8 | int(body[5])
+		case 3:
+			size = int(binary.BigEndian.Uint32(body[4:8]))
+			frameFlags = binary.BigEndian.Uint16(body[8:10])
+		default:
+			size = synchsafe(body[4:8])
+			frameFlags = binary.BigEndian.Uint16(body[8:10])
+		}
+
+		if size <= 0 || headerLen+size > len(body) {
+			break
+		}
+		data := body[headerLen : headerLen+size]
+		body = body[headerLen+size:]
+
+		var ok bool
+		if data, ok = decodeFrameFlags(data, version, frameFlags); !ok {
+			continue
+		}
+
+		handleID3v2Frame(id, data, meta)
+	}
+}
+
+// decodeFrameFlags снимает с данных фрейма флаги формата; сжатые и зашифрованные фреймы пропускаются
+func decodeFrameFlags(data []byte, version byte, flags uint16) ([]byte, bool) {
+	switch version {
+	case 3:
+		if flags&0x00C0 != 0 {
+			return nil, false
+		}
+		if flags&0x0020 != 0 && len(data) > 0 {
+			data = data[1:] // grouping identity
+		}
+	case 4:
+		if flags&0x000C != 0 {
+			return nil, false
+		}
+		if flags&0x0040 != 0 && len(data) > 0 {
+			data = data[1:] // grouping identity
+		}
+		if flags&0x0001 != 0 && len(data) >= 4 {
+			data = data[4:] // data length indicator
+		}
+		if flags&0x0002 != 0 {
+			data = removeUnsynchronisation(data)
+		}
+	}
+	return data, true
+}
+
+func handleID3v2Frame(id string, data []byte, meta *Metadata) {
+	switch id {
+	case "TIT2", "TT2":
+		setIfEmpty(&meta.Title, decodeID3Text(data))
+	case "TPE1", "TP1":
+		setIfEmpty(&meta.Artist, decodeID3Text(data))
+	case "TALB", "TAL":
+		setIfEmpty(&meta.Album, decodeID3Text(data))
+	case "TPE2", "TP2":
+		setIfEmpty(&meta.AlbumArtist, decodeID3Text(data))
+	case "TCON", "TCO":
+		setIfEmpty(&meta.Genre, normalizeID3Genre(decodeID3Text(data)))
+	case "TRCK", "TRK":
+		setNumberPair(decodeID3Text(data), &meta.TrackNumber, &meta.TrackTotal)
+	case "TPOS", "TPA":
+		setNumberPair(decodeID3Text(data), &meta.DiscNumber, &meta.DiscTotal)
+	case "TYER", "TYE", "TDRC":
+		setIfZero(&meta.Year, parseYear(decodeID3Text(data)))
+	case "APIC":
+		parseAPIC(data, meta)
+	case "PIC":
+		parsePIC(data, meta)
+	case "TLEN", "TLE":
+		if ms, err := strconv.Atoi(decodeID3Text(data)); err == nil && ms > 0 && meta.Duration == 0 {
+			meta.Duration = time.Duration(ms) * time.Millisecond
+		}
+	}
+}
+
+// parseAPIC разбирает фрейм APIC (ID3v2.3/2.4): кодировка, MIME-тип, тип изображения, описание, данные
+func parseAPIC(data []byte, meta *Metadata) {
+	if len(data) < 4 {
+		return
+	}
+	encoding := data[0]
+
+	mimeType, rest, ok := bytes.Cut(data[1:], []byte{0})
+	if !ok || len(rest) < 1 {
+		return
+	}
+	pictureType := int(rest[0])
+
+	_, picture := decodeID3String(encoding, rest[1:])
+	meta.setPicture(string(mimeType), pictureType, picture)
+}
+
+// parsePIC разбирает фрейм PIC (ID3v2.2), в котором вместо MIME-типа трехбуквенный формат
+func parsePIC(data []byte, meta *Metadata) {
+	if len(data) < 6 {
+		return
+	}
+	encoding := data[0]
+	format := string(data[1:4])
+	pictureType := int(data[4])
+
+	_, picture := decodeID3String(encoding, data[5:])
+	meta.setPicture(format, pictureType, picture)
+}
+
+// decodeID3Text декодирует текстовый фрейм; из нескольких значений (ID3v2.4) берется первое
+func decodeID3Text(data []byte) string {
+	if len(data) == 0 {
+		return ""
+	}
+
+	text, _ := decodeID3String(data[0], data[1:])
+	return strings.TrimSpace(text)
+}
+
+// decodeID3String декодирует строку в указанной кодировке до терминатора
+// и возвращает остаток данных после него
+func decodeID3String(encoding byte, data []byte) (string, []byte) {
+	switch encoding {
+	case 1, 2: // UTF-16 с BOM, UTF-16BE
+		end := 0
+		for end+1 < len(data) && !(data[end] == 0 && data[end+1] == 0) {
+			end += 2
+		}
+		text := data[:min(end, len(data))]
+		rest := data[min(end+2, len(data)):]
+		return decodeUTF16(text, encoding == 2), rest
+	case 3: // UTF-8
+		text, rest, _ := bytes.Cut(data, []byte{0})
+		return string(text), rest
+	default: // ISO-8859-1
+		text, rest, _ := bytes.Cut(data, []byte{0})
+		return decodeLatin1(text), rest
+	}
+}
+
+func decodeUTF16(data []byte, bigEndian bool) string {
+	if len(data) >= 2 {
+		switch {
+		case data[0] == 0xFF && data[1] == 0xFE:
+			bigEndian = false
+			data = data[2:]
+		case data[0] == 0xFE && data[1] == 0xFF:
+			bigEndian = true
+			data = data[2:]
+		}
+	}
+
+	units := make([]uint16, 0, len(data)/2)
+	for i := 0; i+1 < len(data); i += 2 {
+		if bigEndian {
+			units = append(units, binary.BigEndian.Uint16(data[i:]))
+		} else {
+			units = append(units, binary.LittleEndian.Uint16(data[i:]))
+		}
+	}
+	return string(utf16.Decode(units))
+}
+
+func decodeLatin1(data []byte) string {
+	runes := make([]rune, len(data))
+	for i, b := range data {
+		runes[i] = rune(b)
+	}
+	return string(runes)
+}
+
+// normalizeID3Genre заменяет ссылки на жанры ID3v1 вида "(17)" или "17" их названиями
+func normalizeID3Genre(genre string) string {
+	genre = strings.TrimSpace(genre)
+	if strings.HasPrefix(genre, "(") {
+		if end := strings.Index(genre, ")"); end > 0 {
+			if rest := strings.TrimSpace(genre[end+1:]); rest != "" {
+				return rest
+			}
+			genre = genre[1:end]
+		}
+	}
+
+	if idx, err := strconv.Atoi(genre); err == nil {
+		if idx >= 0 && idx < len(id3v1Genres) {
+			return id3v1Genres[idx]
+		}
+		return ""
+	}
+	return genre
+}
+
+// readID3v1 разбирает тег ID3v1/ID3v1.1 в последних 128 байтах файла и
+// заполняет только поля, не найденные в ID3v2. Возвращает true, если тег найден.
+func readID3v1(r io.ReadSeeker, size int64, meta *Metadata) bool {
+	if size < id3v1Size {
+		return false
+	}
+	if _, err := r.Seek(size-id3v1Size, io.SeekStart); err != nil {
+		return false
+	}
+
+	tag := make([]byte, id3v1Size)
+	if _, err := io.ReadFull(r, tag); err != nil || string(tag[:3]) != "TAG" {
+		return false
+	}
+
+	field := func(b []byte) string {
+		b, _, _ = bytes.Cut(b, []byte{0})
+		return strings.TrimSpace(decodeLatin1(b))
+	}
+
+	setIfEmpty(&meta.Title, field(tag[3:33]))
+	setIfEmpty(&meta.Artist, field(tag[33:63]))
+	setIfEmpty(&meta.Album, field(tag[63:93]))
+	setIfZero(&meta.Year, parseYear(field(tag[93:97])))
+	if tag[125] == 0 && tag[126] != 0 {
+		setIfZero(&meta.TrackNumber, int(tag[126]))
+	}
+	if int(tag[127]) < len(id3v1Genres) {
+		setIfEmpty(&meta.Genre, id3v1Genres[tag[127]])
+	}
+
+	return true
+}
+
+func synchsafe(b []byte) int {
+	return int(b[0]&0x7F)<<21 | int(b[1]&0x7F)<<14 | int(b[2]&0x7F)<<7 | int(b[3]&0x7F)
+}
+
+func removeUnsynchronisation(data []byte) []byte {
+	out := make([]byte, 0, len(data))
+	for i := 0; i < len(data); i++ {
+		out = append(out, data[i])
+		if data[i] == 0xFF && i+1 < len(data) && data[i+1] == 0x00 {
+			i++
+		}
+	}
+	return out
+}
+
+var id3v1Genres = []string{
+	"Blues", "Classic Rock", "Country", "Dance", "Disco", "Funk", "Grunge", "Hip-Hop",
+	"Jazz", "Metal", "New Age", "Oldies", "Other", "Pop", "R&B", "Rap",
+	"Reggae", "Rock", "Techno", "Industrial", "Alternative", "Ska", "Death Metal", "Pranks",
+	"Soundtrack", "Euro-Techno", "Ambient", "Trip-Hop", "Vocal", "Jazz+Funk", "Fusion", "Trance",
+	"Classical", "Instrumental", "Acid", "House", "Game", "Sound Clip", "Gospel", "Noise",
+	"Alternative Rock", "Bass", "Soul", "Punk", "Space", "Meditative", "Instrumental Pop", "Instrumental Rock",
+	"Ethnic", "Gothic", "Darkwave", "Techno-Industrial", "Electronic", "Pop-Folk", "Eurodance", "Dream",
+	"Southern Rock", "Comedy", "Cult", "Gangsta", "Top 40", "Christian Rap", "Pop/Funk", "Jungle",
+	"Native American", "Cabaret", "New Wave", "Psychedelic", "Rave", "Showtunes", "Trailer", "Lo-Fi",
+	"Tribal", "Acid Punk", "Acid Jazz", "Polka", "Retro", "Musical", "Rock & Roll", "Hard Rock",
+	"Folk", "Folk-Rock", "National Folk", "Swing", "Fast Fusion", "Bebop", "Latin", "Revival",
+	"Celtic", "Bluegrass", "Avantgarde", "Gothic Rock", "Progressive Rock", "Psychedelic Rock", "Symphonic Rock", "Slow Rock",
+	"Big Band", "Chorus", "Easy Listening", "Acoustic", "Humour", "Speech", "Chanson", "Opera",
+	"Chamber Music", "Sonata", "Symphony", "Booty Bass", "Primus", "Porn Groove", "Satire", "Slow Jam",
+	"Club", "Tango", "Samba", "Folklore", "Ballad", "Power Ballad", "Rhythmic Soul", "Freestyle",
+	"Duet", "Punk Rock", "Drum Solo", "A Cappella", "Euro-House", "Dance Hall", "Goa", "Drum & Bass",
+	"Club-House", "Hardcore", "Terror", "Indie", "BritPop", "Afro-Punk", "Polsk Punk", "Beat",
+	"Christian Gangsta Rap", "Heavy Metal", "Black Metal", "Crossover", "Contemporary Christian", "Christian Rock", "Merengue", "Salsa",
+	"Thrash Metal", "Anime", "JPop", "Synthpop",
+}
@@ -0,0 +1,220 @@
+package audiometa
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"time"
+)
+
+// mp3ScanLimit ограничивает поиск первого MPEG-фрейма после тега ID3v2
+const mp3ScanLimit = 64 << 10
+
+type mpegFrame struct {
+	version    int // 1, 2 или 25 (MPEG 2.5)
+	layer      int
+	bitRate    int // in kbps
+	sampleRate int
+	padding    int
+	channels   int
+}
+
+var mpegBitRates = map[[2]int][16]int{
+	{1, 1}: {0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448, 0},
+	{1, 2}: {0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384, 0},
+	{1, 3}: {0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 0},
+	{2, 1}: {0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256, 0},
+	{2, 2}: {0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},
+	{2, 3}: {0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},
+}
+
+var mpegSampleRates = map[int][3]int{
+	1:  {44100, 48000, 32000},
+	2:  {22050, 24000, 16000},
+	25: {11025, 12000, 8000},
+}
+
+func isMPEGFrameSync(b []byte) bool {
+	_, ok := parseMPEGHeader(b)
+	return ok
+}
+
+func parseMPEGHeader(b []byte) (mpegFrame, bool) {
+	if len(b) < 4 || b[0] != 0xFF || b[1]&0xE0 != 0xE0 {
+		return mpegFrame{}, false
+	}
+
+	var f mpegFrame
+	switch (b[1] >> 3) & 0x03 {
+	case 0:
+		f.version = 25
+	case 2:
+		f.version = 2
+	case 3:
+		f.version = 1
+	default:
+		return mpegFrame{}, false
+	}
+
+	f.layer = 4 - int((b[1]>>1)&0x03)
+	if f.layer == 4 {
+		return mpegFrame{}, false
+	}
+
+	tableVersion := f.version
+	if tableVersion == 25 {
+		tableVersion = 2
+	}
+	f.bitRate = mpegBitRates[[2]int{tableVersion, f.layer}][b[2]>>4]
+	if f.bitRate == 0 {
+		return mpegFrame{}, false // free format и недопустимые значения не поддерживаются
+	}
+
+	srIndex := (b[2] >> 2) & 0x03
+	if srIndex == 3 {
+		return mpegFrame{}, false
+	}
+	f.sampleRate = mpegSampleRates[f.version][srIndex]
+	f.padding = int((b[2] >> 1) & 0x01)
+
+	f.channels = 2
+	if b[3]>>6 == 3 {
+		f.channels = 1
+	}
+
+	return f, true
+}
+
+func (f mpegFrame) samplesPerFrame() int {
+	switch {
+	case f.layer == 1:
+		return 384
+	case f.layer == 3 && f.version != 1:
+		return 576
+	default:
+		return 1152
+	}
+}
+
+func (f mpegFrame) length() int {
+	if f.layer == 1 {
+		return (12*f.bitRate*1000/f.sampleRate + f.padding) * 4
+	}
+	return f.samplesPerFrame()/8*f.bitRate*1000/f.sampleRate + f.padding
+}
+
+// sideInfoSize возвращает размер side information Layer III, после которого расположен заголовок Xing/Info
+func (f mpegFrame) sideInfoSize() int {
+	switch {
+	case f.version == 1 && f.channels == 1:
+		return 17
+	case f.version == 1:
+		return 32
+	case f.channels == 1:
+		return 9
+	default:
+		return 17
+	}
+}
+
+func readMP3(r io.ReadSeeker, size int64) (*Metadata, error) {
+	meta := &Metadata{Format: FormatMP3}
+
+	tagSize, err := readID3v2(r, meta)
+	if err != nil {
+		return nil, err
+	}
+
+	audioEnd := size
+	if readID3v1(r, size, meta) {
+		audioEnd -= id3v1Size
+	}
+
+	if _, err := r.Seek(tagSize, io.SeekStart); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, mp3ScanLimit)
+	n, err := io.ReadFull(r, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	buf = buf[:n]
+
+	offset, frame, ok := findFirstMPEGFrame(buf)
+	if !ok {
+		if meta.Title != "" || meta.Artist != "" {
+			return meta, nil
+		}
+		return nil, ErrMalformed
+	}
+
+	meta.SampleRate = frame.sampleRate
+	meta.Channels = frame.channels
+
+	audioStart := tagSize + int64(offset)
+	audioSize := audioEnd - audioStart
+
+	if frames, ok := vbrFrameCount(buf[offset:], frame); ok {
+		samples := int64(frames) * int64(frame.samplesPerFrame())
+		meta.Duration = durationFromSamples(samples, frame.sampleRate)
+		if ms := int64(meta.Duration / time.Millisecond); ms > 0 {
+			meta.BitRate = int(audioSize * 8 / ms)
+		}
+		return meta, nil
+	}
+
+	// CBR: длительность определяется размером аудиоданных
+	meta.BitRate = frame.bitRate
+	meta.Duration = time.Duration(audioSize*8) * time.Millisecond / time.Duration(frame.bitRate)
+
+	return meta, nil
+}
+
+// findFirstMPEGFrame ищет заголовок фрейма, за которым следует еще один корректный заголовок,
+// чтобы не принять за синхрослово случайные байты
+func findFirstMPEGFrame(buf []byte) (int, mpegFrame, bool) {
+	for i := 0; i+4 <= len(buf); i++ {
+		if buf[i] != 0xFF {
+			continue
+		}
+
+		frame, ok := parseMPEGHeader(buf[i:])
+		if !ok {
+			continue
+		}
+
+		next := i + frame.length()
+		if next+4 <= len(buf) {
+			if _, ok := parseMPEGHeader(buf[next:]); !ok {
+				continue
+			}
+		}
+
+		return i, frame, true
+	}
+
+	return 0, mpegFrame{}, false
+}
+
+// vbrFrameCount читает количество фреймов из заголовка Xing/Info или VBRI
+func vbrFrameCount(frameData []byte, frame mpegFrame) (uint32, bool) {
+	xing := 4 + frame.sideInfoSize()
+	if len(frameData) >= xing+12 {
+		tag := frameData[xing : xing+4]
+		if bytes.Equal(tag, []byte("Xing")) || bytes.Equal(tag, []byte("Info")) {
+			flags := binary.BigEndian.Uint32(frameData[xing+4:])
+			if flags&0x01 != 0 {
+				frames := binary.BigEndian.Uint32(frameData[xing+8:])
+				return frames, frames > 0
+			}
+		}
+	}
+
+	const vbri = 4 + 32
+	if len(frameData) >= vbri+18 && bytes.Equal(frameData[vbri:vbri+4], []byte("VBRI")) {
+		frames := binary.BigEndian.Uint32(frameData[vbri+14:])
+		return frames, frames > 0
+	}
+
+	return 0, false
+}
@@ -0,0 +1,275 @@
+package audiometa
+
+import (
+	"encoding/binary"
+	"io"
+	"strings"
+)
+
+const (
+	// mp4MaxMoovSize ограничивает размер атома moov, читаемого в память
+	mp4MaxMoovSize = 64 << 20
+	// mp4DataTypePNG - well-known type атома data для PNG (13 - JPEG)
+	mp4DataTypePNG = 14
+)
+
+type mp4Box struct {
+	typ  string
+	data []byte
+}
+
+// mp4Boxes разбирает последовательность атомов внутри data
+func mp4Boxes(data []byte) []mp4Box {
+	var boxes []mp4Box
+	for len(data) >= 8 {
+		size := int64(binary.BigEndian.Uint32(data))
+		typ := string(data[4:8])
+		headerSize := int64(8)
+
+		switch size {
+		case 0:
+			size = int64(len(data))
+		case 1:
+			if len(data) < 16 {
+				return boxes
+			}
+			size = int64(binary.BigEndian.Uint64(data[8:]))
+			headerSize = 16
+		}
+		if size < headerSize || size > int64(len(data)) {
+			return boxes
+		}
+
+		boxes = append(boxes, mp4Box{typ: typ, data: data[headerSize:size]})
+		data = data[size:]
+	}
+	return boxes
+}
+
+func mp4Find(data []byte, path ...string) []byte {
+	for _, name := range path {
+		found := false
+		for _, box := range mp4Boxes(data) {
+			if box.typ == name {
+				data = box.data
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil
+		}
+	}
+	return data
+}
+
+// readMP4Moov находит на верхнем уровне файла атом moov и читает его целиком
+func readMP4Moov(r io.ReadSeeker, size int64) ([]byte, error) {
+	offset := int64(0)
+	header := make([]byte, 16)
+	for offset+8 <= size {
+		if _, err := r.Seek(offset, io.SeekStart); err != nil {
+			return nil, err
+		}
+		if _, err := io.ReadFull(r, header[:8]); err != nil {
+			return nil, ErrMalformed
+		}
+
+		boxSize := int64(binary.BigEndian.Uint32(header))
+		typ := string(header[4:8])
+		headerSize := int64(8)
+		switch boxSize {
+		case 0:
+			boxSize = size - offset
+		case 1:
+			if _, err := io.ReadFull(r, header[8:16]); err != nil {
+				return nil, ErrMalformed
+			}
+			boxSize = int64(binary.BigEndian.Uint64(header[8:]))
+			headerSize = 16
+		}
+		if boxSize < headerSize || offset+boxSize > size {
+			return nil, ErrMalformed
+		}
+
+		if typ == "moov" {
+			if boxSize-headerSize > mp4MaxMoovSize {
+				return nil, ErrMalformed
+			}
+			moov := make([]byte, boxSize-headerSize)
+			if _, err := io.ReadFull(r, moov); err != nil {
+				return nil, ErrMalformed
+			}
+			return moov, nil
+		}
+
+		offset += boxSize
+	}
+
+	return nil, ErrMalformed
+}
+
+func readMP4(r io.ReadSeeker, size int64) (*Metadata, error) {
+	moov, err := readMP4Moov(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	meta := &Metadata{Format: FormatMP4}
+
+	if mvhd := mp4Find(moov, "mvhd"); len(mvhd) >= 20 {
+		var timescale uint32
+		var duration uint64
+		if mvhd[0] == 1 && len(mvhd) >= 32 {
+			timescale = binary.BigEndian.Uint32(mvhd[20:])
+			duration = binary.BigEndian.Uint64(mvhd[24:])
+		} else {
+			timescale = binary.BigEndian.Uint32(mvhd[12:])
+			duration = uint64(binary.BigEndian.Uint32(mvhd[16:]))
+		}
+		meta.Duration = durationFromSamples(int64(duration), int(timescale))
+	}
+
+	for _, trak := range mp4Boxes(moov) {
+		if trak.typ != "trak" {
+			continue
+		}
+		if parseMP4AudioTrack(trak.data, meta) {
+			break
+		}
+	}
+
+	if ilst := mp4Find(moov, "udta", "meta"); len(ilst) > 4 {
+		// meta - full box: перед дочерними атомами идут 4 байта версии и флагов
+		parseMP4Items(mp4Find(ilst[4:], "ilst"), meta)
+	}
+
+	return meta, nil
+}
+
+// parseMP4AudioTrack читает параметры звуковой дорожки из stsd и esds
+func parseMP4AudioTrack(trak []byte, meta *Metadata) bool {
+	hdlr := mp4Find(trak, "mdia", "hdlr")
+	if len(hdlr) < 12 || string(hdlr[8:12]) != "soun" {
+		return false
+	}
+
+	stsd := mp4Find(trak, "mdia", "minf", "stbl", "stsd")
+	if len(stsd) < 8 {
+		return true
+	}
+
+	entries := mp4Boxes(stsd[8:])
+	if len(entries) == 0 {
+		return true
+	}
+	entry := entries[0].data
+
+	// AudioSampleEntry: 6 reserved + 2 data_reference_index + 8 reserved,
+	// затем channelcount, samplesize, 4 байта, samplerate (16.16)
+	if len(entry) < 28 {
+		return true
+	}
+	meta.Channels = int(binary.BigEndian.Uint16(entry[16:]))
+	meta.SampleRate = int(binary.BigEndian.Uint32(entry[24:]) >> 16)
+
+	if esds := mp4Find(entry[28:], "esds"); len(esds) > 4 {
+		if avg := esdsAverageBitRate(esds[4:]); avg > 0 {
+			meta.BitRate = avg / 1000
+		}
+	}
+
+	return true
+}
+
+// esdsAverageBitRate ищет DecoderConfigDescriptor (тег 0x04) и возвращает avgBitrate
+func esdsAverageBitRate(data []byte) int {
+	for i := 0; i < len(data); {
+		tag := data[i]
+		i++
+
+		length := 0
+		for n := 0; n < 4 && i < len(data); n++ {
+			b := data[i]
+			i++
+			length = length<<7 | int(b&0x7F)
+			if b&0x80 == 0 {
+				break
+			}
+		}
+
+		switch tag {
+		case 0x03: // ES_Descriptor: ES_ID (2) + flags (1), далее вложенные дескрипторы
+			if i+3 > len(data) {
+				return 0
+			}
+			flags := data[i+2]
+			i += 3
+			if flags&0x80 != 0 {
+				i += 2
+			}
+			if flags&0x40 != 0 && i < len(data) {
+				i += 1 + int(data[i])
+			}
+			if flags&0x20 != 0 {
+				i += 2
+			}
+		case 0x04: // DecoderConfigDescriptor
+			if i+13 > len(data) {
+				return 0
+			}
+			return int(binary.BigEndian.Uint32(data[i+9:]))
+		default:
+			i += length
+		}
+	}
+	return 0
+}
+
+func parseMP4Items(ilst []byte, meta *Metadata) {
+	for _, item := range mp4Boxes(ilst) {
+		data := mp4Find(item.data, "data")
+		if len(data) < 8 {
+			continue
+		}
+		value := data[8:] // 4 байта типа значения + 4 байта локали
+
+		switch item.typ {
+		case "\xa9nam":
+			setIfEmpty(&meta.Title, string(value))
+		case "\xa9ART":
+			setIfEmpty(&meta.Artist, string(value))
+		case "\xa9alb":
+			setIfEmpty(&meta.Album, string(value))
+		case "aART":
+			setIfEmpty(&meta.AlbumArtist, string(value))
+		case "\xa9gen":
+			setIfEmpty(&meta.Genre, string(value))
+		case "gnre":
+			if len(value) >= 2 {
+				idx := int(binary.BigEndian.Uint16(value)) - 1
+				if idx >= 0 && idx < len(id3v1Genres) {
+					setIfEmpty(&meta.Genre, id3v1Genres[idx])
+				}
+			}
+		case "\xa9day":
+			setIfZero(&meta.Year, parseYear(strings.TrimSpace(string(value))))
+		case "trkn":
+			if len(value) >= 6 {
+				setIfZero(&meta.TrackNumber, int(binary.BigEndian.Uint16(value[2:])))
+				setIfZero(&meta.TrackTotal, int(binary.BigEndian.Uint16(value[4:])))
+			}
+		case "covr":
+			mimeType := "image/jpeg"
+			if binary.BigEndian.Uint32(data) == mp4DataTypePNG {
+				mimeType = "image/png"
+			}
+			meta.setPicture(mimeType, pictureTypeFrontCover, value)
+		case "disk":
+			if len(value) >= 6 {
+				setIfZero(&meta.DiscNumber, int(binary.BigEndian.Uint16(value[2:])))
+				setIfZero(&meta.DiscTotal, int(binary.BigEndian.Uint16(value[4:])))
+			}
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package audiometa
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+const (
+	oggPageHeaderSize = 27
+	// oggMaxPacketSize ограничивает размер собираемого пакета заголовков (обложки в комментариях бывают большими)
+	oggMaxPacketSize = 16 << 20
+	// oggTailSize - сколько байт с конца файла просматривается в поисках последней страницы
+	oggTailSize = 64 << 10
+	// opusSampleRate - частота, в которой Opus отсчитывает гранулы независимо от исходной
+	opusSampleRate = 48000
+)
+
+type oggPage struct {
+	granule  int64
+	serial   uint32
+	segments []byte
+	body     []byte
+}
+
+func readOggPage(r io.Reader) (*oggPage, error) {
+	header := make([]byte, oggPageHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	if string(header[:4]) != "OggS" {
+		return nil, ErrMalformed
+	}
+
+	page := &oggPage{
+		granule:  int64(binary.LittleEndian.Uint64(header[6:14])),
+		serial:   binary.LittleEndian.Uint32(header[14:18]),
+		segments: make([]byte, header[26]),
+	}
+	if _, err := io.ReadFull(r, page.segments); err != nil {
+		return nil, err
+	}
+
+	bodySize := 0
+	for _, s := range page.segments {
+		bodySize += int(s)
+	}
+	page.body = make([]byte, bodySize)
+	if _, err := io.ReadFull(r, page.body); err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
+
+// readOggPackets собирает первые count пакетов первого логического потока
+func readOggPackets(r io.Reader, count int) ([][]byte, uint32, error) {
+	var (
+		packets [][]byte
+		current []byte
+		serial  uint32
+		first   = true
+	)
+
+	for len(packets) < count {
+		page, err := readOggPage(r)
+		if err != nil {
+			return nil, 0, ErrMalformed
+		}
+		if first {
+			serial = page.serial
+			first = false
+		} else if page.serial != serial {
+			continue
+		}
+
+		pos := 0
+		for _, seg := range page.segments {
+			current = append(current, page.body[pos:pos+int(seg)]...)
+			pos += int(seg)
+			if len(current) > oggMaxPacketSize {
+				return nil, 0, ErrMalformed
+			}
+			// Сегмент короче 255 байт завершает пакет
+			if seg < 255 {
+				packets = append(packets, current)
+				current = nil
+				if len(packets) == count {
+					break
+				}
+			}
+		}
+	}
+
+	return packets, serial, nil
+}
+
+// lastOggGranule находит позицию гранулы последней страницы потока serial
+func lastOggGranule(r io.ReadSeeker, size int64, serial uint32) int64 {
+	start := max(size-oggTailSize, 0)
+	if _, err := r.Seek(start, io.SeekStart); err != nil {
+		return 0
+	}
+
+	tail := make([]byte, size-start)
+	if _, err := io.ReadFull(r, tail); err != nil {
+		return 0
+	}
+
+	for i := bytes.LastIndex(tail, []byte("OggS")); i >= 0; i = bytes.LastIndex(tail[:i], []byte("OggS")) {
+		if i+oggPageHeaderSize > len(tail) {
+			continue
+		}
+		if binary.LittleEndian.Uint32(tail[i+14:]) != serial {
+			continue
+		}
+		if granule := int64(binary.LittleEndian.Uint64(tail[i+6:])); granule > 0 {
+			return granule
+		}
+	}
+
+	return 0
+}
+
+func readOgg(r io.ReadSeeker, size int64) (*Metadata, error) {
+	packets, serial, err := readOggPackets(r, 2)
+	if err != nil {
+		return nil, err
+	}
+	id, comments := packets[0], packets[1]
+
+	meta := &Metadata{}
+	granule := lastOggGranule(r, size, serial)
+
+	switch {
+	case bytes.HasPrefix(id, []byte("\x01vorbis")) && len(id) >= 30:
+		meta.Format = FormatOgg
+		meta.Channels = int(id[11])
+		meta.SampleRate = int(binary.LittleEndian.Uint32(id[12:16]))
+		if nominal := int32(binary.LittleEndian.Uint32(id[20:24])); nominal > 0 {
+			meta.BitRate = int(nominal) / 1000
+		}
+		meta.Duration = durationFromSamples(granule, meta.SampleRate)
+		if bytes.HasPrefix(comments, []byte("\x03vorbis")) {
+			parseVorbisComment(comments[7:], meta)
+		}
+
+	case bytes.HasPrefix(id, []byte("OpusHead")) && len(id) >= 19:
+		meta.Format = FormatOpus
+		meta.Channels = int(id[9])
+		preSkip := int64(binary.LittleEndian.Uint16(id[10:12]))
+		meta.SampleRate = int(binary.LittleEndian.Uint32(id[12:16]))
+		if meta.SampleRate == 0 {
+			meta.SampleRate = opusSampleRate
+		}
+		meta.Duration = durationFromSamples(granule-preSkip, opusSampleRate)
+		if bytes.HasPrefix(comments, []byte("OpusTags")) {
+			parseVorbisComment(comments[8:], meta)
+		}
+
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+
+	return meta, nil
+}
@@ -0,0 +1,67 @@
+package audiometa
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"strings"
+)
+
+// parseVorbisComment разбирает блок Vorbis comment (FLAC, Ogg Vorbis, Opus).
+// Все длины в блоке записаны в little-endian.
+func parseVorbisComment(data []byte, meta *Metadata) {
+	if len(data) < 4 {
+		return
+	}
+	vendorLen := int(binary.LittleEndian.Uint32(data))
+	if 4+vendorLen+4 > len(data) {
+		return
+	}
+	data = data[4+vendorLen:]
+
+	count := int(binary.LittleEndian.Uint32(data))
+	data = data[4:]
+
+	for i := 0; i < count && len(data) >= 4; i++ {
+		length := int(binary.LittleEndian.Uint32(data))
+		if length < 0 || 4+length > len(data) {
+			return
+		}
+		comment := string(data[4 : 4+length])
+		data = data[4+length:]
+
+		key, value, ok := strings.Cut(comment, "=")
+		if !ok {
+			continue
+		}
+		handleVorbisField(strings.ToUpper(key), value, meta)
+	}
+}
+
+func handleVorbisField(key, value string, meta *Metadata) {
+	switch key {
+	case "TITLE":
+		setIfEmpty(&meta.Title, value)
+	case "ARTIST":
+		setIfEmpty(&meta.Artist, value)
+	case "ALBUM":
+		setIfEmpty(&meta.Album, value)
+	case "ALBUMARTIST", "ALBUM ARTIST", "ALBUM_ARTIST":
+		setIfEmpty(&meta.AlbumArtist, value)
+	case "GENRE":
+		setIfEmpty(&meta.Genre, value)
+	case "DATE", "YEAR":
+		setIfZero(&meta.Year, parseYear(value))
+	case "TRACKNUMBER":
+		setNumberPair(value, &meta.TrackNumber, &meta.TrackTotal)
+	case "TRACKTOTAL", "TOTALTRACKS":
+		setNumberPair(value, &meta.TrackTotal, new(int))
+	case "DISCNUMBER":
+		setNumberPair(value, &meta.DiscNumber, &meta.DiscTotal)
+	case "DISCTOTAL", "TOTALDISCS":
+		setNumberPair(value, &meta.DiscTotal, new(int))
+	case "METADATA_BLOCK_PICTURE":
+		if block, err := base64.StdEncoding.DecodeString(value); err == nil {
+			parseFLACPicture(block, meta)
+		}
+	}
+}
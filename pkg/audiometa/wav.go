@@ -0,0 +1,93 @@
+package audiometa
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"time"
+)
+
+// readWAV разбирает чанки RIFF/WAVE: fmt, data и LIST INFO
+func readWAV(r io.ReadSeeker, size int64) (*Metadata, error) {
+	meta := &Metadata{Format: FormatWAV}
+
+	if _, err := r.Seek(12, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	var byteRate, dataSize int64
+	offset := int64(12)
+	header := make([]byte, 8)
+	for offset+8 <= size {
+		if _, err := io.ReadFull(r, header); err != nil {
+			break
+		}
+		id := string(header[:4])
+		length := int64(binary.LittleEndian.Uint32(header[4:]))
+		next := offset + 8 + length + length%2 // чанки выравниваются по 2 байтам
+
+		switch id {
+		case "fmt ", "LIST":
+			if length > 1<<20 {
+				return nil, ErrMalformed
+			}
+			chunk := make([]byte, length)
+			if _, err := io.ReadFull(r, chunk); err != nil {
+				return nil, ErrMalformed
+			}
+			if id == "LIST" {
+				parseRIFFInfo(chunk, meta)
+			} else if len(chunk) >= 12 {
+				meta.Channels = int(binary.LittleEndian.Uint16(chunk[2:]))
+				meta.SampleRate = int(binary.LittleEndian.Uint32(chunk[4:]))
+				byteRate = int64(binary.LittleEndian.Uint32(chunk[8:]))
+			}
+		case "data":
+			dataSize = min(length, size-offset-8)
+		}
+
+		offset = next
+		if _, err := r.Seek(offset, io.SeekStart); err != nil {
+			return nil, err
+		}
+	}
+
+	if byteRate > 0 {
+		meta.BitRate = int(byteRate * 8 / 1000)
+		meta.Duration = time.Duration(dataSize) * time.Second / time.Duration(byteRate)
+	}
+
+	return meta, nil
+}
+
+func parseRIFFInfo(chunk []byte, meta *Metadata) {
+	if len(chunk) < 4 || string(chunk[:4]) != "INFO" {
+		return
+	}
+	chunk = chunk[4:]
+
+	for len(chunk) >= 8 {
+		id := string(chunk[:4])
+		length := int(binary.LittleEndian.Uint32(chunk[4:]))
+		if length < 0 || 8+length > len(chunk) {
+			return
+		}
+		value, _, _ := bytes.Cut(chunk[8:8+length], []byte{0})
+		chunk = chunk[min(8+length+length%2, len(chunk)):]
+
+		switch id {
+		case "INAM":
+			setIfEmpty(&meta.Title, string(value))
+		case "IART":
+			setIfEmpty(&meta.Artist, string(value))
+		case "IPRD":
+			setIfEmpty(&meta.Album, string(value))
+		case "IGNR":
+			setIfEmpty(&meta.Genre, string(value))
+		case "ICRD":
+			setIfZero(&meta.Year, parseYear(string(value)))
+		case "ITRK", "IPRT":
+			setNumberPair(string(value), &meta.TrackNumber, &meta.TrackTotal)
+		}
+	}
+}
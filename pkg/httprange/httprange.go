@@ -0,0 +1,146 @@
+package httprange
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidRange        = errors.New("invalid range")
+	ErrRangeNotSatisfiable = errors.New("range not satisfiable")
+)
+
+// Range описывает один диапазон байт (RFC 7233) в пределах ресурса
+type Range struct {
+	Start  int64
+	Length int64
+}
+
+func (r Range) End() int64 {
+	return r.Start + r.Length - 1
+}
+
+func (r Range) ContentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", r.Start, r.End(), size)
+}
+
+// Parse разбирает заголовок Range для ресурса размером size.
+// Диапазоны, целиком лежащие за концом ресурса, отбрасываются; если не осталось ни одного,
+// возвращается ErrRangeNotSatisfiable. Синтаксические ошибки возвращают ErrInvalidRange,
+// и в этом случае заголовок следует игнорировать и отдавать ресурс целиком.
+func Parse(header string, size int64) ([]Range, error) {
+	if header == "" {
+		return nil, nil
+	}
+
+	const prefix = "bytes="
+	if !strings.HasPrefix(header, prefix) {
+		return nil, ErrInvalidRange
+	}
+
+	var ranges []Range
+	noOverlap := false
+	for _, spec := range strings.Split(header[len(prefix):], ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+
+		startStr, endStr, ok := strings.Cut(spec, "-")
+		if !ok {
+			return nil, ErrInvalidRange
+		}
+		startStr, endStr = strings.TrimSpace(startStr), strings.TrimSpace(endStr)
+
+		var r Range
+		if startStr == "" {
+			// suffix-byte-range-spec: последние N байт
+			if endStr == "" {
+				return nil, ErrInvalidRange
+			}
+			n, err := strconv.ParseInt(endStr, 10, 64)
+			if err != nil || n < 0 {
+				return nil, ErrInvalidRange
+			}
+			if n == 0 || size == 0 {
+				noOverlap = true
+				continue
+			}
+			if n > size {
+				n = size
+			}
+			r.Start = size - n
+			r.Length = n
+		} else {
+			start, err := strconv.ParseInt(startStr, 10, 64)
+			if err != nil || start < 0 {
+				return nil, ErrInvalidRange
+			}
+			if start >= size {
+				noOverlap = true
+				continue
+			}
+			r.Start = start
+
+			if endStr == "" {
+				r.Length = size - start
+			} else {
+				end, err := strconv.ParseInt(endStr, 10, 64)
+				if err != nil || start > end {
+					return nil, ErrInvalidRange
+				}
+				if end >= size {
+					end = size - 1
+				}
+				r.Length = end - start + 1
+			}
+		}
+
+		ranges = append(ranges, r)
+	}
+
+	if len(ranges) == 0 {
+		if noOverlap {
+			return nil, ErrRangeNotSatisfiable
+		}
+		return nil, ErrInvalidRange
+	}
+
+	return ranges, nil
+}
+
+// SumLength возвращает суммарную длину всех диапазонов
+func SumLength(ranges []Range) int64 {
+	var n int64
+	for _, r := range ranges {
+		n += r.Length
+	}
+	return n
+}
+
+// IfRangeMatches проверяет условие If-Range (RFC 7233, раздел 3.2).
+// Условие выполняется, если заголовок отсутствует, совпадает с сильным ETag ресурса
+// или содержит дату, точно совпадающую с Last-Modified.
+func IfRangeMatches(ifRange, etag string, lastModified time.Time) bool {
+	if ifRange == "" {
+		return true
+	}
+
+	if strings.HasPrefix(ifRange, `"`) || strings.HasPrefix(ifRange, "W/") {
+		// Для If-Range допустимо только сильное сравнение
+		if strings.HasPrefix(ifRange, "W/") || strings.HasPrefix(etag, "W/") {
+			return false
+		}
+		return etag != "" && ifRange == etag
+	}
+
+	t, err := http.ParseTime(ifRange)
+	if err != nil || lastModified.IsZero() {
+		return false
+	}
+	return lastModified.Truncate(time.Second).Equal(t)
+}
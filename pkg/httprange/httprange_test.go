@@ -0,0 +1,142 @@
+package httprange
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	const size = 1000
+
+	tests := []struct {
+		name    string
+		header  string
+		want    []Range
+		wantErr error
+	}{
+		{name: "no header", header: ""},
+		{name: "closed", header: "bytes=0-499", want: []Range{{Start: 0, Length: 500}}},
+		{name: "probe", header: "bytes=0-1", want: []Range{{Start: 0, Length: 2}}},
+		{name: "end past size is clamped", header: "bytes=900-5000", want: []Range{{Start: 900, Length: 100}}},
+		{name: "open-ended", header: "bytes=0-", want: []Range{{Start: 0, Length: size}}},
+		{name: "open-ended from middle", header: "bytes=600-", want: []Range{{Start: 600, Length: 400}}},
+		{name: "open-ended last byte", header: "bytes=999-", want: []Range{{Start: 999, Length: 1}}},
+		{name: "suffix", header: "bytes=-100", want: []Range{{Start: 900, Length: 100}}},
+		{name: "suffix longer than resource", header: "bytes=-5000", want: []Range{{Start: 0, Length: size}}},
+		{name: "spaces", header: "bytes= 0 - 9 , -10", want: []Range{{Start: 0, Length: 10}, {Start: 990, Length: 10}}},
+		{
+			name:   "multiple",
+			header: "bytes=0-99,200-299,-50",
+			want:   []Range{{Start: 0, Length: 100}, {Start: 200, Length: 100}, {Start: 950, Length: 50}},
+		},
+		{
+			// Перекрывающиеся диапазоны не объединяются; отказ от них - решение вызывающего кода по SumLength
+			name:   "overlapping",
+			header: "bytes=0-599,500-999,-700",
+			want:   []Range{{Start: 0, Length: 600}, {Start: 500, Length: 500}, {Start: 300, Length: 700}},
+		},
+		{name: "unsatisfiable ranges dropped", header: "bytes=2000-3000,0-9", want: []Range{{Start: 0, Length: 10}}},
+		{name: "start at size", header: "bytes=1000-", wantErr: ErrRangeNotSatisfiable},
+		{name: "start past size", header: "bytes=2000-3000", wantErr: ErrRangeNotSatisfiable},
+		{name: "zero suffix", header: "bytes=-0", wantErr: ErrRangeNotSatisfiable},
+		{name: "all unsatisfiable", header: "bytes=1000-1100,-0", wantErr: ErrRangeNotSatisfiable},
+		{name: "other unit", header: "items=0-9", wantErr: ErrInvalidRange},
+		{name: "no dash", header: "bytes=100", wantErr: ErrInvalidRange},
+		{name: "only dash", header: "bytes=-", wantErr: ErrInvalidRange},
+		{name: "start after end", header: "bytes=500-100", wantErr: ErrInvalidRange},
+		{name: "negative start", header: "bytes=--5", wantErr: ErrInvalidRange},
+		{name: "not a number", header: "bytes=a-b", wantErr: ErrInvalidRange},
+		{name: "empty set", header: "bytes=,", wantErr: ErrInvalidRange},
+		{name: "invalid after valid", header: "bytes=0-9,x-", wantErr: ErrInvalidRange},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.header, size)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Parse(%q) error = %v, want %v", tt.header, err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Parse(%q) = %v, want %v", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseEmptyResource(t *testing.T) {
+	for _, header := range []string{"bytes=0-", "bytes=0-0", "bytes=-10"} {
+		if _, err := Parse(header, 0); !errors.Is(err, ErrRangeNotSatisfiable) {
+			t.Errorf("Parse(%q, 0) error = %v, want %v", header, err, ErrRangeNotSatisfiable)
+		}
+	}
+}
+
+func TestRangeHelpers(t *testing.T) {
+	r := Range{Start: 900, Length: 100}
+	if r.End() != 999 {
+		t.Errorf("End() = %d, want 999", r.End())
+	}
+	if got := r.ContentRange(1000); got != "bytes 900-999/1000" {
+		t.Errorf("ContentRange() = %q", got)
+	}
+
+	ranges, err := Parse("bytes=0-599,500-999", 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := SumLength(ranges); got != 1100 {
+		t.Errorf("SumLength() = %d, want 1100", got)
+	}
+}
+
+func TestIfRangeMatches(t *testing.T) {
+	const etag = `"5d41402abc4b2a76b9719d911017c592"`
+	modified := time.Date(2024, time.March, 10, 12, 30, 45, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		ifRange      string
+		etag         string
+		lastModified time.Time
+		want         bool
+	}{
+		{name: "no header", ifRange: "", etag: etag, lastModified: modified, want: true},
+		{name: "etag match", ifRange: etag, etag: etag, lastModified: modified, want: true},
+		{name: "etag mismatch", ifRange: `"other"`, etag: etag, lastModified: modified},
+		{name: "unquoted etag", ifRange: "5d41402abc4b2a76b9719d911017c592", etag: etag, lastModified: modified},
+		{name: "weak validator", ifRange: "W/" + etag, etag: etag, lastModified: modified},
+		{name: "weak resource etag", ifRange: `W/"abc"`, etag: `W/"abc"`, lastModified: modified},
+		{name: "resource without etag", ifRange: `""`, etag: "", lastModified: modified},
+		{name: "date match", ifRange: modified.Format(http.TimeFormat), etag: etag, lastModified: modified, want: true},
+		{
+			// Last-Modified отдается с точностью до секунды, поэтому доли секунды не мешают совпадению
+			name:         "date match with fractional seconds",
+			ifRange:      modified.Format(http.TimeFormat),
+			etag:         etag,
+			lastModified: modified.Add(300 * time.Millisecond),
+			want:         true,
+		},
+		{
+			name:         "date in another zone",
+			ifRange:      modified.Format(http.TimeFormat),
+			lastModified: modified.In(time.FixedZone("MSK", 3*60*60)),
+			want:         true,
+		},
+		{name: "RFC 850 date", ifRange: modified.Format(time.RFC850), lastModified: modified, want: true},
+		{name: "date before change", ifRange: modified.Add(-time.Second).Format(http.TimeFormat), lastModified: modified},
+		{name: "date after change", ifRange: modified.Add(time.Hour).Format(http.TimeFormat), lastModified: modified},
+		{name: "date without Last-Modified", ifRange: modified.Format(http.TimeFormat), etag: etag},
+		{name: "garbage", ifRange: "yesterday", etag: etag, lastModified: modified},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IfRangeMatches(tt.ifRange, tt.etag, tt.lastModified); got != tt.want {
+				t.Errorf("IfRangeMatches(%q, %q, %v) = %v, want %v", tt.ifRange, tt.etag, tt.lastModified, got, tt.want)
+			}
+		})
+	}
+}
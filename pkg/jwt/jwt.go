@@ -13,30 +13,42 @@ var (
 )
 
 type JWTService interface {
-	GenerateToken(userID uint) (string, error)
+	GenerateToken(userID uint, role, sessionID string) (string, error)
+	TokenTTL() time.Duration
 	ValidateToken(tokenString string) (*Claims, error)
 }
 
 type Claims struct {
-	UserID uint `json:"user_id"`
+	UserID    uint   `json:"user_id"`
+	Role      string `json:"role"`
+	SessionID string `json:"sid"`
 	jwt.RegisteredClaims
 }
 
 type jwtService struct {
 	secretKey []byte
+	tokenTTL  time.Duration
 }
 
-func NewJWTService(secretKey string) JWTService {
+// NewJWTService создает сервис токенов доступа со временем жизни tokenTTL
+func NewJWTService(secretKey string, tokenTTL time.Duration) JWTService {
 	return &jwtService{
 		secretKey: []byte(secretKey),
+		tokenTTL:  tokenTTL,
 	}
 }
 
-func (s *jwtService) GenerateToken(userID uint) (string, error) {
+func (s *jwtService) TokenTTL() time.Duration {
+	return s.tokenTTL
+}
+
+func (s *jwtService) GenerateToken(userID uint, role, sessionID string) (string, error) {
 	claims := &Claims{
-		UserID: userID,
+		UserID:    userID,
+		Role:      role,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)), // Токен действителен 24 часа
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(s.tokenTTL)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
 			Issuer:    "music-service",
@@ -0,0 +1,95 @@
+package oidc_test
+
+import (
+	"MusicService/pkg/oidc"
+	"MusicService/pkg/oidc/oidctest"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type login struct {
+	provider *oidc.Provider
+	nonce    string
+	verifier string
+	code     string
+	state    string
+}
+
+func startLogin(t *testing.T, mock *oidctest.Provider, identity oidctest.Identity) login {
+	t.Helper()
+
+	provider, err := oidc.Discover(context.Background(), mock.Config(), nil)
+	if err != nil {
+		t.Fatalf("Discover: %v", err)
+	}
+
+	l := login{provider: provider}
+	if l.nonce, err = oidc.NewState(); err != nil {
+		t.Fatal(err)
+	}
+	if l.verifier, err = oidc.NewVerifier(); err != nil {
+		t.Fatal(err)
+	}
+	authURL := provider.AuthCodeURL("state", l.nonce, l.verifier)
+	l.code, l.state = mock.Authorize(t, authURL, identity)
+	return l
+}
+
+func TestExchange(t *testing.T) {
+	mock := oidctest.NewProvider(t)
+	identity := oidctest.Identity{Subject: "sub-1", Email: "alice@example.com", EmailVerified: true}
+	l := startLogin(t, mock, identity)
+
+	if l.state != "state" {
+		t.Errorf("state = %q, want %q", l.state, "state")
+	}
+
+	claims, err := l.provider.Exchange(context.Background(), l.code, l.verifier, l.nonce)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if claims.Subject != identity.Subject || claims.Email != identity.Email || !claims.EmailVerified {
+		t.Errorf("claims = %+v, want identity %+v", claims, identity)
+	}
+
+	// Код авторизации одноразовый
+	if _, err := l.provider.Exchange(context.Background(), l.code, l.verifier, l.nonce); err == nil {
+		t.Error("second Exchange with the same code succeeded")
+	}
+}
+
+func TestExchangeNonceMismatch(t *testing.T) {
+	mock := oidctest.NewProvider(t)
+	l := startLogin(t, mock, oidctest.Identity{Subject: "sub-1"})
+
+	_, err := l.provider.Exchange(context.Background(), l.code, l.verifier, "other-nonce")
+	if !errors.Is(err, oidc.ErrInvalidIDToken) || !strings.Contains(err.Error(), "nonce mismatch") {
+		t.Fatalf("Exchange error = %v, want nonce mismatch", err)
+	}
+}
+
+func TestExchangePKCEMismatch(t *testing.T) {
+	mock := oidctest.NewProvider(t)
+	l := startLogin(t, mock, oidctest.Identity{Subject: "sub-1"})
+
+	otherVerifier, err := oidc.NewVerifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = l.provider.Exchange(context.Background(), l.code, otherVerifier, l.nonce)
+	if err == nil || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Fatalf("Exchange error = %v, want invalid_grant", err)
+	}
+}
+
+func TestDiscoverIssuerMismatch(t *testing.T) {
+	mock := oidctest.NewProvider(t)
+	config := mock.Config()
+	config.Issuer += "/"
+
+	if _, err := oidc.Discover(context.Background(), config, nil); err == nil {
+		t.Fatal("Discover succeeded for a mismatched issuer")
+	}
+}
@@ -0,0 +1,191 @@
+// Package oidctest содержит провайдера OpenID Connect на httptest-сервере для тестов входа через oidc
+package oidctest
+
+import (
+	"MusicService/pkg/oidc"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	ClientID    = "music-service"
+	RedirectURL = "http://music.test/auth/oidc/callback"
+	keyID       = "test-key"
+)
+
+// Identity - пользователь, который входит на странице провайдера
+type Identity struct {
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	PreferredUsername string
+}
+
+// grant - выданный код авторизации вместе с параметрами запроса, к которому он относится
+type grant struct {
+	challenge string
+	nonce     string
+	identity  Identity
+}
+
+// Provider отдает discovery, JWKS и token endpoint. Token endpoint проверяет code_verifier
+// по code_challenge из запроса авторизации и выдает ID токен с nonce этого запроса
+type Provider struct {
+	Issuer string
+
+	key    *rsa.PrivateKey
+	server *httptest.Server
+	mu     sync.Mutex
+	grants map[string]grant
+}
+
+// NewProvider запускает провайдера; сервер останавливается по завершении теста
+func NewProvider(t testing.TB) *Provider {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &Provider{key: key, grants: make(map[string]grant)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("GET /jwks", p.jwks)
+	mux.HandleFunc("POST /token", p.token)
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+
+	p.Issuer = p.server.URL
+	return p
+}
+
+// Config возвращает настройки клиента для этого провайдера
+func (p *Provider) Config() oidc.Config {
+	return oidc.Config{Issuer: p.Issuer, ClientID: ClientID, RedirectURL: RedirectURL}
+}
+
+// Authorize имитирует вход identity на странице провайдера по адресу authURL и возвращает
+// код авторизации и state, с которыми провайдер перенаправил бы пользователя на callback
+func (p *Provider) Authorize(t testing.TB, authURL string, identity Identity) (code, state string) {
+	t.Helper()
+
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := u.Query()
+	if query.Get("client_id") != ClientID || query.Get("code_challenge_method") != "S256" {
+		t.Fatalf("unexpected authorization request: %s", authURL)
+	}
+
+	code, err = oidc.NewState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.mu.Lock()
+	p.grants[code] = grant{
+		challenge: query.Get("code_challenge"),
+		nonce:     query.Get("nonce"),
+		identity:  identity,
+	}
+	p.mu.Unlock()
+
+	return code, query.Get("state")
+}
+
+func (p *Provider) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{
+		"issuer":                 p.Issuer,
+		"authorization_endpoint": p.Issuer + "/authorize",
+		"token_endpoint":         p.Issuer + "/token",
+		"jwks_uri":               p.Issuer + "/jwks",
+	})
+}
+
+func (p *Provider) jwks(w http.ResponseWriter, r *http.Request) {
+	public := p.key.PublicKey
+	writeJSON(w, http.StatusOK, map[string]any{
+		"keys": []map[string]string{{
+			"kid": keyID,
+			"kty": "RSA",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+		}},
+	})
+}
+
+func (p *Provider) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		tokenError(w, "invalid_request")
+		return
+	}
+	if r.PostForm.Get("grant_type") != "authorization_code" || r.PostForm.Get("client_id") != ClientID ||
+		r.PostForm.Get("redirect_uri") != RedirectURL {
+		tokenError(w, "invalid_request")
+		return
+	}
+
+	// Код одноразовый
+	code := r.PostForm.Get("code")
+	p.mu.Lock()
+	g, ok := p.grants[code]
+	delete(p.grants, code)
+	p.mu.Unlock()
+	if !ok {
+		tokenError(w, "invalid_grant")
+		return
+	}
+
+	challenge := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(challenge[:]) != g.challenge {
+		tokenError(w, "invalid_grant")
+		return
+	}
+
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, oidc.Claims{
+		Email:             g.identity.Email,
+		EmailVerified:     g.identity.EmailVerified,
+		PreferredUsername: g.identity.PreferredUsername,
+		Nonce:             g.nonce,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    p.Issuer,
+			Subject:   g.identity.Subject,
+			Audience:  jwt.ClaimStrings{ClientID},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(5 * time.Minute)),
+		},
+	})
+	token.Header["kid"] = keyID
+	idToken, err := token.SignedString(p.key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"id_token": idToken, "token_type": "Bearer"})
+}
+
+func tokenError(w http.ResponseWriter, code string) {
+	writeJSON(w, http.StatusBadRequest, map[string]string{"error": code})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,67 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// LockoutPolicy задает прогрессивную блокировку: после Threshold неудач подряд вход блокируется
+// на BaseDuration, и каждая следующая неудача удваивает срок, но не более MaxDuration.
+// Счетчик неудач сбрасывается через Window без новых неудач
+type LockoutPolicy struct {
+	Threshold    int
+	BaseDuration time.Duration
+	MaxDuration  time.Duration
+	Window       time.Duration
+}
+
+type Lockout struct {
+	store  Store
+	policy LockoutPolicy
+}
+
+// NewLockout создает блокировку входа. При Threshold <= 0 блокировка отключена
+func NewLockout(store Store, policy LockoutPolicy) *Lockout {
+	policy.MaxDuration = max(policy.MaxDuration, policy.BaseDuration)
+	return &Lockout{store: store, policy: policy}
+}
+
+func (l *Lockout) enabled() bool {
+	return l != nil && l.policy.Threshold > 0
+}
+
+// Check возвращает оставшееся время блокировки key или 0
+func (l *Lockout) Check(ctx context.Context, key string) (time.Duration, error) {
+	if !l.enabled() {
+		return 0, nil
+	}
+	return l.store.LockedFor(ctx, "lockout:lock:"+key)
+}
+
+// Fail учитывает неудачную попытку и возвращает срок блокировки, если она наступила
+func (l *Lockout) Fail(ctx context.Context, key string) (time.Duration, error) {
+	if !l.enabled() {
+		return 0, nil
+	}
+
+	failures, err := l.store.Incr(ctx, "lockout:fail:"+key, l.policy.Window)
+	if err != nil || failures < int64(l.policy.Threshold) {
+		return 0, err
+	}
+
+	duration := l.policy.BaseDuration
+	for i := int64(l.policy.Threshold); i < failures && duration < l.policy.MaxDuration; i++ {
+		duration *= 2
+	}
+	duration = min(duration, l.policy.MaxDuration)
+
+	return duration, l.store.Lock(ctx, "lockout:lock:"+key, duration)
+}
+
+// Reset снимает блокировку и обнуляет счетчик после успешного входа
+func (l *Lockout) Reset(ctx context.Context, key string) error {
+	if !l.enabled() {
+		return nil
+	}
+	return l.store.Reset(ctx, "lockout:fail:"+key, "lockout:lock:"+key)
+}
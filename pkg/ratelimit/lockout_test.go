@@ -0,0 +1,91 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLockoutEscalates(t *testing.T) {
+	store, clock := newTestStore()
+	ctx := context.Background()
+	lockout := NewLockout(store, LockoutPolicy{
+		Threshold:    3,
+		BaseDuration: time.Minute,
+		MaxDuration:  5 * time.Minute,
+		Window:       time.Hour,
+	})
+
+	// Блокировка наступает на третьей неудаче, каждая следующая удваивает срок до MaxDuration
+	want := []time.Duration{0, 0, time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute, 5 * time.Minute}
+	for i, duration := range want {
+		locked, err := lockout.Fail(ctx, "alice")
+		if err != nil || locked != duration {
+			t.Fatalf("failure %d locked for %v, %v; want %v", i+1, locked, err, duration)
+		}
+
+		retryAfter, err := lockout.Check(ctx, "alice")
+		if err != nil || retryAfter != duration {
+			t.Fatalf("after failure %d Check = %v, %v; want %v", i+1, retryAfter, err, duration)
+		}
+	}
+
+	if retryAfter, _ := lockout.Check(ctx, "bob"); retryAfter != 0 {
+		t.Fatalf("other key locked for %v", retryAfter)
+	}
+
+	clock.advance(5 * time.Minute)
+	if retryAfter, _ := lockout.Check(ctx, "alice"); retryAfter != 0 {
+		t.Fatalf("lock still active after it expired: %v", retryAfter)
+	}
+}
+
+func TestLockoutReset(t *testing.T) {
+	store, _ := newTestStore()
+	ctx := context.Background()
+	lockout := NewLockout(store, LockoutPolicy{Threshold: 2, BaseDuration: time.Minute, Window: time.Hour})
+
+	lockout.Fail(ctx, "alice")
+	if locked, _ := lockout.Fail(ctx, "alice"); locked != time.Minute {
+		t.Fatalf("second failure locked for %v, want 1m", locked)
+	}
+
+	if err := lockout.Reset(ctx, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if retryAfter, _ := lockout.Check(ctx, "alice"); retryAfter != 0 {
+		t.Fatalf("lock still active after Reset: %v", retryAfter)
+	}
+	// Счетчик неудач начинается заново
+	if locked, _ := lockout.Fail(ctx, "alice"); locked != 0 {
+		t.Fatalf("first failure after Reset locked for %v", locked)
+	}
+}
+
+func TestLockoutWindowForgetsFailures(t *testing.T) {
+	store, clock := newTestStore()
+	ctx := context.Background()
+	lockout := NewLockout(store, LockoutPolicy{Threshold: 2, BaseDuration: time.Minute, Window: 10 * time.Minute})
+
+	lockout.Fail(ctx, "alice")
+	clock.advance(11 * time.Minute)
+	if locked, _ := lockout.Fail(ctx, "alice"); locked != 0 {
+		t.Fatalf("failure after the window locked for %v", locked)
+	}
+}
+
+func TestLockoutDisabled(t *testing.T) {
+	store, _ := newTestStore()
+	ctx := context.Background()
+
+	for _, lockout := range []*Lockout{nil, NewLockout(store, LockoutPolicy{BaseDuration: time.Minute})} {
+		for range 10 {
+			if locked, err := lockout.Fail(ctx, "alice"); err != nil || locked != 0 {
+				t.Fatalf("disabled lockout locked for %v, %v", locked, err)
+			}
+		}
+		if retryAfter, _ := lockout.Check(ctx, "alice"); retryAfter != 0 {
+			t.Fatalf("disabled lockout Check = %v", retryAfter)
+		}
+	}
+}
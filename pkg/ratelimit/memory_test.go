@@ -0,0 +1,130 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// fakeClock подменяет время MemoryStore
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newTestStore() (*MemoryStore, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	store := NewMemoryStore()
+	store.now = func() time.Time { return clock.now }
+	return store, clock
+}
+
+func TestPer(t *testing.T) {
+	tests := []struct {
+		name string
+		n    int
+		d    time.Duration
+		want Limit
+	}{
+		{"per minute", 60, time.Minute, Limit{Rate: 1, Burst: 60}},
+		{"per hour", 5, time.Hour, Limit{Rate: 5.0 / 3600, Burst: 5}},
+		{"no requests", 0, time.Minute, Limit{}},
+		{"no period", 10, 0, Limit{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Per(tt.n, tt.d)
+			if got != tt.want {
+				t.Errorf("Per(%d, %v) = %+v, want %+v", tt.n, tt.d, got, tt.want)
+			}
+			if got.Enabled() != (tt.want != Limit{}) {
+				t.Errorf("Enabled() = %v", got.Enabled())
+			}
+		})
+	}
+}
+
+func TestMemoryStoreTakeBurst(t *testing.T) {
+	store, _ := newTestStore()
+	ctx := context.Background()
+	limit := Per(3, 3*time.Second)
+
+	for i := range 3 {
+		result, err := store.Take(ctx, "ip:1", limit)
+		if err != nil || !result.Allowed || result.Remaining != 2-i {
+			t.Fatalf("request %d = %+v, %v; want allowed with %d remaining", i+1, result, err, 2-i)
+		}
+	}
+
+	result, err := store.Take(ctx, "ip:1", limit)
+	if err != nil || result.Allowed || result.RetryAfter != time.Second {
+		t.Fatalf("request over burst = %+v, %v; want rejected with retry after 1s", result, err)
+	}
+
+	// У другого ключа своя корзина
+	if result, _ := store.Take(ctx, "ip:2", limit); !result.Allowed {
+		t.Fatal("separate key was rejected")
+	}
+}
+
+func TestMemoryStoreTakeRefill(t *testing.T) {
+	store, clock := newTestStore()
+	ctx := context.Background()
+	limit := Per(2, 2*time.Second)
+
+	take := func() Result {
+		t.Helper()
+		result, err := store.Take(ctx, "ip:1", limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	take()
+	take()
+	if take().Allowed {
+		t.Fatal("empty bucket allowed a request")
+	}
+
+	clock.advance(500 * time.Millisecond)
+	if result := take(); result.Allowed || result.RetryAfter != 500*time.Millisecond {
+		t.Fatalf("half a token = %+v; want rejected with retry after 500ms", result)
+	}
+
+	clock.advance(500 * time.Millisecond)
+	if !take().Allowed {
+		t.Fatal("refilled token was not available")
+	}
+
+	// Корзина наполняется не больше чем до Burst
+	clock.advance(time.Hour)
+	for i := range 2 {
+		if !take().Allowed {
+			t.Fatalf("request %d after refill was rejected", i+1)
+		}
+	}
+	if take().Allowed {
+		t.Fatal("bucket refilled above burst")
+	}
+}
+
+func TestMemoryStoreIncrExpires(t *testing.T) {
+	store, clock := newTestStore()
+	ctx := context.Background()
+
+	for want := int64(1); want <= 2; want++ {
+		if got, _ := store.Incr(ctx, "fail", time.Minute); got != want {
+			t.Fatalf("Incr = %d, want %d", got, want)
+		}
+	}
+
+	clock.advance(time.Minute + time.Second)
+	if got, _ := store.Incr(ctx, "fail", time.Minute); got != 1 {
+		t.Fatalf("Incr after ttl = %d, want 1", got)
+	}
+}
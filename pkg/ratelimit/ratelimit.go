@@ -0,0 +1,49 @@
+// Package ratelimit ограничивает частоту запросов алгоритмом token bucket и блокирует вход
+// после повторных неудачных попыток. Состояние хранится в Store: в памяти процесса для одного
+// экземпляра сервиса или во внешнем хранилище вроде Redis, если экземпляров несколько
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// Limit - параметры корзины: Rate токенов в секунду, не больше Burst одновременно
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Per задает лимит n запросов за период d с возможностью выполнить их подряд
+func Per(n int, d time.Duration) Limit {
+	if n <= 0 || d <= 0 {
+		return Limit{}
+	}
+	return Limit{Rate: float64(n) / d.Seconds(), Burst: n}
+}
+
+// Enabled сообщает, задан ли лимит; нулевой Limit не ограничивает запросы
+func (l Limit) Enabled() bool {
+	return l.Rate > 0 && l.Burst > 0
+}
+
+type Result struct {
+	Allowed    bool
+	Remaining  int
+	RetryAfter time.Duration // через сколько появится следующий токен, если запрос отклонен
+}
+
+// Store хранит состояние ограничителей. Операции соответствуют примитивам Redis: Take - скрипту
+// token bucket, Incr - INCR с PEXPIRE, Lock - SET PX, LockedFor - PTTL, Reset - DEL
+type Store interface {
+	// Take забирает токен из корзины key
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+	// Incr увеличивает счетчик key; счетчик удаляется через ttl после последнего увеличения
+	Incr(ctx context.Context, key string, ttl time.Duration) (int64, error)
+	// Lock устанавливает блокировку key на время ttl
+	Lock(ctx context.Context, key string, ttl time.Duration) error
+	// LockedFor возвращает оставшееся время блокировки key или 0
+	LockedFor(ctx context.Context, key string) (time.Duration, error)
+	// Reset удаляет счетчики и блокировки с указанными ключами
+	Reset(ctx context.Context, keys ...string) error
+}
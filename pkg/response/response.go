@@ -1,7 +1,10 @@
 package response
 
 import (
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -28,6 +31,12 @@ func Error(ctx *gin.Context, statusCode int, errorMessage string) {
 	ctx.Abort()
 }
 
+// TooManyRequests отвечает 429 и сообщает клиенту через Retry-After, когда можно повторить запрос
+func TooManyRequests(ctx *gin.Context, retryAfter time.Duration, errorMessage string) {
+	ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	Error(ctx, http.StatusTooManyRequests, errorMessage)
+}
+
 func CORSMiddleware() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		ctx.Writer.Header().Set("Access-Control-Allow-Origin", "*")
@@ -53,28 +62,33 @@ func CORSMiddleware() gin.HandlerFunc {
 //	ctx.Abort()
 //}
 
-//type PaginatedResponse struct {
-//	Data       interface{} `json:"data"`
-//	Total      int64       `json:"total"`
-//	Page       int         `json:"page"`
-//	PerPage    int         `json:"per_page"`
-//	TotalPages int         `json:"total_pages"`
-//}
-//
-//func PaginatedSuccess(ctx *gin.Context, data interface{}, total int64, page int, perPage int) {
-//	totalPages := total / int64(perPage)
-//	if total%int64(perPage) > 0 {
-//		totalPages++
-//	}
-//
-//	ctx.JSON(http.StatusOK, Response{
-//		Success: true,
-//		Data: PaginatedResponse{
-//			Data:       data,
-//			Total:      total,
-//			Page:       page,
-//			PerPage:    perPage,
-//			TotalPages: int(totalPages),
-//		},
-//	})
-//}
+// PaginatedResponse - страница списка. Page заполняется при выборке по номеру страницы,
+// NextCursor - если за страницей есть еще записи
+type PaginatedResponse struct {
+	Data       interface{} `json:"data"`
+	Total      int64       `json:"total"`
+	Page       int         `json:"page,omitempty"`
+	PerPage    int         `json:"per_page"`
+	TotalPages int         `json:"total_pages"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+}
+
+func NewPaginatedResponse(data interface{}, total int64, page int, perPage int, nextCursor string) PaginatedResponse {
+	totalPages := total / int64(perPage)
+	if total%int64(perPage) > 0 {
+		totalPages++
+	}
+
+	return PaginatedResponse{
+		Data:       data,
+		Total:      total,
+		Page:       page,
+		PerPage:    perPage,
+		TotalPages: int(totalPages),
+		NextCursor: nextCursor,
+	}
+}
+
+func PaginatedSuccess(ctx *gin.Context, data interface{}, total int64, page int, perPage int, nextCursor string) {
+	Success(ctx, http.StatusOK, NewPaginatedResponse(data, total, page, perPage, nextCursor))
+}
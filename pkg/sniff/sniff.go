@@ -0,0 +1,170 @@
+package sniff
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// HeaderSize - сколько байт из начала файла достаточно для определения формата
+const HeaderSize = 512
+
+type Kind int
+
+const (
+	KindAudio Kind = iota + 1
+	KindImage
+)
+
+// Type - формат файла, определенный по сигнатуре
+type Type struct {
+	Name       string
+	MIME       string
+	Kind       Kind
+	Extensions []string // первое значение используется как каноническое расширение
+}
+
+var (
+	MP3  = Type{Name: "mp3", MIME: "audio/mpeg", Kind: KindAudio, Extensions: []string{".mp3", ".mpga"}}
+	FLAC = Type{Name: "flac", MIME: "audio/flac", Kind: KindAudio, Extensions: []string{".flac"}}
+	Ogg  = Type{Name: "ogg", MIME: "audio/ogg", Kind: KindAudio, Extensions: []string{".ogg", ".oga"}}
+	Opus = Type{Name: "opus", MIME: "audio/ogg; codecs=opus", Kind: KindAudio, Extensions: []string{".opus", ".ogg", ".oga"}}
+	WAV  = Type{Name: "wav", MIME: "audio/wav", Kind: KindAudio, Extensions: []string{".wav", ".wave"}}
+	AAC  = Type{Name: "aac", MIME: "audio/aac", Kind: KindAudio, Extensions: []string{".aac", ".adts"}}
+	M4A  = Type{Name: "m4a", MIME: "audio/mp4", Kind: KindAudio, Extensions: []string{".m4a", ".m4b", ".mp4", ".aac"}}
+
+	JPEG = Type{Name: "jpeg", MIME: "image/jpeg", Kind: KindImage, Extensions: []string{".jpg", ".jpeg", ".jfif"}}
+	PNG  = Type{Name: "png", MIME: "image/png", Kind: KindImage, Extensions: []string{".png"}}
+	WebP = Type{Name: "webp", MIME: "image/webp", Kind: KindImage, Extensions: []string{".webp"}}
+	GIF  = Type{Name: "gif", MIME: "image/gif", Kind: KindImage, Extensions: []string{".gif"}}
+)
+
+var ErrUnknownFormat = errors.New("unknown file format")
+
+// Extension возвращает каноническое расширение формата
+func (t Type) Extension() string {
+	return t.Extensions[0]
+}
+
+// MatchesFilename проверяет, что расширение имени файла соответствует формату.
+// Имена без расширения считаются подходящими.
+func (t Type) MatchesFilename(filename string) bool {
+	ext := strings.ToLower(filepath.Ext(filename))
+	return ext == "" || slices.Contains(t.Extensions, ext)
+}
+
+// Detect определяет формат по первым байтам файла
+func Detect(header []byte) (Type, error) {
+	switch {
+	case bytes.HasPrefix(header, []byte("ID3")):
+		return MP3, nil
+	case bytes.HasPrefix(header, []byte("fLaC")):
+		return FLAC, nil
+	case bytes.HasPrefix(header, []byte("OggS")):
+		return detectOgg(header)
+	case len(header) >= 12 && string(header[:4]) == "RIFF" && string(header[8:12]) == "WAVE":
+		return WAV, nil
+	case len(header) >= 12 && string(header[:4]) == "RIFF" && string(header[8:12]) == "WEBP":
+		return WebP, nil
+	case len(header) >= 12 && string(header[4:8]) == "ftyp":
+		return detectFtyp(header)
+	case bytes.HasPrefix(header, []byte{0xFF, 0xD8, 0xFF}):
+		return JPEG, nil
+	case bytes.HasPrefix(header, []byte("\x89PNG\r\n\x1a\n")):
+		return PNG, nil
+	case bytes.HasPrefix(header, []byte("GIF87a")), bytes.HasPrefix(header, []byte("GIF89a")):
+		return GIF, nil
+	case len(header) >= 2 && header[0] == 0xFF && header[1]&0xE0 == 0xE0:
+		return detectMPEGSync(header)
+	}
+
+	return Type{}, ErrUnknownFormat
+}
+
+// DetectReader читает начало файла, определяет формат и возвращает позицию чтения в начало
+func DetectReader(r io.ReadSeeker) (Type, error) {
+	header := make([]byte, HeaderSize)
+	n, err := io.ReadFull(r, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return Type{}, err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return Type{}, err
+	}
+
+	return Detect(header[:n])
+}
+
+// detectOgg различает Vorbis и Opus по заголовку первого пакета
+func detectOgg(header []byte) (Type, error) {
+	if len(header) < 27 {
+		return Type{}, ErrUnknownFormat
+	}
+
+	packet := header[min(27+int(header[26]), len(header)):]
+	switch {
+	case bytes.HasPrefix(packet, []byte("\x01vorbis")):
+		return Ogg, nil
+	case bytes.HasPrefix(packet, []byte("OpusHead")):
+		return Opus, nil
+	}
+	return Type{}, ErrUnknownFormat
+}
+
+// detectFtyp проверяет основной и совместимые бренды ISO BMFF на принадлежность к аудио
+func detectFtyp(header []byte) (Type, error) {
+	audioBrands := []string{"M4A ", "M4B ", "M4P ", "mp41", "mp42", "isom", "iso2", "dash"}
+
+	size := int(uint32(header[0])<<24 | uint32(header[1])<<16 | uint32(header[2])<<8 | uint32(header[3]))
+	size = min(size, len(header))
+
+	for i := 8; i+4 <= size; i += 4 {
+		if i == 12 {
+			continue // minor_version
+		}
+		if slices.Contains(audioBrands, string(header[i:i+4])) {
+			return M4A, nil
+		}
+	}
+	return Type{}, ErrUnknownFormat
+}
+
+// detectMPEGSync различает MPEG audio (layer I-III) и AAC в контейнере ADTS
+func detectMPEGSync(header []byte) (Type, error) {
+	if header[1]&0x06 == 0 {
+		// В ADTS поле layer всегда 00
+		if header[1]&0xF0 == 0xF0 {
+			return AAC, nil
+		}
+		return Type{}, ErrUnknownFormat
+	}
+	if header[1]&0x18 == 0x08 {
+		return Type{}, ErrUnknownFormat // зарезервированная версия MPEG
+	}
+	return MP3, nil
+}
+
+var types = []Type{MP3, FLAC, Ogg, Opus, WAV, AAC, M4A, JPEG, PNG, WebP, GIF}
+
+// ByMIME возвращает формат по MIME-типу, сохраненному при загрузке
+func ByMIME(mimeType string) (Type, bool) {
+	for _, t := range types {
+		if t.MIME == mimeType {
+			return t, true
+		}
+	}
+	return Type{}, false
+}
+
+// ByName возвращает формат по короткому имени (mp3, flac и т.д.)
+func ByName(name string) (Type, bool) {
+	for _, t := range types {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return Type{}, false
+}
@@ -0,0 +1,14 @@
+// Package tokenhash хэширует непрозрачные токены (refresh токены, API ключи, коды восстановления)
+// для хранения и поиска в базе
+package tokenhash
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// Sum - токены имеют высокую энтропию, поэтому для хранения достаточно SHA-256 без соли
+func Sum(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
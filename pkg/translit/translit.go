@@ -0,0 +1,105 @@
+// Package translit переводит текст между кириллицей и латиницей по упрощенной практической
+// транслитерации, чтобы поисковый запрос, набранный не в той раскладке письма, находил совпадения
+package translit
+
+import (
+	"strings"
+	"unicode"
+)
+
+var toLatin = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e", 'ж': "zh",
+	'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o",
+	'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts",
+	'ч': "ch", 'ш': "sh", 'щ': "shch", 'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu",
+	'я': "ya",
+}
+
+// toCyrillic - сочетания латинских букв, проверяемые от длинных к коротким
+var toCyrillic = []struct{ latin, cyrillic string }{
+	{"shch", "щ"},
+	{"sch", "щ"},
+	{"zh", "ж"}, {"kh", "х"}, {"ts", "ц"}, {"ch", "ч"}, {"sh", "ш"},
+	{"yu", "ю"}, {"ya", "я"}, {"yo", "ё"}, {"ye", "е"}, {"ph", "ф"},
+	{"a", "а"}, {"b", "б"}, {"c", "к"}, {"d", "д"}, {"e", "е"}, {"f", "ф"}, {"g", "г"},
+	{"h", "х"}, {"i", "и"}, {"j", "дж"}, {"k", "к"}, {"l", "л"}, {"m", "м"}, {"n", "н"},
+	{"o", "о"}, {"p", "п"}, {"q", "к"}, {"r", "р"}, {"s", "с"}, {"t", "т"}, {"u", "у"},
+	{"v", "в"}, {"w", "в"}, {"x", "кс"}, {"z", "з"},
+}
+
+// ToLatin транслитерирует кириллицу в латиницу в нижнем регистре; прочие символы сохраняются
+func ToLatin(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if latin, ok := toLatin[r]; ok {
+			b.WriteString(latin)
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// ToCyrillic транслитерирует латиницу в кириллицу в нижнем регистре. "y" после гласной
+// читается как "й", иначе как "ы"
+func ToCyrillic(s string) string {
+	s = strings.ToLower(s)
+	var b strings.Builder
+	var prev rune
+	for i := 0; i < len(s); {
+		if s[i] == 'y' && !strings.HasPrefix(s[i:], "yu") && !strings.HasPrefix(s[i:], "ya") &&
+			!strings.HasPrefix(s[i:], "yo") && !strings.HasPrefix(s[i:], "ye") {
+			if strings.ContainsRune("aeiou", prev) {
+				b.WriteString("й")
+			} else {
+				b.WriteString("ы")
+			}
+			prev = 'y'
+			i++
+			continue
+		}
+
+		matched := false
+		for _, pair := range toCyrillic {
+			if strings.HasPrefix(s[i:], pair.latin) {
+				b.WriteString(pair.cyrillic)
+				prev = rune(pair.latin[len(pair.latin)-1])
+				i += len(pair.latin)
+				matched = true
+				break
+			}
+		}
+		if matched {
+			continue
+		}
+
+		r := []rune(s[i:])[0]
+		b.WriteRune(r)
+		prev = r
+		i += len(string(r))
+	}
+	return b.String()
+}
+
+// Alternate возвращает написание s другим алфавитом: кириллицу - латиницей, латиницу - кириллицей.
+// Для текста без букв этих алфавитов или со смешанным письмом возвращает пустую строку
+func Alternate(s string) string {
+	var cyrillic, latin bool
+	for _, r := range s {
+		switch {
+		case unicode.Is(unicode.Cyrillic, r):
+			cyrillic = true
+		case r <= unicode.MaxASCII && unicode.IsLetter(r):
+			latin = true
+		}
+	}
+
+	switch {
+	case cyrillic && !latin:
+		return ToLatin(s)
+	case latin && !cyrillic:
+		return ToCyrillic(s)
+	default:
+		return ""
+	}
+}